@@ -6,14 +6,33 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"goarchive/core"
 
 	// Import plugins to trigger auto-registration via init()
+	_ "goarchive/database/badger"
+	_ "goarchive/database/bbolt"
+	_ "goarchive/database/etcd"
+	_ "goarchive/database/exec"
+	_ "goarchive/database/files"
+	_ "goarchive/database/mongodb"
+	_ "goarchive/database/mysql"
 	_ "goarchive/database/postgres"
+	_ "goarchive/database/redis"
+	_ "goarchive/database/sqlite"
+	_ "goarchive/storage/azureblob"
 	_ "goarchive/storage/disk"
+	_ "goarchive/storage/ftp"
+	_ "goarchive/storage/gcs"
+	_ "goarchive/storage/mirror"
+	_ "goarchive/storage/repository"
 	_ "goarchive/storage/s3"
+	_ "goarchive/storage/sftp"
+	_ "goarchive/storage/webdav"
 )
 
 const version = "1.0.0"
@@ -22,20 +41,45 @@ func main() {
 	// Define subcommands
 	backupCmd := flag.NewFlagSet("backup", flag.ExitOnError)
 	listCmd := flag.NewFlagSet("list", flag.ExitOnError)
+	restoreCmd := flag.NewFlagSet("restore", flag.ExitOnError)
+	walPushCmd := flag.NewFlagSet("wal-push", flag.ExitOnError)
+	walFetchCmd := flag.NewFlagSet("wal-fetch", flag.ExitOnError)
+	tierCmd := flag.NewFlagSet("tier", flag.ExitOnError)
 
-	// Database flags (shared)
-	var dbHost, dbUser, dbPass, dbName, dbType, dbSSLMode string
-	var dbPort int
-
-	// Storage flags (shared)
-	var storageType, storageBucket, storageRegion, storageAccessKey, storageSecretKey, storagePrefix, storagePath string
+	// Configuration shared by all subcommands, populated from flags
+	config := &core.Config{}
 
 	// Define flags for backup command
-	setupDatabaseFlags(backupCmd, &dbHost, &dbUser, &dbPass, &dbName, &dbType, &dbSSLMode, &dbPort)
-	setupStorageFlags(backupCmd, &storageType, &storageBucket, &storageRegion, &storageAccessKey, &storageSecretKey, &storagePrefix, &storagePath)
+	var backupTimeout time.Duration
+	setupDatabaseFlags(backupCmd, &config.Database)
+	setupStorageFlags(backupCmd, &config.Storage)
+	backupCmd.DurationVar(&backupTimeout, "backup-timeout", getEnvAsDuration("DB_BACKUP_TIMEOUT", 0), "Cancel backups running longer than this (e.g. 12h; default 30m, no limit for physical backups)")
 
 	// Define flags for list command
-	setupStorageFlags(listCmd, &storageType, &storageBucket, &storageRegion, &storageAccessKey, &storageSecretKey, &storagePrefix, &storagePath)
+	setupStorageFlags(listCmd, &config.Storage)
+
+	// Define flags for restore command
+	var backupID string
+	restoreOpts := &core.RestoreOptions{}
+	setupDatabaseFlags(restoreCmd, &config.Database)
+	setupStorageFlags(restoreCmd, &config.Storage)
+	setupRestoreFlags(restoreCmd, &backupID, restoreOpts)
+
+	// Define flags for point-in-time recovery
+	var pitrTarget, restoreCommand string
+	restoreCmd.StringVar(&pitrTarget, "pitr", "", "Recover a physical backup to this RFC3339 timestamp (requires --data-dir)")
+	restoreCmd.StringVar(&restoreCommand, "restore-command", "", "restore_command for --pitr (default: goarchive wal-fetch with the storage flags)")
+
+	// Define flags for WAL archiving commands
+	setupStorageFlags(walPushCmd, &config.Storage)
+	setupStorageFlags(walFetchCmd, &config.Storage)
+
+	// Define flags for tier command
+	var tierPolicy string
+	var tierDryRun bool
+	setupStorageFlags(tierCmd, &config.Storage)
+	tierCmd.StringVar(&tierPolicy, "tier-policy", getEnv("TIER_POLICY", ""), "Tiers for backups by age, as comma-separated age=tier rules (e.g. 30d=GLACIER,180d=DEEP_ARCHIVE)")
+	tierCmd.BoolVar(&tierDryRun, "dry-run", false, "Show the backups that would move without moving them")
 
 	// Check for subcommand
 	if len(os.Args) < 2 {
@@ -46,12 +90,31 @@ func main() {
 	switch os.Args[1] {
 	case "backup":
 		backupCmd.Parse(os.Args[2:])
-		executeBackup(dbHost, dbUser, dbPass, dbName, dbType, dbSSLMode, dbPort,
-			storageType, storageBucket, storageRegion, storageAccessKey, storageSecretKey, storagePrefix, storagePath)
+		executeBackup(config, backupTimeout)
+
+	case "restore":
+		restoreCmd.Parse(os.Args[2:])
+		if pitrTarget != "" {
+			executePITRRestore(&config.Storage, pitrTarget, restoreOpts.DataDirectory, restoreCommand)
+			break
+		}
+		executeRestore(config, backupID, restoreOpts)
+
+	case "wal-push":
+		walPushCmd.Parse(os.Args[2:])
+		executeWALPush(&config.Storage, walPushCmd.Args())
+
+	case "wal-fetch":
+		walFetchCmd.Parse(os.Args[2:])
+		executeWALFetch(&config.Storage, walFetchCmd.Args())
 
 	case "list":
 		listCmd.Parse(os.Args[2:])
-		executeList(storageType, storageBucket, storageRegion, storageAccessKey, storageSecretKey, storagePrefix, storagePath)
+		executeList(&config.Storage)
+
+	case "tier":
+		tierCmd.Parse(os.Args[2:])
+		executeTier(&config.Storage, tierPolicy, tierDryRun)
 
 	case "providers":
 		printProviders()
@@ -76,7 +139,11 @@ func printUsage() {
 	fmt.Println("  goarchive <command> [flags]")
 	fmt.Println("\nCommands:")
 	fmt.Println("  backup      Create a database backup")
+	fmt.Println("  restore     Restore a database from a backup")
 	fmt.Println("  list        List available backups")
+	fmt.Println("  tier        Move older backups to colder storage tiers")
+	fmt.Println("  wal-push    Archive a PostgreSQL WAL file (for archive_command)")
+	fmt.Println("  wal-fetch   Retrieve an archived WAL file (for restore_command)")
 	fmt.Println("  providers   Show available database and storage providers")
 	fmt.Println("  version     Show version information")
 	fmt.Println("  help        Show this help message")
@@ -92,72 +159,136 @@ func printUsage() {
 	fmt.Println("\n  # Backup using environment variables")
 	fmt.Println("  export DB_HOST=localhost DB_NAME=mydb STORAGE_BUCKET=my-backups")
 	fmt.Println("  goarchive backup")
+	fmt.Println("\n  # Restore a backup into a new staging database")
+	fmt.Println("  goarchive restore --backup-id mydb_postgres_20260215-103020.dump --target-db mydb_staging --create-db --jobs 4")
+	fmt.Println("\n  # Restore one table next to the live data")
+	fmt.Println("  goarchive restore --backup-id mydb_postgres_20260215-103020.dump --table public.orders --target-schema recovered")
+	fmt.Println("\n  # Refresh staging with masked personal data")
+	fmt.Println("  goarchive restore --backup-id mydb_postgres_20260215-103020.dump --target-db mydb_staging --drop-db --masking-profile masking.json --masking-report report.json")
+	fmt.Println("\n  # Archive WAL from postgresql.conf")
+	fmt.Printf("  archive_command = 'goarchive wal-push --storage-path /var/backups %%p'\n")
+	fmt.Println("\n  # Recover a physical backup to a point in time")
+	fmt.Println("  goarchive restore --storage-path /var/backups --pitr 2026-02-15T10:30:00Z --data-dir /var/lib/postgresql/data")
 	fmt.Println("\n  # List backups")
 	fmt.Println("  goarchive list --storage-bucket my-backups --storage-region us-east-1")
+	fmt.Println("\n  # Move backups to Glacier after 30 days and Deep Archive after 180")
+	fmt.Println("  goarchive tier --storage-type s3 --storage-bucket my-backups --tier-policy 30d=GLACIER,180d=DEEP_ARCHIVE")
 	fmt.Println("\nFlags inherit from environment variables if not specified.")
 	fmt.Println("Run 'goarchive <command> -h' for command-specific flags.")
 }
 
-func setupDatabaseFlags(fs *flag.FlagSet, host, user, pass, name, dbType, sslMode *string, port *int) {
+func setupDatabaseFlags(fs *flag.FlagSet, db *core.DatabaseConfig) {
 	availableDBs := core.ListDatabases()
 	dbTypeHelp := fmt.Sprintf("Database type (available: %v)", availableDBs)
 
-	fs.StringVar(host, "db-host", getEnv("DB_HOST", "localhost"), "Database host")
-	fs.IntVar(port, "db-port", getEnvAsInt("DB_PORT", 5432), "Database port")
-	fs.StringVar(user, "db-user", getEnv("DB_USERNAME", "postgres"), "Database username")
-	fs.StringVar(pass, "db-password", getEnv("DB_PASSWORD", ""), "Database password")
-	fs.StringVar(name, "db-name", getEnv("DB_DATABASE", "postgres"), "Database name")
-	fs.StringVar(dbType, "db-type", getEnv("DB_TYPE", "postgres"), dbTypeHelp)
-	fs.StringVar(sslMode, "db-sslmode", getEnv("DB_SSLMODE", "disable"), "SSL mode (disable, require, verify-full)")
+	fs.StringVar(&db.Host, "db-host", getEnv("DB_HOST", "localhost"), "Database host")
+	fs.IntVar(&db.Port, "db-port", getEnvAsInt("DB_PORT", 0), "Database port (default: the database type's usual port, e.g. 5432 for postgres or 3306 for mysql)")
+	fs.StringVar(&db.Username, "db-user", getEnv("DB_USERNAME", "postgres"), "Database username")
+	fs.StringVar(&db.Password, "db-password", getEnv("DB_PASSWORD", ""), "Database password")
+	fs.StringVar(&db.Database, "db-name", getEnv("DB_DATABASE", "postgres"), "Database name")
+	fs.StringVar(&db.Type, "db-type", getEnv("DB_TYPE", "postgres"), dbTypeHelp)
+	fs.StringVar(&db.SSLMode, "db-sslmode", getEnv("DB_SSLMODE", "disable"), "SSL mode (disable, require, verify-full)")
+	fs.StringVar(&db.BackupMode, "db-backup-mode", getEnv("DB_BACKUP_MODE", ""), "Backup mode (postgres: logical, physical, native)")
+	fs.StringVar(&db.URI, "db-uri", getEnv("DB_URI", ""), "Connection URI or DSN (replaces host, port, user, name and SSL mode)")
+	fs.StringVar(&db.SSLRootCert, "db-sslrootcert", getEnv("DB_SSLROOTCERT", ""), "CA certificate file for verifying the server")
+	fs.StringVar(&db.SSLCert, "db-sslcert", getEnv("DB_SSLCERT", ""), "Client certificate file")
+	fs.StringVar(&db.SSLKey, "db-sslkey", getEnv("DB_SSLKEY", ""), "Client private key file")
+	fs.StringVar(&db.PassFile, "db-passfile", getEnv("DB_PASSFILE", ""), "Password file (e.g. .pgpass)")
+	fs.StringVar(&db.Service, "db-service", getEnv("DB_SERVICE", ""), "Connection service name (e.g. from pg_service.conf)")
+	fs.IntVar(&db.ConnectTimeout, "db-connect-timeout", getEnvAsInt("DB_CONNECT_TIMEOUT", 0), "Connection timeout in seconds")
+	fs.StringVar(&db.ApplicationName, "db-application-name", getEnv("DB_APPLICATION_NAME", ""), "Application name reported to the server (default goarchive)")
+	fs.StringVar(&db.Path, "db-path", getEnv("DB_PATH", ""), "Database file (for sqlite and bbolt) or directory (for badger), or snapshot file to restore to (for etcd)")
+	db.Collections = getEnvAsList("DB_COLLECTIONS")
+	db.ExcludeCollections = getEnvAsList("DB_EXCLUDE_COLLECTIONS")
+	fs.Var((*stringSlice)(&db.Collections), "collection", "Back up only this collection (for mongodb, repeatable)")
+	fs.Var((*stringSlice)(&db.ExcludeCollections), "exclude-collection", "Leave this collection out of the backup (for mongodb, repeatable)")
+	fs.StringVar(&db.BackupCommand, "exec-backup-command", getEnv("DB_BACKUP_COMMAND", ""), "Shell command writing a backup to stdout (for exec)")
+	fs.StringVar(&db.RestoreCommand, "exec-restore-command", getEnv("DB_RESTORE_COMMAND", ""), "Shell command reading a backup from stdin (for exec)")
+	fs.StringVar(&db.MetadataCommand, "exec-metadata-command", getEnv("DB_METADATA_COMMAND", ""), "Shell command printing database metadata as JSON (for exec)")
+	db.SourcePaths = getEnvAsList("DB_SOURCE_PATHS")
+	db.IncludePatterns = getEnvAsList("DB_INCLUDE_PATTERNS")
+	db.ExcludePatterns = getEnvAsList("DB_EXCLUDE_PATTERNS")
+	fs.Var((*stringSlice)(&db.SourcePaths), "source-path", "File or directory to archive (for files, repeatable)")
+	fs.Var((*stringSlice)(&db.IncludePatterns), "include", "Archive only files matching this glob (for files, repeatable)")
+	fs.Var((*stringSlice)(&db.ExcludePatterns), "exclude", "Leave out files and directories matching this glob (for files, repeatable)")
+	fs.StringVar(&db.SymlinkPolicy, "symlinks", getEnv("DB_SYMLINK_POLICY", ""), "Symlink policy (for files: preserve, follow, skip; default preserve)")
+	fs.DurationVar(&db.MaxReplicationLag, "max-replication-lag", getEnvAsDuration("DB_MAX_REPLICATION_LAG", 0), "Refuse to back up a standby lagging further behind (e.g. 5m, 0 to disable)")
+	fs.DurationVar(&db.LockWaitTimeout, "lock-wait-timeout", getEnvAsDuration("DB_LOCK_WAIT_TIMEOUT", 0), "Fail if table locks are not granted within this time (e.g. 30s, 0 to wait)")
+	fs.BoolVar(&db.SerializableDeferrable, "serializable-deferrable", getEnvAsBool("DB_SERIALIZABLE_DEFERRABLE", false), "Dump from a serializable deferrable snapshot")
+	fs.StringVar(&db.MaskingProfile, "masking-profile", getEnv("DB_MASKING_PROFILE", ""), "Masking profile (JSON) applied to native backups and restores")
+	fs.StringVar(&db.MaskingReport, "masking-report", getEnv("DB_MASKING_REPORT", ""), "Write a JSON report of the masked columns to this file")
 }
 
-func setupStorageFlags(fs *flag.FlagSet, storageType, bucket, region, accessKey, secretKey, prefix, path *string) {
+func setupStorageFlags(fs *flag.FlagSet, storage *core.StorageConfig) {
 	availableStorages := core.ListStorages()
 	storageTypeHelp := fmt.Sprintf("Storage type (available: %v)", availableStorages)
 
-	fs.StringVar(storageType, "storage-type", getEnv("STORAGE_TYPE", "disk"), storageTypeHelp)
-	fs.StringVar(path, "storage-path", getEnv("STORAGE_PATH", "./backups"), "Storage path for disk storage")
-	fs.StringVar(bucket, "storage-bucket", getEnv("STORAGE_BUCKET", ""), "Storage bucket name (for S3)")
-	fs.StringVar(region, "storage-region", getEnv("STORAGE_REGION", "us-east-1"), "Storage region (for S3)")
-	fs.StringVar(accessKey, "storage-access-key", getEnv("STORAGE_ACCESS_KEY", ""), "Storage access key (for S3, optional with IAM)")
-	fs.StringVar(secretKey, "storage-secret-key", getEnv("STORAGE_SECRET_KEY", ""), "Storage secret key (for S3, optional with IAM)")
-	fs.StringVar(prefix, "storage-prefix", getEnv("STORAGE_PREFIX", "backups/"), "Storage prefix path (for S3)")
+	fs.StringVar(&storage.Type, "storage-type", getEnv("STORAGE_TYPE", "disk"), storageTypeHelp)
+	fs.StringVar(&storage.Path, "storage-path", getEnv("STORAGE_PATH", "./backups"), "Storage path for disk storage, or remote directory (for SFTP and FTP)")
+	fs.StringVar(&storage.Bucket, "storage-bucket", getEnv("STORAGE_BUCKET", ""), "Storage bucket name (for S3 and GCS) or container name (for Azure)")
+	fs.StringVar(&storage.Region, "storage-region", getEnv("STORAGE_REGION", "us-east-1"), "Storage region (for S3)")
+	fs.StringVar(&storage.AccessKey, "storage-access-key", getEnv("STORAGE_ACCESS_KEY", ""), "Storage access key (for S3, optional with IAM)")
+	fs.StringVar(&storage.SecretKey, "storage-secret-key", getEnv("STORAGE_SECRET_KEY", ""), "Storage secret key (for S3, optional with IAM) or account key (for Azure)")
+	fs.StringVar(&storage.CredentialsFile, "storage-credentials-file", getEnv("STORAGE_CREDENTIALS_FILE", ""), "Service account key file (for GCS, optional with Application Default Credentials)")
+	fs.StringVar(&storage.Account, "storage-account", getEnv("STORAGE_ACCOUNT", ""), "Storage account name (for Azure)")
+	fs.StringVar(&storage.SASToken, "storage-sas-token", getEnv("STORAGE_SAS_TOKEN", ""), "Shared access signature (for Azure)")
+	fs.StringVar(&storage.ConnectionString, "storage-connection-string", getEnv("STORAGE_CONNECTION_STRING", ""), "Storage account connection string (for Azure)")
+	fs.StringVar(&storage.AccessTier, "storage-access-tier", getEnv("STORAGE_ACCESS_TIER", ""), "Access tier for new backups: Hot, Cool, Cold or Archive (for Azure)")
+	fs.StringVar(&storage.ArchivePath, "storage-archive-path", getEnv("STORAGE_ARCHIVE_PATH", ""), "Directory of the archive tier, such as a slower volume (for disk)")
+	fs.StringVar(&storage.RetrievalTier, "storage-retrieval-tier", getEnv("STORAGE_RETRIEVAL_TIER", ""), "Speed of retrievals from Glacier: Expedited, Standard or Bulk (for S3, default Standard)")
+	fs.DurationVar(&storage.RetrievalWait, "storage-retrieval-wait", getEnvAsDuration("STORAGE_RETRIEVAL_WAIT", 0), "How long downloads of archived backups wait for their retrieval (for S3, e.g. 6h, 0 to fail after requesting it)")
+	fs.StringVar(&storage.Username, "storage-username", getEnv("STORAGE_USERNAME", ""), "Storage username (for SFTP, FTP and WebDAV)")
+	fs.StringVar(&storage.Password, "storage-password", getEnv("STORAGE_PASSWORD", ""), "Storage password (for FTP and WebDAV), or password or private key passphrase (for SFTP)")
+	fs.StringVar(&storage.PrivateKeyFile, "storage-private-key", getEnv("STORAGE_PRIVATE_KEY_FILE", ""), "Private key file (for SFTP)")
+	fs.StringVar(&storage.KnownHostsFile, "storage-known-hosts", getEnv("STORAGE_KNOWN_HOSTS_FILE", ""), "known_hosts file verifying the server (for SFTP, default ~/.ssh/known_hosts)")
+	fs.StringVar(&storage.CACertFile, "storage-ca-cert", getEnv("STORAGE_CA_CERT_FILE", ""), "CA certificate file verifying the server (for FTPS)")
+	fs.StringVar(&storage.Token, "storage-token", getEnv("STORAGE_TOKEN", ""), "Bearer token (for WebDAV)")
+	fs.StringVar(&storage.Endpoint, "storage-endpoint", getEnv("STORAGE_ENDPOINT", ""), "Storage server as host:port (for SFTP), ftp://, ftpes:// or ftps:// URL (for FTP), collection URL (for WebDAV), or custom endpoint (for S3-compatible storage, GCS emulators and Azurite)")
+	fs.StringVar(&storage.Prefix, "storage-prefix", getEnv("STORAGE_PREFIX", "backups/"), "Storage prefix path (for S3, GCS, Azure and WebDAV)")
+	storage.Backends = getEnvAsList("STORAGE_BACKENDS")
+	fs.Var((*stringSlice)(&storage.Backends), "storage-backend", "Storage type to write to, with optional ?setting=value overrides (for mirror and repository; repeat for mirror)")
+	fs.StringVar(&storage.MirrorPolicy, "storage-mirror-policy", getEnv("STORAGE_MIRROR_POLICY", "all"), "Backends that must store each backup: all, quorum or a number (for mirror)")
 }
 
-func executeBackup(dbHost, dbUser, dbPass, dbName, dbType, dbSSLMode string, dbPort int,
-	storageType, storageBucket, storageRegion, storageAccessKey, storageSecretKey, storagePrefix, storagePath string) {
+func setupRestoreFlags(fs *flag.FlagSet, backupID *string, opts *core.RestoreOptions) {
+	fs.StringVar(backupID, "backup-id", "", "ID of the backup to restore (required, see 'goarchive list')")
+	fs.StringVar(&opts.TargetDatabase, "target-db", "", "Restore into this database instead of --db-name")
+	fs.BoolVar(&opts.CreateDatabase, "create-db", false, "Create the target database if it does not exist")
+	fs.BoolVar(&opts.DropDatabase, "drop-db", false, "Drop and recreate the target database before restoring")
+	fs.BoolVar(&opts.SingleTransaction, "single-transaction", false, "Restore as a single transaction")
+	fs.IntVar(&opts.Jobs, "jobs", 1, "Number of parallel restore jobs")
+	fs.StringVar(&opts.DataDirectory, "data-dir", "", "Target data directory for physical backups, or target directory for files backups")
+	fs.Var((*stringSlice)(&opts.Tables), "table", "Restore only this table, as schema.table or table (repeatable)")
+	fs.Var((*stringSlice)(&opts.Schemas), "schema", "Restore only this schema (repeatable)")
+	fs.StringVar(&opts.TargetSchema, "target-schema", "", "Restore the selected tables into this new schema instead of in place")
+}
 
-	log.Println("Starting goarchive backup...")
+// stringSlice is a flag that may be given more than once
+type stringSlice []string
 
-	// Build configuration
-	config := &core.Config{
-		Database: core.DatabaseConfig{
-			Type:     dbType,
-			Host:     dbHost,
-			Port:     dbPort,
-			Username: dbUser,
-			Password: dbPass,
-			Database: dbName,
-			SSLMode:  dbSSLMode,
-		},
-		Storage: core.StorageConfig{
-			Type:      storageType,
-			Bucket:    storageBucket,
-			Region:    storageRegion,
-			AccessKey: storageAccessKey,
-			SecretKey: storageSecretKey,
-			Prefix:    storagePrefix,
-			Path:      storagePath,
-		},
-	}
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func executeBackup(config *core.Config, timeout time.Duration) {
+	log.Println("Starting goarchive backup...")
 
 	if err := config.Validate(); err != nil {
 		log.Fatalf("Invalid configuration: %v", err)
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
-	defer cancel()
+	// Create context with timeout, unless the backup may take as long as it needs
+	ctx := context.Background()
+	if limit := resolveBackupTimeout(&config.Database, timeout); limit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limit)
+		defer cancel()
+	}
 
 	// Initialize database provider using registry
 	dbProvider, err := core.GetDatabase(config.Database.Type, &config.Database)
@@ -189,24 +320,76 @@ func executeBackup(dbHost, dbUser, dbPass, dbName, dbType, dbSSLMode string, dbP
 	fmt.Printf("Timestamp:       %s\n", metadata.Timestamp.Format(time.RFC3339))
 	fmt.Printf("Size:            %d bytes (%.2f MB)\n", metadata.Size, float64(metadata.Size)/(1024*1024))
 	fmt.Printf("Checksum (MD5):  %s\n", metadata.Checksum)
+	if metadata.BackupMode != "" {
+		fmt.Printf("Backup Mode:     %s\n", metadata.BackupMode)
+	}
 	fmt.Println("====================================")
 
 	log.Println("Backup completed successfully")
 }
 
-func executeList(storageType, storageBucket, storageRegion, storageAccessKey, storageSecretKey, storagePrefix, storagePath string) {
-	log.Println("Listing backups...")
+// resolveBackupTimeout returns the configured backup timeout or, when none
+// is set, 30 minutes. Physical backups copy whole clusters, so by default
+// they are not limited.
+func resolveBackupTimeout(db *core.DatabaseConfig, timeout time.Duration) time.Duration {
+	switch {
+	case timeout > 0:
+		return timeout
+	case db.BackupMode == "physical":
+		return 0
+	default:
+		return 30 * time.Minute
+	}
+}
+
+func executeRestore(config *core.Config, backupID string, opts *core.RestoreOptions) {
+	if backupID == "" {
+		log.Fatal("--backup-id is required (run 'goarchive list' to see available backups)")
+	}
+
+	log.Printf("Starting goarchive restore of %s...", backupID)
+
+	if err := config.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Create context with timeout, allowing for the retrieval of archived backups
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour+config.Storage.RetrievalWait)
+	defer cancel()
+
+	// Initialize database provider using registry
+	dbProvider, err := core.GetDatabase(config.Database.Type, &config.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize database provider: %v", err)
+	}
+	defer dbProvider.Close()
 
-	config := &core.StorageConfig{
-		Type:      storageType,
-		Bucket:    storageBucket,
-		Region:    storageRegion,
-		AccessKey: storageAccessKey,
-		SecretKey: storageSecretKey,
-		Prefix:    storagePrefix,
-		Path:      storagePath,
+	// Initialize storage provider using registry
+	storageProvider, err := core.GetStorage(ctx, config.Storage.Type, &config.Storage)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage provider: %v", err)
 	}
 
+	backupService := core.NewBackupService(dbProvider, storageProvider)
+
+	switch {
+	case opts.DataDirectory != "":
+		log.Printf("Restoring into data directory %s...", opts.DataDirectory)
+	case opts.TargetDatabase != "":
+		log.Printf("Restoring into database %s...", opts.TargetDatabase)
+	default:
+		log.Printf("Restoring into database %s...", config.Database.Database)
+	}
+	if err := backupService.RestoreWithOptions(ctx, backupID, opts); err != nil {
+		log.Fatalf("Restore failed: %v", err)
+	}
+
+	log.Println("Restore completed successfully")
+}
+
+func executeList(config *core.StorageConfig) {
+	log.Println("Listing backups...")
+
 	ctx := context.Background()
 
 	// Initialize storage provider
@@ -234,10 +417,29 @@ func executeList(storageType, storageBucket, storageRegion, storageAccessKey, st
 		if backup.Checksum != "" {
 			fmt.Printf("   Checksum:  %s\n", backup.Checksum)
 		}
+		if backup.BackupMode != "" {
+			fmt.Printf("   Mode:      %s\n", backup.BackupMode)
+		}
+		if backup.Tier != "" {
+			fmt.Printf("   Tier:      %s\n", backup.Tier)
+		}
+		if len(backup.Tags) > 0 {
+			fmt.Printf("   Tags:      %s\n", formatTags(backup.Tags))
+		}
 		fmt.Println()
 	}
 }
 
+// formatTags renders tags as sorted key=value pairs
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -260,6 +462,33 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return value
 }
 
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsList splits a comma-separated variable, ignoring empty items
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func printProviders() {
 	fmt.Println("Available Providers")
 	fmt.Println("==================")
@@ -289,7 +518,30 @@ func printProviders() {
 	fmt.Println("  goarchive backup --db-host localhost [--storage-path /path/to/backups]")
 	fmt.Println("\n  # S3 storage")
 	fmt.Println("  goarchive backup --storage-type s3 --storage-bucket my-backups --db-host localhost")
+	fmt.Println("\n  # Google Cloud Storage")
+	fmt.Println("  goarchive backup --storage-type gcs --storage-bucket my-backups --storage-credentials-file sa.json --db-host localhost")
+	fmt.Println("\n  # Azure Blob Storage")
+	fmt.Println("  goarchive backup --storage-type azureblob --storage-bucket backups --storage-account myaccount --storage-sas-token \"$SAS\" --db-host localhost")
+	fmt.Println("\n  # SFTP server")
+	fmt.Println("  goarchive backup --storage-type sftp --storage-endpoint backups.example.com:22 --storage-username backup --storage-private-key ~/.ssh/id_ed25519 --storage-path /srv/backups --db-host localhost")
+	fmt.Println("\n  # FTPS server (explicit TLS)")
+	fmt.Println("  goarchive backup --storage-type ftp --storage-endpoint ftpes://ftp.partner.example.com --storage-username acme --storage-password \"$FTP_PASSWORD\" --storage-path /incoming --db-host localhost")
+	fmt.Println("\n  # Mirror to local disk and S3")
+	fmt.Println("  goarchive backup --storage-type mirror --storage-backend disk --storage-backend s3 --storage-path /var/backups --storage-bucket my-backups --db-host localhost")
+
+	fmt.Println("\n  # Deduplicating repository on S3")
+	fmt.Println("  goarchive backup --storage-type repository --storage-backend s3 --storage-bucket my-backups --storage-prefix repository/ --db-host localhost")
+	fmt.Println("\n  # WebDAV server (e.g., Nextcloud)")
+	fmt.Println("  goarchive backup --storage-type webdav --storage-endpoint https://cloud.example.com/remote.php/dav/files/backup/ --storage-username backup --storage-password \"$APP_PASSWORD\" --storage-prefix databases/ --db-host localhost")
 	fmt.Println("\nExamples:")
 	fmt.Println("  goarchive backup --db-type postgres --storage-type disk --db-host localhost")
 	fmt.Println("  goarchive backup --db-type postgres --storage-type s3 --storage-bucket my-backups")
+	fmt.Println("  goarchive backup --db-type mysql --db-user root --db-name myapp")
+	fmt.Println("  goarchive backup --db-type sqlite --db-path /var/lib/app/app.db")
+	fmt.Println("  goarchive backup --db-type mongodb --db-uri mongodb://backup@localhost:27017/myapp --exclude-collection sessions")
+	fmt.Println("  goarchive backup --db-type redis --db-user default --db-name cache")
+	fmt.Println("  goarchive backup --db-type exec --db-name orders --exec-backup-command 'kvctl dump --host \"$GOARCHIVE_DB_HOST\"'")
+	fmt.Println("  goarchive backup --db-type files --source-path /srv/uploads --exclude '*.tmp'")
+	fmt.Println("  goarchive backup --db-type etcd --db-sslmode verify-full --db-sslcert client.crt --db-sslkey client.key")
+	fmt.Println("  goarchive backup --db-type bbolt --db-path /var/lib/app/state.db")
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"goarchive/core"
+)
+
+func executeTier(config *core.StorageConfig, spec string, dryRun bool) {
+	policy, err := core.ParseTierPolicy(spec)
+	if err != nil {
+		log.Fatalf("Invalid --tier-policy: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+	defer cancel()
+
+	storageProvider, err := core.GetStorage(ctx, config.Type, config)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage provider: %v", err)
+	}
+
+	moves, err := core.ApplyTierPolicy(ctx, storageProvider, policy, time.Now(), dryRun)
+	for _, move := range moves {
+		from := move.Backup.Tier
+		if from == "" {
+			from = "current tier"
+		}
+		if dryRun {
+			fmt.Printf("Would move %s from %s to %s\n", move.Backup.ID, from, move.Tier)
+		} else {
+			fmt.Printf("Moved %s from %s to %s\n", move.Backup.ID, from, move.Tier)
+		}
+	}
+	if err != nil {
+		log.Fatalf("Tiering failed: %v", err)
+	}
+
+	if len(moves) == 0 {
+		fmt.Println("No backups to move.")
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goarchive/core"
+	"goarchive/database/postgres"
+)
+
+func executeWALPush(config *core.StorageConfig, args []string) {
+	if len(args) != 1 {
+		log.Fatal("usage: goarchive wal-push [flags] <wal-path>")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	storageProvider, err := core.GetStorage(ctx, config.Type, config)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage provider: %v", err)
+	}
+
+	if err := postgres.NewWALArchive(storageProvider).Push(ctx, args[0]); err != nil {
+		log.Fatalf("WAL push failed: %v", err)
+	}
+}
+
+func executeWALFetch(config *core.StorageConfig, args []string) {
+	if len(args) != 2 {
+		log.Fatal("usage: goarchive wal-fetch [flags] <wal-name> <destination>")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	storageProvider, err := core.GetStorage(ctx, config.Type, config)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage provider: %v", err)
+	}
+
+	if err := postgres.NewWALArchive(storageProvider).Fetch(ctx, args[0], args[1]); err != nil {
+		log.Fatalf("WAL fetch failed: %v", err)
+	}
+}
+
+func executePITRRestore(config *core.StorageConfig, target, dataDir, restoreCommand string) {
+	if dataDir == "" {
+		log.Fatal("--data-dir is required with --pitr")
+	}
+
+	targetTime, err := time.Parse(time.RFC3339, target)
+	if err != nil {
+		log.Fatalf("Invalid --pitr timestamp (expected RFC3339): %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour+config.RetrievalWait)
+	defer cancel()
+
+	storageProvider, err := core.GetStorage(ctx, config.Type, config)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage provider: %v", err)
+	}
+
+	backups, err := storageProvider.List(ctx)
+	if err != nil {
+		log.Fatalf("Failed to list backups: %v", err)
+	}
+
+	base, err := postgres.SelectBaseBackup(backups, targetTime)
+	if err != nil {
+		log.Fatalf("Point-in-time recovery failed: %v", err)
+	}
+	log.Printf("Using base backup %s from %s", base.ID, base.Timestamp.Format(time.RFC3339))
+
+	reader, err := storageProvider.Download(ctx, base.ID)
+	if err != nil {
+		log.Fatalf("Failed to download base backup: %v", err)
+	}
+	defer reader.Close()
+
+	log.Printf("Extracting base backup into %s...", dataDir)
+	if err := postgres.ExtractBaseBackup(reader, dataDir); err != nil {
+		log.Fatalf("Point-in-time recovery failed: %v", err)
+	}
+
+	if restoreCommand == "" {
+		restoreCommand = defaultRestoreCommand(config)
+	}
+	if err := postgres.WriteRecoveryConfig(dataDir, restoreCommand, targetTime); err != nil {
+		log.Fatalf("Point-in-time recovery failed: %v", err)
+	}
+
+	log.Printf("Data directory prepared; start PostgreSQL to replay WAL up to %s", targetTime.Format(time.RFC3339))
+}
+
+// defaultRestoreCommand builds a restore_command that fetches WAL with this
+// binary and the same storage settings. Credentials are left out and must be
+// provided to the server through the environment.
+func defaultRestoreCommand(config *core.StorageConfig) string {
+	executable, err := os.Executable()
+	if err != nil {
+		executable = os.Args[0]
+	}
+
+	args := []string{shellQuote(executable), "wal-fetch"}
+	flag := func(name, value string) {
+		if value != "" {
+			args = append(args, "--"+name, shellQuote(value))
+		}
+	}
+
+	flag("storage-type", config.Type)
+	flag("storage-path", absPath(config.Path))
+	flag("storage-bucket", config.Bucket)
+	flag("storage-region", config.Region)
+	flag("storage-endpoint", config.Endpoint)
+	flag("storage-prefix", config.Prefix)
+	flag("storage-account", config.Account)
+	flag("storage-access-tier", config.AccessTier)
+	flag("storage-archive-path", absPath(config.ArchivePath))
+	flag("storage-retrieval-tier", config.RetrievalTier)
+	if config.RetrievalWait != 0 {
+		flag("storage-retrieval-wait", config.RetrievalWait.String())
+	}
+	flag("storage-username", config.Username)
+	flag("storage-private-key", absPath(config.PrivateKeyFile))
+	flag("storage-known-hosts", absPath(config.KnownHostsFile))
+	flag("storage-ca-cert", absPath(config.CACertFile))
+	// The GCS key itself, rather than the path to it, is a credential
+	if !strings.HasPrefix(strings.TrimSpace(config.CredentialsFile), "{") {
+		flag("storage-credentials-file", absPath(config.CredentialsFile))
+	}
+	for _, backend := range config.Backends {
+		flag("storage-backend", publicBackend(backend))
+	}
+	flag("storage-mirror-policy", config.MirrorPolicy)
+
+	return strings.Join(append(args, "%f", "%p"), " ")
+}
+
+// secretSettings are the storage settings that hold credentials
+var secretSettings = map[string]bool{
+	"AccessKey":        true,
+	"SecretKey":        true,
+	"SASToken":         true,
+	"ConnectionString": true,
+	"Password":         true,
+	"Token":            true,
+	"CredentialsFile":  true,
+}
+
+// publicBackend returns a --storage-backend spec without its credential
+// overrides, which the server must get some other way
+func publicBackend(spec string) string {
+	storageType, query, ok := strings.Cut(spec, "?")
+	if !ok {
+		return spec
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return storageType
+	}
+
+	for key := range values {
+		if secretSettings[core.StorageSettingName(key)] {
+			log.Printf("Warning: leaving %s of storage backend %s out of restore_command; provide it to the server separately", key, storageType)
+			values.Del(key)
+		}
+	}
+	if len(values) == 0 {
+		return storageType
+	}
+	return storageType + "?" + values.Encode()
+}
+
+// absPath returns path made absolute, so the server finds it whatever its
+// working directory
+func absPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// shellQuote quotes a value for use as a single shell word
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// ParseBackend returns the settings of a storage backend of the mirror and
+// repository providers, described by spec as a storage type optionally
+// followed by "?setting=value&..." overrides (e.g.
+// "s3?bucket=offsite&region=eu-west-1"). Settings not overridden are those
+// of parent; its backends and mirror policy are not inherited.
+func ParseBackend(spec string, parent *StorageConfig) (*StorageConfig, error) {
+	storageType, query, _ := strings.Cut(strings.TrimSpace(spec), "?")
+	if storageType == "" {
+		return nil, fmt.Errorf("storage backend %q has no type", spec)
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settings in storage backend %q: %w", spec, err)
+	}
+
+	config := *parent
+	config.Type = storageType
+	config.Backends = nil
+	config.MirrorPolicy = ""
+
+	for key, value := range values {
+		name := StorageSettingName(key)
+		if name == "" {
+			return nil, fmt.Errorf("unknown setting %q in storage backend %q", key, spec)
+		}
+		reflect.ValueOf(&config).Elem().FieldByName(name).SetString(value[len(value)-1])
+	}
+
+	return &config, nil
+}
+
+// StorageSettingName returns the name of the StorageConfig string field a
+// backend setting overrides, ignoring case, dashes and underscores, so
+// "access_key", "access-key" and "accessKey" all name AccessKey. It returns
+// an empty string for unknown settings and for Type, which cannot be
+// overridden.
+func StorageSettingName(key string) string {
+	name := strings.NewReplacer("-", "", "_", "").Replace(key)
+	if strings.EqualFold(name, "type") {
+		return ""
+	}
+
+	field, ok := reflect.TypeOf(StorageConfig{}).FieldByNameFunc(func(field string) bool {
+		return strings.EqualFold(field, name)
+	})
+	if !ok || field.Type.Kind() != reflect.String {
+		return ""
+	}
+	return field.Name
+}
@@ -0,0 +1,67 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"goarchive/core"
+)
+
+func TestParseBackend(t *testing.T) {
+	parent := &core.StorageConfig{
+		Type:         "mirror",
+		Bucket:       "primary",
+		Region:       "us-east-1",
+		Backends:     []string{"s3", "disk"},
+		MirrorPolicy: "quorum",
+	}
+
+	tests := []struct {
+		name    string
+		spec    string
+		want    core.StorageConfig
+		wantErr string
+	}{
+		{
+			name: "inherits settings",
+			spec: "s3",
+			want: core.StorageConfig{Type: "s3", Bucket: "primary", Region: "us-east-1"},
+		},
+		{
+			name: "overrides settings",
+			spec: "s3?bucket=offsite&Region=eu-west-1&access_key=AKIA&secret-key=s",
+			want: core.StorageConfig{Type: "s3", Bucket: "offsite", Region: "eu-west-1", AccessKey: "AKIA", SecretKey: "s"},
+		},
+		{name: "no type", spec: "?bucket=offsite", wantErr: "has no type"},
+		{name: "unknown setting", spec: "s3?colour=red", wantErr: "unknown setting"},
+		{name: "type override", spec: "s3?type=disk", wantErr: "unknown setting"},
+		{name: "list setting", spec: "s3?backends=disk", wantErr: "unknown setting"},
+		{name: "invalid query", spec: "s3?bucket=%zz", wantErr: "invalid settings"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := core.ParseBackend(tt.spec, parent)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseBackend() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBackend() error = %v", err)
+			}
+			if config.Type != tt.want.Type || config.Bucket != tt.want.Bucket || config.Region != tt.want.Region ||
+				config.AccessKey != tt.want.AccessKey || config.SecretKey != tt.want.SecretKey {
+				t.Errorf("ParseBackend() = %+v, want %+v", config, tt.want)
+			}
+			if config.Backends != nil || config.MirrorPolicy != "" {
+				t.Errorf("expected the backends and policy not to be inherited, got %+v", config)
+			}
+		})
+	}
+
+	if parent.Bucket != "primary" {
+		t.Errorf("expected the parent to be left alone, got bucket %q", parent.Bucket)
+	}
+}
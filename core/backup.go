@@ -2,6 +2,8 @@ package core
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"time"
 )
@@ -21,6 +23,25 @@ type DatabaseProvider interface {
 	Close() error
 }
 
+// OptionsRestorer is implemented by database providers that support restore options
+type OptionsRestorer interface {
+	// RestoreWithOptions restores a database from backup data using the given options
+	RestoreWithOptions(ctx context.Context, reader io.Reader, opts *RestoreOptions) error
+}
+
+// TaggedReader is implemented by backup readers that learn details about the
+// backup while producing it, such as the format version of the data
+type TaggedReader interface {
+	io.Reader
+
+	// Tags returns details to record in the backup metadata
+	Tags() map[string]string
+}
+
+// ErrBackupNotFound is wrapped by the errors storage providers return for
+// backups that do not exist
+var ErrBackupNotFound = errors.New("backup not found")
+
 // StorageProvider defines the interface for storage operations
 type StorageProvider interface {
 	// Upload uploads the backup data to storage
@@ -29,19 +50,23 @@ type StorageProvider interface {
 	// List lists available backups
 	List(ctx context.Context) ([]*BackupMetadata, error)
 
-	// Download downloads a backup from storage
+	// Download downloads a backup from storage. Missing backups fail with
+	// an error wrapping ErrBackupNotFound.
 	Download(ctx context.Context, backupID string) (io.ReadCloser, error)
 
-	// Delete deletes a backup from storage
+	// Delete deletes a backup from storage. Providers that can tell a
+	// missing backup apart fail with an error wrapping ErrBackupNotFound.
 	Delete(ctx context.Context, backupID string) error
 }
 
 // DatabaseMetadata contains information about the database
 type DatabaseMetadata struct {
-	Type    string
-	Version string
-	Size    int64
-	Name    string
+	Type       string
+	Version    string
+	Size       int64
+	Name       string
+	BackupMode string            // Provider-specific backup mode, empty for the provider default
+	Tags       map[string]string // Provider-specific details recorded with each backup
 }
 
 // BackupMetadata contains information about a backup
@@ -52,7 +77,54 @@ type BackupMetadata struct {
 	Timestamp    time.Time
 	Size         int64
 	Checksum     string
-	Tags         map[string]string
+	BackupMode   string            // Backup mode reported by the database provider
+	Key          string            // Storage key relative to the provider root, derived from the other fields when empty
+	Tags         map[string]string // Provider-specific details, persisted by the storage provider
+	Tier         string            // Storage tier holding the backup, reported by providers with tiers
+}
+
+// Clone returns a copy of the metadata that can be changed separately
+func (m *BackupMetadata) Clone() *BackupMetadata {
+	c := *m
+	if m.Tags != nil {
+		c.Tags = make(map[string]string, len(m.Tags))
+		for k, v := range m.Tags {
+			c.Tags[k] = v
+		}
+	}
+	return &c
+}
+
+// RestoreOptions controls where and how a backup is restored
+type RestoreOptions struct {
+	TargetDatabase    string   // Restore into this database instead of the configured one
+	CreateDatabase    bool     // Create the target database if it does not exist
+	DropDatabase      bool     // Drop and recreate the target database before restoring
+	SingleTransaction bool     // Restore everything in a single transaction
+	Jobs              int      // Number of parallel restore jobs (0 or 1 restores serially)
+	DataDirectory     string   // Target data directory for physical restores
+	Tables            []string // Restore only these tables, as "schema.table" or "table"
+	Schemas           []string // Restore only the objects in these schemas
+	TargetSchema      string   // Restore the selected objects into this new schema instead
+	BackupMode        string   // Backup mode of the backup being restored, set by BackupService
+}
+
+// Selective reports whether opts restores only selected tables or schemas
+func (o *RestoreOptions) Selective() bool {
+	return len(o.Tables) > 0 || len(o.Schemas) > 0
+}
+
+// isDefault reports whether opts leaves every option at its default
+func (o *RestoreOptions) isDefault() bool {
+	return o.TargetDatabase == "" &&
+		!o.CreateDatabase &&
+		!o.DropDatabase &&
+		!o.SingleTransaction &&
+		o.Jobs <= 1 &&
+		o.DataDirectory == "" &&
+		!o.Selective() &&
+		o.TargetSchema == "" &&
+		o.BackupMode == ""
 }
 
 // BackupService orchestrates the backup process
@@ -89,9 +161,18 @@ func (s *BackupService) Execute(ctx context.Context) (*BackupMetadata, error) {
 		ID:           generateBackupID(),
 		DatabaseName: dbMeta.Name,
 		DatabaseType: dbMeta.Type,
+		BackupMode:   dbMeta.BackupMode,
 		Timestamp:    time.Now(),
 		Tags:         make(map[string]string),
 	}
+	for key, value := range dbMeta.Tags {
+		metadata.Tags[key] = value
+	}
+	if tagged, ok := reader.(TaggedReader); ok {
+		for key, value := range tagged.Tags() {
+			metadata.Tags[key] = value
+		}
+	}
 
 	// Upload to storage
 	if err := s.storage.Upload(ctx, reader, metadata); err != nil {
@@ -103,6 +184,30 @@ func (s *BackupService) Execute(ctx context.Context) (*BackupMetadata, error) {
 
 // Restore performs the restore operation
 func (s *BackupService) Restore(ctx context.Context, backupID string) error {
+	return s.RestoreWithOptions(ctx, backupID, nil)
+}
+
+// RestoreWithOptions performs the restore operation using the given restore options.
+// A nil opts restores with the provider's defaults. The backup mode recorded in the
+// backup's metadata is passed on to the provider so it can pick the matching restore.
+func (s *BackupService) RestoreWithOptions(ctx context.Context, backupID string, opts *RestoreOptions) error {
+	backupMode, err := s.lookupBackupMode(ctx, backupID)
+	if err != nil {
+		return err
+	}
+
+	effective := RestoreOptions{}
+	if opts != nil {
+		effective = *opts
+	}
+	effective.BackupMode = backupMode
+
+	// Check the provider supports options before downloading anything
+	restorer, supportsOptions := s.database.(OptionsRestorer)
+	if !supportsOptions && !effective.isDefault() {
+		return fmt.Errorf("database provider does not support restore options")
+	}
+
 	// Download from storage
 	reader, err := s.storage.Download(ctx, backupID)
 	if err != nil {
@@ -111,6 +216,9 @@ func (s *BackupService) Restore(ctx context.Context, backupID string) error {
 	defer reader.Close()
 
 	// Restore to database
+	if supportsOptions && (opts != nil || backupMode != "") {
+		return restorer.RestoreWithOptions(ctx, reader, &effective)
+	}
 	if err := s.database.Restore(ctx, reader); err != nil {
 		return err
 	}
@@ -118,6 +226,23 @@ func (s *BackupService) Restore(ctx context.Context, backupID string) error {
 	return nil
 }
 
+// lookupBackupMode returns the backup mode recorded for backupID, or an empty
+// string when the backup is not listed or has no recorded mode
+func (s *BackupService) lookupBackupMode(ctx context.Context, backupID string) (string, error) {
+	backups, err := s.storage.List(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up backup %s: %w", backupID, err)
+	}
+
+	for _, backup := range backups {
+		if backup.ID == backupID {
+			return backup.BackupMode, nil
+		}
+	}
+
+	return "", nil
+}
+
 // List lists all available backups
 func (s *BackupService) List(ctx context.Context) ([]*BackupMetadata, error) {
 	return s.storage.List(ctx)
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -172,6 +174,35 @@ func TestBackupService_Execute(t *testing.T) {
 		}
 	})
 
+	t.Run("records backup mode", func(t *testing.T) {
+		db := &mockModeDatabaseProvider{mode: "physical"}
+		service := core.NewBackupService(db, &mockStorageProvider{})
+
+		metadata, err := service.Execute(ctx)
+		if err != nil {
+			t.Fatalf("Execute() error = %v, want nil", err)
+		}
+
+		if metadata.BackupMode != "physical" {
+			t.Errorf("Expected BackupMode 'physical', got '%s'", metadata.BackupMode)
+		}
+	})
+
+	t.Run("records tags", func(t *testing.T) {
+		db := &mockTaggedDatabaseProvider{}
+		service := core.NewBackupService(db, &mockStorageProvider{})
+
+		metadata, err := service.Execute(ctx)
+		if err != nil {
+			t.Fatalf("Execute() error = %v, want nil", err)
+		}
+
+		want := map[string]string{"server": "1.0.3", "format": "11"}
+		if !reflect.DeepEqual(metadata.Tags, want) {
+			t.Errorf("Expected Tags %v, got %v", want, metadata.Tags)
+		}
+	})
+
 	t.Run("GetMetadata error", func(t *testing.T) {
 		db := &mockDatabaseProviderWithError{metadataErr: fmt.Errorf("metadata error")}
 		storage := &mockStorageProvider{}
@@ -243,6 +274,106 @@ func TestBackupService_Restore(t *testing.T) {
 	})
 }
 
+func TestBackupService_RestoreWithOptions(t *testing.T) {
+	ctx := context.Background()
+	opts := &core.RestoreOptions{TargetDatabase: "staging", CreateDatabase: true, Jobs: 4}
+
+	t.Run("options passed to provider", func(t *testing.T) {
+		db := &mockOptionsRestorer{}
+		service := core.NewBackupService(db, &mockStorageProvider{})
+
+		if err := service.RestoreWithOptions(ctx, "backup-123", opts); err != nil {
+			t.Fatalf("RestoreWithOptions() error = %v, want nil", err)
+		}
+
+		if db.opts == nil || db.opts.TargetDatabase != "staging" || !db.opts.CreateDatabase || db.opts.Jobs != 4 {
+			t.Errorf("expected options to be passed to provider, got %+v", db.opts)
+		}
+	})
+
+	t.Run("nil options use plain restore", func(t *testing.T) {
+		db := &mockOptionsRestorer{}
+		service := core.NewBackupService(db, &mockStorageProvider{})
+
+		if err := service.RestoreWithOptions(ctx, "backup-123", nil); err != nil {
+			t.Fatalf("RestoreWithOptions() error = %v, want nil", err)
+		}
+
+		if !db.plainRestore || db.opts != nil {
+			t.Error("expected plain Restore to be used when options are nil")
+		}
+	})
+
+	t.Run("backup mode from metadata", func(t *testing.T) {
+		db := &mockOptionsRestorer{}
+		storage := &mockModeStorageProvider{mode: "physical"}
+		service := core.NewBackupService(db, storage)
+
+		if err := service.Restore(ctx, "20060102-150405"); err != nil {
+			t.Fatalf("Restore() error = %v, want nil", err)
+		}
+
+		if db.opts == nil || db.opts.BackupMode != "physical" {
+			t.Errorf("expected backup mode 'physical' to be passed to provider, got %+v", db.opts)
+		}
+	})
+
+	t.Run("backup mode without options support", func(t *testing.T) {
+		service := core.NewBackupService(&mockDatabaseProvider{}, &mockModeStorageProvider{mode: "physical"})
+
+		if err := service.Restore(ctx, "20060102-150405"); err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+
+	t.Run("default options without options support", func(t *testing.T) {
+		service := core.NewBackupService(&mockDatabaseProvider{}, &mockStorageProvider{})
+
+		if err := service.RestoreWithOptions(ctx, "backup-123", &core.RestoreOptions{Jobs: 1}); err != nil {
+			t.Errorf("RestoreWithOptions() error = %v, want nil", err)
+		}
+	})
+
+	t.Run("provider without options support", func(t *testing.T) {
+		storage := &mockStorageProviderWithError{downloadErr: fmt.Errorf("download should not be called")}
+		service := core.NewBackupService(&mockDatabaseProvider{}, storage)
+
+		err := service.RestoreWithOptions(ctx, "backup-123", opts)
+		if err == nil || err.Error() != "database provider does not support restore options" {
+			t.Errorf("expected unsupported options error, got %v", err)
+		}
+	})
+
+	t.Run("selective restore without options support", func(t *testing.T) {
+		service := core.NewBackupService(&mockDatabaseProvider{}, &mockStorageProvider{})
+
+		err := service.RestoreWithOptions(ctx, "backup-123", &core.RestoreOptions{Tables: []string{"public.users"}})
+		if err == nil {
+			t.Error("expected error restoring selected tables without options support, got nil")
+		}
+	})
+}
+
+func TestRestoreOptions_Selective(t *testing.T) {
+	tests := []struct {
+		name string
+		opts core.RestoreOptions
+		want bool
+	}{
+		{name: "everything", opts: core.RestoreOptions{TargetDatabase: "staging"}, want: false},
+		{name: "tables", opts: core.RestoreOptions{Tables: []string{"users"}}, want: true},
+		{name: "schemas", opts: core.RestoreOptions{Schemas: []string{"billing"}}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.Selective(); got != tt.want {
+				t.Errorf("Selective() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestBackupService_List(t *testing.T) {
 	ctx := context.Background()
 
@@ -370,3 +501,74 @@ func (m *mockStorageProviderWithError) Download(ctx context.Context, backupID st
 func (m *mockStorageProviderWithError) Delete(ctx context.Context, backupID string) error {
 	return m.deleteErr
 }
+
+type mockOptionsRestorer struct {
+	mockDatabaseProvider
+	plainRestore bool
+	opts         *core.RestoreOptions
+}
+
+func (m *mockOptionsRestorer) Restore(ctx context.Context, reader io.Reader) error {
+	m.plainRestore = true
+	return nil
+}
+
+func (m *mockOptionsRestorer) RestoreWithOptions(ctx context.Context, reader io.Reader, opts *core.RestoreOptions) error {
+	m.opts = opts
+	return nil
+}
+
+type mockModeDatabaseProvider struct {
+	mockDatabaseProvider
+	mode string
+}
+
+func (m *mockModeDatabaseProvider) GetMetadata() (*core.DatabaseMetadata, error) {
+	return &core.DatabaseMetadata{
+		Type:       "mock",
+		Version:    "1.0",
+		Name:       "testdb",
+		BackupMode: m.mode,
+	}, nil
+}
+
+// mockTaggedDatabaseProvider reports tags from its metadata and its backup reader
+type mockTaggedDatabaseProvider struct {
+	mockDatabaseProvider
+}
+
+func (m *mockTaggedDatabaseProvider) GetMetadata() (*core.DatabaseMetadata, error) {
+	return &core.DatabaseMetadata{
+		Type:    "mock",
+		Version: "1.0",
+		Name:    "testdb",
+		Tags:    map[string]string{"server": "1.0.3", "format": "unknown"},
+	}, nil
+}
+
+func (m *mockTaggedDatabaseProvider) Backup(ctx context.Context) (io.ReadCloser, error) {
+	return &taggedReader{ReadCloser: io.NopCloser(strings.NewReader("data"))}, nil
+}
+
+type taggedReader struct {
+	io.ReadCloser
+}
+
+func (r *taggedReader) Tags() map[string]string {
+	return map[string]string{"format": "11"}
+}
+
+type mockModeStorageProvider struct {
+	mockStorageProvider
+	mode string
+}
+
+func (m *mockModeStorageProvider) List(ctx context.Context) ([]*core.BackupMetadata, error) {
+	return []*core.BackupMetadata{
+		{
+			ID:         "20060102-150405",
+			Timestamp:  time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC),
+			BackupMode: m.mode,
+		},
+	}, nil
+}
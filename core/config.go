@@ -3,6 +3,9 @@ package core
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds the application configuration
@@ -13,25 +16,70 @@ type Config struct {
 
 // DatabaseConfig contains database connection settings
 type DatabaseConfig struct {
-	Type     string
-	Host     string
-	Port     int
-	Username string
-	Password string
-	Database string
-	SSLMode  string
+	Type            string
+	Host            string
+	Port            int // 0 for the database type's usual port
+	Username        string
+	Password        string
+	Database        string
+	SSLMode         string
+	BackupMode      string // Provider-specific backup mode (e.g. "logical" or "physical" for PostgreSQL)
+	URI             string // Connection URI or DSN, used instead of Host, Port, Username, Database and SSLMode
+	SSLRootCert     string // CA certificate file for verifying the server
+	SSLCert         string // Client certificate file
+	SSLKey          string // Client private key file
+	PassFile        string // Password file (e.g. PostgreSQL's .pgpass)
+	Service         string // Connection service name (e.g. from PostgreSQL's pg_service.conf)
+	ConnectTimeout  int    // Connection timeout in seconds, 0 for the driver default
+	ApplicationName string // Application name reported to the server
+	Path            string // Database file for file-based databases such as SQLite
+
+	Collections        []string // Back up only these collections (MongoDB), all when empty
+	ExcludeCollections []string // Collections left out of backups (MongoDB)
+
+	BackupCommand   string // Shell command writing a backup to its standard output (exec provider)
+	RestoreCommand  string // Shell command reading a backup from its standard input (exec provider)
+	MetadataCommand string // Shell command printing database metadata as JSON (exec provider)
+
+	SourcePaths     []string // Files and directories archived by the files provider
+	IncludePatterns []string // Glob patterns selecting the files to archive (files provider), all when empty
+	ExcludePatterns []string // Glob patterns of files and directories left out (files provider)
+	SymlinkPolicy   string   // How the files provider archives symlinks: "preserve" (default), "follow" or "skip"
+
+	MaxReplicationLag      time.Duration // Refuse to back up a replica lagging further behind, 0 to disable
+	LockWaitTimeout        time.Duration // Fail a dump that waits longer for table locks, 0 to wait indefinitely
+	SerializableDeferrable bool          // Dump from a serializable snapshot that cannot conflict with writers
+
+	MaskingProfile string // Masking profile file applied to backups and restores
+	MaskingReport  string // File to write the report of masked columns to
 }
 
 // StorageConfig contains storage settings
 type StorageConfig struct {
-	Type      string
-	Bucket    string // For S3-compatible storage
-	Region    string // For S3-compatible storage
-	Endpoint  string // For S3-compatible storage (e.g., LocalStack)
-	AccessKey string // For S3-compatible storage
-	SecretKey string // For S3-compatible storage
-	Prefix    string // For S3-compatible storage
-	Path      string // For disk storage
+	Type             string
+	Bucket           string        // For S3-compatible storage and GCS, or the Azure container
+	Region           string        // For S3-compatible storage
+	Endpoint         string        // For S3-compatible storage (e.g., LocalStack), GCS and Azure emulators, or the SFTP, FTP or WebDAV server
+	AccessKey        string        // For S3-compatible storage
+	SecretKey        string        // For S3-compatible storage, or the Azure account key
+	CredentialsFile  string        // For GCS: service account key file, or the key JSON itself
+	Account          string        // For Azure: storage account name
+	SASToken         string        // For Azure: shared access signature
+	ConnectionString string        // For Azure: storage account connection string
+	AccessTier       string        // For Azure: Hot, Cool, Cold or Archive
+	Username         string        // For SFTP, FTP and WebDAV
+	Password         string        // For SFTP, FTP and WebDAV, or the passphrase of the SFTP private key
+	Token            string        // For WebDAV: bearer token
+	PrivateKeyFile   string        // For SFTP
+	KnownHostsFile   string        // For SFTP: defaults to ~/.ssh/known_hosts
+	CACertFile       string        // For FTPS: CA certificate file verifying the server
+	Prefix           string        // For S3-compatible storage, GCS, Azure and WebDAV
+	Path             string        // For disk storage, or the remote directory for SFTP and FTP
+	Backends         []string      // For mirror and repository: child storage types, each optionally followed by "?setting=value&..." overrides
+	MirrorPolicy     string        // For mirror: "all" (default), "quorum" for a majority, or how many children must store each backup
+	ArchivePath      string        // For disk: directory of the archive tier, such as a slower volume
+	RetrievalTier    string        // For S3: speed of retrievals from Glacier: Expedited, Standard (default) or Bulk
+	RetrievalWait    time.Duration // For S3: how long downloads of archived backups wait for their retrieval
 }
 
 // LoadConfigFromEnv loads configuration from environment variables
@@ -39,23 +87,69 @@ type StorageConfig struct {
 func LoadConfigFromEnv() (*Config, error) {
 	config := &Config{
 		Database: DatabaseConfig{
-			Type:     getEnv("DB_TYPE", "postgres"),
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnvAsInt("DB_PORT", 5432),
-			Username: getEnv("DB_USERNAME", "postgres"),
-			Password: getEnv("DB_PASSWORD", ""),
-			Database: getEnv("DB_DATABASE", "postgres"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+			Type:       getEnv("DB_TYPE", "postgres"),
+			Host:       getEnv("DB_HOST", "localhost"),
+			Port:       getEnvAsInt("DB_PORT", 0),
+			Username:   getEnv("DB_USERNAME", "postgres"),
+			Password:   getEnv("DB_PASSWORD", ""),
+			Database:   getEnv("DB_DATABASE", "postgres"),
+			SSLMode:    getEnv("DB_SSLMODE", "disable"),
+			BackupMode: getEnv("DB_BACKUP_MODE", ""),
+
+			URI:             getEnv("DB_URI", ""),
+			SSLRootCert:     getEnv("DB_SSLROOTCERT", ""),
+			SSLCert:         getEnv("DB_SSLCERT", ""),
+			SSLKey:          getEnv("DB_SSLKEY", ""),
+			PassFile:        getEnv("DB_PASSFILE", ""),
+			Service:         getEnv("DB_SERVICE", ""),
+			ConnectTimeout:  getEnvAsInt("DB_CONNECT_TIMEOUT", 0),
+			ApplicationName: getEnv("DB_APPLICATION_NAME", ""),
+			Path:            getEnv("DB_PATH", ""),
+
+			Collections:        getEnvAsList("DB_COLLECTIONS"),
+			ExcludeCollections: getEnvAsList("DB_EXCLUDE_COLLECTIONS"),
+
+			BackupCommand:   getEnv("DB_BACKUP_COMMAND", ""),
+			RestoreCommand:  getEnv("DB_RESTORE_COMMAND", ""),
+			MetadataCommand: getEnv("DB_METADATA_COMMAND", ""),
+
+			SourcePaths:     getEnvAsList("DB_SOURCE_PATHS"),
+			IncludePatterns: getEnvAsList("DB_INCLUDE_PATTERNS"),
+			ExcludePatterns: getEnvAsList("DB_EXCLUDE_PATTERNS"),
+			SymlinkPolicy:   getEnv("DB_SYMLINK_POLICY", ""),
+
+			MaxReplicationLag:      getEnvAsDuration("DB_MAX_REPLICATION_LAG", 0),
+			LockWaitTimeout:        getEnvAsDuration("DB_LOCK_WAIT_TIMEOUT", 0),
+			SerializableDeferrable: getEnvAsBool("DB_SERIALIZABLE_DEFERRABLE", false),
+
+			MaskingProfile: getEnv("DB_MASKING_PROFILE", ""),
+			MaskingReport:  getEnv("DB_MASKING_REPORT", ""),
 		},
 		Storage: StorageConfig{
-			Type:      getEnv("STORAGE_TYPE", "disk"),
-			Bucket:    getEnv("STORAGE_BUCKET", ""),
-			Endpoint:  getEnv("STORAGE_ENDPOINT", ""),
-			Region:    getEnv("STORAGE_REGION", "us-east-1"),
-			AccessKey: getEnv("STORAGE_ACCESS_KEY", ""),
-			SecretKey: getEnv("STORAGE_SECRET_KEY", ""),
-			Prefix:    getEnv("STORAGE_PREFIX", "backups/"),
-			Path:      getEnv("STORAGE_PATH", "./backups"),
+			Type:             getEnv("STORAGE_TYPE", "disk"),
+			Bucket:           getEnv("STORAGE_BUCKET", ""),
+			Endpoint:         getEnv("STORAGE_ENDPOINT", ""),
+			Region:           getEnv("STORAGE_REGION", "us-east-1"),
+			AccessKey:        getEnv("STORAGE_ACCESS_KEY", ""),
+			SecretKey:        getEnv("STORAGE_SECRET_KEY", ""),
+			CredentialsFile:  getEnv("STORAGE_CREDENTIALS_FILE", ""),
+			Account:          getEnv("STORAGE_ACCOUNT", ""),
+			SASToken:         getEnv("STORAGE_SAS_TOKEN", ""),
+			ConnectionString: getEnv("STORAGE_CONNECTION_STRING", ""),
+			AccessTier:       getEnv("STORAGE_ACCESS_TIER", ""),
+			Username:         getEnv("STORAGE_USERNAME", ""),
+			Password:         getEnv("STORAGE_PASSWORD", ""),
+			Token:            getEnv("STORAGE_TOKEN", ""),
+			PrivateKeyFile:   getEnv("STORAGE_PRIVATE_KEY_FILE", ""),
+			KnownHostsFile:   getEnv("STORAGE_KNOWN_HOSTS_FILE", ""),
+			CACertFile:       getEnv("STORAGE_CA_CERT_FILE", ""),
+			Prefix:           getEnv("STORAGE_PREFIX", "backups/"),
+			Path:             getEnv("STORAGE_PATH", "./backups"),
+			Backends:         getEnvAsList("STORAGE_BACKENDS"),
+			MirrorPolicy:     getEnv("STORAGE_MIRROR_POLICY", ""),
+			ArchivePath:      getEnv("STORAGE_ARCHIVE_PATH", ""),
+			RetrievalTier:    getEnv("STORAGE_RETRIEVAL_TIER", ""),
+			RetrievalWait:    getEnvAsDuration("STORAGE_RETRIEVAL_WAIT", 0),
 		},
 	}
 
@@ -68,12 +162,28 @@ func LoadConfigFromEnv() (*Config, error) {
 
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
-	if c.Database.Host == "" {
-		return fmt.Errorf("database host is required")
+	// A URI or service provides the host and user itself, and file-based
+	// databases and file sources need neither
+	if c.Database.URI == "" && c.Database.Service == "" && c.Database.Path == "" && len(c.Database.SourcePaths) == 0 {
+		if c.Database.Host == "" {
+			return fmt.Errorf("database host is required")
+		}
+
+		if c.Database.Username == "" {
+			return fmt.Errorf("database username is required")
+		}
+	}
+
+	if c.Database.ConnectTimeout < 0 {
+		return fmt.Errorf("database connect timeout cannot be negative")
+	}
+
+	if c.Database.MaxReplicationLag < 0 || c.Database.LockWaitTimeout < 0 {
+		return fmt.Errorf("database replication lag and lock wait timeout cannot be negative")
 	}
 
-	if c.Database.Username == "" {
-		return fmt.Errorf("database username is required")
+	if c.Storage.RetrievalWait < 0 {
+		return fmt.Errorf("storage retrieval wait cannot be negative")
 	}
 
 	// Storage validation depends on type
@@ -82,6 +192,40 @@ func (c *Config) Validate() error {
 		if c.Storage.Bucket == "" {
 			return fmt.Errorf("storage bucket is required for S3 storage")
 		}
+	case "gcs":
+		if c.Storage.Bucket == "" {
+			return fmt.Errorf("storage bucket is required for GCS storage")
+		}
+	case "azureblob":
+		if c.Storage.Bucket == "" {
+			return fmt.Errorf("storage container is required for Azure storage")
+		}
+		if c.Storage.ConnectionString == "" && c.Storage.Account == "" && c.Storage.Endpoint == "" {
+			return fmt.Errorf("storage account, endpoint or connection string is required for Azure storage")
+		}
+	case "sftp":
+		if c.Storage.Endpoint == "" {
+			return fmt.Errorf("storage endpoint is required for SFTP storage")
+		}
+		if c.Storage.Username == "" {
+			return fmt.Errorf("storage username is required for SFTP storage")
+		}
+	case "ftp":
+		if c.Storage.Endpoint == "" {
+			return fmt.Errorf("storage endpoint is required for FTP storage")
+		}
+	case "webdav":
+		if c.Storage.Endpoint == "" {
+			return fmt.Errorf("storage endpoint is required for WebDAV storage")
+		}
+	case "mirror":
+		if len(c.Storage.Backends) == 0 {
+			return fmt.Errorf("storage backends are required for mirror storage")
+		}
+	case "repository":
+		if len(c.Storage.Backends) != 1 {
+			return fmt.Errorf("one storage backend is required for repository storage")
+		}
 	case "disk":
 		// Path is optional, will default to ./backups
 		// No validation needed
@@ -113,3 +257,30 @@ func getEnvAsInt(key string, defaultValue int) int {
 
 	return value
 }
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsList splits a comma-separated variable, ignoring empty items
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -2,7 +2,9 @@ package core_test
 
 import (
 	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	"goarchive/core"
 )
@@ -73,6 +75,101 @@ func TestConfig_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "database username is required",
 		},
+		{
+			name: "URI without host and username",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					URI: "postgres://app@db.example.com/app",
+				},
+				Storage: core.StorageConfig{
+					Type: "disk",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "service without host and username",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					Service: "production",
+				},
+				Storage: core.StorageConfig{
+					Type: "disk",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "source paths without host and username",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					Type:        "files",
+					SourcePaths: []string{"/srv/uploads"},
+				},
+				Storage: core.StorageConfig{
+					Type: "disk",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "file path without host and username",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					Type: "sqlite",
+					Path: "/var/lib/app/app.db",
+				},
+				Storage: core.StorageConfig{
+					Type: "disk",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative connect timeout",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					Host:           "localhost",
+					Username:       "postgres",
+					ConnectTimeout: -1,
+				},
+				Storage: core.StorageConfig{
+					Type: "disk",
+				},
+			},
+			wantErr: true,
+			errMsg:  "database connect timeout cannot be negative",
+		},
+		{
+			name: "negative replication lag",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					Host:              "localhost",
+					Username:          "postgres",
+					MaxReplicationLag: -time.Second,
+				},
+				Storage: core.StorageConfig{
+					Type: "disk",
+				},
+			},
+			wantErr: true,
+			errMsg:  "database replication lag and lock wait timeout cannot be negative",
+		},
+		{
+			name: "negative retrieval wait",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					Host:     "localhost",
+					Username: "postgres",
+				},
+				Storage: core.StorageConfig{
+					Type:          "disk",
+					RetrievalWait: -time.Minute,
+				},
+			},
+			wantErr: true,
+			errMsg:  "storage retrieval wait cannot be negative",
+		},
 		{
 			name: "S3 storage missing bucket",
 			config: &core.Config{
@@ -89,6 +186,133 @@ func TestConfig_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "storage bucket is required for S3 storage",
 		},
+		{
+			name: "GCS storage missing bucket",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					Host:     "localhost",
+					Username: "postgres",
+					Port:     5432,
+				},
+				Storage: core.StorageConfig{
+					Type:            "gcs",
+					CredentialsFile: "/etc/goarchive/gcs.json",
+				},
+			},
+			wantErr: true,
+			errMsg:  "storage bucket is required for GCS storage",
+		},
+		{
+			name: "Azure storage missing container",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					Host:     "localhost",
+					Username: "postgres",
+					Port:     5432,
+				},
+				Storage: core.StorageConfig{
+					Type:    "azureblob",
+					Account: "backups",
+				},
+			},
+			wantErr: true,
+			errMsg:  "storage container is required for Azure storage",
+		},
+		{
+			name: "Azure storage missing account",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					Host:     "localhost",
+					Username: "postgres",
+					Port:     5432,
+				},
+				Storage: core.StorageConfig{
+					Type:   "azureblob",
+					Bucket: "backups",
+				},
+			},
+			wantErr: true,
+			errMsg:  "storage account, endpoint or connection string is required for Azure storage",
+		},
+		{
+			name: "SFTP storage missing username",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					Host:     "localhost",
+					Username: "postgres",
+					Port:     5432,
+				},
+				Storage: core.StorageConfig{
+					Type:     "sftp",
+					Endpoint: "backups.example.com:22",
+				},
+			},
+			wantErr: true,
+			errMsg:  "storage username is required for SFTP storage",
+		},
+		{
+			name: "WebDAV storage missing endpoint",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					Host:     "localhost",
+					Username: "postgres",
+					Port:     5432,
+				},
+				Storage: core.StorageConfig{
+					Type:     "webdav",
+					Username: "alice",
+				},
+			},
+			wantErr: true,
+			errMsg:  "storage endpoint is required for WebDAV storage",
+		},
+		{
+			name: "FTP storage missing endpoint",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					Host:     "localhost",
+					Username: "postgres",
+					Port:     5432,
+				},
+				Storage: core.StorageConfig{
+					Type:     "ftp",
+					Username: "partner",
+				},
+			},
+			wantErr: true,
+			errMsg:  "storage endpoint is required for FTP storage",
+		},
+		{
+			name: "mirror storage missing backends",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					Host:     "localhost",
+					Username: "postgres",
+					Port:     5432,
+				},
+				Storage: core.StorageConfig{
+					Type: "mirror",
+				},
+			},
+			wantErr: true,
+			errMsg:  "storage backends are required for mirror storage",
+		},
+		{
+			name: "repository storage with two backends",
+			config: &core.Config{
+				Database: core.DatabaseConfig{
+					Host:     "localhost",
+					Username: "postgres",
+					Port:     5432,
+				},
+				Storage: core.StorageConfig{
+					Type:     "repository",
+					Backends: []string{"disk", "s3"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "one storage backend is required for repository storage",
+		},
 	}
 
 	for _, tt := range tests {
@@ -125,8 +349,8 @@ func TestLoadConfigFromEnv(t *testing.T) {
 				if cfg.Database.Host != "localhost" {
 					t.Errorf("expected host 'localhost', got %v", cfg.Database.Host)
 				}
-				if cfg.Database.Port != 5432 {
-					t.Errorf("expected port 5432, got %v", cfg.Database.Port)
+				if cfg.Database.Port != 0 {
+					t.Errorf("expected port 0 for the provider's default, got %v", cfg.Database.Port)
 				}
 				if cfg.Storage.Type != "disk" {
 					t.Errorf("expected storage type 'disk', got %v", cfg.Storage.Type)
@@ -142,6 +366,7 @@ func TestLoadConfigFromEnv(t *testing.T) {
 				"DB_USERNAME":        "admin",
 				"DB_PASSWORD":        "secret",
 				"DB_DATABASE":        "myapp",
+				"DB_BACKUP_MODE":     "physical",
 				"STORAGE_TYPE":       "s3",
 				"STORAGE_BUCKET":     "my-backups",
 				"STORAGE_REGION":     "us-west-2",
@@ -162,6 +387,9 @@ func TestLoadConfigFromEnv(t *testing.T) {
 				if cfg.Database.Username != "admin" {
 					t.Errorf("expected username 'admin', got %v", cfg.Database.Username)
 				}
+				if cfg.Database.BackupMode != "physical" {
+					t.Errorf("expected backup mode 'physical', got %v", cfg.Database.BackupMode)
+				}
 				if cfg.Storage.Type != "s3" {
 					t.Errorf("expected storage type 's3', got %v", cfg.Storage.Type)
 				}
@@ -170,6 +398,125 @@ func TestLoadConfigFromEnv(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "connection options",
+			envVars: map[string]string{
+				"DB_URI":              "postgres://app@db.example.com/app",
+				"DB_SSLROOTCERT":      "/etc/ssl/ca.pem",
+				"DB_SSLCERT":          "/etc/ssl/client.pem",
+				"DB_SSLKEY":           "/etc/ssl/client.key",
+				"DB_PASSFILE":         "/etc/goarchive/pgpass",
+				"DB_SERVICE":          "production",
+				"DB_CONNECT_TIMEOUT":  "15",
+				"DB_APPLICATION_NAME": "nightly-backup",
+			},
+			wantErr: false,
+			check: func(t *testing.T, cfg *core.Config) {
+				db := cfg.Database
+				if db.URI != "postgres://app@db.example.com/app" {
+					t.Errorf("expected URI from DB_URI, got %v", db.URI)
+				}
+				if db.SSLRootCert != "/etc/ssl/ca.pem" || db.SSLCert != "/etc/ssl/client.pem" || db.SSLKey != "/etc/ssl/client.key" {
+					t.Errorf("unexpected TLS files: %v %v %v", db.SSLRootCert, db.SSLCert, db.SSLKey)
+				}
+				if db.PassFile != "/etc/goarchive/pgpass" {
+					t.Errorf("expected passfile from DB_PASSFILE, got %v", db.PassFile)
+				}
+				if db.Service != "production" {
+					t.Errorf("expected service 'production', got %v", db.Service)
+				}
+				if db.ConnectTimeout != 15 {
+					t.Errorf("expected connect timeout 15, got %v", db.ConnectTimeout)
+				}
+				if db.ApplicationName != "nightly-backup" {
+					t.Errorf("expected application name 'nightly-backup', got %v", db.ApplicationName)
+				}
+			},
+		},
+		{
+			name: "backup safety options",
+			envVars: map[string]string{
+				"DB_USERNAME":                "testuser",
+				"DB_MAX_REPLICATION_LAG":     "5m",
+				"DB_LOCK_WAIT_TIMEOUT":       "30s",
+				"DB_SERIALIZABLE_DEFERRABLE": "true",
+			},
+			wantErr: false,
+			check: func(t *testing.T, cfg *core.Config) {
+				if cfg.Database.MaxReplicationLag != 5*time.Minute {
+					t.Errorf("expected max replication lag 5m, got %v", cfg.Database.MaxReplicationLag)
+				}
+				if cfg.Database.LockWaitTimeout != 30*time.Second {
+					t.Errorf("expected lock wait timeout 30s, got %v", cfg.Database.LockWaitTimeout)
+				}
+				if !cfg.Database.SerializableDeferrable {
+					t.Error("expected serializable deferrable to be enabled")
+				}
+			},
+		},
+		{
+			name: "collection filters",
+			envVars: map[string]string{
+				"DB_USERNAME":            "testuser",
+				"DB_COLLECTIONS":         "users, orders,,",
+				"DB_EXCLUDE_COLLECTIONS": "sessions",
+			},
+			wantErr: false,
+			check: func(t *testing.T, cfg *core.Config) {
+				if !reflect.DeepEqual(cfg.Database.Collections, []string{"users", "orders"}) {
+					t.Errorf("expected collections [users orders], got %v", cfg.Database.Collections)
+				}
+				if !reflect.DeepEqual(cfg.Database.ExcludeCollections, []string{"sessions"}) {
+					t.Errorf("expected excluded collections [sessions], got %v", cfg.Database.ExcludeCollections)
+				}
+			},
+		},
+		{
+			name: "exec commands",
+			envVars: map[string]string{
+				"DB_USERNAME":         "testuser",
+				"DB_BACKUP_COMMAND":   "kvctl dump",
+				"DB_RESTORE_COMMAND":  "kvctl load",
+				"DB_METADATA_COMMAND": "kvctl info --json",
+			},
+			wantErr: false,
+			check: func(t *testing.T, cfg *core.Config) {
+				if cfg.Database.BackupCommand != "kvctl dump" {
+					t.Errorf("expected backup command 'kvctl dump', got %v", cfg.Database.BackupCommand)
+				}
+				if cfg.Database.RestoreCommand != "kvctl load" {
+					t.Errorf("expected restore command 'kvctl load', got %v", cfg.Database.RestoreCommand)
+				}
+				if cfg.Database.MetadataCommand != "kvctl info --json" {
+					t.Errorf("expected metadata command 'kvctl info --json', got %v", cfg.Database.MetadataCommand)
+				}
+			},
+		},
+		{
+			name: "file source",
+			envVars: map[string]string{
+				"DB_TYPE":             "files",
+				"DB_SOURCE_PATHS":     "/srv/uploads,/etc/app",
+				"DB_INCLUDE_PATTERNS": "*.conf",
+				"DB_EXCLUDE_PATTERNS": "cache, *.tmp",
+				"DB_SYMLINK_POLICY":   "follow",
+			},
+			wantErr: false,
+			check: func(t *testing.T, cfg *core.Config) {
+				if !reflect.DeepEqual(cfg.Database.SourcePaths, []string{"/srv/uploads", "/etc/app"}) {
+					t.Errorf("expected source paths [/srv/uploads /etc/app], got %v", cfg.Database.SourcePaths)
+				}
+				if !reflect.DeepEqual(cfg.Database.IncludePatterns, []string{"*.conf"}) {
+					t.Errorf("expected include patterns [*.conf], got %v", cfg.Database.IncludePatterns)
+				}
+				if !reflect.DeepEqual(cfg.Database.ExcludePatterns, []string{"cache", "*.tmp"}) {
+					t.Errorf("expected exclude patterns [cache *.tmp], got %v", cfg.Database.ExcludePatterns)
+				}
+				if cfg.Database.SymlinkPolicy != "follow" {
+					t.Errorf("expected symlink policy 'follow', got %v", cfg.Database.SymlinkPolicy)
+				}
+			},
+		},
 		{
 			name: "invalid port number",
 			envVars: map[string]string{
@@ -178,8 +525,8 @@ func TestLoadConfigFromEnv(t *testing.T) {
 			},
 			wantErr: false,
 			check: func(t *testing.T, cfg *core.Config) {
-				if cfg.Database.Port != 5432 {
-					t.Errorf("expected default port 5432, got %v", cfg.Database.Port)
+				if cfg.Database.Port != 0 {
+					t.Errorf("expected port 0 for the provider's default, got %v", cfg.Database.Port)
 				}
 			},
 		},
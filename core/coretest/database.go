@@ -0,0 +1,157 @@
+package coretest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"goarchive/core"
+)
+
+// FakeDatabase implements the DatabaseProvider and OptionsRestorer
+// interfaces. Backups stream its Data; restores are recorded instead of
+// applied. Its embedded Faults script failures of its operations.
+type FakeDatabase struct {
+	Faults
+
+	// Metadata is returned by GetMetadata
+	Metadata core.DatabaseMetadata
+
+	// Data is the content of each backup
+	Data []byte
+
+	// ReaderTags are reported by backup readers through core.TaggedReader
+	// once the backup has been read
+	ReaderTags map[string]string
+
+	mu       sync.Mutex
+	restores []Restore
+	closed   bool
+}
+
+// Restore records a restore received by a FakeDatabase
+type Restore struct {
+	Data    []byte
+	Options *core.RestoreOptions // nil for restores without options
+}
+
+// NewFakeDatabase creates a fake database whose backups contain data
+func NewFakeDatabase(data []byte) *FakeDatabase {
+	return &FakeDatabase{
+		Metadata: core.DatabaseMetadata{
+			Type:    "fake",
+			Name:    "testdb",
+			Version: "1.0",
+			Size:    int64(len(data)),
+		},
+		Data: data,
+	}
+}
+
+// Backup returns a reader over the database's data
+func (d *FakeDatabase) Backup(ctx context.Context) (io.ReadCloser, error) {
+	fault, err := d.begin(ctx, OpBackup)
+	if err != nil {
+		return nil, err
+	}
+
+	return &backupReader{
+		Reader: fault.wrap(bytes.NewReader(d.Data)),
+		tags:   d.ReaderTags,
+	}, nil
+}
+
+// Restore reads the backup data and records it
+func (d *FakeDatabase) Restore(ctx context.Context, reader io.Reader) error {
+	return d.restore(ctx, reader, nil)
+}
+
+// RestoreWithOptions reads the backup data and records it with opts
+func (d *FakeDatabase) RestoreWithOptions(ctx context.Context, reader io.Reader, opts *core.RestoreOptions) error {
+	options := *opts
+	return d.restore(ctx, reader, &options)
+}
+
+// restore records a restore, failing as scripted
+func (d *FakeDatabase) restore(ctx context.Context, reader io.Reader, opts *core.RestoreOptions) error {
+	fault, err := d.begin(ctx, OpRestore)
+	if err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(fault.wrap(reader))
+	if err != nil {
+		return fmt.Errorf("failed to read backup data: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.restores = append(d.restores, Restore{Data: data, Options: opts})
+
+	return nil
+}
+
+// GetMetadata returns the database's Metadata
+func (d *FakeDatabase) GetMetadata() (*core.DatabaseMetadata, error) {
+	if _, err := d.begin(context.Background(), OpGetMetadata); err != nil {
+		return nil, err
+	}
+
+	metadata := d.Metadata
+	if d.Metadata.Tags != nil {
+		metadata.Tags = make(map[string]string, len(d.Metadata.Tags))
+		for k, v := range d.Metadata.Tags {
+			metadata.Tags[k] = v
+		}
+	}
+	return &metadata, nil
+}
+
+// Close marks the database closed
+func (d *FakeDatabase) Close() error {
+	if _, err := d.begin(context.Background(), OpClose); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+
+	return nil
+}
+
+// Restores returns the restores received so far, oldest first
+func (d *FakeDatabase) Restores() []Restore {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	restores := make([]Restore, len(d.restores))
+	copy(restores, d.restores)
+	return restores
+}
+
+// Closed reports whether Close succeeded
+func (d *FakeDatabase) Closed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.closed
+}
+
+// backupReader streams a backup and reports its tags
+type backupReader struct {
+	io.Reader
+	tags map[string]string
+}
+
+// Tags returns the tags to record in the backup metadata
+func (r *backupReader) Tags() map[string]string {
+	return r.tags
+}
+
+// Close does nothing; the data is in memory
+func (r *backupReader) Close() error {
+	return nil
+}
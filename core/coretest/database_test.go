@@ -0,0 +1,171 @@
+package coretest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"goarchive/core"
+	"goarchive/core/coretest"
+)
+
+func TestFakeDatabase_Backup(t *testing.T) {
+	db := coretest.NewFakeDatabase([]byte("backup data"))
+	db.ReaderTags = map[string]string{"format": "v2"}
+
+	reader, err := db.Backup(context.Background())
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil || string(data) != "backup data" {
+		t.Errorf("Backup() data = %q, %v", data, err)
+	}
+
+	tagged, ok := reader.(core.TaggedReader)
+	if !ok {
+		t.Fatal("expected backup reader to implement core.TaggedReader")
+	}
+	if tagged.Tags()["format"] != "v2" {
+		t.Errorf("Tags() = %v", tagged.Tags())
+	}
+}
+
+func TestFakeDatabase_Restore(t *testing.T) {
+	db := coretest.NewFakeDatabase(nil)
+	ctx := context.Background()
+
+	if err := db.Restore(ctx, strings.NewReader("first")); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if err := db.RestoreWithOptions(ctx, strings.NewReader("second"), &core.RestoreOptions{TargetDatabase: "copy"}); err != nil {
+		t.Fatalf("RestoreWithOptions() error = %v", err)
+	}
+
+	restores := db.Restores()
+	if len(restores) != 2 {
+		t.Fatalf("expected 2 restores, got %d", len(restores))
+	}
+	if string(restores[0].Data) != "first" || restores[0].Options != nil {
+		t.Errorf("unexpected first restore: %+v", restores[0])
+	}
+	if string(restores[1].Data) != "second" || restores[1].Options == nil || restores[1].Options.TargetDatabase != "copy" {
+		t.Errorf("unexpected second restore: %+v", restores[1])
+	}
+}
+
+func TestFakeDatabase_Faults(t *testing.T) {
+	lost := errors.New("connection lost")
+
+	tests := []struct {
+		name    string
+		op      coretest.Op
+		fault   coretest.Fault
+		call    func(db *coretest.FakeDatabase) error
+		wantErr error
+	}{
+		{
+			name:  "metadata fails",
+			op:    coretest.OpGetMetadata,
+			fault: coretest.Fault{Err: lost},
+			call: func(db *coretest.FakeDatabase) error {
+				_, err := db.GetMetadata()
+				return err
+			},
+			wantErr: lost,
+		},
+		{
+			name:  "backup fails part way",
+			op:    coretest.OpBackup,
+			fault: coretest.Fault{Err: lost, After: 3},
+			call: func(db *coretest.FakeDatabase) error {
+				reader, err := db.Backup(context.Background())
+				if err != nil {
+					return err
+				}
+				defer reader.Close()
+				data, err := io.ReadAll(reader)
+				if string(data) != "bac" {
+					t.Errorf("read %q before the failure, want %q", data, "bac")
+				}
+				return err
+			},
+			wantErr: lost,
+		},
+		{
+			name:  "restore fails part way",
+			op:    coretest.OpRestore,
+			fault: coretest.Fault{Err: lost, After: 3},
+			call: func(db *coretest.FakeDatabase) error {
+				return db.Restore(context.Background(), strings.NewReader("backup"))
+			},
+			wantErr: lost,
+		},
+		{
+			name:  "close fails",
+			op:    coretest.OpClose,
+			fault: coretest.Fault{Err: lost},
+			call: func(db *coretest.FakeDatabase) error {
+				err := db.Close()
+				if db.Closed() {
+					t.Error("expected the database to stay open")
+				}
+				return err
+			},
+			wantErr: lost,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := coretest.NewFakeDatabase([]byte("backup"))
+			db.Queue(tt.op, tt.fault)
+
+			if err := tt.call(db); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if len(db.Restores()) != 0 {
+				t.Error("expected no restore to be recorded")
+			}
+		})
+	}
+}
+
+func TestBackupService_WithFakes(t *testing.T) {
+	ctx := context.Background()
+	db := coretest.NewFakeDatabase([]byte("backup data"))
+	db.Metadata.BackupMode = "logical"
+	storage := coretest.NewMemoryStorage()
+	service := core.NewBackupService(db, storage)
+
+	if _, err := service.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	backups, err := service.List(ctx)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("List() = %v, %v", backups, err)
+	}
+
+	// A backup corrupted in storage is restored as stored
+	storage.Queue(coretest.OpDownload, coretest.Fault{Corrupt: []int64{0}})
+	if err := service.Restore(ctx, backups[0].ID); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	restores := db.Restores()
+	if len(restores) != 1 || string(restores[0].Data) != "\x9dackup data" {
+		t.Fatalf("unexpected restores: %+v", restores)
+	}
+	if restores[0].Options == nil || restores[0].Options.BackupMode != "logical" {
+		t.Errorf("expected the backup mode to be passed on, got %+v", restores[0].Options)
+	}
+
+	// Storage failures reach the caller
+	storage.Queue(coretest.OpUpload, coretest.Fault{Err: errors.New("disk full")})
+	if _, err := service.Execute(ctx); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Execute() error = %v, want the storage failure", err)
+	}
+}
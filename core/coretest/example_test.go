@@ -0,0 +1,37 @@
+package coretest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"goarchive/core"
+	"goarchive/core/coretest"
+)
+
+// Example demonstrates testing a backup flow against scripted failures
+func Example() {
+	ctx := context.Background()
+
+	db := coretest.NewFakeDatabase([]byte("backup data"))
+	storage := coretest.NewMemoryStorage()
+	service := core.NewBackupService(db, storage)
+
+	// The first upload fails half way through the stream
+	storage.Queue(coretest.OpUpload, coretest.Fault{Err: errors.New("connection reset"), After: 6})
+
+	if _, err := service.Execute(ctx); err != nil {
+		fmt.Println("First attempt:", err)
+	}
+	if _, err := service.Execute(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Stored backups:", storage.Len())
+	fmt.Println("Upload attempts:", storage.Calls(coretest.OpUpload))
+	// Output:
+	// First attempt: failed to read backup data: connection reset
+	// Stored backups: 1
+	// Upload attempts: 2
+}
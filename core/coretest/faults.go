@@ -0,0 +1,171 @@
+// Package coretest provides an in-memory storage provider and a fake
+// database provider for testing code built on goarchive, with scriptable
+// failures, delays and data corruption.
+package coretest
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Op names an operation of a fake provider
+type Op string
+
+// Operations of MemoryStorage and FakeDatabase
+const (
+	OpUpload      Op = "upload"
+	OpList        Op = "list"
+	OpDownload    Op = "download"
+	OpDelete      Op = "delete"
+	OpTier        Op = "tier"
+	OpBackup      Op = "backup"
+	OpRestore     Op = "restore"
+	OpGetMetadata Op = "get-metadata"
+	OpClose       Op = "close"
+)
+
+// Fault describes how one call of an operation misbehaves. The zero Fault
+// lets the call succeed.
+type Fault struct {
+	// Delay is waited before the call runs. A context done in the meantime
+	// fails the call with the context's error.
+	Delay time.Duration
+
+	// Err fails the call. For operations streaming data (upload, download,
+	// backup and restore) with After set, the call starts and the stream
+	// fails with Err after After bytes instead.
+	Err   error
+	After int64
+
+	// Corrupt lists offsets in the streamed data whose bytes are inverted.
+	// Uploads store the corrupted data with the checksum of the original,
+	// as storage that rots would.
+	Corrupt []int64
+}
+
+// Faults scripts the failures of a fake provider. Faults queued for an
+// operation apply to its next calls in order, one per call. Once the queue
+// is empty, calls use the fault set with Always, or succeed.
+type Faults struct {
+	mu     sync.Mutex
+	queued map[Op][]Fault
+	always map[Op]Fault
+	calls  map[Op]int
+}
+
+// Queue adds faults for the next calls of op
+func (f *Faults) Queue(op Op, faults ...Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.queued == nil {
+		f.queued = make(map[Op][]Fault)
+	}
+	f.queued[op] = append(f.queued[op], faults...)
+}
+
+// Always applies fault to every call of op once its queue is empty
+func (f *Faults) Always(op Op, fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.always == nil {
+		f.always = make(map[Op]Fault)
+	}
+	f.always[op] = fault
+}
+
+// Reset removes every fault, so all calls succeed
+func (f *Faults) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.queued = nil
+	f.always = nil
+}
+
+// Calls returns how many times op was called
+func (f *Faults) Calls(op Op) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[op]
+}
+
+// next counts a call of op and returns its fault
+func (f *Faults) next(op Op) Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.calls == nil {
+		f.calls = make(map[Op]int)
+	}
+	f.calls[op]++
+
+	if queue := f.queued[op]; len(queue) > 0 {
+		f.queued[op] = queue[1:]
+		return queue[0]
+	}
+	return f.always[op]
+}
+
+// begin starts a call of op, waiting for its delay. It returns the error
+// failing the call, or the fault to apply to the call's stream.
+func (f *Faults) begin(ctx context.Context, op Op) (Fault, error) {
+	fault := f.next(op)
+
+	if fault.Delay > 0 {
+		timer := time.NewTimer(fault.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return fault, ctx.Err()
+		}
+	}
+
+	if fault.Err != nil && fault.After <= 0 {
+		return fault, fault.Err
+	}
+	return fault, nil
+}
+
+// faultReader applies a fault to a stream
+type faultReader struct {
+	r      io.Reader
+	fault  Fault
+	offset int64
+}
+
+// wrap applies the fault's stream failure and corruption to r
+func (fault Fault) wrap(r io.Reader) io.Reader {
+	if fault.Err == nil && len(fault.Corrupt) == 0 {
+		return r
+	}
+	return &faultReader{r: r, fault: fault}
+}
+
+// Read reads from the underlying reader, failing after fault.After bytes
+// and inverting the bytes at the corrupted offsets
+func (r *faultReader) Read(p []byte) (int, error) {
+	if r.fault.Err != nil {
+		remaining := r.fault.After - r.offset
+		if remaining <= 0 {
+			return 0, r.fault.Err
+		}
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+
+	n, err := r.r.Read(p)
+	for _, offset := range r.fault.Corrupt {
+		if offset >= r.offset && offset < r.offset+int64(n) {
+			p[offset-r.offset] ^= 0xff
+		}
+	}
+	r.offset += int64(n)
+	return n, err
+}
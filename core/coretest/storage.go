@@ -0,0 +1,223 @@
+package coretest
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"goarchive/core"
+)
+
+// init registers the memory provider with the global registry. Providers
+// created for the same StorageConfig.Bucket share their backups.
+func init() {
+	core.RegisterStorage("memory", func(ctx context.Context, config *core.StorageConfig) (core.StorageProvider, error) {
+		return SharedMemoryStorage(config.Bucket), nil
+	})
+}
+
+var (
+	sharedMu sync.Mutex
+	shared   = make(map[string]*MemoryStorage)
+)
+
+// SharedMemoryStorage returns the storage the "memory" provider uses for
+// StorageConfig.Bucket name, creating it when needed. Tests can script its
+// faults before handing the configuration to code under test.
+func SharedMemoryStorage(name string) *MemoryStorage {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	storage, ok := shared[name]
+	if !ok {
+		storage = NewMemoryStorage()
+		shared[name] = storage
+	}
+	return storage
+}
+
+// ResetSharedMemoryStorages forgets every shared storage
+func ResetSharedMemoryStorages() {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	shared = make(map[string]*MemoryStorage)
+}
+
+// MemoryStorage implements the StorageProvider and Tierer interfaces in
+// memory. Its embedded Faults script failures of its operations.
+type MemoryStorage struct {
+	Faults
+
+	mu      sync.Mutex
+	backups map[string]*memoryBackup
+}
+
+// memoryBackup is a stored backup and its metadata
+type memoryBackup struct {
+	data     []byte
+	metadata core.BackupMetadata
+}
+
+// NewMemoryStorage creates an empty memory storage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{backups: make(map[string]*memoryBackup)}
+}
+
+// Upload reads the backup into memory. Like the other providers, it sets
+// the metadata's checksum and size, and stores the backup under
+// metadata.Key or a name derived from the database and timestamp.
+func (s *MemoryStorage) Upload(ctx context.Context, reader io.Reader, metadata *core.BackupMetadata) error {
+	fault, err := s.begin(ctx, OpUpload)
+	if err != nil {
+		return err
+	}
+
+	// The checksum covers the data as received, before any corruption
+	hash := md5.New()
+	data, err := io.ReadAll(fault.wrap(io.TeeReader(reader, hash)))
+	if err != nil {
+		return fmt.Errorf("failed to read backup data: %w", err)
+	}
+
+	metadata.Checksum = hex.EncodeToString(hash.Sum(nil))
+	metadata.Size = int64(len(data))
+
+	id := s.getBackupFilename(metadata)
+	stored := metadata.Clone()
+	stored.ID = id
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backups[id] = &memoryBackup{data: data, metadata: *stored}
+
+	return nil
+}
+
+// List lists the stored backups, newest first
+func (s *MemoryStorage) List(ctx context.Context) ([]*core.BackupMetadata, error) {
+	if _, err := s.begin(ctx, OpList); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backups := make([]*core.BackupMetadata, 0, len(s.backups))
+	for _, backup := range s.backups {
+		backups = append(backups, backup.metadata.Clone())
+	}
+
+	// Sort by timestamp descending (newest first), then by ID
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].Timestamp.Equal(backups[j].Timestamp) {
+			return backups[i].Timestamp.After(backups[j].Timestamp)
+		}
+		return backups[i].ID < backups[j].ID
+	})
+
+	return backups, nil
+}
+
+// Download returns a reader over a stored backup
+func (s *MemoryStorage) Download(ctx context.Context, backupID string) (io.ReadCloser, error) {
+	fault, err := s.begin(ctx, OpDownload)
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := s.Data(backupID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+	}
+
+	return io.NopCloser(fault.wrap(bytes.NewReader(data))), nil
+}
+
+// Delete removes a stored backup
+func (s *MemoryStorage) Delete(ctx context.Context, backupID string) error {
+	if _, err := s.begin(ctx, OpDelete); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.backups[backupID]; !ok {
+		return fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+	}
+	delete(s.backups, backupID)
+
+	return nil
+}
+
+// Tier records the tier of a stored backup. Any tier name is accepted.
+func (s *MemoryStorage) Tier(ctx context.Context, backupID string, tier string) error {
+	if _, err := s.begin(ctx, OpTier); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backup, ok := s.backups[backupID]
+	if !ok {
+		return fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+	}
+	backup.metadata.Tier = tier
+
+	return nil
+}
+
+// Put stores data as backup id directly, without scripted faults, for
+// tests that start with existing backups
+func (s *MemoryStorage) Put(id string, data []byte, metadata *core.BackupMetadata) {
+	stored := metadata.Clone()
+	stored.ID = id
+	if stored.Checksum == "" {
+		sum := md5.Sum(data)
+		stored.Checksum = hex.EncodeToString(sum[:])
+	}
+	stored.Size = int64(len(data))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backups[id] = &memoryBackup{data: bytes.Clone(data), metadata: *stored}
+}
+
+// Data returns a copy of the data of backup id
+func (s *MemoryStorage) Data(id string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backup, ok := s.backups[id]
+	if !ok {
+		return nil, false
+	}
+	return bytes.Clone(backup.data), true
+}
+
+// Len returns the number of stored backups
+func (s *MemoryStorage) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.backups)
+}
+
+// getBackupFilename generates the filename for a backup
+func (s *MemoryStorage) getBackupFilename(metadata *core.BackupMetadata) string {
+	if metadata.Key != "" {
+		return metadata.Key
+	}
+	return fmt.Sprintf("%s_%s_%s.dump",
+		metadata.DatabaseName,
+		metadata.DatabaseType,
+		metadata.Timestamp.Format("20060102-150405"),
+	)
+}
@@ -0,0 +1,249 @@
+package coretest_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"goarchive/core"
+	"goarchive/core/coretest"
+)
+
+func testMetadata(day int) *core.BackupMetadata {
+	return &core.BackupMetadata{
+		DatabaseName: "orders",
+		DatabaseType: "postgres",
+		Timestamp:    time.Date(2026, 2, day, 10, 30, 20, 0, time.UTC),
+		Tags:         map[string]string{"server-version": "16.2"},
+	}
+}
+
+func TestMemoryStorage_RoundTrip(t *testing.T) {
+	storage := coretest.NewMemoryStorage()
+	ctx := context.Background()
+
+	data := []byte("backup data")
+	metadata := testMetadata(15)
+	if err := storage.Upload(ctx, bytes.NewReader(data), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	sum := md5.Sum(data)
+	if metadata.Checksum != hex.EncodeToString(sum[:]) || metadata.Size != int64(len(data)) {
+		t.Errorf("checksum %s and size %d, want %s and %d", metadata.Checksum, metadata.Size, hex.EncodeToString(sum[:]), len(data))
+	}
+
+	keyed := testMetadata(16)
+	keyed.Key = "nightly/orders.dump"
+	if err := storage.Upload(ctx, strings.NewReader("keyed"), keyed); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	backups, err := storage.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 2 || backups[0].ID != "nightly/orders.dump" || backups[1].ID != "orders_postgres_20260215-103020.dump" {
+		t.Fatalf("List() returned unexpected backups: %+v", backups)
+	}
+	if backups[1].Tags["server-version"] != "16.2" || backups[1].Checksum != metadata.Checksum {
+		t.Errorf("List() lost the metadata: %+v", backups[1])
+	}
+
+	// Listed metadata is a copy
+	backups[1].Tags["server-version"] = "changed"
+	if again, _ := storage.List(ctx); again[1].Tags["server-version"] != "16.2" {
+		t.Error("expected List() to return copies of the metadata")
+	}
+
+	reader, err := storage.Download(ctx, backups[1].ID)
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	downloaded, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || !bytes.Equal(downloaded, data) {
+		t.Errorf("Download() = %q, %v, want %q", downloaded, err, data)
+	}
+
+	if err := storage.Delete(ctx, backups[1].ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if storage.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", storage.Len())
+	}
+}
+
+func TestMemoryStorage_NotFound(t *testing.T) {
+	storage := coretest.NewMemoryStorage()
+	ctx := context.Background()
+
+	if _, err := storage.Download(ctx, "missing.dump"); !errors.Is(err, core.ErrBackupNotFound) {
+		t.Errorf("Download() error = %v, want not found", err)
+	}
+	if err := storage.Delete(ctx, "missing.dump"); !errors.Is(err, core.ErrBackupNotFound) {
+		t.Errorf("Delete() error = %v, want not found", err)
+	}
+}
+
+func TestMemoryStorage_Put(t *testing.T) {
+	storage := coretest.NewMemoryStorage()
+
+	storage.Put("old.dump", []byte("old backup"), &core.BackupMetadata{BackupMode: "physical"})
+
+	backups, err := storage.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 || backups[0].ID != "old.dump" || backups[0].BackupMode != "physical" || backups[0].Size != 10 {
+		t.Errorf("List() = %+v, want the stored backup", backups)
+	}
+	if data, ok := storage.Data("old.dump"); !ok || string(data) != "old backup" {
+		t.Errorf("Data() = %q, %v", data, ok)
+	}
+}
+
+func TestMemoryStorage_Faults(t *testing.T) {
+	unavailable := errors.New("bucket unavailable")
+	data := []byte("0123456789")
+	id := "orders_postgres_20260215-103020.dump"
+
+	tests := []struct {
+		name     string
+		op       coretest.Op
+		fault    coretest.Fault
+		wantErr  error
+		wantData string // stored or downloaded data when there is no error
+	}{
+		{name: "upload fails", op: coretest.OpUpload, fault: coretest.Fault{Err: unavailable}, wantErr: unavailable},
+		{name: "upload fails part way", op: coretest.OpUpload, fault: coretest.Fault{Err: unavailable, After: 4}, wantErr: unavailable},
+		{name: "upload corrupts", op: coretest.OpUpload, fault: coretest.Fault{Corrupt: []int64{0, 9}}, wantData: "\xcf12345678\xc6"},
+		{name: "download fails", op: coretest.OpDownload, fault: coretest.Fault{Err: unavailable}, wantErr: unavailable},
+		{name: "download fails part way", op: coretest.OpDownload, fault: coretest.Fault{Err: unavailable, After: 4}, wantErr: unavailable},
+		{name: "download corrupts", op: coretest.OpDownload, fault: coretest.Fault{Corrupt: []int64{5}}, wantData: "01234\xca6789"},
+		{name: "list fails", op: coretest.OpList, fault: coretest.Fault{Err: unavailable}, wantErr: unavailable},
+		{name: "delete fails", op: coretest.OpDelete, fault: coretest.Fault{Err: unavailable}, wantErr: unavailable},
+		{name: "delay", op: coretest.OpDownload, fault: coretest.Fault{Delay: time.Millisecond}, wantData: string(data)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := coretest.NewMemoryStorage()
+			ctx := context.Background()
+			if tt.op != coretest.OpUpload {
+				if err := storage.Upload(ctx, bytes.NewReader(data), testMetadata(15)); err != nil {
+					t.Fatalf("Upload() error = %v", err)
+				}
+			}
+			storage.Queue(tt.op, tt.fault)
+
+			var got []byte
+			var err error
+			switch tt.op {
+			case coretest.OpUpload:
+				metadata := testMetadata(15)
+				err = storage.Upload(ctx, bytes.NewReader(data), metadata)
+				if err == nil {
+					// The checksum is that of the data received
+					sum := md5.Sum(data)
+					if metadata.Checksum != hex.EncodeToString(sum[:]) {
+						t.Errorf("checksum %s, want that of the original data", metadata.Checksum)
+					}
+				}
+				got, _ = storage.Data(id)
+			case coretest.OpDownload:
+				var reader io.ReadCloser
+				if reader, err = storage.Download(ctx, id); err == nil {
+					got, err = io.ReadAll(reader)
+					reader.Close()
+				}
+			case coretest.OpList:
+				_, err = storage.List(ctx)
+			case coretest.OpDelete:
+				err = storage.Delete(ctx, id)
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && string(got) != tt.wantData {
+				t.Errorf("data = %q, want %q", got, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestFaults_QueueAndAlways(t *testing.T) {
+	storage := coretest.NewMemoryStorage()
+	ctx := context.Background()
+	first, second, always := errors.New("first"), errors.New("second"), errors.New("always")
+
+	storage.Queue(coretest.OpList, coretest.Fault{Err: first}, coretest.Fault{}, coretest.Fault{Err: second})
+	storage.Always(coretest.OpList, coretest.Fault{Err: always})
+
+	// Queued faults apply one per call, in order, then the one set with Always
+	for i, want := range []error{first, nil, second, always, always} {
+		if _, err := storage.List(ctx); !errors.Is(err, want) {
+			t.Errorf("call %d: error = %v, want %v", i+1, err, want)
+		}
+	}
+	if calls := storage.Calls(coretest.OpList); calls != 5 {
+		t.Errorf("Calls() = %d, want 5", calls)
+	}
+
+	// Other operations are unaffected
+	if err := storage.Upload(ctx, strings.NewReader("data"), testMetadata(15)); err != nil {
+		t.Errorf("Upload() error = %v", err)
+	}
+
+	storage.Reset()
+	if _, err := storage.List(ctx); err != nil {
+		t.Errorf("List() after Reset() error = %v", err)
+	}
+}
+
+func TestFaults_DelayHonoursContext(t *testing.T) {
+	storage := coretest.NewMemoryStorage()
+	storage.Queue(coretest.OpList, coretest.Fault{Delay: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := storage.List(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("List() error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestMemoryStorage_Registered(t *testing.T) {
+	t.Cleanup(coretest.ResetSharedMemoryStorages)
+
+	shared := coretest.SharedMemoryStorage("backups")
+	shared.Put("existing.dump", []byte("data"), &core.BackupMetadata{})
+
+	provider, err := core.GetStorage(context.Background(), "memory", &core.StorageConfig{Type: "memory", Bucket: "backups"})
+	if err != nil {
+		t.Fatalf("GetStorage() error = %v", err)
+	}
+	backups, err := provider.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 || backups[0].ID != "existing.dump" {
+		t.Errorf("expected the shared storage's backups, got %+v", backups)
+	}
+
+	// Other buckets are separate
+	other, err := core.GetStorage(context.Background(), "memory", &core.StorageConfig{Type: "memory", Bucket: "other"})
+	if err != nil {
+		t.Fatalf("GetStorage() error = %v", err)
+	}
+	if backups, _ := other.List(context.Background()); len(backups) != 0 {
+		t.Errorf("expected no backups in another bucket, got %d", len(backups))
+	}
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Manifests are the .meta files stored next to backups by providers that
+// cannot attach metadata to files, such as disk, SFTP, FTP and WebDAV. They
+// share one format, so backups copied between them keep their details.
+
+// manifestTagPrefix prefixes the keys of tag lines in manifests
+const manifestTagPrefix = "Tag."
+
+// FormatManifest renders the manifest stored next to a backup
+func FormatManifest(metadata *BackupMetadata) string {
+	var b strings.Builder
+	fmt.Fprintf(&b,
+		"ID: %s\nDatabaseName: %s\nDatabaseType: %s\nTimestamp: %s\nSize: %d\nChecksum: %s\nBackupMode: %s\n",
+		metadata.ID,
+		metadata.DatabaseName,
+		metadata.DatabaseType,
+		metadata.Timestamp.Format(time.RFC3339),
+		metadata.Size,
+		metadata.Checksum,
+		metadata.BackupMode,
+	)
+
+	keys := make([]string, 0, len(metadata.Tags))
+	for key := range metadata.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s%s: %s\n", manifestTagPrefix, key, metadata.Tags[key])
+	}
+
+	return b.String()
+}
+
+// ParseManifest copies the details in a manifest into backup. The ID and
+// size are left alone: the ID stays the filename, which Download and Delete
+// expect, and the size that of the stored file.
+func ParseManifest(backup *BackupMetadata, content string) {
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "DatabaseName":
+			backup.DatabaseName = value
+		case "DatabaseType":
+			backup.DatabaseType = value
+		case "Checksum":
+			backup.Checksum = value
+		case "BackupMode":
+			backup.BackupMode = value
+		case "Timestamp":
+			if t, err := time.Parse(time.RFC3339, value); err == nil {
+				backup.Timestamp = t
+			}
+		default:
+			if tag, ok := strings.CutPrefix(key, manifestTagPrefix); ok {
+				if backup.Tags == nil {
+					backup.Tags = make(map[string]string)
+				}
+				backup.Tags[tag] = value
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package core_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"goarchive/core"
+)
+
+func TestManifest(t *testing.T) {
+	metadata := &core.BackupMetadata{
+		ID:           "orders_postgres_20260215-103020.dump",
+		DatabaseName: "orders",
+		DatabaseType: "postgres",
+		Timestamp:    time.Date(2026, 2, 15, 10, 30, 20, 0, time.UTC),
+		Size:         1024,
+		Checksum:     "d41d8cd98f00b204e9800998ecf8427e",
+		BackupMode:   "logical",
+		Tags:         map[string]string{"format": "2", "chunks": "3"},
+	}
+
+	manifest := core.FormatManifest(metadata)
+	want := "ID: orders_postgres_20260215-103020.dump\nDatabaseName: orders\nDatabaseType: postgres\n" +
+		"Timestamp: 2026-02-15T10:30:20Z\nSize: 1024\nChecksum: d41d8cd98f00b204e9800998ecf8427e\n" +
+		"BackupMode: logical\nTag.chunks: 3\nTag.format: 2\n"
+	if manifest != want {
+		t.Errorf("FormatManifest() =\n%s\nwant\n%s", manifest, want)
+	}
+
+	// The ID and size stay those of the stored file
+	backup := &core.BackupMetadata{ID: "renamed.dump", Size: 2048}
+	core.ParseManifest(backup, manifest)
+	parsed := *metadata
+	parsed.ID, parsed.Size = "renamed.dump", 2048
+	if !reflect.DeepEqual(*backup, parsed) {
+		t.Errorf("ParseManifest() = %+v, want %+v", backup, &parsed)
+	}
+}
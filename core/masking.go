@@ -0,0 +1,346 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Masking strategies
+const (
+	// MaskHash replaces a value with its keyed SHA-256 hash in hex
+	MaskHash = "hash"
+
+	// MaskNull replaces a value with NULL
+	MaskNull = "null"
+
+	// MaskFake replaces a value with a realistic fake of the rule's Fake kind
+	MaskFake = "fake"
+
+	// MaskScramble replaces letters and digits while keeping the format
+	MaskScramble = "scramble"
+)
+
+// fakeGenerators produce fake values of each kind from a keystream
+var fakeGenerators = map[string]func(next func() uint32) string{
+	"first_name": func(next func() uint32) string { return pick(fakeFirstNames, next) },
+	"last_name":  func(next func() uint32) string { return pick(fakeLastNames, next) },
+	"name": func(next func() uint32) string {
+		return pick(fakeFirstNames, next) + " " + pick(fakeLastNames, next)
+	},
+	"email": func(next func() uint32) string {
+		// The suffix keeps fakes of distinct values distinct for unique columns
+		return fmt.Sprintf("%s.%s.%08x%08x@example.com", strings.ToLower(pick(fakeFirstNames, next)),
+			strings.ToLower(pick(fakeLastNames, next)), next(), next())
+	},
+	"phone": func(next func() uint32) string {
+		return fmt.Sprintf("+1 555 %03d %04d", next()%1000, next()%10000)
+	},
+	"city": func(next func() uint32) string { return pick(fakeCities, next) },
+}
+
+var (
+	fakeFirstNames = []string{"Ada", "Alan", "Barbara", "Claude", "Donald", "Edsger", "Frances", "Grace",
+		"Hedy", "John", "Katherine", "Ken", "Linus", "Margaret", "Niklaus", "Radia", "Shafi", "Tim"}
+	fakeLastNames = []string{"Allen", "Backus", "Cerf", "Dijkstra", "Hamilton", "Hopper", "Johnson", "Kay",
+		"Knuth", "Lamarr", "Liskov", "Lovelace", "Perlman", "Ritchie", "Shannon", "Thompson", "Turing", "Wirth"}
+	fakeCities = []string{"Springfield", "Riverside", "Fairview", "Franklin", "Greenville", "Bristol",
+		"Clinton", "Georgetown", "Salem", "Madison", "Arlington", "Ashland", "Oxford", "Milton"}
+)
+
+// MaskingProfile lists the columns to transform when copying data, e.g. from
+// production into staging
+type MaskingProfile struct {
+	Salt  string        `json:"salt"` // Secret keying hashes and fakes; random per run when empty
+	Rules []MaskingRule `json:"rules"`
+}
+
+// MaskingRule masks one column
+type MaskingRule struct {
+	Table    string `json:"table"` // "schema.table", or "table" to match any schema
+	Column   string `json:"column"`
+	Strategy string `json:"strategy"`         // hash, null, fake or scramble
+	Fake     string `json:"fake,omitempty"`   // Kind of fake value: first_name, last_name, name, email, phone or city
+	Length   int    `json:"length,omitempty"` // Truncate hashes to this many characters
+}
+
+// LoadMaskingProfile reads and validates a JSON masking profile
+func LoadMaskingProfile(path string) (*MaskingProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read masking profile: %w", err)
+	}
+
+	var profile MaskingProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse masking profile %s: %w", path, err)
+	}
+
+	if err := profile.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid masking profile %s: %w", path, err)
+	}
+
+	return &profile, nil
+}
+
+// Validate checks the profile's rules
+func (p *MaskingProfile) Validate() error {
+	if len(p.Rules) == 0 {
+		return fmt.Errorf("no masking rules")
+	}
+
+	seen := make(map[string]bool)
+	for i, rule := range p.Rules {
+		if rule.Table == "" || rule.Column == "" {
+			return fmt.Errorf("rule %d: table and column are required", i+1)
+		}
+
+		key := rule.Table + "." + rule.Column
+		if seen[key] {
+			return fmt.Errorf("rule %d: duplicate rule for %s", i+1, key)
+		}
+		seen[key] = true
+
+		switch rule.Strategy {
+		case MaskHash, MaskNull, MaskScramble:
+		case MaskFake:
+			if _, ok := fakeGenerators[rule.Fake]; !ok {
+				return fmt.Errorf("rule %d: unknown fake kind %q", i+1, rule.Fake)
+			}
+		default:
+			return fmt.Errorf("rule %d: unknown masking strategy %q", i+1, rule.Strategy)
+		}
+
+		if rule.Length < 0 {
+			return fmt.Errorf("rule %d: length cannot be negative", i+1)
+		}
+	}
+
+	return nil
+}
+
+// ValueMasker masks one value of a column; null reports a NULL value
+type ValueMasker func(value string, null bool) (string, bool)
+
+// Masker applies a masking profile and counts the values it transforms. It is
+// not safe for concurrent use.
+type Masker struct {
+	key           []byte
+	deterministic bool
+	rules         []MaskingRule
+	columns       []*MaskedColumn
+	matched       []bool
+}
+
+// NewMasker prepares a profile for masking. Without a salt, a random key is
+// used, so fakes and hashes are only consistent within one run.
+func NewMasker(profile *MaskingProfile) (*Masker, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+
+	m := &Masker{
+		key:           []byte(profile.Salt),
+		deterministic: profile.Salt != "",
+		rules:         profile.Rules,
+		matched:       make([]bool, len(profile.Rules)),
+	}
+
+	if !m.deterministic {
+		m.key = make([]byte, 32)
+		if _, err := rand.Read(m.key); err != nil {
+			return nil, fmt.Errorf("failed to generate masking key: %w", err)
+		}
+	}
+
+	return m, nil
+}
+
+// Rule returns the rule for a column, or nil if the column is not masked
+func (m *Masker) Rule(schema, table, column string) *MaskingRule {
+	for i := range m.rules {
+		rule := &m.rules[i]
+		if rule.Column != column {
+			continue
+		}
+		if ruleSchema, ruleTable, ok := strings.Cut(rule.Table, "."); ok {
+			if ruleSchema == schema && ruleTable == table {
+				return rule
+			}
+		} else if rule.Table == table {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Column returns a masker for a column's values, or nil if the column is not
+// masked. Every value passed to it is counted in the report.
+func (m *Masker) Column(schema, table, column string) ValueMasker {
+	rule := m.Rule(schema, table, column)
+	if rule == nil {
+		return nil
+	}
+
+	for i := range m.rules {
+		if &m.rules[i] == rule {
+			m.matched[i] = true
+		}
+	}
+
+	masked := &MaskedColumn{
+		Schema:   schema,
+		Table:    table,
+		Column:   column,
+		Strategy: rule.Strategy,
+		Fake:     rule.Fake,
+	}
+	m.columns = append(m.columns, masked)
+
+	return func(value string, null bool) (string, bool) {
+		masked.Rows++
+		if rule.Strategy == MaskNull {
+			return "", true
+		}
+		if null {
+			return "", true
+		}
+		return m.mask(rule, value), false
+	}
+}
+
+// mask transforms a non-NULL value according to rule
+func (m *Masker) mask(rule *MaskingRule, value string) string {
+	switch rule.Strategy {
+	case MaskHash:
+		mac := hmac.New(sha256.New, m.key)
+		mac.Write([]byte(value))
+		hash := hex.EncodeToString(mac.Sum(nil))
+		if rule.Length > 0 && rule.Length < len(hash) {
+			hash = hash[:rule.Length]
+		}
+		return hash
+	case MaskFake:
+		return fakeGenerators[rule.Fake](m.keystream(value))
+	case MaskScramble:
+		return scramble(value, m.keystream(value))
+	default:
+		return value
+	}
+}
+
+// keystream returns a deterministic pseudo-random sequence derived from the
+// key and value, so equal values mask to equal results across tables
+func (m *Masker) keystream(value string) func() uint32 {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write([]byte(value))
+	seed := mac.Sum(nil)
+
+	var block []byte
+	var counter uint64
+	return func() uint32 {
+		if len(block) == 0 {
+			h := sha256.New()
+			h.Write(seed)
+			binary.Write(h, binary.BigEndian, counter)
+			block = h.Sum(nil)
+			counter++
+		}
+		n := binary.BigEndian.Uint32(block)
+		block = block[4:]
+		return n
+	}
+}
+
+// Unmatched returns the rules that have not matched any column, which
+// usually means a column was renamed and its data would leak unmasked
+func (m *Masker) Unmatched() []string {
+	var unmatched []string
+	for i, rule := range m.rules {
+		if !m.matched[i] {
+			unmatched = append(unmatched, rule.Table+"."+rule.Column)
+		}
+	}
+	return unmatched
+}
+
+// Report returns the columns masked so far
+func (m *Masker) Report() *MaskingReport {
+	columns := make([]MaskedColumn, len(m.columns))
+	for i, column := range m.columns {
+		columns[i] = *column
+	}
+
+	return &MaskingReport{
+		GeneratedAt:   time.Now().UTC(),
+		Deterministic: m.deterministic,
+		Columns:       columns,
+	}
+}
+
+// MaskingReport records which columns were transformed, for audits
+type MaskingReport struct {
+	Profile       string         `json:"profile,omitempty"`
+	Database      string         `json:"database,omitempty"`
+	Operation     string         `json:"operation,omitempty"` // backup or restore
+	GeneratedAt   time.Time      `json:"generated_at"`
+	Deterministic bool           `json:"deterministic"` // A salt was configured
+	Columns       []MaskedColumn `json:"columns"`
+}
+
+// MaskedColumn is a column transformed by a masking rule
+type MaskedColumn struct {
+	Schema   string `json:"schema"`
+	Table    string `json:"table"`
+	Column   string `json:"column"`
+	Strategy string `json:"strategy"`
+	Fake     string `json:"fake,omitempty"`
+	Rows     int64  `json:"rows"`
+}
+
+// WriteFile writes the report as indented JSON
+func (r *MaskingReport) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode masking report: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write masking report: %w", err)
+	}
+
+	return nil
+}
+
+// scramble replaces every letter and digit with a random one of the same
+// class, keeping case, punctuation and length
+func scramble(value string, next func() uint32) string {
+	var b strings.Builder
+	b.Grow(len(value))
+	for _, r := range value {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteByte(byte('0' + next()%10))
+		case unicode.IsUpper(r):
+			b.WriteByte(byte('A' + next()%26))
+		case unicode.IsLetter(r):
+			b.WriteByte(byte('a' + next()%26))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// pick returns a pseudo-random element of list
+func pick(list []string, next func() uint32) string {
+	return list[next()%uint32(len(list))]
+}
@@ -0,0 +1,214 @@
+package core_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"goarchive/core"
+)
+
+func TestLoadMaskingProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		wantErr string
+	}{
+		{
+			name: "valid profile",
+			profile: `{"salt": "s3cret", "rules": [
+				{"table": "public.users", "column": "email", "strategy": "fake", "fake": "email"},
+				{"table": "users", "column": "ssn", "strategy": "scramble"},
+				{"table": "users", "column": "notes", "strategy": "null"},
+				{"table": "orders", "column": "card", "strategy": "hash", "length": 12}
+			]}`,
+		},
+		{
+			name:    "no rules",
+			profile: `{"rules": []}`,
+			wantErr: "no masking rules",
+		},
+		{
+			name:    "unknown strategy",
+			profile: `{"rules": [{"table": "users", "column": "email", "strategy": "encrypt"}]}`,
+			wantErr: `unknown masking strategy "encrypt"`,
+		},
+		{
+			name:    "unknown fake kind",
+			profile: `{"rules": [{"table": "users", "column": "email", "strategy": "fake", "fake": "iban"}]}`,
+			wantErr: `unknown fake kind "iban"`,
+		},
+		{
+			name: "duplicate rule",
+			profile: `{"rules": [
+				{"table": "users", "column": "email", "strategy": "null"},
+				{"table": "users", "column": "email", "strategy": "hash"}
+			]}`,
+			wantErr: "duplicate rule for users.email",
+		},
+		{
+			name:    "missing column",
+			profile: `{"rules": [{"table": "users", "strategy": "null"}]}`,
+			wantErr: "table and column are required",
+		},
+		{
+			name:    "invalid JSON",
+			profile: `{"rules": [`,
+			wantErr: "failed to parse masking profile",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "profile.json")
+			if err := os.WriteFile(path, []byte(tt.profile), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := core.LoadMaskingProfile(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadMaskingProfile() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadMaskingProfile() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMasker(t *testing.T) {
+	profile := &core.MaskingProfile{
+		Salt: "s3cret",
+		Rules: []core.MaskingRule{
+			{Table: "public.users", Column: "email", Strategy: core.MaskFake, Fake: "email"},
+			{Table: "users", Column: "phone", Strategy: core.MaskScramble},
+			{Table: "users", Column: "notes", Strategy: core.MaskNull},
+			{Table: "orders", Column: "card", Strategy: core.MaskHash, Length: 12},
+			{Table: "audit", Column: "ip", Strategy: core.MaskHash},
+		},
+	}
+
+	masker, err := core.NewMasker(profile)
+	if err != nil {
+		t.Fatalf("NewMasker() error = %v", err)
+	}
+
+	if masker.Column("public", "users", "id") != nil {
+		t.Error("expected unmasked column to have no masker")
+	}
+	if masker.Column("billing", "users", "email") != nil {
+		t.Error("expected schema-qualified rule not to match another schema")
+	}
+
+	t.Run("fake", func(t *testing.T) {
+		email := masker.Column("public", "users", "email")
+		got, null := email("ada@example.org", false)
+		if null || !regexp.MustCompile(`^[a-z]+\.[a-z]+\.[0-9a-f]{16}@example\.com$`).MatchString(got) {
+			t.Errorf("unexpected fake email %q", got)
+		}
+
+		// Equal values mask to equal fakes, so joins on masked columns still work
+		again, _ := email("ada@example.org", false)
+		if again != got {
+			t.Errorf("expected deterministic fake, got %q and %q", got, again)
+		}
+
+		if _, null := email("", true); !null {
+			t.Error("expected NULL to stay NULL")
+		}
+	})
+
+	t.Run("scramble", func(t *testing.T) {
+		got, _ := masker.Column("sales", "users", "phone")("+1 (555) 010-Ab99", false)
+		if !regexp.MustCompile(`^\+\d \(\d{3}\) \d{3}-[A-Z][a-z]\d{2}$`).MatchString(got) {
+			t.Errorf("expected scramble to keep the format, got %q", got)
+		}
+		if got == "+1 (555) 010-Ab99" {
+			t.Error("expected scramble to change the value")
+		}
+	})
+
+	t.Run("null", func(t *testing.T) {
+		if _, null := masker.Column("public", "users", "notes")("private", false); !null {
+			t.Error("expected value to be replaced with NULL")
+		}
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		got, _ := masker.Column("public", "orders", "card")("4111111111111111", false)
+		if !regexp.MustCompile(`^[0-9a-f]{12}$`).MatchString(got) {
+			t.Errorf("expected 12 character hash, got %q", got)
+		}
+
+		other, err := core.NewMasker(&core.MaskingProfile{Salt: "other", Rules: profile.Rules})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if salted, _ := other.Column("public", "orders", "card")("4111111111111111", false); salted == got {
+			t.Error("expected a different salt to give a different hash")
+		}
+	})
+
+	if unmatched := masker.Unmatched(); len(unmatched) != 1 || unmatched[0] != "audit.ip" {
+		t.Errorf("expected audit.ip to be unmatched, got %v", unmatched)
+	}
+
+	report := masker.Report()
+	if !report.Deterministic {
+		t.Error("expected report to be deterministic with a salt")
+	}
+	rows := make(map[string]int64)
+	for _, column := range report.Columns {
+		rows[column.Schema+"."+column.Table+"."+column.Column] = column.Rows
+	}
+	if rows["public.users.email"] != 3 || rows["sales.users.phone"] != 1 || rows["public.orders.card"] != 1 {
+		t.Errorf("unexpected report row counts: %v", rows)
+	}
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	report.Operation = "backup"
+	if err := report.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded core.MaskingReport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if decoded.Operation != "backup" || len(decoded.Columns) != len(report.Columns) {
+		t.Errorf("unexpected decoded report: %+v", decoded)
+	}
+}
+
+func TestMasker_RandomKeyWithoutSalt(t *testing.T) {
+	profile := &core.MaskingProfile{
+		Rules: []core.MaskingRule{{Table: "users", Column: "email", Strategy: core.MaskHash}},
+	}
+
+	first, err := core.NewMasker(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := core.NewMasker(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := first.Column("public", "users", "email")("ada@example.org", false)
+	b, _ := second.Column("public", "users", "email")("ada@example.org", false)
+	if a == b {
+		t.Error("expected unsalted runs to use different keys")
+	}
+	if first.Report().Deterministic {
+		t.Error("expected report not to be deterministic without a salt")
+	}
+}
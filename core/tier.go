@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tierer is implemented by storage providers that keep backups in several
+// storage tiers, such as S3 storage classes
+type Tierer interface {
+	// Tier moves a backup to the named storage tier. Tier names are
+	// provider-specific; backups already in the tier are left as they are.
+	Tier(ctx context.Context, backupID string, tier string) error
+}
+
+// TierRule moves backups older than After to Tier
+type TierRule struct {
+	After time.Duration
+	Tier  string
+}
+
+// TierPolicy assigns storage tiers to backups by age. Each backup belongs
+// in the tier of the rule with the longest After it is older than.
+type TierPolicy []TierRule
+
+// ParseTierPolicy parses comma-separated age=tier rules, such as
+// "30d=GLACIER,180d=DEEP_ARCHIVE". Ages are a number of days followed by
+// "d", or a Go duration such as "12h".
+func ParseTierPolicy(spec string) (TierPolicy, error) {
+	var policy TierPolicy
+	for _, rule := range strings.Split(spec, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		age, tier, ok := strings.Cut(rule, "=")
+		tier = strings.TrimSpace(tier)
+		if !ok || tier == "" {
+			return nil, fmt.Errorf("invalid tier rule %q: use age=tier, such as 30d=GLACIER", rule)
+		}
+
+		after, err := parseAge(strings.TrimSpace(age))
+		if err != nil {
+			return nil, fmt.Errorf("invalid tier rule %q: %w", rule, err)
+		}
+		policy = append(policy, TierRule{After: after, Tier: tier})
+	}
+
+	if len(policy) == 0 {
+		return nil, fmt.Errorf("tier policy is required")
+	}
+
+	sort.SliceStable(policy, func(i, j int) bool {
+		return policy[i].After < policy[j].After
+	})
+	return policy, nil
+}
+
+// parseAge parses a number of days followed by "d", or a Go duration
+func parseAge(value string) (time.Duration, error) {
+	var age time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+		age = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if age, err = time.ParseDuration(value); err != nil {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+	}
+
+	if age < 0 {
+		return 0, fmt.Errorf("age %q cannot be negative", value)
+	}
+	return age, nil
+}
+
+// TierFor returns the tier for a backup of the given age, or an empty
+// string when the backup is too recent for any rule
+func (p TierPolicy) TierFor(age time.Duration) string {
+	tier := ""
+	after := time.Duration(-1)
+	for _, rule := range p {
+		if age >= rule.After && rule.After > after {
+			tier, after = rule.Tier, rule.After
+		}
+	}
+	return tier
+}
+
+// TierMove is a backup that ApplyTierPolicy moved, or would move
+type TierMove struct {
+	Backup *BackupMetadata
+	Tier   string // Tier the backup moves to
+}
+
+// ApplyTierPolicy moves the backups in storage to the tiers the policy
+// assigns to their age at now. Backups whose listed tier already matches
+// are skipped. With dryRun, the moves are only returned. A backup that
+// fails to move does not stop the others; the failures are returned
+// together with the moves that succeeded.
+func ApplyTierPolicy(ctx context.Context, storage StorageProvider, policy TierPolicy, now time.Time, dryRun bool) ([]TierMove, error) {
+	tierer, ok := storage.(Tierer)
+	if !ok {
+		return nil, fmt.Errorf("storage provider does not support tiering")
+	}
+
+	backups, err := storage.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var moves []TierMove
+	var errs []error
+	for _, backup := range backups {
+		tier := policy.TierFor(now.Sub(backup.Timestamp))
+		if tier == "" || strings.EqualFold(tier, backup.Tier) {
+			continue
+		}
+
+		if !dryRun {
+			if err := tierer.Tier(ctx, backup.ID, tier); err != nil {
+				errs = append(errs, fmt.Errorf("failed to move %s to %s: %w", backup.ID, tier, err))
+				continue
+			}
+		}
+		moves = append(moves, TierMove{Backup: backup, Tier: tier})
+	}
+
+	return moves, errors.Join(errs...)
+}
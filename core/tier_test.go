@@ -0,0 +1,188 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"goarchive/core"
+	"goarchive/core/coretest"
+)
+
+func TestParseTierPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    core.TierPolicy
+		wantErr string
+	}{
+		{
+			name: "days",
+			spec: "30d=GLACIER",
+			want: core.TierPolicy{{After: 30 * 24 * time.Hour, Tier: "GLACIER"}},
+		},
+		{
+			name: "several rules, sorted by age",
+			spec: "180d=DEEP_ARCHIVE, 30d=GLACIER",
+			want: core.TierPolicy{
+				{After: 30 * 24 * time.Hour, Tier: "GLACIER"},
+				{After: 180 * 24 * time.Hour, Tier: "DEEP_ARCHIVE"},
+			},
+		},
+		{
+			name: "go duration",
+			spec: "36h=archive",
+			want: core.TierPolicy{{After: 36 * time.Hour, Tier: "archive"}},
+		},
+		{name: "empty", spec: "", wantErr: "tier policy is required"},
+		{name: "missing tier", spec: "30d=", wantErr: "use age=tier"},
+		{name: "missing age", spec: "GLACIER", wantErr: "use age=tier"},
+		{name: "invalid days", spec: "lots d=GLACIER", wantErr: "invalid age"},
+		{name: "invalid duration", spec: "30 days=GLACIER", wantErr: "invalid age"},
+		{name: "negative age", spec: "-1d=GLACIER", wantErr: "cannot be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := core.ParseTierPolicy(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseTierPolicy() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTierPolicy() error = %v", err)
+			}
+			if len(policy) != len(tt.want) {
+				t.Fatalf("ParseTierPolicy() = %v, want %v", policy, tt.want)
+			}
+			for i := range policy {
+				if policy[i] != tt.want[i] {
+					t.Errorf("rule %d = %v, want %v", i, policy[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTierPolicy_TierFor(t *testing.T) {
+	policy, err := core.ParseTierPolicy("30d=GLACIER,180d=DEEP_ARCHIVE")
+	if err != nil {
+		t.Fatalf("ParseTierPolicy() error = %v", err)
+	}
+
+	day := 24 * time.Hour
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{age: 0, want: ""},
+		{age: 29 * day, want: ""},
+		{age: 30 * day, want: "GLACIER"},
+		{age: 179 * day, want: "GLACIER"},
+		{age: 180 * day, want: "DEEP_ARCHIVE"},
+		{age: 1000 * day, want: "DEEP_ARCHIVE"},
+	}
+
+	for _, tt := range tests {
+		if got := policy.TierFor(tt.age); got != tt.want {
+			t.Errorf("TierFor(%v) = %q, want %q", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestApplyTierPolicy(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	policy := core.TierPolicy{
+		{After: 30 * 24 * time.Hour, Tier: "GLACIER"},
+		{After: 180 * 24 * time.Hour, Tier: "DEEP_ARCHIVE"},
+	}
+
+	newStorage := func() *coretest.MemoryStorage {
+		storage := coretest.NewMemoryStorage()
+		storage.Put("recent.dump", []byte("data"), &core.BackupMetadata{Timestamp: now.AddDate(0, 0, -3), Tier: "STANDARD"})
+		storage.Put("month.dump", []byte("data"), &core.BackupMetadata{Timestamp: now.AddDate(0, 0, -40), Tier: "STANDARD"})
+		storage.Put("moved.dump", []byte("data"), &core.BackupMetadata{Timestamp: now.AddDate(0, 0, -50), Tier: "glacier"})
+		storage.Put("year.dump", []byte("data"), &core.BackupMetadata{Timestamp: now.AddDate(-1, 0, 0), Tier: "GLACIER"})
+		return storage
+	}
+
+	tiers := func(storage *coretest.MemoryStorage) map[string]string {
+		backups, err := storage.List(ctx)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		tiers := make(map[string]string)
+		for _, backup := range backups {
+			tiers[backup.ID] = backup.Tier
+		}
+		return tiers
+	}
+
+	t.Run("moves backups", func(t *testing.T) {
+		storage := newStorage()
+
+		moves, err := core.ApplyTierPolicy(ctx, storage, policy, now, false)
+		if err != nil {
+			t.Fatalf("ApplyTierPolicy() error = %v", err)
+		}
+		if len(moves) != 2 {
+			t.Errorf("expected 2 moves, got %+v", moves)
+		}
+
+		want := map[string]string{"recent.dump": "STANDARD", "month.dump": "GLACIER", "moved.dump": "glacier", "year.dump": "DEEP_ARCHIVE"}
+		for id, tier := range tiers(storage) {
+			if tier != want[id] {
+				t.Errorf("%s is in tier %q, want %q", id, tier, want[id])
+			}
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		storage := newStorage()
+
+		moves, err := core.ApplyTierPolicy(ctx, storage, policy, now, true)
+		if err != nil {
+			t.Fatalf("ApplyTierPolicy() error = %v", err)
+		}
+		if len(moves) != 2 {
+			t.Errorf("expected 2 moves, got %+v", moves)
+		}
+		if storage.Calls(coretest.OpTier) != 0 {
+			t.Error("expected no backup to be moved")
+		}
+	})
+
+	t.Run("failures do not stop other moves", func(t *testing.T) {
+		storage := newStorage()
+		storage.Queue(coretest.OpTier, coretest.Fault{Err: errors.New("access denied")})
+
+		moves, err := core.ApplyTierPolicy(ctx, storage, policy, now, false)
+		if err == nil || !strings.Contains(err.Error(), "access denied") {
+			t.Errorf("expected the failure, got %v", err)
+		}
+		if len(moves) != 1 || storage.Calls(coretest.OpTier) != 2 {
+			t.Errorf("expected the other backup to move, got %+v", moves)
+		}
+	})
+
+	t.Run("list fails", func(t *testing.T) {
+		storage := newStorage()
+		storage.Queue(coretest.OpList, coretest.Fault{Err: errors.New("bucket unavailable")})
+
+		if _, err := core.ApplyTierPolicy(ctx, storage, policy, now, false); err == nil || !strings.Contains(err.Error(), "failed to list backups") {
+			t.Errorf("expected the list failure, got %v", err)
+		}
+	})
+
+	t.Run("storage without tiers", func(t *testing.T) {
+		_, err := core.ApplyTierPolicy(ctx, &mockStorageProvider{}, policy, now, false)
+		if err == nil || !strings.Contains(err.Error(), "does not support tiering") {
+			t.Errorf("expected an unsupported error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,364 @@
+package badger
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	badgerdb "github.com/dgraph-io/badger/v4"
+
+	"goarchive/core"
+)
+
+// loadPendingWrites is the number of pending writes while loading a backup,
+// as used by the badger command-line tool
+const loadPendingWrites = 256
+
+// maxFrameSize bounds the size of a single entry list in a backup. Badger
+// trusts the length prefixes and allocates what they claim, so damaged
+// backups are rejected before they reach it.
+const maxFrameSize = 4 << 30
+
+// init registers the Badger provider with the global registry
+func init() {
+	core.RegisterDatabase("badger", func(config *core.DatabaseConfig) (core.DatabaseProvider, error) {
+		return New(config)
+	})
+}
+
+// Provider implements the DatabaseProvider interface for BadgerDB
+// directories
+type Provider struct {
+	config *core.DatabaseConfig
+	path   string
+	db     *badgerdb.DB // Database opened by the application, nil when opened per operation
+}
+
+// New creates a new Badger provider for the database directory at
+// config.Path. The directory may not exist yet, so that a backup can be
+// restored into a new one, but its parent must.
+func New(config *core.DatabaseConfig) (*Provider, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("database path is required")
+	}
+
+	path, err := filepath.Abs(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database path: %w", err)
+	}
+
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("database directory does not exist: %s", filepath.Dir(path))
+	}
+
+	return &Provider{config: config, path: path}, nil
+}
+
+// NewFromDB creates a Badger provider backing up a database the application
+// already has open, which other processes cannot open while it does.
+// Restores are refused, as the directory cannot be replaced under an open
+// database; close it and restore with a provider from New.
+func NewFromDB(config *core.DatabaseConfig, db *badgerdb.DB) (*Provider, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database is required")
+	}
+	return &Provider{config: config, path: db.Opts().Dir, db: db}, nil
+}
+
+// open opens the database directory read-only, or returns the
+// application's database. release closes what open opened.
+func (p *Provider) open() (db *badgerdb.DB, release func(), err error) {
+	if p.db != nil {
+		return p.db, func() {}, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(p.path, badgerdb.ManifestFilename)); err != nil {
+		return nil, nil, fmt.Errorf("database not found: %w", err)
+	}
+
+	db, err = badgerdb.Open(badgerdb.DefaultOptions(p.path).WithReadOnly(true).WithLogger(nil))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, func() { db.Close() }, nil
+}
+
+// Backup streams every live entry in Badger's backup format, written with
+// DB.Backup from a consistent read timestamp
+func (p *Provider) Backup(ctx context.Context) (io.ReadCloser, error) {
+	if p.config.BackupMode != "" {
+		return nil, fmt.Errorf("unsupported backup mode: %s", p.config.BackupMode)
+	}
+
+	db, release, err := p.open()
+	if err != nil {
+		return nil, err
+	}
+
+	// The backup reads at the latest version, so entries written while it
+	// streams are left out
+	tags := map[string]string{"max-version": strconv.FormatUint(db.MaxVersion(), 10)}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer release()
+
+		_, err := db.Backup(&contextWriter{ctx: ctx, w: pw}, 0)
+		if err != nil {
+			err = fmt.Errorf("failed to back up database: %w", err)
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return &backupReader{PipeReader: pr, done: done, tags: tags}, nil
+}
+
+// Restore replaces the database directory with a backup
+func (p *Provider) Restore(ctx context.Context, reader io.Reader) error {
+	return p.RestoreWithOptions(ctx, reader, &core.RestoreOptions{})
+}
+
+// RestoreWithOptions loads the backup into a new directory next to the
+// target and swaps it with the target once complete. TargetDatabase names a
+// different directory to restore into. Applications using the database must
+// close it first.
+func (p *Provider) RestoreWithOptions(ctx context.Context, reader io.Reader, opts *core.RestoreOptions) error {
+	if opts == nil {
+		opts = &core.RestoreOptions{}
+	}
+
+	switch {
+	case p.db != nil:
+		return fmt.Errorf("cannot restore into a database the application has open; close it and restore by path")
+	case opts.BackupMode != "":
+		return fmt.Errorf("unsupported backup mode: %s", opts.BackupMode)
+	case opts.Jobs > 1:
+		return fmt.Errorf("parallel restore is not supported by the badger provider")
+	case opts.Selective() || opts.TargetSchema != "":
+		return fmt.Errorf("selective restore is not supported by the badger provider")
+	}
+
+	target := p.path
+	if opts.TargetDatabase != "" {
+		abs, err := filepath.Abs(opts.TargetDatabase)
+		if err != nil {
+			return fmt.Errorf("invalid target database path: %w", err)
+		}
+		target = abs
+	}
+
+	if err := checkTarget(target); err != nil {
+		return err
+	}
+
+	temp, err := os.MkdirTemp(filepath.Dir(target), "."+filepath.Base(target)+".restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(temp)
+
+	if err := load(ctx, reader, temp); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	return swap(temp, target)
+}
+
+// checkTarget fails unless target is missing, empty or a Badger database,
+// so a restore never replaces an unrelated directory
+func checkTarget(target string) error {
+	entries, err := os.ReadDir(target)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(entries) == 0) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read target directory: %w", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(target, badgerdb.ManifestFilename)); err != nil {
+		return fmt.Errorf("refusing to replace %s: not a Badger database", target)
+	}
+	return nil
+}
+
+// load writes the backup into a new database in dir
+func load(ctx context.Context, reader io.Reader, dir string) error {
+	db, err := badgerdb.Open(badgerdb.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		return fmt.Errorf("failed to create database: %w", err)
+	}
+
+	err = db.Load(&frameReader{r: &contextReader{ctx: ctx, r: reader}}, loadPendingWrites)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load backup: %w", err)
+	}
+	return nil
+}
+
+// swap moves dir to target. An existing target is moved aside first and
+// put back if the move fails.
+func swap(dir, target string) error {
+	old := ""
+	if _, err := os.Stat(target); err == nil {
+		old = dir + ".old"
+		if err := os.Rename(target, old); err != nil {
+			return fmt.Errorf("failed to replace database directory: %w", err)
+		}
+	}
+
+	if err := os.Rename(dir, target); err != nil {
+		if old != "" {
+			os.Rename(old, target)
+		}
+		return fmt.Errorf("failed to replace database directory: %w", err)
+	}
+
+	if old != "" {
+		if err := os.RemoveAll(old); err != nil {
+			return fmt.Errorf("failed to remove previous database: %w", err)
+		}
+	}
+
+	// Persist the renames
+	if parent, err := os.Open(filepath.Dir(target)); err == nil {
+		parent.Sync()
+		parent.Close()
+	}
+
+	return nil
+}
+
+// GetMetadata returns the size of the LSM tree and value log and the
+// latest version in the database
+func (p *Provider) GetMetadata() (*core.DatabaseMetadata, error) {
+	db, release, err := p.open()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	lsm, vlog := db.Size()
+
+	return &core.DatabaseMetadata{
+		Type: "badger",
+		Size: lsm + vlog,
+		Name: filepath.Base(p.path),
+		Tags: map[string]string{
+			"lsm-size":    strconv.FormatInt(lsm, 10),
+			"vlog-size":   strconv.FormatInt(vlog, 10),
+			"max-version": strconv.FormatUint(db.MaxVersion(), 10),
+		},
+	}, nil
+}
+
+// Close releases the provider. Databases are opened per operation, and a
+// database passed to NewFromDB stays open for the application.
+func (p *Provider) Close() error {
+	return nil
+}
+
+// backupReader reads the backup while it is written
+type backupReader struct {
+	*io.PipeReader
+	done chan struct{}
+	tags map[string]string
+}
+
+// Tags returns the latest version included in the backup
+func (r *backupReader) Tags() map[string]string {
+	return r.tags
+}
+
+// Close stops the backup and waits for it to finish
+func (r *backupReader) Close() error {
+	r.PipeReader.Close()
+	<-r.done
+	return nil
+}
+
+// frameReader passes a backup through while checking the length prefix of
+// each entry list against maxFrameSize
+type frameReader struct {
+	r         io.Reader
+	header    []byte // Unread part of the current length prefix
+	remaining uint64 // Unread bytes of the current entry list
+}
+
+// Read reads from the backup, failing on implausible lengths
+func (r *frameReader) Read(p []byte) (int, error) {
+	if len(r.header) == 0 && r.remaining == 0 {
+		header := make([]byte, 8)
+		n, err := io.ReadFull(r.r, header)
+		if n == 0 && errors.Is(err, io.EOF) {
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, fmt.Errorf("backup is truncated: %w", err)
+		}
+
+		size := binary.LittleEndian.Uint64(header)
+		if size > maxFrameSize {
+			return 0, fmt.Errorf("backup is not a valid Badger backup")
+		}
+		r.header = header
+		r.remaining = size
+	}
+
+	if len(r.header) > 0 {
+		n := copy(p, r.header)
+		r.header = r.header[n:]
+		return n, nil
+	}
+
+	if uint64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.r.Read(p)
+	r.remaining -= uint64(n)
+	if errors.Is(err, io.EOF) && r.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// contextWriter stops writing once its context is done
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+// Write writes to the underlying writer unless the context is done
+func (w *contextWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+// contextReader stops reading once its context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read reads from the underlying reader unless the context is done
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,307 @@
+package badger_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	badgerdb "github.com/dgraph-io/badger/v4"
+
+	"goarchive/core"
+	"goarchive/database/badger"
+)
+
+// createDatabase creates a database with n keys and leaves it open
+func createDatabase(t *testing.T, dir string, n int) *badgerdb.DB {
+	t.Helper()
+
+	db, err := badgerdb.Open(badgerdb.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = db.Update(func(txn *badgerdb.Txn) error {
+		for i := 0; i < n; i++ {
+			if err := txn.Set([]byte(fmt.Sprintf("key-%04d", i)), []byte("value")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to fill database: %v", err)
+	}
+	return db
+}
+
+// countKeys returns the number of keys in the database
+func countKeys(t *testing.T, dir string) int {
+	t.Helper()
+
+	db, err := badgerdb.Open(badgerdb.DefaultOptions(dir).WithReadOnly(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	err = db.View(func(txn *badgerdb.Txn) error {
+		it := txn.NewIterator(badgerdb.IteratorOptions{})
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to count keys: %v", err)
+	}
+	return count
+}
+
+func backup(t *testing.T, provider *badger.Provider) ([]byte, map[string]string) {
+	t.Helper()
+
+	reader, err := provider.Backup(context.Background())
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read backup data: %v", err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatalf("Backup() close error = %v", err)
+	}
+	return data, reader.(core.TaggedReader).Tags()
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "missing path", path: "", wantErr: true},
+		{name: "missing parent", path: filepath.Join(dir, "missing", "state"), wantErr: true},
+		{name: "new directory", path: filepath.Join(dir, "state")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := badger.New(&core.DatabaseConfig{Type: "badger", Path: tt.path})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	provider, err := core.GetDatabase("badger", &core.DatabaseConfig{Path: filepath.Join(t.TempDir(), "state")})
+	if err != nil {
+		t.Fatalf("GetDatabase() error = %v", err)
+	}
+	provider.Close()
+}
+
+func TestGetMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	createDatabase(t, path, 10).Close()
+
+	provider, err := badger.New(&core.DatabaseConfig{Type: "badger", Path: path})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	metadata, err := provider.GetMetadata()
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+
+	if metadata.Type != "badger" || metadata.Name != "state" {
+		t.Errorf("unexpected type or name: %+v", metadata)
+	}
+	if metadata.Tags["max-version"] == "" || metadata.Tags["max-version"] == "0" {
+		t.Errorf("expected the latest version, got %v", metadata.Tags)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state")
+	createDatabase(t, path, 500).Close()
+
+	provider, err := badger.New(&core.DatabaseConfig{Type: "badger", Path: path})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	data, tags := backup(t, provider)
+	if tags["max-version"] == "" {
+		t.Errorf("expected a max-version tag, got %v", tags)
+	}
+
+	// Restore over a database with different contents
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("failed to remove database: %v", err)
+	}
+	createDatabase(t, path, 3).Close()
+	if err := provider.Restore(context.Background(), bytes.NewReader(data)); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if n := countKeys(t, path); n != 500 {
+		t.Errorf("expected 500 keys after restore, got %d", n)
+	}
+
+	// Restore into a new directory
+	other := filepath.Join(dir, "copy")
+	err = provider.RestoreWithOptions(context.Background(), bytes.NewReader(data), &core.RestoreOptions{TargetDatabase: other})
+	if err != nil {
+		t.Fatalf("RestoreWithOptions() error = %v", err)
+	}
+	if n := countKeys(t, other); n != 500 {
+		t.Errorf("expected 500 keys in the target database, got %d", n)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected no temporary directories left, got %d entries", len(entries))
+	}
+}
+
+func TestBackup_HeldOpenByAnotherWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	createDatabase(t, path, 1)
+
+	provider, err := badger.New(&core.DatabaseConfig{Type: "badger", Path: path})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	if _, err := provider.Backup(context.Background()); err == nil {
+		t.Error("expected error while another writer holds the database, got nil")
+	}
+}
+
+func TestNewFromDB(t *testing.T) {
+	db := createDatabase(t, filepath.Join(t.TempDir(), "state"), 200)
+
+	provider, err := badger.NewFromDB(&core.DatabaseConfig{Type: "badger"}, db)
+	if err != nil {
+		t.Fatalf("NewFromDB() error = %v", err)
+	}
+	defer provider.Close()
+
+	data, _ := backup(t, provider)
+
+	// The application's database stays usable
+	err = db.Update(func(txn *badgerdb.Txn) error {
+		return txn.Set([]byte("after"), []byte("backup"))
+	})
+	if err != nil {
+		t.Fatalf("failed to write after backup: %v", err)
+	}
+
+	if err := provider.Restore(context.Background(), bytes.NewReader(data)); err == nil {
+		t.Error("expected error restoring into an open database, got nil")
+	}
+
+	restored := filepath.Join(t.TempDir(), "restored")
+	target, err := badger.New(&core.DatabaseConfig{Type: "badger", Path: restored})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := target.Restore(context.Background(), bytes.NewReader(data)); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if n := countKeys(t, restored); n != 200 {
+		t.Errorf("expected 200 keys after restore, got %d", n)
+	}
+}
+
+func TestRestore_KeepsDatabaseOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state")
+	createDatabase(t, path, 5).Close()
+
+	provider, err := badger.New(&core.DatabaseConfig{Type: "badger", Path: path})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := provider.Restore(context.Background(), strings.NewReader("not a badger backup")); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if n := countKeys(t, path); n != 5 {
+		t.Errorf("expected the database to be kept, got %d keys", n)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected no temporary directories left, got %d entries", len(entries))
+	}
+}
+
+func TestRestore_RefusesUnrelatedDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "documents")
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "notes.txt"), []byte("keep me"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	provider, err := badger.New(&core.DatabaseConfig{Type: "badger", Path: path})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := provider.Restore(context.Background(), strings.NewReader("")); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if _, err := os.Stat(filepath.Join(path, "notes.txt")); err != nil {
+		t.Errorf("expected the directory to be kept: %v", err)
+	}
+}
+
+func TestRestoreWithOptions_Unsupported(t *testing.T) {
+	provider, err := badger.New(&core.DatabaseConfig{Type: "badger", Path: filepath.Join(t.TempDir(), "state")})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts *core.RestoreOptions
+	}{
+		{name: "parallel", opts: &core.RestoreOptions{Jobs: 4}},
+		{name: "selective", opts: &core.RestoreOptions{Tables: []string{"items"}}},
+		{name: "backup mode", opts: &core.RestoreOptions{BackupMode: "physical"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := provider.RestoreWithOptions(context.Background(), strings.NewReader(""), tt.opts); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
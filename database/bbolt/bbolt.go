@@ -0,0 +1,318 @@
+package bbolt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"goarchive/core"
+)
+
+// defaultLockTimeout bounds how long opening the file waits for a process
+// holding it open for writing
+const defaultLockTimeout = 5 * time.Second
+
+// init registers the bbolt provider with the global registry
+func init() {
+	core.RegisterDatabase("bbolt", func(config *core.DatabaseConfig) (core.DatabaseProvider, error) {
+		return New(config)
+	})
+}
+
+// Provider implements the DatabaseProvider interface for bbolt database
+// files
+type Provider struct {
+	config  *core.DatabaseConfig
+	path    string
+	db      *bolt.DB // Database opened by the application, nil when opened per operation
+	timeout time.Duration
+}
+
+// New creates a new bbolt provider for the file at config.Path. The file
+// may not exist yet, so that a backup can be restored into a new file, but
+// its directory must.
+func New(config *core.DatabaseConfig) (*Provider, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("database path is required")
+	}
+
+	path, err := filepath.Abs(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database path: %w", err)
+	}
+
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("database directory does not exist: %s", filepath.Dir(path))
+	}
+
+	timeout := defaultLockTimeout
+	if config.ConnectTimeout > 0 {
+		timeout = time.Duration(config.ConnectTimeout) * time.Second
+	}
+
+	return &Provider{config: config, path: path, timeout: timeout}, nil
+}
+
+// NewFromDB creates a bbolt provider backing up a database the application
+// already has open, which other processes cannot open while it does.
+// Restores are refused, as the file cannot be replaced under an open
+// database; close it and restore with a provider from New.
+func NewFromDB(config *core.DatabaseConfig, db *bolt.DB) (*Provider, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database is required")
+	}
+	return &Provider{config: config, path: db.Path(), db: db}, nil
+}
+
+// view runs fn in a read transaction, on the application's database or on
+// the file opened read-only
+func (p *Provider) view(fn func(*bolt.Tx) error) error {
+	if p.db != nil {
+		return p.db.View(fn)
+	}
+
+	db, err := p.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(fn)
+}
+
+// open opens the database file read-only
+func (p *Provider) open() (*bolt.DB, error) {
+	if _, err := os.Stat(p.path); err != nil {
+		return nil, fmt.Errorf("database file not found: %w", err)
+	}
+
+	db, err := bolt.Open(p.path, 0, &bolt.Options{ReadOnly: true, Timeout: p.timeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("failed to open database: %s is held open for writing by another process", p.path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
+// Backup streams a consistent copy of the database written with
+// Tx.WriteTo from a read transaction. Writers are not blocked while it
+// streams.
+func (p *Provider) Backup(ctx context.Context) (io.ReadCloser, error) {
+	if p.config.BackupMode != "" {
+		return nil, fmt.Errorf("unsupported backup mode: %s", p.config.BackupMode)
+	}
+
+	db := p.db
+	if db == nil {
+		opened, err := p.open()
+		if err != nil {
+			return nil, err
+		}
+		db = opened
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if p.db == nil {
+			defer db.Close()
+		}
+
+		err := db.View(func(tx *bolt.Tx) error {
+			_, err := tx.WriteTo(&contextWriter{ctx: ctx, w: pw})
+			return err
+		})
+		if err != nil {
+			err = fmt.Errorf("failed to copy database: %w", err)
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return &backupReader{PipeReader: pr, done: done}, nil
+}
+
+// Restore replaces the database file with a backup
+func (p *Provider) Restore(ctx context.Context, reader io.Reader) error {
+	return p.RestoreWithOptions(ctx, reader, &core.RestoreOptions{})
+}
+
+// RestoreWithOptions writes the backup next to the target file, checks it
+// and renames it over the target, so the swap is atomic. TargetDatabase
+// names a different file to restore into. Applications using the database
+// must close it first.
+func (p *Provider) RestoreWithOptions(ctx context.Context, reader io.Reader, opts *core.RestoreOptions) error {
+	if opts == nil {
+		opts = &core.RestoreOptions{}
+	}
+
+	switch {
+	case p.db != nil:
+		return fmt.Errorf("cannot restore into a database the application has open; close it and restore by path")
+	case opts.BackupMode != "":
+		return fmt.Errorf("unsupported backup mode: %s", opts.BackupMode)
+	case opts.Jobs > 1:
+		return fmt.Errorf("parallel restore is not supported by the bbolt provider")
+	case opts.Selective() || opts.TargetSchema != "":
+		return fmt.Errorf("selective restore is not supported by the bbolt provider")
+	}
+
+	target := p.path
+	if opts.TargetDatabase != "" {
+		abs, err := filepath.Abs(opts.TargetDatabase)
+		if err != nil {
+			return fmt.Errorf("invalid target database path: %w", err)
+		}
+		target = abs
+	}
+
+	temp, err := writeTemp(ctx, reader, target)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp)
+
+	if err := check(temp); err != nil {
+		return fmt.Errorf("backup is not a valid bbolt database: %w", err)
+	}
+
+	if err := os.Rename(temp, target); err != nil {
+		return fmt.Errorf("failed to replace database file: %w", err)
+	}
+
+	// Persist the rename
+	if dir, err := os.Open(filepath.Dir(target)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	return nil
+}
+
+// writeTemp copies reader into a temporary file in the target's directory,
+// so it can be renamed over the target
+func writeTemp(ctx context.Context, reader io.Reader, target string) (string, error) {
+	file, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".restore-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	_, err = io.Copy(&contextWriter{ctx: ctx, w: file}, reader)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write backup data: %w", err)
+	}
+
+	// Keep the permissions of the file being replaced
+	mode := os.FileMode(0o600)
+	if info, err := os.Stat(target); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(file.Name(), mode); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to set file permissions: %w", err)
+	}
+
+	return file.Name(), nil
+}
+
+// check opens the database at path read-only and verifies its pages
+func check(path string) error {
+	db, err := bolt.Open(path, 0, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		var problems []string
+		for err := range tx.Check() {
+			problems = append(problems, err.Error())
+		}
+		if len(problems) > 0 {
+			return errors.New(strings.Join(problems, "; "))
+		}
+		return nil
+	})
+}
+
+// GetMetadata returns the size of the data, the number of top-level
+// buckets and the transaction the backup reflects
+func (p *Provider) GetMetadata() (*core.DatabaseMetadata, error) {
+	var size int64
+	var buckets int
+	var txID int
+
+	err := p.view(func(tx *bolt.Tx) error {
+		size = tx.Size()
+		txID = tx.ID()
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			buckets++
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	name := filepath.Base(p.path)
+
+	return &core.DatabaseMetadata{
+		Type: "bbolt",
+		Size: size,
+		Name: strings.TrimSuffix(name, filepath.Ext(name)),
+		Tags: map[string]string{
+			"bucket-count": strconv.Itoa(buckets),
+			"txid":         strconv.Itoa(txID),
+		},
+	}, nil
+}
+
+// Close releases the provider. Files are opened per operation, and a
+// database passed to NewFromDB stays open for the application.
+func (p *Provider) Close() error {
+	return nil
+}
+
+// backupReader reads the copy while it is written
+type backupReader struct {
+	*io.PipeReader
+	done chan struct{}
+}
+
+// Close stops the copy and waits for the read transaction to end
+func (r *backupReader) Close() error {
+	r.PipeReader.Close()
+	<-r.done
+	return nil
+}
+
+// contextWriter stops writing once its context is done
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+// Write writes to the underlying writer unless the context is done
+func (w *contextWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
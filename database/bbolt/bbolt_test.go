@@ -0,0 +1,289 @@
+package bbolt_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	"goarchive/core"
+	"goarchive/database/bbolt"
+)
+
+// createDatabase creates a database with a bucket of n keys and leaves it
+// open
+func createDatabase(t *testing.T, path string, n int) *bolt.DB {
+	t.Helper()
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("items"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if err := bucket.Put([]byte(fmt.Sprintf("key-%04d", i)), []byte("value")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to fill database: %v", err)
+	}
+	return db
+}
+
+// countKeys returns the number of keys in the items bucket of the database
+func countKeys(t *testing.T, path string) int {
+	t.Helper()
+
+	db, err := bolt.Open(path, 0, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("items"))
+		if bucket == nil {
+			return fmt.Errorf("bucket not found")
+		}
+		count = bucket.Stats().KeyN
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to count keys: %v", err)
+	}
+	return count
+}
+
+func backup(t *testing.T, provider *bbolt.Provider) []byte {
+	t.Helper()
+
+	reader, err := provider.Backup(context.Background())
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read backup data: %v", err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatalf("Backup() close error = %v", err)
+	}
+	return data
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "missing path", path: "", wantErr: true},
+		{name: "missing directory", path: filepath.Join(dir, "missing", "app.db"), wantErr: true},
+		{name: "new file", path: filepath.Join(dir, "app.db")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := bbolt.New(&core.DatabaseConfig{Type: "bbolt", Path: tt.path})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	provider, err := core.GetDatabase("bbolt", &core.DatabaseConfig{Path: filepath.Join(t.TempDir(), "app.db")})
+	if err != nil {
+		t.Fatalf("GetDatabase() error = %v", err)
+	}
+	provider.Close()
+}
+
+func TestGetMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	createDatabase(t, path, 10).Close()
+
+	provider, err := bbolt.New(&core.DatabaseConfig{Type: "bbolt", Path: path})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	metadata, err := provider.GetMetadata()
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+
+	if metadata.Type != "bbolt" || metadata.Name != "state" {
+		t.Errorf("unexpected type or name: %+v", metadata)
+	}
+	if metadata.Size <= 0 {
+		t.Errorf("expected a positive size, got %d", metadata.Size)
+	}
+	if metadata.Tags["bucket-count"] != "1" {
+		t.Errorf("expected one bucket, got %v", metadata.Tags)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.db")
+	createDatabase(t, path, 500).Close()
+
+	provider, err := bbolt.New(&core.DatabaseConfig{Type: "bbolt", Path: path})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	data := backup(t, provider)
+
+	// Restore over a database with different contents
+	createDatabase(t, path, 3).Close()
+	if err := provider.Restore(context.Background(), bytes.NewReader(data)); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if n := countKeys(t, path); n != 500 {
+		t.Errorf("expected 500 keys after restore, got %d", n)
+	}
+
+	// Restore into a different file
+	other := filepath.Join(dir, "copy.db")
+	err = provider.RestoreWithOptions(context.Background(), bytes.NewReader(data), &core.RestoreOptions{TargetDatabase: other})
+	if err != nil {
+		t.Fatalf("RestoreWithOptions() error = %v", err)
+	}
+	if n := countKeys(t, other); n != 500 {
+		t.Errorf("expected 500 keys in the target database, got %d", n)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected no temporary files left, got %d entries", len(entries))
+	}
+}
+
+func TestBackup_HeldOpenByAnotherWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	createDatabase(t, path, 1)
+
+	provider, err := bbolt.New(&core.DatabaseConfig{Type: "bbolt", Path: path, ConnectTimeout: 1})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	_, err = provider.Backup(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "held open") {
+		t.Errorf("expected error about the lock, got %v", err)
+	}
+}
+
+func TestNewFromDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	db := createDatabase(t, path, 200)
+
+	provider, err := bbolt.NewFromDB(&core.DatabaseConfig{Type: "bbolt"}, db)
+	if err != nil {
+		t.Fatalf("NewFromDB() error = %v", err)
+	}
+	defer provider.Close()
+
+	data := backup(t, provider)
+
+	// The application's database stays usable
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("items")).Put([]byte("after"), []byte("backup"))
+	})
+	if err != nil {
+		t.Fatalf("failed to write after backup: %v", err)
+	}
+
+	if err := provider.Restore(context.Background(), bytes.NewReader(data)); err == nil {
+		t.Error("expected error restoring into an open database, got nil")
+	}
+
+	restored := filepath.Join(t.TempDir(), "restored.db")
+	target, err := bbolt.New(&core.DatabaseConfig{Type: "bbolt", Path: restored})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := target.Restore(context.Background(), bytes.NewReader(data)); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if n := countKeys(t, restored); n != 200 {
+		t.Errorf("expected 200 keys after restore, got %d", n)
+	}
+}
+
+func TestRestore_InvalidBackupKeepsDatabase(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.db")
+	createDatabase(t, path, 5).Close()
+
+	provider, err := bbolt.New(&core.DatabaseConfig{Type: "bbolt", Path: path})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := provider.Restore(context.Background(), strings.NewReader("not a bbolt database")); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if n := countKeys(t, path); n != 5 {
+		t.Errorf("expected the database to be kept, got %d keys", n)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected no temporary files left, got %d entries", len(entries))
+	}
+}
+
+func TestRestoreWithOptions_Unsupported(t *testing.T) {
+	provider, err := bbolt.New(&core.DatabaseConfig{Type: "bbolt", Path: filepath.Join(t.TempDir(), "state.db")})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts *core.RestoreOptions
+	}{
+		{name: "parallel", opts: &core.RestoreOptions{Jobs: 4}},
+		{name: "selective", opts: &core.RestoreOptions{Tables: []string{"items"}}},
+		{name: "backup mode", opts: &core.RestoreOptions{BackupMode: "physical"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := provider.RestoreWithOptions(context.Background(), strings.NewReader(""), tt.opts); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+module goarchive/database/bbolt
+
+go 1.24.0
+
+require (
+	go.etcd.io/bbolt v1.4.3
+	goarchive v0.0.0
+)
+
+require golang.org/x/sys v0.29.0 // indirect
+
+replace goarchive => ../../
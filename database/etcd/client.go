@@ -0,0 +1,204 @@
+package etcd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+
+	"goarchive/core"
+)
+
+// defaultConnectTimeout bounds how long an endpoint may take to answer
+const defaultConnectTimeout = 5 * time.Second
+
+// defaultPort is the client port used when neither the configuration nor a
+// member URL sets one
+const defaultPort = "2379"
+
+// settings holds what is needed to connect to a cluster member
+type settings struct {
+	endpoints []string
+	username  string
+	password  string
+	tls       *tls.Config
+	timeout   time.Duration
+}
+
+// connSettings builds the connection settings from config. A URI holding a
+// comma-separated list of member URLs ("http://" or "https://" for TLS)
+// replaces Host, Port and SSLMode.
+func connSettings(config *core.DatabaseConfig) (*settings, error) {
+	if config.PassFile != "" || config.Service != "" {
+		return nil, fmt.Errorf("password files and services are not supported by the etcd provider")
+	}
+
+	s := &settings{timeout: defaultConnectTimeout}
+	sslMode := config.SSLMode
+	serverName := config.Host
+
+	if config.URI != "" {
+		secure := false
+		for _, endpoint := range strings.Split(config.URI, ",") {
+			uri, err := url.Parse(strings.TrimSpace(endpoint))
+			if err != nil {
+				return nil, fmt.Errorf("invalid connection URI: %w", err)
+			}
+
+			switch uri.Scheme {
+			case "http":
+			case "https":
+				secure = true
+			default:
+				return nil, fmt.Errorf("invalid connection URI: unsupported scheme %q", uri.Scheme)
+			}
+
+			port := uri.Port()
+			if port == "" {
+				port = defaultPort
+			}
+			s.endpoints = append(s.endpoints, net.JoinHostPort(uri.Hostname(), port))
+		}
+
+		// The server name is taken from each endpoint
+		serverName = ""
+		if secure && (sslMode == "" || sslMode == "disable" || sslMode == "prefer") {
+			sslMode = "verify-full"
+		}
+	} else {
+		port := defaultPort
+		if config.Port != 0 {
+			port = strconv.Itoa(config.Port)
+		}
+		s.endpoints = []string{net.JoinHostPort(config.Host, port)}
+	}
+
+	// Authentication is only enabled with a password, as the username
+	// defaults to one meant for other databases
+	if config.Password != "" {
+		s.username = config.Username
+		s.password = config.Password
+	}
+	if config.ConnectTimeout > 0 {
+		s.timeout = time.Duration(config.ConnectTimeout) * time.Second
+	}
+
+	tlsConfig, err := tlsSettings(sslMode, serverName, config)
+	if err != nil {
+		return nil, err
+	}
+	s.tls = tlsConfig
+
+	return s, nil
+}
+
+// tlsSettings maps the PostgreSQL-style SSL mode and certificate files onto
+// a TLS configuration, nil for plain connections
+func tlsSettings(sslMode, serverName string, config *core.DatabaseConfig) (*tls.Config, error) {
+	switch sslMode {
+	case "", "prefer", "disable":
+		// etcd serves either TLS or plain text on a port, so prefer connects
+		// without it
+		return nil, nil
+	case "require", "verify-ca", "verify-full":
+	default:
+		return nil, fmt.Errorf("unsupported SSL mode: %s", sslMode)
+	}
+
+	tlsConfig := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+
+	if config.SSLRootCert != "" {
+		pem, err := os.ReadFile(config.SSLRootCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", config.SSLRootCert)
+		}
+	}
+
+	if config.SSLCert != "" || config.SSLKey != "" {
+		cert, err := tls.LoadX509KeyPair(config.SSLCert, config.SSLKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	switch sslMode {
+	case "require":
+		tlsConfig.InsecureSkipVerify = true
+	case "verify-ca":
+		// Verify the chain but not the host name
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			opts := x509.VerifyOptions{Roots: tlsConfig.RootCAs, Intermediates: x509.NewCertPool()}
+			for _, cert := range state.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := state.PeerCertificates[0].Verify(opts)
+			return err
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// member is a client connected to a single cluster member
+type member struct {
+	client   *clientv3.Client
+	endpoint string
+	status   *clientv3.StatusResponse
+}
+
+// connect returns a client for the first member answering a status
+// request. Snapshots are taken from one member, so each client is limited to
+// a single endpoint.
+func connect(ctx context.Context, s *settings) (*member, error) {
+	var lastErr error
+	for _, endpoint := range s.endpoints {
+		m, err := connectEndpoint(ctx, s, endpoint)
+		if err == nil {
+			return m, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// connectEndpoint connects to endpoint and fetches its status
+func connectEndpoint(ctx context.Context, s *settings, endpoint string) (*member, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{endpoint},
+		Username:    s.username,
+		Password:    s.password,
+		TLS:         s.tls,
+		DialTimeout: s.timeout,
+		Context:     ctx,
+		Logger:      zap.NewNop(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to etcd at %s: %w", endpoint, err)
+	}
+
+	statusCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	status, err := client.Status(statusCtx, endpoint)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to etcd at %s: %w", endpoint, err)
+	}
+
+	return &member{client: client, endpoint: endpoint, status: status}, nil
+}
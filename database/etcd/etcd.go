@@ -0,0 +1,251 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"goarchive/core"
+)
+
+// init registers the etcd provider with the global registry
+func init() {
+	core.RegisterDatabase("etcd", func(config *core.DatabaseConfig) (core.DatabaseProvider, error) {
+		return New(config)
+	})
+}
+
+// Provider implements the DatabaseProvider interface for etcd v3. Backups
+// are snapshots streamed through the maintenance API; restores write the
+// snapshot to a file for etcdutl snapshot restore.
+type Provider struct {
+	config   *core.DatabaseConfig
+	settings *settings
+}
+
+// New creates a new etcd provider. The cluster is not contacted, as
+// restores only write a file and must work while it is down.
+func New(config *core.DatabaseConfig) (*Provider, error) {
+	s, err := connSettings(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{config: config, settings: s}, nil
+}
+
+// Backup streams a snapshot of the first reachable member's backend
+// database. The stream is checked against the checksum etcd appends, so a
+// damaged snapshot fails the backup.
+func (p *Provider) Backup(ctx context.Context) (io.ReadCloser, error) {
+	if p.config.BackupMode != "" {
+		return nil, fmt.Errorf("unsupported backup mode: %s", p.config.BackupMode)
+	}
+
+	m, err := connect(ctx, p.settings)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := m.client.SnapshotWithVersion(ctx)
+	if err != nil {
+		m.client.Close()
+		return nil, fmt.Errorf("failed to start snapshot: %w", err)
+	}
+
+	tags := map[string]string{
+		"member-id": fmt.Sprintf("%x", m.status.Header.MemberId),
+		"revision":  strconv.FormatInt(m.status.Header.Revision, 10),
+	}
+	if resp.Header != nil && resp.Header.Revision > 0 {
+		tags["revision"] = strconv.FormatInt(resp.Header.Revision, 10)
+	}
+	if resp.Version != "" {
+		tags["etcd-version"] = resp.Version
+	} else {
+		tags["etcd-version"] = m.status.Version
+	}
+
+	return &snapshotReader{
+		snapshot: resp.Snapshot,
+		member:   m,
+		checksum: newChecksum(),
+		tags:     tags,
+	}, nil
+}
+
+// Restore writes the snapshot to the file given by the database path
+func (p *Provider) Restore(ctx context.Context, reader io.Reader) error {
+	return p.RestoreWithOptions(ctx, reader, &core.RestoreOptions{})
+}
+
+// RestoreWithOptions writes the snapshot to the file given by the database
+// path, ready for etcdutl snapshot restore. The file only appears once the
+// snapshot is complete and its checksum verified; an existing file is
+// replaced.
+func (p *Provider) RestoreWithOptions(ctx context.Context, reader io.Reader, opts *core.RestoreOptions) error {
+	if opts == nil {
+		opts = &core.RestoreOptions{}
+	}
+
+	switch {
+	case opts.BackupMode != "":
+		return fmt.Errorf("unsupported backup mode: %s", opts.BackupMode)
+	case p.config.Path == "":
+		return fmt.Errorf("etcd restore requires a snapshot file path")
+	case opts.DataDirectory != "":
+		return fmt.Errorf("the etcd provider writes a snapshot file; restore the data directory with etcdutl snapshot restore")
+	case opts.TargetDatabase != "" || opts.CreateDatabase || opts.DropDatabase:
+		return fmt.Errorf("the etcd provider writes a snapshot file and does not support target databases")
+	case opts.Jobs > 1:
+		return fmt.Errorf("parallel restore is not supported by the etcd provider")
+	case opts.SingleTransaction:
+		return fmt.Errorf("single-transaction restore is not supported by the etcd provider")
+	case opts.Selective() || opts.TargetSchema != "":
+		return fmt.Errorf("selective restore is not supported by the etcd provider")
+	}
+
+	if err := writeSnapshot(ctx, reader, p.config.Path); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+	return nil
+}
+
+// writeSnapshot copies the snapshot to a temporary file next to path and
+// renames it into place once verified
+func writeSnapshot(ctx context.Context, reader io.Reader, path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(dir, filepath.Base(path)+".*.part")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	sum := newChecksum()
+	if _, err := io.Copy(io.MultiWriter(file, sum), &contextReader{ctx: ctx, r: reader}); err != nil {
+		return err
+	}
+	if err := sum.verify(); err != nil {
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+	return nil
+}
+
+// GetMetadata reports the backend database size, version and position of
+// the first reachable member
+func (p *Provider) GetMetadata() (*core.DatabaseMetadata, error) {
+	m, err := connect(context.Background(), p.settings)
+	if err != nil {
+		return nil, err
+	}
+	defer m.client.Close()
+
+	status := m.status
+	tags := map[string]string{
+		"member-id":  fmt.Sprintf("%x", status.Header.MemberId),
+		"cluster-id": fmt.Sprintf("%x", status.Header.ClusterId),
+		"leader":     fmt.Sprintf("%x", status.Leader),
+		"revision":   strconv.FormatInt(status.Header.Revision, 10),
+		"raft-index": strconv.FormatUint(status.RaftIndex, 10),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.settings.timeout)
+	defer cancel()
+
+	// Member names are informative only, so failing to list them is not
+	// an error
+	if members, err := m.client.MemberList(ctx); err == nil {
+		for _, member := range members.Members {
+			if member.ID == status.Header.MemberId && member.Name != "" {
+				tags["member-name"] = member.Name
+			}
+		}
+	}
+
+	name := p.config.Database
+	if name == "" {
+		name = "etcd"
+	}
+
+	return &core.DatabaseMetadata{
+		Type:    "etcd",
+		Version: status.Version,
+		Size:    status.DbSize,
+		Name:    name,
+		Tags:    tags,
+	}, nil
+}
+
+// Close releases the provider. Clients are created per operation, so there
+// is nothing to close.
+func (p *Provider) Close() error {
+	return nil
+}
+
+// snapshotReader streams a snapshot while verifying its checksum
+type snapshotReader struct {
+	snapshot io.ReadCloser
+	member   *member
+	checksum *checksum
+	tags     map[string]string
+}
+
+// Read reads from the snapshot. A snapshot failing verification returns an
+// error instead of EOF, so it is not stored as a complete backup.
+func (r *snapshotReader) Read(p []byte) (int, error) {
+	n, err := r.snapshot.Read(p)
+	r.checksum.Write(p[:n])
+	if errors.Is(err, io.EOF) {
+		if verr := r.checksum.verify(); verr != nil {
+			return n, verr
+		}
+	}
+	return n, err
+}
+
+// Tags returns the member, revision and server version of the snapshot
+func (r *snapshotReader) Tags() map[string]string {
+	return r.tags
+}
+
+// Close stops the snapshot stream and closes the client
+func (r *snapshotReader) Close() error {
+	r.snapshot.Close()
+	return r.member.client.Close()
+}
+
+// contextReader stops reading once its context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read reads from the underlying reader unless the context is done
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
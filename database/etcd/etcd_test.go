@@ -0,0 +1,469 @@
+package etcd_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+
+	"goarchive/core"
+	"goarchive/database/etcd"
+)
+
+// freeURL returns a URL on a free local port
+func freeURL(t *testing.T, scheme string) url.URL {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	defer l.Close()
+	return url.URL{Scheme: scheme, Host: l.Addr().String()}
+}
+
+// startEtcd runs an embedded single-member cluster and returns its client
+// URL. tlsDir, when set, holds certificates from writeCerts and makes the
+// server require client certificates.
+func startEtcd(t *testing.T, tlsDir string) url.URL {
+	t.Helper()
+
+	cfg := embed.NewConfig()
+	cfg.Name = "archive-test"
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+
+	scheme := "http"
+	if tlsDir != "" {
+		scheme = "https"
+		cfg.ClientTLSInfo.CertFile = filepath.Join(tlsDir, "server.crt")
+		cfg.ClientTLSInfo.KeyFile = filepath.Join(tlsDir, "server.key")
+		cfg.ClientTLSInfo.TrustedCAFile = filepath.Join(tlsDir, "ca.crt")
+		cfg.ClientTLSInfo.ClientCertAuth = true
+	}
+
+	clientURL := freeURL(t, scheme)
+	peerURL := freeURL(t, "http")
+	cfg.ListenClientUrls = []url.URL{clientURL}
+	cfg.AdvertiseClientUrls = []url.URL{clientURL}
+	cfg.ListenPeerUrls = []url.URL{peerURL}
+	cfg.AdvertisePeerUrls = []url.URL{peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	server, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatalf("failed to start etcd: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	select {
+	case <-server.Server.ReadyNotify():
+	case <-time.After(30 * time.Second):
+		t.Fatal("etcd did not become ready")
+	}
+	return clientURL
+}
+
+// configFor returns a provider configuration for the server at u
+func configFor(u url.URL) *core.DatabaseConfig {
+	port, _ := strconv.Atoi(u.Port())
+	return &core.DatabaseConfig{Type: "etcd", Host: u.Hostname(), Port: port, Username: "postgres"}
+}
+
+// put writes keys to the server at u
+func put(t *testing.T, u url.URL, kvs map[string]string) {
+	t.Helper()
+
+	client, err := clientv3.New(clientv3.Config{Endpoints: []string{u.Host}, DialTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	for key, value := range kvs {
+		if _, err := client.Put(context.Background(), key, value); err != nil {
+			t.Fatalf("failed to put %s: %v", key, err)
+		}
+	}
+}
+
+// backup takes a snapshot with provider
+func backup(t *testing.T, provider *etcd.Provider) ([]byte, map[string]string) {
+	t.Helper()
+
+	reader, err := provider.Backup(context.Background())
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read snapshot: %v", err)
+	}
+
+	tagged, ok := reader.(core.TaggedReader)
+	if !ok {
+		t.Fatal("expected the snapshot reader to carry tags")
+	}
+	return data, tagged.Tags()
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	provider, err := core.GetDatabase("etcd", &core.DatabaseConfig{Host: "localhost", Port: 2379})
+	if err != nil {
+		t.Fatalf("GetDatabase() error = %v", err)
+	}
+	provider.Close()
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *core.DatabaseConfig
+	}{
+		{name: "unsupported scheme", config: &core.DatabaseConfig{URI: "unix://etcd.sock"}},
+		{name: "unsupported SSL mode", config: &core.DatabaseConfig{Host: "localhost", Port: 2379, SSLMode: "allow"}},
+		{name: "missing CA file", config: &core.DatabaseConfig{Host: "localhost", Port: 2379, SSLMode: "verify-full", SSLRootCert: "/nonexistent/ca.crt"}},
+		{name: "password file", config: &core.DatabaseConfig{Host: "localhost", Port: 2379, PassFile: "/root/.pgpass"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := etcd.New(tt.config); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	u := startEtcd(t, "")
+	put(t, u, map[string]string{"/app/config": "v1", "/app/flags": "beta"})
+
+	config := configFor(u)
+	config.Path = filepath.Join(t.TempDir(), "restore", "etcd.snapshot")
+	provider, err := etcd.New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	data, tags := backup(t, provider)
+	if len(data)%512 != sha256.Size {
+		t.Errorf("expected a snapshot with its checksum appended, got %d bytes", len(data))
+	}
+	if tags["etcd-version"] == "" || tags["member-id"] == "" {
+		t.Errorf("expected version and member tags, got %v", tags)
+	}
+	if revision, _ := strconv.Atoi(tags["revision"]); revision < 3 {
+		t.Errorf("expected revision of at least 3, got %q", tags["revision"])
+	}
+
+	if err := provider.Restore(context.Background(), bytes.NewReader(data)); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	restored, err := os.ReadFile(config.Path)
+	if err != nil {
+		t.Fatalf("failed to read restored snapshot: %v", err)
+	}
+	if !bytes.Equal(restored, data) {
+		t.Error("restored snapshot differs from the backup")
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(config.Path))
+	if err != nil {
+		t.Fatalf("failed to read restore directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the snapshot file, got %d entries", len(entries))
+	}
+}
+
+func TestBackup_FallsBackToReachableMember(t *testing.T) {
+	u := startEtcd(t, "")
+	unreachable := freeURL(t, "http")
+
+	provider, err := etcd.New(&core.DatabaseConfig{
+		URI:            unreachable.String() + "," + u.String(),
+		ConnectTimeout: 1,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	data, _ := backup(t, provider)
+	if len(data) == 0 {
+		t.Error("expected snapshot data")
+	}
+}
+
+func TestBackup_Unreachable(t *testing.T) {
+	u := freeURL(t, "http")
+	config := configFor(u)
+	config.ConnectTimeout = 1
+
+	provider, err := etcd.New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	if _, err := provider.Backup(context.Background()); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestBackup_DefaultPort(t *testing.T) {
+	provider, err := etcd.New(&core.DatabaseConfig{Host: "127.0.0.1", ConnectTimeout: 1})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	_, err = provider.Backup(context.Background())
+	if err == nil {
+		t.Skip("an etcd member is listening on the default port")
+	}
+	if !strings.Contains(err.Error(), "127.0.0.1:2379") {
+		t.Errorf("expected a connection to port 2379, got %v", err)
+	}
+}
+
+func TestBackup_TLSClientCertificate(t *testing.T) {
+	dir := writeCerts(t)
+	u := startEtcd(t, dir)
+
+	config := configFor(u)
+	config.SSLMode = "verify-full"
+	config.SSLRootCert = filepath.Join(dir, "ca.crt")
+	config.ConnectTimeout = 2
+
+	// The server requires a client certificate
+	provider, err := etcd.New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := provider.Backup(context.Background()); err == nil {
+		t.Error("expected error without a client certificate, got nil")
+	}
+
+	config.SSLCert = filepath.Join(dir, "client.crt")
+	config.SSLKey = filepath.Join(dir, "client.key")
+	provider, err = etcd.New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	data, _ := backup(t, provider)
+	if len(data) == 0 {
+		t.Error("expected snapshot data")
+	}
+
+	metadata, err := provider.GetMetadata()
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+	if metadata.Version == "" {
+		t.Error("expected a server version")
+	}
+}
+
+func TestRestore_InvalidSnapshot(t *testing.T) {
+	u := startEtcd(t, "")
+	config := configFor(u)
+	config.Path = filepath.Join(t.TempDir(), "etcd.snapshot")
+	provider, err := etcd.New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	data, _ := backup(t, provider)
+
+	corrupted := bytes.Clone(data)
+	corrupted[100] ^= 0xff
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "corrupted", data: corrupted},
+		{name: "truncated", data: data[:len(data)-100]},
+		{name: "without checksum", data: data[:len(data)-sha256.Size]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := provider.Restore(context.Background(), bytes.NewReader(tt.data))
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if _, err := os.Stat(config.Path); !os.IsNotExist(err) {
+				t.Error("expected no snapshot file after a failed restore")
+			}
+		})
+	}
+}
+
+func TestRestoreWithOptions_Unsupported(t *testing.T) {
+	provider, err := etcd.New(&core.DatabaseConfig{Host: "localhost", Port: 2379, Path: filepath.Join(t.TempDir(), "etcd.snapshot")})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	tests := []struct {
+		name string
+		opts *core.RestoreOptions
+	}{
+		{name: "data directory", opts: &core.RestoreOptions{DataDirectory: "/var/lib/etcd"}},
+		{name: "target database", opts: &core.RestoreOptions{TargetDatabase: "other"}},
+		{name: "parallel", opts: &core.RestoreOptions{Jobs: 4}},
+		{name: "selective", opts: &core.RestoreOptions{Tables: []string{"users"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := provider.RestoreWithOptions(context.Background(), strings.NewReader(""), tt.opts); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestRestore_RequiresPath(t *testing.T) {
+	provider, err := etcd.New(&core.DatabaseConfig{Host: "localhost", Port: 2379})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	if err := provider.Restore(context.Background(), strings.NewReader("")); err == nil {
+		t.Error("expected error without a snapshot path, got nil")
+	}
+}
+
+func TestGetMetadata(t *testing.T) {
+	u := startEtcd(t, "")
+	put(t, u, map[string]string{"/app/config": "v1"})
+
+	config := configFor(u)
+	config.Database = "orders-cluster"
+	provider, err := etcd.New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	metadata, err := provider.GetMetadata()
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+
+	if metadata.Type != "etcd" || metadata.Name != "orders-cluster" {
+		t.Errorf("unexpected type or name: %+v", metadata)
+	}
+	if metadata.Size <= 0 || metadata.Version == "" {
+		t.Errorf("expected size and version, got %+v", metadata)
+	}
+	if metadata.Tags["member-name"] != "archive-test" {
+		t.Errorf("expected member name tag, got %v", metadata.Tags)
+	}
+	if metadata.Tags["member-id"] == "" || metadata.Tags["member-id"] != metadata.Tags["leader"] {
+		t.Errorf("expected the single member to lead, got %v", metadata.Tags)
+	}
+	if revision, _ := strconv.Atoi(metadata.Tags["revision"]); revision < 2 {
+		t.Errorf("expected revision of at least 2, got %q", metadata.Tags["revision"])
+	}
+}
+
+// writeCerts writes a CA, a server certificate for 127.0.0.1 and a client
+// certificate to a temporary directory
+func writeCerts(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "goarchive test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("failed to parse CA: %v", err)
+	}
+	writePEM(t, filepath.Join(dir, "ca.crt"), "CERTIFICATE", caDER)
+
+	for i, name := range []string{"server", "client"} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 2)),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("failed to create %s certificate: %v", name, err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatalf("failed to marshal key: %v", err)
+		}
+		writePEM(t, filepath.Join(dir, name+".crt"), "CERTIFICATE", der)
+		writePEM(t, filepath.Join(dir, name+".key"), "EC PRIVATE KEY", keyDER)
+	}
+
+	return dir
+}
+
+// writePEM writes a single PEM block to path
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(fmt.Errorf("failed to write %s: %w", path, err))
+	}
+}
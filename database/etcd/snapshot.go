@@ -0,0 +1,46 @@
+package etcd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+)
+
+// checksum verifies the SHA-256 digest etcd appends to snapshots. Data is
+// fed in as it streams; the last 32 bytes seen are the digest of everything
+// before them.
+type checksum struct {
+	hash hash.Hash
+	tail []byte
+	size int64
+}
+
+// newChecksum returns an empty checksum
+func newChecksum() *checksum {
+	return &checksum{hash: sha256.New()}
+}
+
+// Write adds p to the data seen so far
+func (c *checksum) Write(p []byte) (int, error) {
+	c.size += int64(len(p))
+	c.tail = append(c.tail, p...)
+	if n := len(c.tail) - sha256.Size; n > 0 {
+		c.hash.Write(c.tail[:n])
+		c.tail = append(c.tail[:0], c.tail[n:]...)
+	}
+	return len(p), nil
+}
+
+// verify checks that the data seen ends with the digest of the rest.
+// Snapshot databases are made of 512-byte pages, so only a snapshot with the
+// digest appended is 32 bytes past a page boundary.
+func (c *checksum) verify() error {
+	if c.size%512 != sha256.Size {
+		return fmt.Errorf("snapshot has no SHA-256 checksum (%d bytes)", c.size)
+	}
+	if !bytes.Equal(c.hash.Sum(nil), c.tail) {
+		return fmt.Errorf("snapshot checksum mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	osexec "os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"goarchive/core"
+)
+
+const (
+	// outputLimit is the amount of command output kept for error messages
+	outputLimit = 4096
+
+	// waitDelay bounds how long a cancelled command's children may keep its
+	// output open
+	waitDelay = 10 * time.Second
+)
+
+// init registers the exec provider with the global registry
+func init() {
+	core.RegisterDatabase("exec", func(config *core.DatabaseConfig) (core.DatabaseProvider, error) {
+		return New(config)
+	})
+}
+
+// Provider implements the DatabaseProvider interface by running configured
+// shell commands, for datastores without a dedicated provider
+type Provider struct {
+	config *core.DatabaseConfig
+}
+
+// New creates a new exec provider. At least one of the backup and restore
+// commands is required.
+func New(config *core.DatabaseConfig) (*Provider, error) {
+	if config.BackupCommand == "" && config.RestoreCommand == "" {
+		return nil, fmt.Errorf("a backup or restore command is required")
+	}
+
+	return &Provider{config: config}, nil
+}
+
+// Backup runs the backup command and returns a reader over its standard
+// output. The reader fails at the end of the output if the command exits
+// with an error, so a failed backup is never mistaken for a complete one.
+func (p *Provider) Backup(ctx context.Context) (io.ReadCloser, error) {
+	if p.config.BackupCommand == "" {
+		return nil, fmt.Errorf("no backup command configured")
+	}
+
+	cmd := p.command(ctx, p.config.BackupCommand,
+		"GOARCHIVE_BACKUP_MODE="+p.config.BackupMode,
+	)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipe: %w", err)
+	}
+
+	stderr := &tailBuffer{}
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start backup command: %w", err)
+	}
+
+	return &backupReader{ReadCloser: stdout, cmd: cmd, stderr: stderr}, nil
+}
+
+// Restore feeds backup data to the restore command
+func (p *Provider) Restore(ctx context.Context, reader io.Reader) error {
+	return p.RestoreWithOptions(ctx, reader, &core.RestoreOptions{})
+}
+
+// RestoreWithOptions feeds backup data to the restore command's standard
+// input. The target database, whether to create or drop it and the backup
+// mode are passed in the environment; the command is responsible for
+// honouring them.
+func (p *Provider) RestoreWithOptions(ctx context.Context, reader io.Reader, opts *core.RestoreOptions) error {
+	if opts == nil {
+		opts = &core.RestoreOptions{}
+	}
+
+	switch {
+	case opts.Jobs > 1:
+		return fmt.Errorf("parallel restore is not supported by the exec provider")
+	case opts.SingleTransaction:
+		return fmt.Errorf("single-transaction restore is not supported by the exec provider")
+	case opts.Selective() || opts.TargetSchema != "":
+		return fmt.Errorf("selective restore is not supported by the exec provider")
+	case opts.DataDirectory != "":
+		return fmt.Errorf("restoring into a data directory is not supported by the exec provider")
+	}
+
+	if p.config.RestoreCommand == "" {
+		return fmt.Errorf("no restore command configured")
+	}
+
+	target := opts.TargetDatabase
+	if target == "" {
+		target = p.config.Database
+	}
+
+	cmd := p.command(ctx, p.config.RestoreCommand,
+		"GOARCHIVE_TARGET_DATABASE="+target,
+		"GOARCHIVE_CREATE_DATABASE="+strconv.FormatBool(opts.CreateDatabase),
+		"GOARCHIVE_DROP_DATABASE="+strconv.FormatBool(opts.DropDatabase),
+		"GOARCHIVE_BACKUP_MODE="+opts.BackupMode,
+	)
+	cmd.Stdin = reader
+
+	output := &tailBuffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("restore command failed: %w (output: %s)", err, output)
+	}
+
+	return nil
+}
+
+// metadataOutput is the JSON printed by the metadata command. Fields left
+// out keep their defaults.
+type metadataOutput struct {
+	Name    string            `json:"name"`
+	Version string            `json:"version"`
+	Size    int64             `json:"size"`
+	Tags    map[string]string `json:"tags"`
+}
+
+// GetMetadata returns metadata about the database, from the metadata
+// command when one is configured
+func (p *Provider) GetMetadata() (*core.DatabaseMetadata, error) {
+	metadata := &core.DatabaseMetadata{
+		Type:       "exec",
+		Name:       p.config.Database,
+		BackupMode: p.config.BackupMode,
+	}
+
+	if p.config.MetadataCommand != "" {
+		cmd := p.command(context.Background(), p.config.MetadataCommand)
+		stderr := &tailBuffer{}
+		cmd.Stderr = stderr
+
+		data, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("metadata command failed: %w (output: %s)", err, stderr)
+		}
+
+		var output metadataOutput
+		if err := json.Unmarshal(data, &output); err != nil {
+			return nil, fmt.Errorf("failed to parse metadata command output: %w", err)
+		}
+
+		if output.Name != "" {
+			metadata.Name = output.Name
+		}
+		metadata.Version = output.Version
+		metadata.Size = output.Size
+		metadata.Tags = output.Tags
+	}
+
+	if metadata.Name == "" {
+		metadata.Name = "exec"
+	}
+
+	return metadata, nil
+}
+
+// Close releases the provider. Commands run per operation, so there is
+// nothing to close.
+func (p *Provider) Close() error {
+	return nil
+}
+
+// command returns a command running script with sh. The connection settings
+// and env are added to its environment, so secrets never appear on the
+// command line.
+func (p *Provider) command(ctx context.Context, script string, env ...string) *osexec.Cmd {
+	cmd := osexec.CommandContext(ctx, "sh", "-c", script)
+	cmd.Env = append(append(os.Environ(), p.connectionEnv()...), env...)
+	cmd.WaitDelay = waitDelay
+	setProcessGroup(cmd)
+	return cmd
+}
+
+// connectionEnv returns the configured connection settings as GOARCHIVE_DB_*
+// variables, leaving out unset ones
+func (p *Provider) connectionEnv() []string {
+	var env []string
+	add := func(name, value string) {
+		if value != "" {
+			env = append(env, "GOARCHIVE_DB_"+name+"="+value)
+		}
+	}
+
+	add("HOST", p.config.Host)
+	if p.config.Port > 0 {
+		add("PORT", strconv.Itoa(p.config.Port))
+	}
+	add("USERNAME", p.config.Username)
+	add("PASSWORD", p.config.Password)
+	add("DATABASE", p.config.Database)
+	add("SSLMODE", p.config.SSLMode)
+	add("URI", p.config.URI)
+	add("PATH", p.config.Path)
+	add("PASSFILE", p.config.PassFile)
+
+	return env
+}
+
+// backupReader wraps the stdout pipe of the backup command and checks how
+// the command exited
+type backupReader struct {
+	io.ReadCloser
+	cmd    *osexec.Cmd
+	stderr *tailBuffer
+	waited bool
+	err    error
+}
+
+// Read reads the command's output, returning the command's error instead of
+// io.EOF if it failed
+func (r *backupReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF {
+		if waitErr := r.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Close closes the pipe and waits for the command to finish
+func (r *backupReader) Close() error {
+	r.ReadCloser.Close()
+	return r.wait()
+}
+
+// wait waits for the command once and returns how it exited
+func (r *backupReader) wait() error {
+	if !r.waited {
+		r.waited = true
+		if err := r.cmd.Wait(); err != nil {
+			r.err = fmt.Errorf("backup command failed: %w (output: %s)", err, r.stderr)
+		}
+	}
+	return r.err
+}
+
+// tailBuffer keeps the last outputLimit bytes written to it, so chatty
+// commands cannot exhaust memory
+type tailBuffer struct {
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > outputLimit {
+		b.data = append(b.data[:0], b.data[len(b.data)-outputLimit:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return strings.TrimSpace(string(b.data))
+}
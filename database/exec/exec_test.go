@@ -0,0 +1,309 @@
+package exec_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"goarchive/core"
+	"goarchive/database/exec"
+)
+
+func newProvider(t *testing.T, config *core.DatabaseConfig) *exec.Provider {
+	t.Helper()
+
+	if config.Type == "" {
+		config.Type = "exec"
+	}
+	provider, err := exec.New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { provider.Close() })
+	return provider
+}
+
+func TestNew_RequiresCommand(t *testing.T) {
+	_, err := exec.New(&core.DatabaseConfig{Type: "exec", MetadataCommand: "echo {}"})
+	if err == nil {
+		t.Error("expected error without backup or restore command, got nil")
+	}
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	provider, err := core.GetDatabase("exec", &core.DatabaseConfig{BackupCommand: "true"})
+	if err != nil {
+		t.Fatalf("GetDatabase() error = %v", err)
+	}
+	provider.Close()
+}
+
+func TestBackup(t *testing.T) {
+	provider := newProvider(t, &core.DatabaseConfig{
+		Host:          "kv.internal",
+		Port:          7000,
+		Username:      "backup",
+		Password:      "s3cr3t",
+		Database:      "orders",
+		BackupMode:    "incremental",
+		BackupCommand: `printf '%s|%s|%s|%s|%s|%s' "$GOARCHIVE_DB_HOST" "$GOARCHIVE_DB_PORT" "$GOARCHIVE_DB_USERNAME" "$GOARCHIVE_DB_PASSWORD" "$GOARCHIVE_DB_DATABASE" "$GOARCHIVE_BACKUP_MODE"`,
+	})
+
+	reader, err := provider.Backup(context.Background())
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read backup data: %v", err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	want := "kv.internal|7000|backup|s3cr3t|orders|incremental"
+	if string(data) != want {
+		t.Errorf("backup = %q, want %q", data, want)
+	}
+}
+
+func TestBackup_CommandFails(t *testing.T) {
+	provider := newProvider(t, &core.DatabaseConfig{
+		BackupCommand: "printf partial; echo 'disk full' >&2; exit 3",
+	})
+
+	reader, err := provider.Backup(context.Background())
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	defer reader.Close()
+
+	// The failure must surface while reading, before storage sees a clean EOF
+	data, err := io.ReadAll(reader)
+	if err == nil {
+		t.Fatal("expected error reading a failed backup, got nil")
+	}
+	if string(data) != "partial" {
+		t.Errorf("expected the partial output, got %q", data)
+	}
+	if !strings.Contains(err.Error(), "disk full") || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("expected error with stderr and exit status, got %v", err)
+	}
+
+	if err := reader.Close(); err == nil {
+		t.Error("expected Close() to report the failure, got nil")
+	}
+}
+
+func TestBackup_StderrIsBounded(t *testing.T) {
+	provider := newProvider(t, &core.DatabaseConfig{
+		BackupCommand: "i=0; while [ $i -lt 2000 ]; do echo 'progress line' >&2; i=$((i+1)); done; echo 'last line' >&2; exit 1",
+	})
+
+	reader, err := provider.Backup(context.Background())
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	defer reader.Close()
+
+	_, err = io.ReadAll(reader)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if len(err.Error()) > 5000 {
+		t.Errorf("expected stderr to be truncated, got %d bytes", len(err.Error()))
+	}
+	if !strings.HasSuffix(err.Error(), "last line)") {
+		t.Errorf("expected the end of stderr to be kept, got ...%s", err.Error()[len(err.Error())-40:])
+	}
+}
+
+func TestBackup_Cancelled(t *testing.T) {
+	provider := newProvider(t, &core.DatabaseConfig{BackupCommand: "sleep 30"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reader, err := provider.Backup(ctx)
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	defer reader.Close()
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if _, err := io.ReadAll(reader); err == nil {
+		t.Error("expected error from a cancelled backup, got nil")
+	}
+}
+
+func TestBackup_NoCommand(t *testing.T) {
+	provider := newProvider(t, &core.DatabaseConfig{RestoreCommand: "cat > /dev/null"})
+
+	if _, err := provider.Backup(context.Background()); err == nil {
+		t.Error("expected error without backup command, got nil")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "restored")
+	t.Setenv("RESTORE_OUT", out)
+
+	provider := newProvider(t, &core.DatabaseConfig{
+		Database:       "orders",
+		RestoreCommand: `cat > "$RESTORE_OUT" && printf '%s|%s|%s' "$GOARCHIVE_TARGET_DATABASE" "$GOARCHIVE_CREATE_DATABASE" "$GOARCHIVE_DROP_DATABASE" > "$RESTORE_OUT.env"`,
+	})
+
+	tests := []struct {
+		name    string
+		opts    *core.RestoreOptions
+		wantEnv string
+	}{
+		{name: "defaults", opts: nil, wantEnv: "orders|false|false"},
+		{
+			name:    "target database",
+			opts:    &core.RestoreOptions{TargetDatabase: "orders_staging", CreateDatabase: true, DropDatabase: true},
+			wantEnv: "orders_staging|true|true",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := provider.RestoreWithOptions(context.Background(), strings.NewReader("backup data"), tt.opts)
+			if err != nil {
+				t.Fatalf("RestoreWithOptions() error = %v", err)
+			}
+
+			data, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatalf("failed to read restored data: %v", err)
+			}
+			if string(data) != "backup data" {
+				t.Errorf("restored %q, want %q", data, "backup data")
+			}
+
+			env, err := os.ReadFile(out + ".env")
+			if err != nil {
+				t.Fatalf("failed to read restore environment: %v", err)
+			}
+			if string(env) != tt.wantEnv {
+				t.Errorf("restore environment %q, want %q", env, tt.wantEnv)
+			}
+		})
+	}
+}
+
+func TestRestore_CommandFails(t *testing.T) {
+	provider := newProvider(t, &core.DatabaseConfig{
+		RestoreCommand: "cat > /dev/null; echo 'schema mismatch' >&2; exit 2",
+	})
+
+	err := provider.Restore(context.Background(), strings.NewReader("backup data"))
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "schema mismatch") {
+		t.Errorf("expected error with the command output, got %v", err)
+	}
+}
+
+func TestRestoreWithOptions_Unsupported(t *testing.T) {
+	provider := newProvider(t, &core.DatabaseConfig{RestoreCommand: "cat > /dev/null"})
+
+	tests := []struct {
+		name string
+		opts *core.RestoreOptions
+	}{
+		{name: "parallel", opts: &core.RestoreOptions{Jobs: 4}},
+		{name: "single transaction", opts: &core.RestoreOptions{SingleTransaction: true}},
+		{name: "selective", opts: &core.RestoreOptions{Tables: []string{"users"}}},
+		{name: "data directory", opts: &core.RestoreOptions{DataDirectory: "/tmp/data"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := provider.RestoreWithOptions(context.Background(), strings.NewReader(""), tt.opts); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestRestore_NoCommand(t *testing.T) {
+	provider := newProvider(t, &core.DatabaseConfig{BackupCommand: "true"})
+
+	if err := provider.Restore(context.Background(), strings.NewReader("")); err == nil {
+		t.Error("expected error without restore command, got nil")
+	}
+}
+
+func TestGetMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *core.DatabaseConfig
+		want    *core.DatabaseMetadata
+		wantErr bool
+	}{
+		{
+			name:   "without metadata command",
+			config: &core.DatabaseConfig{Database: "orders", BackupCommand: "true"},
+			want:   &core.DatabaseMetadata{Type: "exec", Name: "orders"},
+		},
+		{
+			name:   "default name",
+			config: &core.DatabaseConfig{BackupCommand: "true"},
+			want:   &core.DatabaseMetadata{Type: "exec", Name: "exec"},
+		},
+		{
+			name: "from metadata command",
+			config: &core.DatabaseConfig{
+				Database:        "orders",
+				BackupMode:      "full",
+				BackupCommand:   "true",
+				MetadataCommand: `echo "{\"name\": \"orders-kv\", \"version\": \"2.1\", \"size\": 4096, \"tags\": {\"host\": \"$GOARCHIVE_DB_HOST\"}}"`,
+				Host:            "kv.internal",
+			},
+			want: &core.DatabaseMetadata{
+				Type:       "exec",
+				Name:       "orders-kv",
+				Version:    "2.1",
+				Size:       4096,
+				BackupMode: "full",
+				Tags:       map[string]string{"host": "kv.internal"},
+			},
+		},
+		{
+			name: "invalid JSON",
+			config: &core.DatabaseConfig{
+				BackupCommand:   "true",
+				MetadataCommand: "echo version 2.1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "command fails",
+			config: &core.DatabaseConfig{
+				BackupCommand:   "true",
+				MetadataCommand: "echo unreachable >&2; exit 1",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newProvider(t, tt.config)
+
+			metadata, err := provider.GetMetadata()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(metadata, tt.want) {
+				t.Errorf("GetMetadata() = %+v, want %+v", metadata, tt.want)
+			}
+		})
+	}
+}
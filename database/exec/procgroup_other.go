@@ -0,0 +1,8 @@
+//go:build !unix
+
+package exec
+
+import osexec "os/exec"
+
+// setProcessGroup leaves cmd unchanged; cancellation kills the shell only
+func setProcessGroup(cmd *osexec.Cmd) {}
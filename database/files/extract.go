@@ -0,0 +1,205 @@
+package files
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// extract restores the tar archive read from reader below dir. Existing
+// files are replaced. Entries are never written through symlinks, so an
+// archive cannot place files outside dir.
+func extract(ctx context.Context, reader io.Reader, dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid target directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create target directory: %w", err)
+	}
+
+	// Directory permissions and times are applied last, so that read-only
+	// directories can be filled and writing does not change their times
+	var dirs []*tar.Header
+	var dirPaths []string
+
+	tr := tar.NewReader(reader)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		target, err := safeJoin(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		if err := checkParents(dir, target); err != nil {
+			return err
+		}
+
+		if err := extractEntry(tr, header, target); err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeDir {
+			dirs = append(dirs, header)
+			dirPaths = append(dirPaths, target)
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := applyAttributes(dirPaths[i], dirs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractEntry writes a single tar entry to target
+func extractEntry(tr *tar.Reader, header *tar.Header, target string) error {
+	switch header.Typeflag {
+	case tar.TypeDir:
+		info, err := os.Lstat(target)
+		if err == nil && !info.IsDir() {
+			if err := os.Remove(target); err != nil {
+				return fmt.Errorf("failed to replace %s: %w", header.Name, err)
+			}
+		}
+		if err := os.MkdirAll(target, 0700); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", header.Name, err)
+		}
+		return nil
+
+	case tar.TypeReg:
+		if err := prepareTarget(target, header.Name); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create file %s: %w", header.Name, err)
+		}
+		if _, err := io.Copy(file, tr); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write file %s: %w", header.Name, err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", header.Name, err)
+		}
+		return applyAttributes(target, header)
+
+	case tar.TypeSymlink:
+		if err := prepareTarget(target, header.Name); err != nil {
+			return err
+		}
+		if err := os.Symlink(header.Linkname, target); err != nil {
+			return fmt.Errorf("failed to create symlink %s: %w", header.Name, err)
+		}
+		return applyOwner(target, header)
+	}
+
+	return fmt.Errorf("unsupported entry %s in archive", header.Name)
+}
+
+// prepareTarget creates the parent directories of target and removes a
+// file or symlink already at target
+func prepareTarget(target, name string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", name, err)
+	}
+
+	info, err := os.Lstat(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to replace %s: %w", name, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("failed to replace %s: a directory is in the way", name)
+	}
+	if err := os.Remove(target); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", name, err)
+	}
+	return nil
+}
+
+// applyAttributes restores the ownership, permissions and modification time
+// of a file or directory
+func applyAttributes(target string, header *tar.Header) error {
+	// Changing the owner clears set-user-ID bits, so it comes first
+	if err := applyOwner(target, header); err != nil {
+		return err
+	}
+
+	mode := header.FileInfo().Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+	if err := os.Chmod(target, mode); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %w", header.Name, err)
+	}
+
+	if err := os.Chtimes(target, time.Time{}, header.ModTime); err != nil {
+		return fmt.Errorf("failed to set modification time of %s: %w", header.Name, err)
+	}
+	return nil
+}
+
+// applyOwner restores the numeric owner and group of target when running
+// as root, the only user allowed to give files away
+func applyOwner(target string, header *tar.Header) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	if err := os.Lchown(target, header.Uid, header.Gid); err != nil {
+		return fmt.Errorf("failed to set owner of %s: %w", header.Name, err)
+	}
+	return nil
+}
+
+// safeJoin joins name to dir, rejecting absolute names and names that would
+// escape dir
+func safeJoin(dir, name string) (string, error) {
+	local := filepath.FromSlash(strings.TrimSuffix(name, "/"))
+	if local != "" && !filepath.IsLocal(local) {
+		return "", fmt.Errorf("invalid path %s in archive", name)
+	}
+	return filepath.Join(dir, local), nil
+}
+
+// checkParents fails if a directory between dir and target is a symlink,
+// which could redirect writes outside dir
+func checkParents(dir, target string) error {
+	rel, err := filepath.Rel(dir, filepath.Dir(target))
+	if err != nil || rel == "." {
+		return err
+	}
+
+	current := dir
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+
+		info, err := os.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check %s: %w", current, err)
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("refusing to extract %s through symlink %s", target, current)
+		}
+	}
+	return nil
+}
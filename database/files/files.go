@@ -0,0 +1,231 @@
+package files
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"goarchive/core"
+)
+
+// init registers the files provider with the global registry
+func init() {
+	core.RegisterDatabase("files", func(config *core.DatabaseConfig) (core.DatabaseProvider, error) {
+		return New(config)
+	})
+}
+
+// Provider implements the DatabaseProvider interface for files and
+// directories, which are backed up as a tar archive
+type Provider struct {
+	config   *core.DatabaseConfig
+	symlinks string
+}
+
+// New creates a new files provider. Source paths are only needed for
+// backups; restores extract into the directory given in the restore options.
+func New(config *core.DatabaseConfig) (*Provider, error) {
+	symlinks := config.SymlinkPolicy
+	switch symlinks {
+	case "":
+		symlinks = SymlinksPreserve
+	case SymlinksPreserve, SymlinksFollow, SymlinksSkip:
+	default:
+		return nil, fmt.Errorf("unsupported symlink policy: %s", symlinks)
+	}
+
+	if err := validatePatterns(config.IncludePatterns); err != nil {
+		return nil, err
+	}
+	if err := validatePatterns(config.ExcludePatterns); err != nil {
+		return nil, err
+	}
+
+	for _, source := range config.SourcePaths {
+		if _, err := os.Lstat(source); err != nil {
+			return nil, fmt.Errorf("failed to read source path: %w", err)
+		}
+	}
+
+	return &Provider{config: config, symlinks: symlinks}, nil
+}
+
+// walker returns a walker over the configured source paths calling visit
+func (p *Provider) walker(visit func(entry) error) (*walker, error) {
+	if len(p.config.SourcePaths) == 0 {
+		return nil, fmt.Errorf("no source paths configured")
+	}
+
+	return &walker{
+		sources:  p.config.SourcePaths,
+		include:  p.config.IncludePatterns,
+		exclude:  p.config.ExcludePatterns,
+		symlinks: p.symlinks,
+		visit:    visit,
+	}, nil
+}
+
+// Backup streams a tar archive of the source paths. Absolute paths are
+// stored without their leading separator, as tar does, and modes,
+// ownership and modification times are preserved.
+func (p *Provider) Backup(ctx context.Context) (io.ReadCloser, error) {
+	if p.config.BackupMode != "" {
+		return nil, fmt.Errorf("unsupported backup mode: %s", p.config.BackupMode)
+	}
+
+	pr, pw := io.Pipe()
+	tw := tar.NewWriter(pw)
+
+	w, err := p.walker(func(e entry) error { return writeEntry(tw, e) })
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		err := w.walk(ctx)
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return &backupReader{PipeReader: pr, done: done}, nil
+}
+
+// writeEntry writes e to the archive
+func writeEntry(tw *tar.Writer, e entry) error {
+	header, err := tar.FileInfoHeader(e.info, e.link)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", e.path, err)
+	}
+	header.Name = e.name
+	if e.info.IsDir() {
+		header.Name += "/"
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", e.path, err)
+	}
+	if !e.info.Mode().IsRegular() {
+		return nil
+	}
+
+	file, err := os.Open(e.path)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", e.path, err)
+	}
+	defer file.Close()
+
+	// Only the size recorded in the header fits in the archive, so data
+	// appended since is left out
+	if _, err := io.CopyN(tw, file, header.Size); err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to archive %s: file shrank while being read", e.path)
+		}
+		return fmt.Errorf("failed to archive %s: %w", e.path, err)
+	}
+	return nil
+}
+
+// Restore fails, as restores need a target directory; use
+// RestoreWithOptions
+func (p *Provider) Restore(ctx context.Context, reader io.Reader) error {
+	return p.RestoreWithOptions(ctx, reader, &core.RestoreOptions{})
+}
+
+// RestoreWithOptions extracts the archive into opts.DataDirectory, creating
+// it if needed and replacing files already there. Give the root directory
+// to restore files to their original locations.
+func (p *Provider) RestoreWithOptions(ctx context.Context, reader io.Reader, opts *core.RestoreOptions) error {
+	if opts == nil {
+		opts = &core.RestoreOptions{}
+	}
+
+	switch {
+	case opts.BackupMode != "":
+		return fmt.Errorf("unsupported backup mode: %s", opts.BackupMode)
+	case opts.DataDirectory == "":
+		return fmt.Errorf("files restore requires a target directory")
+	case opts.TargetDatabase != "" || opts.CreateDatabase || opts.DropDatabase:
+		return fmt.Errorf("the files provider restores into a target directory and does not support target databases")
+	case opts.Jobs > 1:
+		return fmt.Errorf("parallel restore is not supported by the files provider")
+	case opts.SingleTransaction:
+		return fmt.Errorf("single-transaction restore is not supported by the files provider")
+	case opts.Selective() || opts.TargetSchema != "":
+		return fmt.Errorf("selective restore is not supported by the files provider")
+	}
+
+	if err := extract(ctx, reader, opts.DataDirectory); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to restore files: %w", err)
+	}
+
+	return nil
+}
+
+// GetMetadata counts the files that would be archived and their total size
+func (p *Provider) GetMetadata() (*core.DatabaseMetadata, error) {
+	var count, size int64
+	w, err := p.walker(func(e entry) error {
+		if !e.info.IsDir() {
+			count++
+		}
+		if e.info.Mode().IsRegular() {
+			size += e.info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.walk(context.Background()); err != nil {
+		return nil, err
+	}
+
+	// Name backups after the first source path
+	name, err := filepath.Abs(p.config.SourcePaths[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid source path: %w", err)
+	}
+	name = filepath.Base(name)
+	if name == string(filepath.Separator) || name == "." {
+		name = "files"
+	}
+
+	return &core.DatabaseMetadata{
+		Type: "files",
+		Size: size,
+		Name: name,
+		Tags: map[string]string{"file-count": strconv.FormatInt(count, 10)},
+	}, nil
+}
+
+// Close releases the provider. There are no connections to close.
+func (p *Provider) Close() error {
+	return nil
+}
+
+// backupReader reads the archive while it is written
+type backupReader struct {
+	*io.PipeReader
+	done chan struct{}
+}
+
+// Close stops the archiving and waits for it to finish
+func (r *backupReader) Close() error {
+	r.PipeReader.Close()
+	<-r.done
+	return nil
+}
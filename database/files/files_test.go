@@ -0,0 +1,511 @@
+package files_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"goarchive/core"
+	"goarchive/database/files"
+)
+
+// writeTree creates files below dir from a map of slash-separated names to
+// contents. Names ending in "/" are directories.
+func writeTree(t *testing.T, dir string, tree map[string]string) {
+	t.Helper()
+
+	for name, content := range tree {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newProvider(t *testing.T, config *core.DatabaseConfig) *files.Provider {
+	t.Helper()
+
+	config.Type = "files"
+	provider, err := files.New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { provider.Close() })
+	return provider
+}
+
+// backup runs a backup and returns the archive
+func backup(t *testing.T, provider *files.Provider) []byte {
+	t.Helper()
+
+	reader, err := provider.Backup(context.Background())
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read backup data: %v", err)
+	}
+	return data
+}
+
+// archiveNames lists the entry names in an archive relative to prefix
+func archiveNames(t *testing.T, data []byte, prefix string) []string {
+	t.Helper()
+
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid archive: %v", err)
+		}
+		names = append(names, strings.TrimPrefix(header.Name, prefix))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prefixOf returns the archive name prefix of entries below dir
+func prefixOf(dir string) string {
+	return strings.TrimPrefix(filepath.ToSlash(dir), "/") + "/"
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name   string
+		config *core.DatabaseConfig
+	}{
+		{name: "symlink policy", config: &core.DatabaseConfig{SourcePaths: []string{dir}, SymlinkPolicy: "copy"}},
+		{name: "include pattern", config: &core.DatabaseConfig{SourcePaths: []string{dir}, IncludePatterns: []string{"[a-"}}},
+		{name: "exclude pattern", config: &core.DatabaseConfig{SourcePaths: []string{dir}, ExcludePatterns: []string{"[a-"}}},
+		{name: "missing source path", config: &core.DatabaseConfig{SourcePaths: []string{filepath.Join(dir, "missing")}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := files.New(tt.config); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	provider, err := core.GetDatabase("files", &core.DatabaseConfig{SourcePaths: []string{t.TempDir()}})
+	if err != nil {
+		t.Fatalf("GetDatabase() error = %v", err)
+	}
+	provider.Close()
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "uploads")
+	writeTree(t, source, map[string]string{
+		"a.txt":           "alpha",
+		"images/logo.png": "png",
+		"empty/":          "",
+		"bin/run.sh":      "#!/bin/sh",
+	})
+	if err := os.Chmod(filepath.Join(source, "bin/run.sh"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("images/logo.png", filepath.Join(source, "logo")); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(source, "a.txt"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(source, "images"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := newProvider(t, &core.DatabaseConfig{SourcePaths: []string{source}})
+	data := backup(t, provider)
+
+	// The source directory is stored under its absolute path
+	want := []string{"", "a.txt", "bin/", "bin/run.sh", "empty/", "images/", "images/logo.png", "logo"}
+	if got := archiveNames(t, data, prefixOf(source)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("archive entries = %v, want %v", got, want)
+	}
+
+	target := t.TempDir()
+	err := provider.RestoreWithOptions(context.Background(), bytes.NewReader(data), &core.RestoreOptions{DataDirectory: target})
+	if err != nil {
+		t.Fatalf("RestoreWithOptions() error = %v", err)
+	}
+
+	restored := filepath.Join(target, source)
+	content, err := os.ReadFile(filepath.Join(restored, "a.txt"))
+	if err != nil || string(content) != "alpha" {
+		t.Errorf("restored a.txt = %q, %v", content, err)
+	}
+
+	checks := []struct {
+		name string
+		mode os.FileMode
+	}{
+		{name: "a.txt", mode: 0644},
+		{name: "bin/run.sh", mode: 0750},
+		{name: "images", mode: os.ModeDir | 0700},
+		{name: "empty", mode: os.ModeDir | 0755},
+	}
+	for _, check := range checks {
+		info, err := os.Stat(filepath.Join(restored, check.name))
+		if err != nil {
+			t.Errorf("%s not restored: %v", check.name, err)
+			continue
+		}
+		if info.Mode() != check.mode {
+			t.Errorf("%s mode = %v, want %v", check.name, info.Mode(), check.mode)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(restored, "a.txt"))
+	if err == nil && !info.ModTime().Equal(modTime) {
+		t.Errorf("a.txt modification time = %v, want %v", info.ModTime(), modTime)
+	}
+
+	link, err := os.Readlink(filepath.Join(restored, "logo"))
+	if err != nil || link != "images/logo.png" {
+		t.Errorf("restored symlink = %q, %v", link, err)
+	}
+
+	// Restoring again replaces the files
+	if err := os.WriteFile(filepath.Join(restored, "a.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = provider.RestoreWithOptions(context.Background(), bytes.NewReader(data), &core.RestoreOptions{DataDirectory: target})
+	if err != nil {
+		t.Fatalf("second RestoreWithOptions() error = %v", err)
+	}
+	content, _ = os.ReadFile(filepath.Join(restored, "a.txt"))
+	if string(content) != "alpha" {
+		t.Errorf("expected a.txt to be replaced, got %q", content)
+	}
+}
+
+func TestBackup_RecordsOwnership(t *testing.T) {
+	source := t.TempDir()
+	writeTree(t, source, map[string]string{"file": "data"})
+
+	data := backup(t, newProvider(t, &core.DatabaseConfig{SourcePaths: []string{filepath.Join(source, "file")}}))
+
+	tr := tar.NewReader(bytes.NewReader(data))
+	header, err := tr.Next()
+	if err != nil {
+		t.Fatalf("invalid archive: %v", err)
+	}
+	if header.Uid != os.Getuid() || header.Gid != os.Getgid() {
+		t.Errorf("owner = %d:%d, want %d:%d", header.Uid, header.Gid, os.Getuid(), os.Getgid())
+	}
+}
+
+func TestBackup_Patterns(t *testing.T) {
+	source := t.TempDir()
+	writeTree(t, source, map[string]string{
+		"app.conf":            "",
+		"app.conf.tmp":        "",
+		"notes.txt":           "",
+		"cache/index.conf":    "",
+		"conf.d/site.conf":    "",
+		"conf.d/README":       "",
+		"docs/guide/intro.md": "",
+		"docs/guide/old/x.md": "",
+		"logs/":               "",
+	})
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{
+			name: "everything",
+			want: []string{"", "app.conf", "app.conf.tmp", "cache/", "cache/index.conf", "conf.d/", "conf.d/README", "conf.d/site.conf", "docs/", "docs/guide/", "docs/guide/intro.md", "docs/guide/old/", "docs/guide/old/x.md", "logs/", "notes.txt"},
+		},
+		{
+			name:    "include by name",
+			include: []string{"*.conf"},
+			want:    []string{"", "app.conf", "cache/", "cache/index.conf", "conf.d/", "conf.d/site.conf"},
+		},
+		{
+			name:    "exclude directories and names",
+			include: []string{"*.conf"},
+			exclude: []string{"cache", "*.tmp"},
+			want:    []string{"", "app.conf", "conf.d/", "conf.d/site.conf"},
+		},
+		{
+			name:    "include a directory",
+			include: []string{"docs/guide"},
+			exclude: []string{"docs/guide/old"},
+			want:    []string{"", "docs/", "docs/guide/", "docs/guide/intro.md"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newProvider(t, &core.DatabaseConfig{
+				SourcePaths:     []string{source},
+				IncludePatterns: tt.include,
+				ExcludePatterns: tt.exclude,
+			})
+
+			got := archiveNames(t, backup(t, provider), prefixOf(source))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("archive entries = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackup_SymlinkPolicies(t *testing.T) {
+	root := t.TempDir()
+	shared := filepath.Join(root, "shared")
+	source := filepath.Join(root, "site")
+	writeTree(t, root, map[string]string{
+		"shared/style.css": "body {}",
+		"site/index.html":  "<html>",
+	})
+	if err := os.Symlink(shared, filepath.Join(source, "assets")); err != nil {
+		t.Fatal(err)
+	}
+	// A loop back to the source must not be followed forever
+	if err := os.Symlink("..", filepath.Join(source, "parent")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{policy: "", want: []string{"", "assets", "index.html", "parent"}},
+		{policy: files.SymlinksSkip, want: []string{"", "index.html"}},
+		{
+			policy: files.SymlinksFollow,
+			want: []string{
+				"", "assets/", "assets/style.css", "index.html",
+				"parent/", "parent/shared/", "parent/shared/style.css",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			provider := newProvider(t, &core.DatabaseConfig{SourcePaths: []string{source}, SymlinkPolicy: tt.policy})
+
+			got := archiveNames(t, backup(t, provider), prefixOf(source))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("archive entries = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackup_EarlyClose(t *testing.T) {
+	source := t.TempDir()
+	writeTree(t, source, map[string]string{"big": strings.Repeat("x", 1<<20)})
+
+	provider := newProvider(t, &core.DatabaseConfig{SourcePaths: []string{source}})
+	reader, err := provider.Backup(context.Background())
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+
+	if _, err := reader.Read(make([]byte, 512)); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		reader.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() did not stop the backup")
+	}
+}
+
+func TestGetMetadata(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "uploads")
+	writeTree(t, source, map[string]string{
+		"a.txt":     "12345",
+		"dir/b.txt": "123",
+		"skip.tmp":  "1234567890",
+	})
+	if err := os.Symlink("a.txt", filepath.Join(source, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := newProvider(t, &core.DatabaseConfig{
+		SourcePaths:     []string{source},
+		ExcludePatterns: []string{"*.tmp"},
+	})
+
+	metadata, err := provider.GetMetadata()
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+
+	if metadata.Type != "files" || metadata.Name != "uploads" {
+		t.Errorf("unexpected Type %q and Name %q", metadata.Type, metadata.Name)
+	}
+	if metadata.Size != 8 {
+		t.Errorf("expected Size 8, got %d", metadata.Size)
+	}
+	if metadata.Tags["file-count"] != "3" {
+		t.Errorf("expected file-count 3, got %q", metadata.Tags["file-count"])
+	}
+}
+
+func TestGetMetadata_NoSourcePaths(t *testing.T) {
+	provider := newProvider(t, &core.DatabaseConfig{})
+
+	if _, err := provider.GetMetadata(); err == nil {
+		t.Error("expected error without source paths, got nil")
+	}
+	if _, err := provider.Backup(context.Background()); err == nil {
+		t.Error("expected error without source paths, got nil")
+	}
+}
+
+// archive builds a tar archive from headers, giving regular files the
+// content "data"
+func archive(t *testing.T, headers ...*tar.Header) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, header := range headers {
+		if header.Typeflag == tar.TypeReg {
+			header.Size = 4
+		}
+		if header.Mode == 0 {
+			header.Mode = 0644
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			tw.Write([]byte("data"))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRestore_PathTraversal(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []*tar.Header
+	}{
+		{
+			name:    "parent directory",
+			headers: []*tar.Header{{Name: "../evil", Typeflag: tar.TypeReg}},
+		},
+		{
+			name:    "nested parent directory",
+			headers: []*tar.Header{{Name: "a/../../evil", Typeflag: tar.TypeReg}},
+		},
+		{
+			name: "through a symlink",
+			headers: []*tar.Header{
+				{Name: "link", Typeflag: tar.TypeSymlink, Linkname: ".."},
+				{Name: "link/evil", Typeflag: tar.TypeReg},
+			},
+		},
+		{
+			name: "through a replaced symlink",
+			headers: []*tar.Header{
+				{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "dir", Typeflag: tar.TypeSymlink, Linkname: ".."},
+				{Name: "dir/evil", Typeflag: tar.TypeReg},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			target := filepath.Join(parent, "target")
+			provider := newProvider(t, &core.DatabaseConfig{})
+
+			err := provider.RestoreWithOptions(context.Background(), bytes.NewReader(archive(t, tt.headers...)),
+				&core.RestoreOptions{DataDirectory: target})
+			if err == nil {
+				t.Error("expected error, got nil")
+			}
+			if _, err := os.Stat(filepath.Join(parent, "evil")); err == nil {
+				t.Error("a file was written outside the target directory")
+			}
+		})
+	}
+}
+
+func TestRestore_AbsoluteName(t *testing.T) {
+	target := t.TempDir()
+	provider := newProvider(t, &core.DatabaseConfig{})
+
+	data := archive(t, &tar.Header{Name: "/etc/evil", Typeflag: tar.TypeReg})
+	if err := provider.RestoreWithOptions(context.Background(), bytes.NewReader(data), &core.RestoreOptions{DataDirectory: target}); err == nil {
+		t.Error("expected error for an absolute name, got nil")
+	}
+}
+
+func TestRestoreWithOptions_Unsupported(t *testing.T) {
+	provider := newProvider(t, &core.DatabaseConfig{})
+	data := archive(t, &tar.Header{Name: "file", Typeflag: tar.TypeReg})
+
+	tests := []struct {
+		name string
+		opts *core.RestoreOptions
+	}{
+		{name: "no target directory", opts: &core.RestoreOptions{}},
+		{name: "target database", opts: &core.RestoreOptions{DataDirectory: t.TempDir(), TargetDatabase: "other"}},
+		{name: "parallel", opts: &core.RestoreOptions{DataDirectory: t.TempDir(), Jobs: 4}},
+		{name: "selective", opts: &core.RestoreOptions{DataDirectory: t.TempDir(), Tables: []string{"users"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := provider.RestoreWithOptions(context.Background(), bytes.NewReader(data), tt.opts); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+
+	if err := provider.Restore(context.Background(), bytes.NewReader(data)); err == nil {
+		t.Error("expected Restore() without a target directory to fail, got nil")
+	}
+}
@@ -0,0 +1,7 @@
+module goarchive/database/files
+
+go 1.24.0
+
+require goarchive v0.0.0
+
+replace goarchive => ../../
@@ -0,0 +1,221 @@
+package files
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Symlink policies
+const (
+	SymlinksPreserve = "preserve" // Archive symlinks as links (default)
+	SymlinksFollow   = "follow"   // Archive what symlinks point to
+	SymlinksSkip     = "skip"     // Leave symlinks out
+)
+
+// entry is a file, directory or symlink to archive
+type entry struct {
+	name string      // Slash-separated name in the archive
+	path string      // Path on disk
+	info fs.FileInfo // Information about the file, or the symlink target when following
+	link string      // Target of a preserved symlink
+}
+
+// walker visits the entries selected from the source paths in archive order
+type walker struct {
+	sources  []string
+	include  []string
+	exclude  []string
+	symlinks string
+	visit    func(entry) error
+
+	pending []entry // Directories not yet visited, in case include patterns select nothing below them
+}
+
+// validatePatterns checks that every pattern is a valid glob
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matches reports whether rel, a slash-separated path relative to its source
+// path, matches one of patterns. Patterns containing a slash match rel or one
+// of its parent directories; other patterns match any name in rel.
+func matches(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if strings.Contains(pattern, "/") {
+			for p := rel; ; {
+				if ok, _ := path.Match(pattern, p); ok {
+					return true
+				}
+				i := strings.LastIndex(p, "/")
+				if i < 0 {
+					break
+				}
+				p = p[:i]
+			}
+			continue
+		}
+
+		for _, name := range strings.Split(rel, "/") {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// archiveName returns the name of an absolute path in the archive: the path
+// without its volume name and leading separator, as tar does
+func archiveName(abs string) string {
+	return strings.TrimPrefix(filepath.ToSlash(abs[len(filepath.VolumeName(abs)):]), "/")
+}
+
+// walk visits every selected entry below the source paths
+func (w *walker) walk(ctx context.Context) error {
+	for _, source := range w.sources {
+		abs, err := filepath.Abs(source)
+		if err != nil {
+			return fmt.Errorf("invalid source path %s: %w", source, err)
+		}
+
+		info, err := os.Lstat(abs)
+		if err != nil {
+			return fmt.Errorf("failed to read source path: %w", err)
+		}
+
+		// A source file is matched by its name, a directory's contents
+		// relative to it
+		rel := "."
+		if !info.IsDir() {
+			rel = filepath.Base(abs)
+		}
+
+		if err := w.walkEntry(ctx, entry{name: archiveName(abs), path: abs, info: info}, rel, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkEntry visits e, and the contents of directories. ancestors holds the
+// directories above e, to detect loops through followed symlinks.
+func (w *walker) walkEntry(ctx context.Context, e entry, rel string, ancestors []fs.FileInfo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if rel != "." && matches(w.exclude, rel) {
+		return nil
+	}
+	included := len(w.include) == 0 || matches(w.include, rel)
+
+	mode := e.info.Mode()
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		switch w.symlinks {
+		case SymlinksSkip:
+			return nil
+		case SymlinksFollow:
+			info, err := os.Stat(e.path)
+			if err != nil {
+				return fmt.Errorf("failed to follow symlink: %w", err)
+			}
+			e.info = info
+			return w.walkEntry(ctx, e, rel, ancestors)
+		}
+
+		link, err := os.Readlink(e.path)
+		if err != nil {
+			return fmt.Errorf("failed to read symlink: %w", err)
+		}
+		e.link = link
+		if !included {
+			return nil
+		}
+		return w.emit(e)
+
+	case mode.IsDir():
+		for _, ancestor := range ancestors {
+			if os.SameFile(ancestor, e.info) {
+				// A followed symlink leads back up the tree
+				return nil
+			}
+		}
+
+		if e.name != "" {
+			w.pending = append(w.pending, e)
+			if included {
+				if err := w.flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		children, err := os.ReadDir(e.path)
+		if err != nil {
+			return fmt.Errorf("failed to read directory: %w", err)
+		}
+
+		ancestors = append(ancestors, e.info)
+		for _, child := range children {
+			info, err := child.Info()
+			if err != nil {
+				return fmt.Errorf("failed to read directory: %w", err)
+			}
+
+			childRel := child.Name()
+			if rel != "." {
+				childRel = rel + "/" + child.Name()
+			}
+
+			childEntry := entry{name: path.Join(e.name, child.Name()), path: filepath.Join(e.path, child.Name()), info: info}
+			if err := w.walkEntry(ctx, childEntry, childRel, ancestors); err != nil {
+				return err
+			}
+		}
+
+		// Drop the directory if nothing below it was selected
+		if n := len(w.pending); n > 0 && w.pending[n-1].path == e.path {
+			w.pending = w.pending[:n-1]
+		}
+		return nil
+
+	case mode.IsRegular():
+		if !included {
+			return nil
+		}
+		return w.emit(e)
+	}
+
+	// Sockets, devices and named pipes cannot be backed up as files
+	return nil
+}
+
+// emit visits the pending directories and then e
+func (w *walker) emit(e entry) error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.visit(e)
+}
+
+// flush visits the pending directories
+func (w *walker) flush() error {
+	for _, dir := range w.pending {
+		if err := w.visit(dir); err != nil {
+			return err
+		}
+	}
+	w.pending = w.pending[:0]
+	return nil
+}
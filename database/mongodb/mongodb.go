@@ -0,0 +1,306 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"goarchive/core"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// defaultConnectTimeout bounds the initial connection when no timeout is
+// configured
+const defaultConnectTimeout = 10 * time.Second
+
+// init registers the MongoDB provider with the global registry
+func init() {
+	core.RegisterDatabase("mongodb", func(config *core.DatabaseConfig) (core.DatabaseProvider, error) {
+		return New(config)
+	})
+}
+
+// Provider implements the DatabaseProvider interface for MongoDB
+type Provider struct {
+	config   *core.DatabaseConfig
+	client   *mongo.Client
+	uri      *url.URL
+	database string
+}
+
+// New creates a new MongoDB provider
+func New(config *core.DatabaseConfig) (*Provider, error) {
+	uri, database, err := connectionURI(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := mongo.Connect(options.Client().ApplyURI(uri.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection settings: %w", err)
+	}
+
+	timeout := defaultConnectTimeout
+	if config.ConnectTimeout > 0 {
+		timeout = time.Duration(config.ConnectTimeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+
+	return &Provider{
+		config:   config,
+		client:   client,
+		uri:      uri,
+		database: database,
+	}, nil
+}
+
+// Backup streams a gzip-compressed mongodump archive of the database
+func (p *Provider) Backup(ctx context.Context) (io.ReadCloser, error) {
+	if p.config.BackupMode != "" {
+		return nil, fmt.Errorf("unsupported backup mode: %s", p.config.BackupMode)
+	}
+
+	excluded, err := p.excludedCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	configPath, cleanup, err := writeToolConfig(p.uri)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"--config=" + configPath, "--db=" + p.database, "--archive", "--gzip"}
+	for _, name := range excluded {
+		args = append(args, "--excludeCollection="+name)
+	}
+
+	cmd := exec.CommandContext(ctx, "mongodump", args...)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to create pipe: %w", err)
+	}
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to start mongodump: %w", err)
+	}
+
+	return &backupReader{ReadCloser: stdout, cmd: cmd, stderr: &stderr, cleanup: cleanup}, nil
+}
+
+// excludedCollections returns the collections to leave out of the backup.
+// mongodump can only include a single collection, so an include list is
+// turned into the exclusion of every other collection.
+func (p *Provider) excludedCollections(ctx context.Context) ([]string, error) {
+	excluded := slices.Clone(p.config.ExcludeCollections)
+	if len(p.config.Collections) == 0 {
+		return excluded, nil
+	}
+
+	names, err := p.client.Database(p.database).ListCollectionNames(ctx, bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	for _, name := range p.config.Collections {
+		if !slices.Contains(names, name) {
+			return nil, fmt.Errorf("collection %q not found in database %q", name, p.database)
+		}
+	}
+	for _, name := range names {
+		if !slices.Contains(p.config.Collections, name) && !slices.Contains(excluded, name) {
+			excluded = append(excluded, name)
+		}
+	}
+
+	return excluded, nil
+}
+
+// Restore restores a mongodump archive, replacing existing collections
+func (p *Provider) Restore(ctx context.Context, reader io.Reader) error {
+	return p.RestoreWithOptions(ctx, reader, &core.RestoreOptions{})
+}
+
+// RestoreWithOptions restores a mongodump archive of the configured
+// database with mongorestore --drop, optionally into a different database
+// or only for some collections
+func (p *Provider) RestoreWithOptions(ctx context.Context, reader io.Reader, opts *core.RestoreOptions) error {
+	if opts == nil {
+		opts = &core.RestoreOptions{}
+	}
+
+	switch {
+	case opts.BackupMode != "":
+		return fmt.Errorf("unsupported backup mode: %s", opts.BackupMode)
+	case opts.SingleTransaction:
+		return fmt.Errorf("single-transaction restore is not supported by the mongodb provider")
+	case len(opts.Schemas) > 0 || opts.TargetSchema != "":
+		return fmt.Errorf("schemas are not supported by the mongodb provider, use --table to select collections")
+	}
+
+	target := opts.TargetDatabase
+	if target == "" {
+		target = p.database
+	}
+
+	if opts.DropDatabase {
+		if err := p.client.Database(target).Drop(ctx); err != nil {
+			return fmt.Errorf("failed to drop database %q: %w", target, err)
+		}
+	}
+
+	configPath, cleanup, err := writeToolConfig(p.uri)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	cmd := exec.CommandContext(ctx, "mongorestore", restoreArgs(configPath, p.database, target, opts)...)
+	cmd.Stdin = reader
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to restore database: %w (output: %s)", err, string(output))
+	}
+
+	return nil
+}
+
+// restoreArgs builds the mongorestore arguments restoring the source
+// database of the archive into target
+func restoreArgs(configPath, source, target string, opts *core.RestoreOptions) []string {
+	args := []string{"--config=" + configPath, "--archive", "--gzip", "--drop"}
+
+	if len(opts.Tables) == 0 {
+		args = append(args, "--nsInclude="+escapeNamespace(source)+".*")
+	}
+	for _, table := range opts.Tables {
+		args = append(args, "--nsInclude="+escapeNamespace(source)+"."+escapeNamespace(table))
+	}
+
+	if target != source {
+		args = append(args, "--nsFrom="+escapeNamespace(source)+".*", "--nsTo="+escapeNamespace(target)+".*")
+	}
+	if opts.Jobs > 1 {
+		args = append(args, "--numParallelCollections="+strconv.Itoa(opts.Jobs))
+	}
+
+	return args
+}
+
+// escapeNamespace escapes the wildcard and escape characters of
+// mongorestore namespace patterns
+func escapeNamespace(name string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`).Replace(name)
+}
+
+// GetMetadata returns metadata about the database from buildInfo and
+// dbStats
+func (p *Provider) GetMetadata() (*core.DatabaseMetadata, error) {
+	ctx := context.Background()
+
+	var buildInfo struct {
+		Version string `bson:"version"`
+	}
+	err := p.client.Database("admin").RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&buildInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get version: %w", err)
+	}
+
+	var stats bson.M
+	err = p.client.Database(p.database).RunCommand(ctx, bson.D{{Key: "dbStats", Value: 1}}).Decode(&stats)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database size: %w", err)
+	}
+
+	return &core.DatabaseMetadata{
+		Type:    "mongodb",
+		Version: buildInfo.Version,
+		Size:    toInt64(stats["storageSize"]) + toInt64(stats["indexSize"]),
+		Name:    p.database,
+	}, nil
+}
+
+// toInt64 converts a numeric BSON value, which dbStats returns as a 32-bit
+// or 64-bit integer or a double depending on its size and server version
+func toInt64(value any) int64 {
+	switch v := value.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// Close disconnects from the server
+func (p *Provider) Close() error {
+	if p.client != nil {
+		return p.client.Disconnect(context.Background())
+	}
+	return nil
+}
+
+// backupReader wraps the stdout pipe and waits for mongodump to complete
+type backupReader struct {
+	io.ReadCloser
+	cmd     *exec.Cmd
+	stderr  *strings.Builder
+	cleanup func()
+	waited  bool
+	err     error
+}
+
+// Read reads from the pipe and, once mongodump exits, reports its failure
+// instead of io.EOF
+func (r *backupReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF {
+		if waitErr := r.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Close closes the pipe, waits for mongodump to finish and removes its
+// configuration file
+func (r *backupReader) Close() error {
+	r.ReadCloser.Close()
+	defer r.cleanup()
+	return r.wait()
+}
+
+// wait waits for mongodump to finish, once
+func (r *backupReader) wait() error {
+	if !r.waited {
+		r.waited = true
+		if err := r.cmd.Wait(); err != nil {
+			r.err = fmt.Errorf("mongodump failed: %w (output: %s)", err, strings.TrimSpace(r.stderr.String()))
+		}
+	}
+	return r.err
+}
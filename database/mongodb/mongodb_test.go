@@ -0,0 +1,249 @@
+package mongodb_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"goarchive/core"
+	"goarchive/database/mongodb"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func TestNew_InvalidConnection(t *testing.T) {
+	config := &core.DatabaseConfig{
+		Type:           "mongodb",
+		Host:           "invalid-host-that-does-not-exist",
+		Port:           27017,
+		Database:       "testdb",
+		ConnectTimeout: 1,
+	}
+
+	_, err := mongodb.New(config)
+	if err == nil {
+		t.Error("Expected error with invalid connection, got nil")
+	}
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	config := &core.DatabaseConfig{
+		Type:           "mongodb",
+		Host:           "localhost",
+		Port:           27017,
+		Database:       "testdb",
+		ConnectTimeout: 1,
+	}
+
+	provider, err := core.GetDatabase("mongodb", config)
+	if err == nil {
+		if provider == nil {
+			t.Error("expected non-nil provider from auto-registration")
+		}
+		provider.Close()
+	} else if err.Error() == "database provider not registered: mongodb" {
+		t.Error("mongodb provider not auto-registered")
+	}
+}
+
+// Integration tests - these require a real MongoDB server and the
+// mongodump and mongorestore tools
+
+func getTestConfig() *core.DatabaseConfig {
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		uri = "mongodb://localhost:27017/testdb"
+	}
+
+	return &core.DatabaseConfig{
+		Type:           "mongodb",
+		URI:            uri,
+		ConnectTimeout: 5,
+	}
+}
+
+// backup runs a backup with the given collection filters
+func backup(t *testing.T, config *core.DatabaseConfig, include, exclude []string) []byte {
+	t.Helper()
+
+	filtered := *config
+	filtered.Collections = include
+	filtered.ExcludeCollections = exclude
+
+	provider, err := mongodb.New(&filtered)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	reader, err := provider.Backup(context.Background())
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read backup data: %v", err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatalf("Backup() close error = %v", err)
+	}
+	return data
+}
+
+func TestIntegration_MongoDB(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	config := getTestConfig()
+
+	provider, err := mongodb.New(config)
+	if err != nil {
+		t.Skipf("Skipping integration test - MongoDB not available: %v", err)
+	}
+	defer provider.Close()
+
+	uri, database, err := mongodb.ConnectionURI(config)
+	if err != nil {
+		t.Fatalf("ConnectionURI() error = %v", err)
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(options.Client().ApplyURI(config.URI))
+	if err != nil {
+		t.Fatalf("failed to connect to %s: %v", uri, err)
+	}
+	defer client.Disconnect(ctx)
+
+	db := client.Database(database)
+	for _, name := range []string{"users", "orders", "sessions"} {
+		if err := db.Collection(name).Drop(ctx); err != nil {
+			t.Fatalf("failed to drop %s: %v", name, err)
+		}
+		if _, err := db.Collection(name).InsertMany(ctx, []any{bson.D{{Key: "n", Value: 1}}, bson.D{{Key: "n", Value: 2}}}); err != nil {
+			t.Fatalf("failed to insert into %s: %v", name, err)
+		}
+	}
+
+	// collections returns the collections restored into a database
+	collections := func(t *testing.T, name string) []string {
+		names, err := client.Database(name).ListCollectionNames(ctx, bson.D{})
+		if err != nil {
+			t.Fatalf("failed to list collections: %v", err)
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	t.Run("GetMetadata", func(t *testing.T) {
+		metadata, err := provider.GetMetadata()
+		if err != nil {
+			t.Fatalf("GetMetadata() error = %v", err)
+		}
+
+		if metadata.Type != "mongodb" {
+			t.Errorf("Expected Type 'mongodb', got '%s'", metadata.Type)
+		}
+		if metadata.Name != database {
+			t.Errorf("Expected Name '%s', got '%s'", database, metadata.Name)
+		}
+		if metadata.Version == "" {
+			t.Error("Expected non-empty Version")
+		}
+		if metadata.Size <= 0 {
+			t.Errorf("Expected positive Size, got %d", metadata.Size)
+		}
+	})
+
+	t.Run("BackupRestore", func(t *testing.T) {
+		data := backup(t, config, nil, []string{"sessions"})
+		if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+			t.Fatal("expected a gzip-compressed archive")
+		}
+
+		target := database + "_copy"
+		err := provider.RestoreWithOptions(ctx, bytes.NewReader(data), &core.RestoreOptions{
+			TargetDatabase: target,
+			DropDatabase:   true,
+		})
+		if err != nil {
+			t.Fatalf("RestoreWithOptions() error = %v", err)
+		}
+
+		if got := collections(t, target); !slices.Equal(got, []string{"orders", "users"}) {
+			t.Errorf("expected collections [orders users], got %v", got)
+		}
+		count, err := client.Database(target).Collection("users").CountDocuments(ctx, bson.D{})
+		if err != nil || count != 2 {
+			t.Errorf("expected 2 restored users, got %d (%v)", count, err)
+		}
+	})
+
+	t.Run("IncludeCollections", func(t *testing.T) {
+		data := backup(t, config, []string{"users"}, nil)
+
+		target := database + "_users"
+		err := provider.RestoreWithOptions(ctx, bytes.NewReader(data), &core.RestoreOptions{
+			TargetDatabase: target,
+			DropDatabase:   true,
+		})
+		if err != nil {
+			t.Fatalf("RestoreWithOptions() error = %v", err)
+		}
+
+		if got := collections(t, target); !slices.Equal(got, []string{"users"}) {
+			t.Errorf("expected collections [users], got %v", got)
+		}
+	})
+
+	t.Run("MissingCollection", func(t *testing.T) {
+		filtered := *config
+		filtered.Collections = []string{"does_not_exist"}
+
+		p, err := mongodb.New(&filtered)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		defer p.Close()
+
+		if _, err := p.Backup(ctx); err == nil {
+			t.Error("expected error for a missing collection, got nil")
+		}
+	})
+
+	t.Run("BackupFailure", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("fake tools use shell scripts")
+		}
+
+		// A dump that fails halfway must not read as a complete backup
+		dir := t.TempDir()
+		script := "#!/bin/sh\nprintf 'partial archive'\necho 'Failed: connection reset by peer' >&2\nexit 1\n"
+		if err := os.WriteFile(filepath.Join(dir, "mongodump"), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		reader, err := provider.Backup(ctx)
+		if err != nil {
+			t.Fatalf("Backup() error = %v", err)
+		}
+		defer reader.Close()
+
+		_, err = io.ReadAll(reader)
+		if err == nil || !strings.Contains(err.Error(), "connection reset") || !strings.Contains(err.Error(), "exit status 1") {
+			t.Errorf("expected the dump failure from Read, got %v", err)
+		}
+		if err := reader.Close(); err == nil {
+			t.Error("expected Close() to report the failure, got nil")
+		}
+	})
+}
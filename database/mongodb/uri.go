@@ -0,0 +1,161 @@
+package mongodb
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"goarchive/core"
+)
+
+// defaultApplicationName is reported to the server when none is configured
+const defaultApplicationName = "goarchive"
+
+// defaultPort is used when the configuration sets no port
+const defaultPort = "27017"
+
+// connectionURI builds the connection URI and database name from config. A
+// URI ("mongodb://" or "mongodb+srv://") replaces Host, Port, Username and
+// SSLMode, and its database, if any, replaces Database. The database is
+// moved out of the returned URI into authSource, so the tools do not mistake
+// it for a namespace filter.
+func connectionURI(config *core.DatabaseConfig) (*url.URL, string, error) {
+	if config.PassFile != "" || config.Service != "" {
+		return nil, "", fmt.Errorf("password files and services are not supported by the mongodb provider")
+	}
+
+	database := config.Database
+	var uri *url.URL
+	query := url.Values{}
+
+	if config.URI != "" {
+		parsed, err := url.Parse(config.URI)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid connection URI: %w", err)
+		}
+		if parsed.Scheme != "mongodb" && parsed.Scheme != "mongodb+srv" {
+			return nil, "", fmt.Errorf("invalid connection URI: unsupported scheme %q", parsed.Scheme)
+		}
+		uri = parsed
+		query = parsed.Query()
+
+		if name := strings.TrimPrefix(parsed.Path, "/"); name != "" {
+			database = name
+			if !query.Has("authSource") {
+				query.Set("authSource", name)
+			}
+		}
+	} else {
+		port := defaultPort
+		if config.Port != 0 {
+			port = strconv.Itoa(config.Port)
+		}
+		uri = &url.URL{Scheme: "mongodb", Host: net.JoinHostPort(config.Host, port)}
+		if config.Username != "" {
+			uri.User = url.User(config.Username)
+		}
+
+		if err := applyTLS(query, config); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if config.Password != "" {
+		username := config.Username
+		if uri.User != nil {
+			username = uri.User.Username()
+		}
+		uri.User = url.UserPassword(username, config.Password)
+	}
+
+	if config.ConnectTimeout > 0 {
+		query.Set("connectTimeoutMS", strconv.Itoa(config.ConnectTimeout*1000))
+		query.Set("serverSelectionTimeoutMS", strconv.Itoa(config.ConnectTimeout*1000))
+	}
+	if config.ApplicationName != "" {
+		query.Set("appName", config.ApplicationName)
+	} else if !query.Has("appName") {
+		query.Set("appName", defaultApplicationName)
+	}
+
+	if database == "" {
+		return nil, "", fmt.Errorf("database name is required")
+	}
+
+	uri.Path = "/"
+	uri.RawQuery = query.Encode()
+
+	return uri, database, nil
+}
+
+// applyTLS maps the PostgreSQL-style SSL mode and certificate files onto the
+// URI's TLS options
+func applyTLS(query url.Values, config *core.DatabaseConfig) error {
+	switch config.SSLMode {
+	case "", "prefer", "disable":
+		// MongoDB has no opportunistic TLS, so prefer connects without it
+		query.Set("tls", "false")
+		return nil
+	case "require":
+		query.Set("tlsInsecure", "true")
+	case "verify-ca":
+		query.Set("tlsAllowInvalidHostnames", "true")
+	case "verify-full":
+	default:
+		return fmt.Errorf("unsupported SSL mode: %s", config.SSLMode)
+	}
+	query.Set("tls", "true")
+
+	if config.SSLRootCert != "" {
+		query.Set("tlsCAFile", config.SSLRootCert)
+	}
+
+	// MongoDB expects the client certificate and key in one PEM file
+	if config.SSLKey != "" && config.SSLKey != config.SSLCert {
+		return fmt.Errorf("the mongodb provider needs the client certificate and key in one file: set DB_SSLCERT only")
+	}
+	if config.SSLCert != "" {
+		query.Set("tlsCertificateKeyFile", config.SSLCert)
+	}
+
+	return nil
+}
+
+// ConnectionURI returns the connection URI for config, with the password
+// left out, and the name of the database to back up
+func ConnectionURI(config *core.DatabaseConfig) (string, string, error) {
+	uri, database, err := connectionURI(config)
+	if err != nil {
+		return "", "", err
+	}
+	if uri.User != nil {
+		uri.User = url.User(uri.User.Username())
+	}
+	return uri.String(), database, nil
+}
+
+// writeToolConfig writes a YAML configuration file for mongodump and
+// mongorestore holding the URI, which keeps the password off the command
+// line. The returned cleanup function removes it.
+func writeToolConfig(uri *url.URL) (string, func(), error) {
+	file, err := os.CreateTemp("", "goarchive-mongodb-*.yaml")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create tool configuration: %w", err)
+	}
+	cleanup := func() { os.Remove(file.Name()) }
+
+	if _, err := fmt.Fprintf(file, "uri: %s\n", strconv.Quote(uri.String())); err != nil {
+		file.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write tool configuration: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write tool configuration: %w", err)
+	}
+
+	return file.Name(), cleanup, nil
+}
@@ -0,0 +1,236 @@
+package mysql
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"goarchive/core"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// tlsConfigName is the name the custom TLS configuration is registered under
+const tlsConfigName = "goarchive"
+
+// defaultApplicationName is reported to the server when none is configured
+const defaultApplicationName = "goarchive"
+
+// defaultPort is used when the configuration sets no port
+const defaultPort = "3306"
+
+// driverConfig builds the driver configuration from config. A URI is parsed
+// as a driver DSN ("user:password@tcp(host:3306)/db?param=value") and
+// replaces Host, Port, Username, Database and SSLMode.
+func driverConfig(config *core.DatabaseConfig) (*mysql.Config, error) {
+	if config.PassFile != "" || config.Service != "" {
+		return nil, fmt.Errorf("password files and services are not supported by the mysql provider")
+	}
+
+	cfg := mysql.NewConfig()
+	if config.URI != "" {
+		parsed, err := mysql.ParseDSN(config.URI)
+		if err != nil {
+			return nil, fmt.Errorf("invalid connection URI: %w", err)
+		}
+		cfg = parsed
+	} else {
+		port := defaultPort
+		if config.Port != 0 {
+			port = strconv.Itoa(config.Port)
+		}
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(config.Host, port)
+		cfg.User = config.Username
+		cfg.DBName = config.Database
+
+		if err := applyTLS(cfg, config); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Password != "" {
+		cfg.Passwd = config.Password
+	}
+	if config.ConnectTimeout > 0 {
+		cfg.Timeout = time.Duration(config.ConnectTimeout) * time.Second
+	}
+
+	applicationName := config.ApplicationName
+	if applicationName == "" {
+		applicationName = defaultApplicationName
+	}
+	cfg.ConnectionAttributes = "program_name:" + applicationName
+
+	return cfg, nil
+}
+
+// applyTLS maps the PostgreSQL-style SSL mode and certificate files onto the
+// driver's TLS settings
+func applyTLS(cfg *mysql.Config, config *core.DatabaseConfig) error {
+	switch config.SSLMode {
+	case "", "prefer":
+		cfg.TLSConfig = "preferred"
+		return nil
+	case "disable":
+		cfg.TLSConfig = "false"
+		return nil
+	case "require", "verify-ca", "verify-full":
+	default:
+		return fmt.Errorf("unsupported SSL mode: %s", config.SSLMode)
+	}
+
+	tlsConfig := &tls.Config{ServerName: config.Host, MinVersion: tls.VersionTLS12}
+
+	if config.SSLRootCert != "" {
+		pem, err := os.ReadFile(config.SSLRootCert)
+		if err != nil {
+			return fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", config.SSLRootCert)
+		}
+	}
+
+	if config.SSLCert != "" || config.SSLKey != "" {
+		cert, err := tls.LoadX509KeyPair(config.SSLCert, config.SSLKey)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	switch config.SSLMode {
+	case "require":
+		tlsConfig.InsecureSkipVerify = true
+	case "verify-ca":
+		// Verify the chain but not the host name
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			opts := x509.VerifyOptions{Roots: tlsConfig.RootCAs, Intermediates: x509.NewCertPool()}
+			for _, cert := range state.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := state.PeerCertificates[0].Verify(opts)
+			return err
+		}
+	}
+
+	cfg.TLS = tlsConfig
+	return nil
+}
+
+// DSN returns the driver data source name for config, with the password
+// left out
+func DSN(config *core.DatabaseConfig) (string, error) {
+	cfg, err := driverConfig(config)
+	if err != nil {
+		return "", err
+	}
+	cfg.Passwd = ""
+
+	// A custom TLS configuration cannot be expressed in a DSN
+	if cfg.TLSConfig == "" && cfg.TLS != nil {
+		cfg.TLSConfig = tlsConfigName
+	}
+
+	return cfg.FormatDSN(), nil
+}
+
+// optionFile renders a [client] option file for the mysql command-line
+// tools. Options only one of MySQL and MariaDB understands use the loose-
+// prefix, which makes the other ignore them.
+func optionFile(config *core.DatabaseConfig, cfg *mysql.Config) string {
+	var b strings.Builder
+	b.WriteString("[client]\n")
+
+	writeOption := func(name, value string) {
+		fmt.Fprintf(&b, "%s=\"%s\"\n", name, optionEscaper.Replace(value))
+	}
+
+	host, port, err := net.SplitHostPort(cfg.Addr)
+	switch {
+	case cfg.Net == "unix":
+		writeOption("socket", cfg.Addr)
+	case err == nil:
+		writeOption("host", host)
+		writeOption("port", port)
+	default:
+		writeOption("host", cfg.Addr)
+	}
+
+	writeOption("user", cfg.User)
+	if cfg.Passwd != "" {
+		writeOption("password", cfg.Passwd)
+	}
+	if cfg.Timeout > 0 {
+		writeOption("loose-connect-timeout", strconv.Itoa(int(cfg.Timeout.Seconds())))
+	}
+
+	if config.URI == "" {
+		writeTLSOptions(writeOption, config)
+	}
+
+	writeOption("default-character-set", "utf8mb4")
+
+	return b.String()
+}
+
+// writeTLSOptions writes the SSL mode and certificate files for the tools
+func writeTLSOptions(writeOption func(name, value string), config *core.DatabaseConfig) {
+	switch config.SSLMode {
+	case "disable":
+		writeOption("loose-ssl-mode", "DISABLED")
+		writeOption("loose-skip-ssl", "1")
+	case "require":
+		writeOption("loose-ssl-mode", "REQUIRED")
+		writeOption("loose-ssl", "1")
+	case "verify-ca":
+		writeOption("loose-ssl-mode", "VERIFY_CA")
+		writeOption("loose-ssl-verify-server-cert", "1")
+	case "verify-full":
+		writeOption("loose-ssl-mode", "VERIFY_IDENTITY")
+		writeOption("loose-ssl-verify-server-cert", "1")
+	}
+
+	if config.SSLRootCert != "" {
+		writeOption("ssl-ca", config.SSLRootCert)
+	}
+	if config.SSLCert != "" {
+		writeOption("ssl-cert", config.SSLCert)
+	}
+	if config.SSLKey != "" {
+		writeOption("ssl-key", config.SSLKey)
+	}
+}
+
+// optionEscaper escapes values inside double quotes in an option file
+var optionEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeOptionFile writes the option file to a private temporary file. The
+// returned cleanup function removes it.
+func writeOptionFile(content string) (string, func(), error) {
+	file, err := os.CreateTemp("", "goarchive-mysql-*.cnf")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create option file: %w", err)
+	}
+	cleanup := func() { os.Remove(file.Name()) }
+
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write option file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write option file: %w", err)
+	}
+
+	return file.Name(), cleanup, nil
+}
@@ -0,0 +1,111 @@
+package mysql_test
+
+import (
+	"strings"
+	"testing"
+
+	"goarchive/core"
+	"goarchive/database/mysql"
+)
+
+func TestDSN(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *core.DatabaseConfig
+		want    string
+		wantErr string
+	}{
+		{
+			name: "individual fields",
+			config: &core.DatabaseConfig{
+				Host:     "localhost",
+				Port:     3306,
+				Username: "app",
+				Password: "secret",
+				Database: "app",
+				SSLMode:  "disable",
+			},
+			want: "app@tcp(localhost:3306)/app?connectionAttributes=program_name%3Agoarchive&tls=false",
+		},
+		{
+			name: "default port",
+			config: &core.DatabaseConfig{
+				Host:     "localhost",
+				Username: "app",
+				Database: "app",
+				SSLMode:  "disable",
+			},
+			want: "app@tcp(localhost:3306)/app?connectionAttributes=program_name%3Agoarchive&tls=false",
+		},
+		{
+			name: "verified TLS and options",
+			config: &core.DatabaseConfig{
+				Host:            "db.example.com",
+				Port:            3306,
+				Username:        "app",
+				Database:        "app",
+				SSLMode:         "verify-full",
+				ConnectTimeout:  10,
+				ApplicationName: "nightly",
+			},
+			want: "app@tcp(db.example.com:3306)/app?connectionAttributes=program_name%3Anightly&timeout=10s&tls=goarchive",
+		},
+		{
+			name: "URI replaces individual fields",
+			config: &core.DatabaseConfig{
+				URI:      "app:p@ss@tcp(db1:3307)/app?tls=skip-verify",
+				Host:     "localhost",
+				Port:     3306,
+				Username: "root",
+				Database: "mysql",
+			},
+			want: "app@tcp(db1:3307)/app?connectionAttributes=program_name%3Agoarchive&tls=skip-verify",
+		},
+		{
+			name: "password file",
+			config: &core.DatabaseConfig{
+				Host:     "localhost",
+				PassFile: "/etc/goarchive/pgpass",
+			},
+			wantErr: "not supported",
+		},
+		{
+			name: "invalid SSL mode",
+			config: &core.DatabaseConfig{
+				Host:    "localhost",
+				SSLMode: "allow-everything",
+			},
+			wantErr: "unsupported SSL mode",
+		},
+		{
+			name: "missing CA certificate",
+			config: &core.DatabaseConfig{
+				Host:        "localhost",
+				SSLMode:     "verify-ca",
+				SSLRootCert: "/nonexistent/ca.pem",
+			},
+			wantErr: "failed to read CA certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mysql.DSN(tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DSN() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DSN() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DSN() =\n%s\nwant\n%s", got, tt.want)
+			}
+			if strings.Contains(got, "secret") || strings.Contains(got, "p@ss") {
+				t.Errorf("DSN() must not include the password: %s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+module goarchive/database/mysql
+
+go 1.24.0
+
+require (
+	github.com/go-sql-driver/mysql v1.10.1
+	goarchive v0.0.0
+)
+
+require filippo.io/edwards25519 v1.2.0 // indirect
+
+replace goarchive => ../../
@@ -0,0 +1,292 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"goarchive/core"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// init registers the MySQL provider with the global registry
+func init() {
+	core.RegisterDatabase("mysql", func(config *core.DatabaseConfig) (core.DatabaseProvider, error) {
+		return New(config)
+	})
+}
+
+// Provider implements the DatabaseProvider interface for MySQL and MariaDB
+type Provider struct {
+	config   *core.DatabaseConfig
+	db       *sql.DB
+	driver   *mysql.Config
+	database string // Name of the connected database
+	mariadb  bool   // The server is MariaDB
+}
+
+// New creates a new MySQL provider
+func New(config *core.DatabaseConfig) (*Provider, error) {
+	driver, err := driverConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	connector, err := mysql.NewConnector(driver)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection settings: %w", err)
+	}
+
+	db := sql.OpenDB(connector)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to MySQL: %w", err)
+	}
+
+	var database, version string
+	err = db.QueryRow("SELECT COALESCE(DATABASE(), ''), VERSION()").Scan(&database, &version)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to get current database: %w", err)
+	}
+	if database == "" {
+		db.Close()
+		return nil, fmt.Errorf("database name is required")
+	}
+
+	return &Provider{
+		config:   config,
+		db:       db,
+		driver:   driver,
+		database: database,
+		mariadb:  strings.Contains(strings.ToLower(version), "mariadb"),
+	}, nil
+}
+
+// Backup creates a backup using mysqldump, or mariadb-dump for MariaDB
+// servers, and returns a reader over the SQL dump
+func (p *Provider) Backup(ctx context.Context) (io.ReadCloser, error) {
+	if p.config.BackupMode != "" && p.config.BackupMode != "logical" {
+		return nil, fmt.Errorf("unsupported backup mode: %s", p.config.BackupMode)
+	}
+
+	tool, err := p.findTool("mysqldump", "mariadb-dump")
+	if err != nil {
+		return nil, err
+	}
+
+	optionPath, cleanup, err := writeOptionFile(optionFile(p.config, p.driver))
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, tool,
+		"--defaults-extra-file="+optionPath, // Must be the first option
+		"--single-transaction",              // Consistent snapshot without locking InnoDB tables
+		"--routines",
+		"--triggers",
+		"--hex-blob",       // Keep binary columns intact
+		"--no-tablespaces", // Needs no PROCESS privilege
+		p.database,
+	)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to create pipe: %w", err)
+	}
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to start %s: %w", tool, err)
+	}
+
+	return &backupReader{ReadCloser: stdout, cmd: cmd, stderr: &stderr, cleanup: cleanup}, nil
+}
+
+// Restore restores a database from a SQL dump using the mysql client
+func (p *Provider) Restore(ctx context.Context, reader io.Reader) error {
+	return p.RestoreWithOptions(ctx, reader, &core.RestoreOptions{})
+}
+
+// RestoreWithOptions restores a SQL dump with the mysql client, optionally
+// into a different database, creating or recreating it first
+func (p *Provider) RestoreWithOptions(ctx context.Context, reader io.Reader, opts *core.RestoreOptions) error {
+	if opts == nil {
+		opts = &core.RestoreOptions{}
+	}
+
+	switch {
+	case opts.BackupMode != "" && opts.BackupMode != "logical":
+		return fmt.Errorf("unsupported backup mode: %s", opts.BackupMode)
+	case opts.Jobs > 1:
+		return fmt.Errorf("parallel restore is not supported by the mysql provider")
+	case opts.SingleTransaction:
+		return fmt.Errorf("single-transaction restore is not supported by the mysql provider")
+	case opts.Selective() || opts.TargetSchema != "":
+		return fmt.Errorf("selective restore is not supported by the mysql provider")
+	}
+
+	target := opts.TargetDatabase
+	if target == "" {
+		target = p.database
+	}
+
+	if err := p.prepareTargetDatabase(ctx, target, opts); err != nil {
+		return err
+	}
+
+	tool, err := p.findTool("mysql", "mariadb")
+	if err != nil {
+		return err
+	}
+
+	optionPath, cleanup, err := writeOptionFile(optionFile(p.config, p.driver))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	cmd := exec.CommandContext(ctx, tool,
+		"--defaults-extra-file="+optionPath,
+		"--binary-mode", // Do not interpret escape sequences in binary data
+		"--database="+target,
+	)
+	cmd.Stdin = reader
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to restore database: %w (output: %s)", err, string(output))
+	}
+
+	return nil
+}
+
+// prepareTargetDatabase creates or recreates the target database as
+// requested by opts
+func (p *Provider) prepareTargetDatabase(ctx context.Context, target string, opts *core.RestoreOptions) error {
+	if !opts.CreateDatabase && !opts.DropDatabase {
+		return nil
+	}
+
+	// The provider's own connections use the configured database
+	if opts.DropDatabase && target == p.database {
+		return fmt.Errorf("cannot drop database %q: the provider is connected to it", target)
+	}
+
+	identifier := quoteIdentifier(target)
+
+	if opts.DropDatabase {
+		if _, err := p.db.ExecContext(ctx, "DROP DATABASE IF EXISTS "+identifier); err != nil {
+			return fmt.Errorf("failed to drop database %q: %w", target, err)
+		}
+	}
+
+	if _, err := p.db.ExecContext(ctx, "CREATE DATABASE IF NOT EXISTS "+identifier); err != nil {
+		return fmt.Errorf("failed to create database %q: %w", target, err)
+	}
+
+	return nil
+}
+
+// findTool returns the first of the MySQL and MariaDB names of a client
+// tool found on the PATH, preferring the MariaDB name for MariaDB servers
+func (p *Provider) findTool(mysqlName, mariadbName string) (string, error) {
+	names := []string{mysqlName, mariadbName}
+	if p.mariadb {
+		names = []string{mariadbName, mysqlName}
+	}
+
+	for _, name := range names {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("neither %s nor %s found in PATH", mysqlName, mariadbName)
+}
+
+// GetMetadata returns metadata about the database
+func (p *Provider) GetMetadata() (*core.DatabaseMetadata, error) {
+	var version string
+	var size int64
+
+	if err := p.db.QueryRow("SELECT VERSION()").Scan(&version); err != nil {
+		return nil, fmt.Errorf("failed to get version: %w", err)
+	}
+
+	err := p.db.QueryRow(`SELECT COALESCE(SUM(data_length + index_length), 0)
+		FROM information_schema.tables WHERE table_schema = ?`, p.database).Scan(&size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database size: %w", err)
+	}
+
+	return &core.DatabaseMetadata{
+		Type:       "mysql",
+		Version:    version,
+		Size:       size,
+		Name:       p.database,
+		BackupMode: "logical",
+	}, nil
+}
+
+// Close closes the database connection
+func (p *Provider) Close() error {
+	if p.db != nil {
+		return p.db.Close()
+	}
+	return nil
+}
+
+// quoteIdentifier quotes a MySQL identifier with backticks
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// backupReader wraps the stdout pipe and waits for the dump to complete
+type backupReader struct {
+	io.ReadCloser
+	cmd     *exec.Cmd
+	stderr  *strings.Builder
+	cleanup func()
+	waited  bool
+	err     error
+}
+
+// Read reads from the pipe and, once the dump ends, reports its failure
+// instead of io.EOF
+func (r *backupReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF {
+		if waitErr := r.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Close closes the pipe, waits for the dump to finish and removes the
+// option file
+func (r *backupReader) Close() error {
+	r.ReadCloser.Close()
+	defer r.cleanup()
+	return r.wait()
+}
+
+// wait waits for the dump to finish, once
+func (r *backupReader) wait() error {
+	if !r.waited {
+		r.waited = true
+		if err := r.cmd.Wait(); err != nil {
+			r.err = fmt.Errorf("%s failed: %w (output: %s)", r.cmd.Args[0], err, strings.TrimSpace(r.stderr.String()))
+		}
+	}
+	return r.err
+}
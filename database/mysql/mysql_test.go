@@ -0,0 +1,224 @@
+package mysql_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"goarchive/core"
+	"goarchive/database/mysql"
+)
+
+func TestNew_InvalidConnection(t *testing.T) {
+	config := &core.DatabaseConfig{
+		Type:           "mysql",
+		Host:           "invalid-host-that-does-not-exist",
+		Port:           3306,
+		Username:       "testuser",
+		Password:       "testpass",
+		Database:       "testdb",
+		SSLMode:        "disable",
+		ConnectTimeout: 1,
+	}
+
+	_, err := mysql.New(config)
+	if err == nil {
+		t.Error("Expected error with invalid connection, got nil")
+	}
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	config := &core.DatabaseConfig{
+		Type:           "mysql",
+		Host:           "localhost",
+		Port:           3306,
+		Database:       "testdb",
+		SSLMode:        "disable",
+		ConnectTimeout: 1,
+	}
+
+	provider, err := core.GetDatabase("mysql", config)
+	if err == nil {
+		if provider == nil {
+			t.Error("expected non-nil provider from auto-registration")
+		}
+		provider.Close()
+	} else if err.Error() == "database provider not registered: mysql" {
+		t.Error("mysql provider not auto-registered")
+	}
+}
+
+// Integration tests - these require a real MySQL or MariaDB server and the
+// mysqldump and mysql client tools
+
+func getTestConfig() *core.DatabaseConfig {
+	host := os.Getenv("MYSQL_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+
+	port := 3306
+	if p, err := strconv.Atoi(os.Getenv("MYSQL_PORT")); err == nil {
+		port = p
+	}
+
+	user := os.Getenv("MYSQL_USER")
+	if user == "" {
+		user = "root"
+	}
+
+	password := os.Getenv("MYSQL_PASSWORD")
+	if password == "" {
+		password = "testpass"
+	}
+
+	database := os.Getenv("MYSQL_DATABASE")
+	if database == "" {
+		database = "testdb"
+	}
+
+	return &core.DatabaseConfig{
+		Type:           "mysql",
+		Host:           host,
+		Port:           port,
+		Username:       user,
+		Password:       password,
+		Database:       database,
+		SSLMode:        "disable",
+		ConnectTimeout: 5,
+	}
+}
+
+func TestIntegration_MySQL(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	config := getTestConfig()
+
+	provider, err := mysql.New(config)
+	if err != nil {
+		t.Skipf("Skipping integration test - MySQL not available: %v", err)
+	}
+	defer provider.Close()
+
+	ctx := context.Background()
+
+	t.Run("GetMetadata", func(t *testing.T) {
+		metadata, err := provider.GetMetadata()
+		if err != nil {
+			t.Fatalf("GetMetadata() error = %v", err)
+		}
+
+		if metadata.Type != "mysql" {
+			t.Errorf("Expected Type 'mysql', got '%s'", metadata.Type)
+		}
+		if metadata.Name != config.Database {
+			t.Errorf("Expected Name '%s', got '%s'", config.Database, metadata.Name)
+		}
+		if metadata.Version == "" {
+			t.Error("Expected non-empty Version")
+		}
+		if metadata.Size < 0 {
+			t.Errorf("Expected non-negative Size, got %d", metadata.Size)
+		}
+	})
+
+	t.Run("BackupRestore", func(t *testing.T) {
+		source := *config
+		source.Database = config.Database + "_source"
+		fixture := "CREATE TABLE people (id INT PRIMARY KEY, name VARCHAR(50), photo BLOB);\n" +
+			"INSERT INTO people VALUES (1, 'Ada', X'00FF0A27'), (2, 'it''s', NULL);\n" +
+			"CREATE TRIGGER people_upper BEFORE INSERT ON people FOR EACH ROW SET NEW.name = UPPER(NEW.name);\n"
+		err := provider.RestoreWithOptions(ctx, bytes.NewReader([]byte(fixture)), &core.RestoreOptions{
+			TargetDatabase: source.Database,
+			DropDatabase:   true,
+		})
+		if err != nil {
+			t.Fatalf("failed to load fixture: %v", err)
+		}
+
+		sourceProvider, err := mysql.New(&source)
+		if err != nil {
+			t.Fatalf("failed to connect to source database: %v", err)
+		}
+		defer sourceProvider.Close()
+
+		reader, err := sourceProvider.Backup(ctx)
+		if err != nil {
+			t.Fatalf("Backup() error = %v", err)
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("failed to read backup data: %v", err)
+		}
+		if err := reader.Close(); err != nil {
+			t.Fatalf("Backup() close error = %v", err)
+		}
+
+		for _, want := range []string{"CREATE TABLE `people`", "0x00FF0A27", "people_upper"} {
+			if !bytes.Contains(data, []byte(want)) {
+				t.Errorf("expected backup to contain %q", want)
+			}
+		}
+
+		err = provider.RestoreWithOptions(ctx, bytes.NewReader(data), &core.RestoreOptions{
+			TargetDatabase: config.Database + "_copy",
+			DropDatabase:   true,
+		})
+		if err != nil {
+			t.Errorf("RestoreWithOptions() error = %v", err)
+		}
+
+		// The connected database cannot be dropped
+		err = provider.RestoreWithOptions(ctx, bytes.NewReader(data), &core.RestoreOptions{
+			DropDatabase: true,
+		})
+		if err == nil {
+			t.Error("expected error dropping the connected database, got nil")
+		}
+
+		err = provider.RestoreWithOptions(ctx, bytes.NewReader(data), &core.RestoreOptions{
+			Jobs: 4,
+		})
+		if err == nil {
+			t.Error("expected error for parallel restore, got nil")
+		}
+	})
+
+	t.Run("BackupFailure", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("fake tools use shell scripts")
+		}
+
+		// A dump that fails halfway must not read as a complete backup
+		dir := t.TempDir()
+		script := "#!/bin/sh\necho 'CREATE TABLE partial (id INT);'\necho 'Got error: 2013: Lost connection' >&2\nexit 2\n"
+		for _, tool := range []string{"mysqldump", "mariadb-dump"} {
+			if err := os.WriteFile(filepath.Join(dir, tool), []byte(script), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		reader, err := provider.Backup(ctx)
+		if err != nil {
+			t.Fatalf("Backup() error = %v", err)
+		}
+		defer reader.Close()
+
+		_, err = io.ReadAll(reader)
+		if err == nil || !strings.Contains(err.Error(), "Lost connection") || !strings.Contains(err.Error(), "exit status 2") {
+			t.Errorf("expected the dump failure from Read, got %v", err)
+		}
+		if err := reader.Close(); err == nil {
+			t.Error("expected Close() to report the failure, got nil")
+		}
+	})
+}
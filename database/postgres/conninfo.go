@@ -0,0 +1,253 @@
+package postgres
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"goarchive/core"
+)
+
+// defaultApplicationName identifies goarchive connections in pg_stat_activity
+const defaultApplicationName = "goarchive"
+
+// connSettings holds libpq connection parameters
+type connSettings map[string]string
+
+// ConnString returns the keyword/value connection string for config, as
+// understood by pgx and the libpq tools. The password is left out so the
+// string can be passed on a command line; supply it through PGPASSWORD.
+func ConnString(config *core.DatabaseConfig) (string, error) {
+	settings, _, err := buildConnSettings(config)
+	if err != nil {
+		return "", err
+	}
+	return settings.String(), nil
+}
+
+// buildConnSettings resolves the connection parameters and password for
+// config. A URI or service replaces Host, Port, Username, Database and
+// SSLMode; the remaining options override the URI when set.
+func buildConnSettings(config *core.DatabaseConfig) (connSettings, string, error) {
+	settings := connSettings{}
+
+	if config.URI != "" {
+		parsed, err := parseConnString(config.URI)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid database URI: %w", err)
+		}
+		settings = parsed
+	} else if config.Service == "" {
+		settings.set("host", config.Host)
+		if config.Port != 0 {
+			settings.set("port", strconv.Itoa(config.Port))
+		}
+		settings.set("user", config.Username)
+		settings.set("dbname", config.Database)
+		settings.set("sslmode", config.SSLMode)
+	}
+
+	settings.set("service", config.Service)
+	settings.set("sslrootcert", config.SSLRootCert)
+	settings.set("sslcert", config.SSLCert)
+	settings.set("sslkey", config.SSLKey)
+	settings.set("passfile", config.PassFile)
+	if config.ConnectTimeout > 0 {
+		settings.set("connect_timeout", strconv.Itoa(config.ConnectTimeout))
+	}
+	settings.set("application_name", config.ApplicationName)
+	if settings["application_name"] == "" {
+		settings["application_name"] = defaultApplicationName
+	}
+
+	password := settings["password"]
+	delete(settings, "password")
+	if config.Password != "" {
+		password = config.Password
+	}
+
+	return settings, password, nil
+}
+
+// set stores value under key unless value is empty
+func (s connSettings) set(key, value string) {
+	if value != "" {
+		s[key] = value
+	}
+}
+
+// withDatabase returns a copy of the settings connecting to database
+func (s connSettings) withDatabase(database string) connSettings {
+	c := make(connSettings, len(s)+1)
+	for key, value := range s {
+		c[key] = value
+	}
+	c.set("dbname", database)
+	return c
+}
+
+// String renders the settings as a keyword/value connection string
+func (s connSettings) String() string {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key + "=" + quoteConnValue(s[key])
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteConnValue quotes a keyword/value connection string value
+func quoteConnValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+// commandEnv returns the environment for libpq tools: the current
+// environment plus the password, if any
+func commandEnv(password string) []string {
+	env := os.Environ()
+	if password != "" {
+		env = append(env, "PGPASSWORD="+password)
+	}
+	return env
+}
+
+// parseConnString parses a postgres:// URI or a keyword/value connection string
+func parseConnString(s string) (connSettings, error) {
+	if strings.HasPrefix(s, "postgres://") || strings.HasPrefix(s, "postgresql://") {
+		return parseConnURI(s)
+	}
+	return parseKeywordValue(s)
+}
+
+// parseConnURI parses a postgres:// URI, including multiple hosts
+func parseConnURI(s string) (connSettings, error) {
+	// net/url rejects host lists, so split them out of the authority first
+	scheme, rest, _ := strings.Cut(s, "://")
+	end := strings.IndexAny(rest, "/?")
+	if end < 0 {
+		end = len(rest)
+	}
+	userinfo, hostlist := "", rest[:end]
+	if at := strings.LastIndex(hostlist, "@"); at >= 0 {
+		userinfo, hostlist = hostlist[:at+1], hostlist[at+1:]
+	}
+
+	u, err := url.Parse(scheme + "://" + userinfo + "localhost" + rest[end:])
+	if err != nil {
+		return nil, err
+	}
+
+	settings := connSettings{}
+	if u.User != nil {
+		settings.set("user", u.User.Username())
+		if password, ok := u.User.Password(); ok {
+			settings["password"] = password
+		}
+	}
+
+	var hosts, ports []string
+	for _, hostport := range strings.Split(hostlist, ",") {
+		if hostport == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(hostport)
+		if err != nil {
+			host, port = strings.Trim(hostport, "[]"), ""
+		}
+		if unescaped, err := url.PathUnescape(host); err == nil {
+			host = unescaped
+		}
+		hosts = append(hosts, host)
+		ports = append(ports, port)
+	}
+	settings.set("host", strings.Join(hosts, ","))
+	if strings.Join(ports, "") != "" {
+		// pgx needs a port for every host once any is given
+		for i, port := range ports {
+			if port == "" {
+				ports[i] = "5432"
+			}
+		}
+		settings.set("port", strings.Join(ports, ","))
+	}
+
+	settings.set("dbname", strings.TrimPrefix(u.Path, "/"))
+
+	for key, values := range u.Query() {
+		if len(values) > 0 {
+			settings[key] = values[len(values)-1]
+		}
+	}
+
+	return settings, nil
+}
+
+// parseKeywordValue parses a keyword/value connection string such as
+// "host=db user='app user'"
+func parseKeywordValue(s string) (connSettings, error) {
+	settings := connSettings{}
+
+	for {
+		s = strings.TrimLeft(s, " \t\n\r")
+		if s == "" {
+			return settings, nil
+		}
+
+		eq := strings.IndexRune(s, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("missing \"=\" after %q", s)
+		}
+		key := strings.TrimSpace(s[:eq])
+		if key == "" {
+			return nil, fmt.Errorf("missing keyword before \"=\"")
+		}
+		s = strings.TrimLeft(s[eq+1:], " \t\n\r")
+
+		var value strings.Builder
+		if strings.HasPrefix(s, "'") {
+			s = s[1:]
+			closed := false
+			for len(s) > 0 && !closed {
+				switch s[0] {
+				case '\\':
+					if len(s) > 1 {
+						s = s[1:]
+					}
+					value.WriteByte(s[0])
+				case '\'':
+					closed = true
+				default:
+					value.WriteByte(s[0])
+				}
+				s = s[1:]
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated quoted value for %q", key)
+			}
+		} else {
+			end := strings.IndexAny(s, " \t\n\r")
+			if end < 0 {
+				end = len(s)
+			}
+			for i := 0; i < end; i++ {
+				if s[i] == '\\' && i+1 < end {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			s = s[end:]
+		}
+
+		settings[key] = value.String()
+	}
+}
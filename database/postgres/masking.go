@@ -0,0 +1,380 @@
+package postgres
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"goarchive/core"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// copyNull is the NULL marker of COPY text format
+const copyNull = `\N`
+
+// loadMasker loads the configured masking profile, or returns nil if none is
+// configured
+func (p *Provider) loadMasker() (*core.Masker, error) {
+	if p.config.MaskingProfile == "" {
+		return nil, nil
+	}
+
+	profile, err := core.LoadMaskingProfile(p.config.MaskingProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	return core.NewMasker(profile)
+}
+
+// finishMasking fails if a rule matched no column and writes the report
+func (p *Provider) finishMasking(masker *core.Masker, database, operation string) error {
+	if unmatched := masker.Unmatched(); len(unmatched) > 0 {
+		return fmt.Errorf("masking rules match no column: %s", strings.Join(unmatched, ", "))
+	}
+
+	if p.config.MaskingReport == "" {
+		return nil
+	}
+
+	report := masker.Report()
+	report.Profile = p.config.MaskingProfile
+	report.Database = database
+	report.Operation = operation
+	return report.WriteFile(p.config.MaskingReport)
+}
+
+// copyRowMasker masks fields of rows in COPY text format
+type copyRowMasker struct {
+	table   string
+	columns []core.ValueMasker // nil for columns kept as they are
+}
+
+// newCopyRowMasker returns a masker for the table's rows, or nil if none of
+// its columns are masked. Masked values are text, so hash, fake and scramble
+// rules may only apply to string columns, and null rules to nullable ones.
+func newCopyRowMasker(ctx context.Context, q querier, masker *core.Masker, table *nativeTable) (*copyRowMasker, error) {
+	if masker == nil {
+		return nil, nil
+	}
+
+	m := &copyRowMasker{
+		table:   table.qualifiedName(),
+		columns: make([]core.ValueMasker, len(table.columns)),
+	}
+	found := false
+	for i, column := range table.columns {
+		rule := masker.Rule(table.schema, table.name, column)
+		if rule == nil {
+			continue
+		}
+		if err := checkMaskedColumn(ctx, q, table, column, rule); err != nil {
+			return nil, err
+		}
+		m.columns[i] = masker.Column(table.schema, table.name, column)
+		found = true
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// checkMaskedColumn checks the column's type can hold the masked values
+func checkMaskedColumn(ctx context.Context, q querier, table *nativeTable, column string, rule *core.MaskingRule) error {
+	rows, err := q.Query(ctx, `SELECT t.typcategory = 'S', a.attnotnull
+		FROM pg_attribute a
+		JOIN pg_type t ON t.oid = pg_catalog.getbasetype(a.atttypid)
+		WHERE a.attrelid = $1::regclass AND a.attname = $2`, table.qualifiedName(), column)
+	if err != nil {
+		return fmt.Errorf("failed to check masked column %s.%s: %w", table.qualifiedName(), column, err)
+	}
+	type columnInfo struct {
+		IsString bool
+		NotNull  bool
+	}
+	info, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByPos[columnInfo])
+	if err != nil {
+		return fmt.Errorf("failed to check masked column %s.%s: %w", table.qualifiedName(), column, err)
+	}
+
+	if rule.Strategy == core.MaskNull {
+		if info.NotNull {
+			return fmt.Errorf("cannot mask %s.%s with null: the column is NOT NULL", table.qualifiedName(), column)
+		}
+	} else if !info.IsString {
+		return fmt.Errorf("cannot mask %s.%s with %s: only text columns can hold masked values",
+			table.qualifiedName(), column, rule.Strategy)
+	}
+
+	return nil
+}
+
+// maskRow masks a row without its trailing newline. Rows whose fields
+// cannot be matched to the columns are an error, as they cannot be masked.
+func (m *copyRowMasker) maskRow(row []byte) ([]byte, error) {
+	fields := bytes.Split(row, []byte{'\t'})
+	if len(fields) != len(m.columns) {
+		return nil, fmt.Errorf("cannot mask a row of %s: it has %d fields, expected %d", m.table, len(fields), len(m.columns))
+	}
+
+	for i, mask := range m.columns {
+		if mask == nil {
+			continue
+		}
+		null := string(fields[i]) == copyNull
+		value := ""
+		if !null {
+			value = unescapeCopyField(fields[i])
+		}
+
+		masked, maskedNull := mask(value, null)
+		if maskedNull {
+			fields[i] = []byte(copyNull)
+		} else {
+			fields[i] = []byte(escapeCopyField(masked))
+		}
+	}
+
+	return bytes.Join(fields, []byte{'\t'}), nil
+}
+
+// unescapeCopyField decodes a COPY text format field
+func unescapeCopyField(field []byte) string {
+	if bytes.IndexByte(field, '\\') < 0 {
+		return string(field)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		c := field[i]
+		if c != '\\' || i+1 == len(field) {
+			b.WriteByte(c)
+			continue
+		}
+
+		i++
+		switch c = field[i]; c {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x':
+			end := i + 1
+			for end < len(field) && end < i+3 && isHexDigit(field[end]) {
+				end++
+			}
+			if end == i+1 {
+				b.WriteByte('x')
+				continue
+			}
+			n, _ := strconv.ParseUint(string(field[i+1:end]), 16, 8)
+			b.WriteByte(byte(n))
+			i = end - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			end := i
+			for end < len(field) && end < i+3 && field[end] >= '0' && field[end] <= '7' {
+				end++
+			}
+			n, _ := strconv.ParseUint(string(field[i:end]), 8, 8)
+			b.WriteByte(byte(n))
+			i = end - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// escapeCopyField encodes a value as a COPY text format field
+func escapeCopyField(value string) string {
+	return copyEscaper.Replace(value)
+}
+
+var copyEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// maskingWriter masks complete COPY rows written to it
+type maskingWriter struct {
+	w       io.Writer
+	masker  *copyRowMasker
+	partial []byte
+}
+
+// Write implements io.Writer
+func (w *maskingWriter) Write(p []byte) (int, error) {
+	data := append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		row, err := w.masker.maskRow(data[:i])
+		if err != nil {
+			return 0, err
+		}
+		if _, err := w.w.Write(append(row, '\n')); err != nil {
+			return 0, err
+		}
+		data = data[i+1:]
+	}
+	w.partial = append(w.partial[:0], data...)
+	return len(p), nil
+}
+
+// maskingReader masks the COPY rows read from r
+type maskingReader struct {
+	r       *bufio.Reader
+	masker  *copyRowMasker
+	pending []byte
+}
+
+// Read implements io.Reader
+func (m *maskingReader) Read(p []byte) (int, error) {
+	if len(m.pending) == 0 {
+		row, err := m.r.ReadBytes('\n')
+		if len(row) == 0 {
+			return 0, err
+		}
+		if row[len(row)-1] == '\n' {
+			masked, err := m.masker.maskRow(row[:len(row)-1])
+			if err != nil {
+				return 0, err
+			}
+			row = append(masked, '\n')
+		}
+		m.pending = row
+	}
+
+	n := copy(p, m.pending)
+	m.pending = m.pending[n:]
+	return n, nil
+}
+
+// parseCopyStatement recovers the table and columns from a native backup's
+// COPY "schema"."table" ("a", "b") FROM STDIN statement
+func parseCopyStatement(stmt string) (*nativeTable, error) {
+	rest, ok := strings.CutPrefix(stmt, "COPY ")
+	table := &nativeTable{}
+
+	if ok {
+		table.schema, rest, ok = cutIdentifier(rest)
+	}
+	if ok {
+		rest, ok = strings.CutPrefix(rest, ".")
+	}
+	if ok {
+		table.name, rest, ok = cutIdentifier(rest)
+	}
+	if ok {
+		rest, ok = strings.CutPrefix(rest, " (")
+	}
+	for ok {
+		var column string
+		column, rest, ok = cutIdentifier(rest)
+		if !ok {
+			break
+		}
+		table.columns = append(table.columns, column)
+		if after, found := strings.CutPrefix(rest, ", "); found {
+			rest = after
+			continue
+		}
+		rest, ok = strings.CutPrefix(rest, ")")
+		break
+	}
+
+	if !ok || rest != " FROM STDIN" {
+		return nil, fmt.Errorf("unrecognized COPY statement %q", firstLine(stmt))
+	}
+	return table, nil
+}
+
+// cutIdentifier reads a double-quoted identifier from the start of s
+func cutIdentifier(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", s, false
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] != '"' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '"' {
+			b.WriteByte('"')
+			i++
+			continue
+		}
+		return b.String(), s[i+1:], true
+	}
+	return "", s, false
+}
+
+// prepareMasking builds a row masker for each table, nil for tables without
+// masked columns, and lists the masked columns. Every rule must match.
+func prepareMasking(ctx context.Context, q querier, masker *core.Masker, tables []*nativeTable) ([]*copyRowMasker, []string, error) {
+	rowMaskers := make([]*copyRowMasker, len(tables))
+	if masker == nil {
+		return rowMaskers, nil, nil
+	}
+
+	var masked []string
+	for i, table := range tables {
+		rowMasker, err := newCopyRowMasker(ctx, q, masker, table)
+		if err != nil {
+			return nil, nil, err
+		}
+		rowMaskers[i] = rowMasker
+
+		for _, column := range table.columns {
+			if rule := masker.Rule(table.schema, table.name, column); rule != nil {
+				masked = append(masked, fmt.Sprintf("%s.%s.%s (%s)", table.schema, table.name, column, rule.Strategy))
+			}
+		}
+	}
+
+	if unmatched := masker.Unmatched(); len(unmatched) > 0 {
+		return nil, nil, fmt.Errorf("masking rules match no column: %s", strings.Join(unmatched, ", "))
+	}
+
+	return rowMaskers, masked, nil
+}
+
+// maskedCopySource wraps the rows of a native backup COPY section in a
+// masking reader when the table has masked columns
+func maskedCopySource(ctx context.Context, q querier, masker *core.Masker, stmt string, rows io.Reader) (io.Reader, error) {
+	if masker == nil {
+		return rows, nil
+	}
+
+	table, err := parseCopyStatement(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	rowMasker, err := newCopyRowMasker(ctx, q, masker, table)
+	if err != nil || rowMasker == nil {
+		return rows, err
+	}
+
+	return &maskingReader{r: bufio.NewReaderSize(rows, 64*1024), masker: rowMasker}, nil
+}
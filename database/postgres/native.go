@@ -0,0 +1,418 @@
+package postgres
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"goarchive/core"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Native backups use a line-oriented goarchive format:
+//
+//	GOARCHIVE-PGNATIVE 1
+//	{"format":1,"database":"app",...}      manifest (one JSON line)
+//	STMT <n>                               SQL statement of n bytes
+//	<statement>
+//	COPY <n>                               COPY ... FROM STDIN statement of n bytes
+//	<statement>
+//	<rows in COPY text format>
+//	\.
+//	END
+//
+// Sections are replayed in order. COPY text format escapes backslashes, so
+// the \. terminator can never appear as a data row.
+const (
+	nativeMagic   = "GOARCHIVE-PGNATIVE"
+	nativeVersion = 1
+
+	// nativeMinServerVersion is the oldest server the catalog queries support
+	nativeMinServerVersion = 130000
+)
+
+// nativeManifest describes a native backup
+type nativeManifest struct {
+	Format        int       `json:"format"`
+	Database      string    `json:"database"`
+	ServerVersion string    `json:"server_version"`
+	Encoding      string    `json:"encoding"`
+	CreatedAt     time.Time `json:"created_at"`
+	Tables        int       `json:"tables"`
+	Masked        []string  `json:"masked,omitempty"` // Columns transformed by a masking profile
+}
+
+// nativeBackup dumps the configured database in-process over a dedicated
+// connection inside a single repeatable-read snapshot, or a serializable
+// deferrable one when configured
+func (p *Provider) nativeBackup(ctx context.Context) (io.ReadCloser, error) {
+	masker, err := p.loadMasker()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, p.conn.Config().Copy())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+
+	txOptions := pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}
+	if p.config.SerializableDeferrable {
+		txOptions.IsoLevel = pgx.Serializable
+		txOptions.DeferrableMode = pgx.Deferrable
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := dumpNative(ctx, conn, pw, txOptions, p.config.LockWaitTimeout, masker)
+		conn.Close(context.Background())
+		if err == nil && masker != nil {
+			err = p.finishMasking(masker, p.database, "backup")
+		}
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	return &nativeReader{PipeReader: pr, done: done}, nil
+}
+
+// dumpNative writes a native backup of the database conn is connected to.
+// Waiting for table locks fails after lockWait unless it is zero, and rows
+// are masked when masker is not nil.
+func dumpNative(ctx context.Context, conn *pgx.Conn, out io.Writer, txOptions pgx.TxOptions, lockWait time.Duration, masker *core.Masker) error {
+	tx, err := conn.BeginTx(ctx, txOptions)
+	if err != nil {
+		return fmt.Errorf("failed to start snapshot transaction: %w", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	manifest := nativeManifest{Format: nativeVersion, CreatedAt: time.Now().UTC()}
+	var versionNum int
+	err = tx.QueryRow(ctx,
+		"SELECT current_database(), current_setting('server_version'), "+
+			"current_setting('server_version_num')::int, current_setting('server_encoding')").
+		Scan(&manifest.Database, &manifest.ServerVersion, &versionNum, &manifest.Encoding)
+	if err != nil {
+		return fmt.Errorf("failed to read server settings: %w", err)
+	}
+	if versionNum < nativeMinServerVersion {
+		return fmt.Errorf("native backups require PostgreSQL 13 or later, server is %s", manifest.ServerVersion)
+	}
+
+	// Qualify every name in generated definitions
+	_, err = tx.Exec(ctx, "SELECT pg_catalog.set_config('search_path', '', true), "+
+		"pg_catalog.set_config('lock_timeout', $1, true)", strconv.FormatInt(lockWait.Milliseconds(), 10))
+	if err != nil {
+		return fmt.Errorf("failed to prepare dump session: %w", err)
+	}
+
+	schema, err := loadNativeSchema(ctx, tx)
+	if err != nil {
+		return err
+	}
+	manifest.Tables = len(schema.tables)
+
+	rowMaskers, masked, err := prepareMasking(ctx, tx, masker, schema.tables)
+	if err != nil {
+		return err
+	}
+	manifest.Masked = masked
+
+	w := bufio.NewWriterSize(out, 64*1024)
+	if err := writeNativeHeader(w, &manifest); err != nil {
+		return err
+	}
+
+	for _, stmt := range schema.preData {
+		if err := writeNativeSection(w, "STMT", stmt); err != nil {
+			return err
+		}
+	}
+
+	for i, table := range schema.tables {
+		if err := writeNativeSection(w, "COPY", table.copyStatement("FROM STDIN")); err != nil {
+			return err
+		}
+		var dst io.Writer = w
+		if rowMaskers[i] != nil {
+			dst = &maskingWriter{w: w, masker: rowMaskers[i]}
+		}
+		if _, err := tx.Conn().PgConn().CopyTo(ctx, dst, table.copyStatement("TO STDOUT")); err != nil {
+			return fmt.Errorf("failed to copy table %s: %w", table.qualifiedName(), err)
+		}
+		if _, err := w.WriteString("\\.\n"); err != nil {
+			return fmt.Errorf("failed to write backup data: %w", err)
+		}
+	}
+
+	for _, stmt := range schema.postData {
+		if err := writeNativeSection(w, "STMT", stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.WriteString("END\n"); err != nil {
+		return fmt.Errorf("failed to write backup data: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write backup data: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// writeNativeHeader writes the format line and the manifest
+func writeNativeHeader(w io.Writer, manifest *nativeManifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "%s %d\n%s\n", nativeMagic, nativeVersion, data); err != nil {
+		return fmt.Errorf("failed to write backup data: %w", err)
+	}
+	return nil
+}
+
+// writeNativeSection writes a length-prefixed statement section
+func writeNativeSection(w io.Writer, kind, stmt string) error {
+	if _, err := fmt.Fprintf(w, "%s %d\n%s\n", kind, len(stmt), stmt); err != nil {
+		return fmt.Errorf("failed to write backup data: %w", err)
+	}
+	return nil
+}
+
+// nativeRestore loads a native backup into the target database. The restore
+// runs in a single transaction, so it either completes or leaves the target
+// untouched; restoring over existing objects fails unless the database is
+// recreated first.
+func (p *Provider) nativeRestore(ctx context.Context, reader io.Reader, opts *core.RestoreOptions) error {
+	if opts.Jobs > 1 {
+		return fmt.Errorf("parallel restore is not supported for native backups")
+	}
+
+	target := opts.TargetDatabase
+	if target == "" {
+		target = p.database
+	}
+
+	masker, err := p.loadMasker()
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.prepareTargetDatabase(ctx, target, opts); err != nil {
+		return err
+	}
+
+	conn, closeConn, err := p.connectTo(ctx, target)
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	if err := restoreNative(ctx, conn, reader, masker); err != nil {
+		return err
+	}
+
+	if masker != nil {
+		return p.finishMasking(masker, target, "restore")
+	}
+	return nil
+}
+
+// restoreNative replays a native backup into the database conn is connected
+// to, inside a single transaction, masking rows when masker is not nil
+func restoreNative(ctx context.Context, conn *pgx.Conn, reader io.Reader, masker *core.Masker) error {
+	r := bufio.NewReaderSize(reader, 64*1024)
+
+	if _, err := readNativeHeader(r); err != nil {
+		return err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start restore transaction: %w", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	_, err = tx.Exec(ctx, "SELECT pg_catalog.set_config('search_path', '', true), "+
+		"pg_catalog.set_config('check_function_bodies', 'false', true)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare restore session: %w", err)
+	}
+
+	pgConn := tx.Conn().PgConn()
+	for {
+		kind, stmt, err := readNativeSection(r)
+		if err != nil {
+			return err
+		}
+
+		switch kind {
+		case "END":
+			// A rule matching nothing may mean unmasked data was restored
+			if masker != nil && len(masker.Unmatched()) > 0 {
+				return fmt.Errorf("masking rules match no column: %s", strings.Join(masker.Unmatched(), ", "))
+			}
+			return tx.Commit(ctx)
+		case "STMT":
+			if _, err := pgConn.Exec(ctx, stmt).ReadAll(); err != nil {
+				return fmt.Errorf("failed to restore statement %q: %w", firstLine(stmt), err)
+			}
+		case "COPY":
+			source, err := maskedCopySource(ctx, tx, masker, stmt, &copyDataReader{r: r, atLineStart: true})
+			if err != nil {
+				return err
+			}
+			if _, err := pgConn.CopyFrom(ctx, source, stmt); err != nil {
+				return fmt.Errorf("failed to restore data (%s): %w", stmt, err)
+			}
+		}
+	}
+}
+
+// readNativeHeader validates the format line and decodes the manifest
+func readNativeHeader(r *bufio.Reader) (*nativeManifest, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup header: %w", err)
+	}
+	if strings.TrimSuffix(line, "\n") != fmt.Sprintf("%s %d", nativeMagic, nativeVersion) {
+		return nil, fmt.Errorf("not a native PostgreSQL backup (unsupported header %q)", firstLine(line))
+	}
+
+	line, err = r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+	var manifest nativeManifest
+	if err := json.Unmarshal([]byte(line), &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode backup manifest: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+// readNativeSection reads the next section header and its statement
+func readNativeSection(r *bufio.Reader) (string, string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read backup data: unexpected end of backup: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\n")
+	if line == "END" {
+		return line, "", nil
+	}
+
+	kind, size, ok := strings.Cut(line, " ")
+	n, err := strconv.Atoi(size)
+	if !ok || err != nil || n < 0 || (kind != "STMT" && kind != "COPY") {
+		return "", "", fmt.Errorf("invalid backup section %q", firstLine(line))
+	}
+
+	stmt := make([]byte, n+1)
+	if _, err := io.ReadFull(r, stmt); err != nil {
+		return "", "", fmt.Errorf("failed to read backup data: %w", err)
+	}
+	if stmt[n] != '\n' {
+		return "", "", fmt.Errorf("invalid backup section %q: missing terminator", firstLine(line))
+	}
+
+	return kind, string(stmt[:n]), nil
+}
+
+// copyDataReader yields COPY rows up to the \. terminator line
+type copyDataReader struct {
+	r           *bufio.Reader
+	pending     []byte
+	atLineStart bool
+	done        bool
+}
+
+// Read implements io.Reader
+func (c *copyDataReader) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+
+		chunk, err := c.r.ReadSlice('\n')
+		if c.atLineStart && string(chunk) == "\\.\n" {
+			c.done = true
+			continue
+		}
+		switch err {
+		case nil:
+			c.atLineStart = true
+		case bufio.ErrBufferFull:
+			c.atLineStart = false
+		case io.EOF:
+			return 0, io.ErrUnexpectedEOF
+		default:
+			return 0, err
+		}
+		c.pending = chunk
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// firstLine shortens a statement for error messages
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	if len(line) > 80 {
+		line = line[:80] + "..."
+	}
+	return line
+}
+
+// nativeReader streams a native backup and reports dump errors on Close
+type nativeReader struct {
+	*io.PipeReader
+	done chan error
+	once sync.Once
+	err  error
+}
+
+// Close stops reading and waits for the dump to finish
+func (r *nativeReader) Close() error {
+	r.once.Do(func() {
+		r.PipeReader.Close()
+		if err := <-r.done; !errors.Is(err, io.ErrClosedPipe) {
+			r.err = err
+		}
+	})
+	return r.err
+}
+
+// nativeTable is a table whose rows are copied into the backup
+type nativeTable struct {
+	schema  string
+	name    string
+	columns []string
+}
+
+// qualifiedName returns the quoted schema-qualified table name
+func (t *nativeTable) qualifiedName() string {
+	return pgx.Identifier{t.schema, t.name}.Sanitize()
+}
+
+// copyStatement returns a COPY statement for the table's columns in direction
+func (t *nativeTable) copyStatement(direction string) string {
+	columns := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		columns[i] = pgx.Identifier{column}.Sanitize()
+	}
+	return fmt.Sprintf("COPY %s (%s) %s", t.qualifiedName(), strings.Join(columns, ", "), direction)
+}
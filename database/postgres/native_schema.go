@@ -0,0 +1,549 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// userNamespace filters out system schemas; it expects the namespace alias n
+const userNamespace = `n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+	AND n.nspname NOT LIKE 'pg_temp_%' AND n.nspname NOT LIKE 'pg_toast_temp_%'`
+
+// notExtensionMember filters out objects created by extensions
+func notExtensionMember(catalog, oid string) string {
+	return fmt.Sprintf(`NOT EXISTS (SELECT 1 FROM pg_depend dep
+		WHERE dep.classid = '%s'::regclass AND dep.objid = %s AND dep.deptype = 'e')`, catalog, oid)
+}
+
+// nativeSchema holds the statements and tables of a native backup in the
+// order they are restored: objects, table data, then constraints, indexes,
+// views and triggers
+type nativeSchema struct {
+	preData  []string
+	tables   []*nativeTable
+	postData []string
+}
+
+// loadNativeSchema reads the schema of the current database from the catalogs.
+// Ownership, privileges, comments, row-level security policies, large objects
+// and non-partition table inheritance are not included.
+func loadNativeSchema(ctx context.Context, tx pgx.Tx) (*nativeSchema, error) {
+	schema := &nativeSchema{}
+
+	loaders := []struct {
+		name string
+		load func(context.Context, pgx.Tx, *nativeSchema) error
+	}{
+		{"schemas", loadSchemas},
+		{"extensions", loadExtensions},
+		{"enum types", loadEnumTypes},
+		{"composite types", loadCompositeTypes},
+		{"domains", loadDomains},
+		{"sequences", loadSequences},
+		{"functions", loadFunctions},
+		{"tables", loadTables},
+		{"sequence values", loadSequenceValues},
+		{"constraints", loadConstraints},
+		{"indexes", loadIndexes},
+		{"views", loadViews},
+		{"functions using row types", loadRowTypeFunctions},
+		{"triggers", loadTriggers},
+	}
+
+	for _, loader := range loaders {
+		if err := loader.load(ctx, tx, schema); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", loader.name, err)
+		}
+	}
+
+	return schema, nil
+}
+
+// querier is satisfied by both *pgx.Conn and pgx.Tx
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// queryStrings runs a query returning one text column per row
+func queryStrings(ctx context.Context, q querier, query string, args ...any) ([]string, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func loadSchemas(ctx context.Context, tx pgx.Tx, schema *nativeSchema) error {
+	names, err := queryStrings(ctx, tx, `SELECT n.nspname FROM pg_namespace n
+		WHERE `+userNamespace+` AND `+notExtensionMember("pg_namespace", "n.oid")+`
+		ORDER BY n.nspname`)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		schema.preData = append(schema.preData, "CREATE SCHEMA IF NOT EXISTS "+pgx.Identifier{name}.Sanitize())
+	}
+	return nil
+}
+
+func loadExtensions(ctx context.Context, tx pgx.Tx, schema *nativeSchema) error {
+	rows, err := tx.Query(ctx, `SELECT e.extname, n.nspname FROM pg_extension e
+		JOIN pg_namespace n ON n.oid = e.extnamespace
+		WHERE e.extname <> 'plpgsql'
+		ORDER BY e.extname`)
+	if err != nil {
+		return err
+	}
+
+	var name, namespace string
+	_, err = pgx.ForEachRow(rows, []any{&name, &namespace}, func() error {
+		schema.preData = append(schema.preData, fmt.Sprintf("CREATE EXTENSION IF NOT EXISTS %s WITH SCHEMA %s",
+			pgx.Identifier{name}.Sanitize(), pgx.Identifier{namespace}.Sanitize()))
+		return nil
+	})
+	return err
+}
+
+func loadEnumTypes(ctx context.Context, tx pgx.Tx, schema *nativeSchema) error {
+	rows, err := tx.Query(ctx, `SELECT n.nspname, t.typname,
+			array_agg(e.enumlabel::text ORDER BY e.enumsortorder)
+		FROM pg_type t
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		JOIN pg_enum e ON e.enumtypid = t.oid
+		WHERE `+userNamespace+` AND `+notExtensionMember("pg_type", "t.oid")+`
+		GROUP BY t.oid, n.nspname, t.typname
+		ORDER BY t.oid`)
+	if err != nil {
+		return err
+	}
+
+	var namespace, name string
+	var labels []string
+	_, err = pgx.ForEachRow(rows, []any{&namespace, &name, &labels}, func() error {
+		quoted := make([]string, len(labels))
+		for i, label := range labels {
+			quoted[i] = quoteLiteral(label)
+		}
+		schema.preData = append(schema.preData, fmt.Sprintf("CREATE TYPE %s AS ENUM (%s)",
+			pgx.Identifier{namespace, name}.Sanitize(), strings.Join(quoted, ", ")))
+		return nil
+	})
+	return err
+}
+
+func loadCompositeTypes(ctx context.Context, tx pgx.Tx, schema *nativeSchema) error {
+	rows, err := tx.Query(ctx, `SELECT n.nspname, c.relname,
+			array_agg(quote_ident(a.attname) || ' ' || format_type(a.atttypid, a.atttypmod) ORDER BY a.attnum)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+		WHERE c.relkind = 'c' AND `+userNamespace+` AND `+notExtensionMember("pg_class", "c.oid")+`
+		GROUP BY c.oid, n.nspname, c.relname
+		ORDER BY c.oid`)
+	if err != nil {
+		return err
+	}
+
+	var namespace, name string
+	var attributes []string
+	_, err = pgx.ForEachRow(rows, []any{&namespace, &name, &attributes}, func() error {
+		schema.preData = append(schema.preData, fmt.Sprintf("CREATE TYPE %s AS (%s)",
+			pgx.Identifier{namespace, name}.Sanitize(), strings.Join(attributes, ", ")))
+		return nil
+	})
+	return err
+}
+
+func loadDomains(ctx context.Context, tx pgx.Tx, schema *nativeSchema) error {
+	definitions, err := queryStrings(ctx, tx, `SELECT
+			'CREATE DOMAIN ' || quote_ident(n.nspname) || '.' || quote_ident(t.typname)
+			|| ' AS ' || format_type(t.typbasetype, t.typtypmod)
+			|| coalesce(' DEFAULT ' || t.typdefault, '')
+			|| CASE WHEN t.typnotnull THEN ' NOT NULL' ELSE '' END
+			|| coalesce((SELECT string_agg(' CONSTRAINT ' || quote_ident(con.conname) || ' '
+					|| pg_get_constraintdef(con.oid), '' ORDER BY con.conname)
+				FROM pg_constraint con WHERE con.contypid = t.oid AND con.contype = 'c'), '')
+		FROM pg_type t
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE t.typtype = 'd' AND `+userNamespace+` AND `+notExtensionMember("pg_type", "t.oid")+`
+		ORDER BY t.oid`)
+	if err != nil {
+		return err
+	}
+
+	schema.preData = append(schema.preData, definitions...)
+	return nil
+}
+
+// loadSequences creates sequences other than identity sequences, which are
+// created with their tables
+func loadSequences(ctx context.Context, tx pgx.Tx, schema *nativeSchema) error {
+	definitions, err := queryStrings(ctx, tx, `SELECT
+			'CREATE SEQUENCE ' || quote_ident(n.nspname) || '.' || quote_ident(c.relname)
+			|| ' AS ' || format_type(s.seqtypid, NULL)
+			|| ' START WITH ' || s.seqstart || ' INCREMENT BY ' || s.seqincrement
+			|| ' MINVALUE ' || s.seqmin || ' MAXVALUE ' || s.seqmax || ' CACHE ' || s.seqcache
+			|| CASE WHEN s.seqcycle THEN ' CYCLE' ELSE ' NO CYCLE' END
+		FROM pg_sequence s
+		JOIN pg_class c ON c.oid = s.seqrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE `+userNamespace+` AND `+notExtensionMember("pg_class", "c.oid")+`
+			AND NOT EXISTS (SELECT 1 FROM pg_depend d
+				WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype = 'i')
+		ORDER BY c.oid`)
+	if err != nil {
+		return err
+	}
+
+	schema.preData = append(schema.preData, definitions...)
+	return nil
+}
+
+// usesRowType matches functions whose signature uses the row type of a table
+// or view; it expects the function alias p
+const usesRowType = `EXISTS (SELECT 1 FROM pg_depend d
+		JOIN pg_type t ON t.oid = d.refobjid
+		JOIN pg_class c ON c.oid = t.typrelid
+		WHERE d.classid = 'pg_proc'::regclass AND d.objid = p.oid
+			AND d.refclassid = 'pg_type'::regclass AND c.relkind IN ('r', 'p', 'v', 'm'))`
+
+// loadFunctions creates functions and procedures before the tables, so column
+// defaults can call them. Function bodies are not validated during restore.
+func loadFunctions(ctx context.Context, tx pgx.Tx, schema *nativeSchema) error {
+	definitions, err := queryFunctions(ctx, tx, false)
+	if err != nil {
+		return err
+	}
+
+	schema.preData = append(schema.preData, definitions...)
+	return nil
+}
+
+// loadRowTypeFunctions creates functions that take or return table or view
+// rows once those exist
+func loadRowTypeFunctions(ctx context.Context, tx pgx.Tx, schema *nativeSchema) error {
+	definitions, err := queryFunctions(ctx, tx, true)
+	if err != nil {
+		return err
+	}
+
+	schema.postData = append(schema.postData, definitions...)
+	return nil
+}
+
+func queryFunctions(ctx context.Context, tx pgx.Tx, rowTypes bool) ([]string, error) {
+	return queryStrings(ctx, tx, `SELECT pg_get_functiondef(p.oid)
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE p.prokind IN ('f', 'p') AND `+usesRowType+` = $1
+			AND `+userNamespace+` AND `+notExtensionMember("pg_proc", "p.oid")+`
+		ORDER BY p.oid`, rowTypes)
+}
+
+// nativeRelation is a table as read from pg_class
+type nativeRelation struct {
+	oid         uint32
+	schema      string
+	name        string
+	kind        string
+	unlogged    bool
+	partitionOf string
+	bound       string
+	partitionBy string
+}
+
+func loadTables(ctx context.Context, tx pgx.Tx, schema *nativeSchema) error {
+	rows, err := tx.Query(ctx, `SELECT c.oid, n.nspname, c.relname, c.relkind::text,
+			c.relpersistence = 'u',
+			coalesce((SELECT quote_ident(pn.nspname) || '.' || quote_ident(pc.relname)
+				FROM pg_inherits i
+				JOIN pg_class pc ON pc.oid = i.inhparent
+				JOIN pg_namespace pn ON pn.oid = pc.relnamespace
+				WHERE c.relispartition AND i.inhrelid = c.oid), ''),
+			coalesce(pg_get_expr(c.relpartbound, c.oid), ''),
+			CASE WHEN c.relkind = 'p' THEN pg_get_partkeydef(c.oid) ELSE '' END
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p') AND `+userNamespace+` AND `+notExtensionMember("pg_class", "c.oid")+`
+		ORDER BY c.oid`)
+	if err != nil {
+		return err
+	}
+
+	relations, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*nativeRelation, error) {
+		rel := &nativeRelation{}
+		err := row.Scan(&rel.oid, &rel.schema, &rel.name, &rel.kind, &rel.unlogged,
+			&rel.partitionOf, &rel.bound, &rel.partitionBy)
+		return rel, err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keep the tables stable for the rest of the dump
+	if len(relations) > 0 {
+		names := make([]string, len(relations))
+		for i, rel := range relations {
+			names[i] = pgx.Identifier{rel.schema, rel.name}.Sanitize()
+		}
+		if _, err := tx.Exec(ctx, "LOCK TABLE "+strings.Join(names, ", ")+" IN ACCESS SHARE MODE"); err != nil {
+			return fmt.Errorf("failed to lock tables: %w", err)
+		}
+	}
+
+	for _, rel := range relations {
+		table, definition, err := loadTable(ctx, tx, rel)
+		if err != nil {
+			return err
+		}
+		schema.preData = append(schema.preData, definition)
+		if table != nil {
+			schema.tables = append(schema.tables, table)
+		}
+	}
+
+	return nil
+}
+
+// loadTable returns the CREATE TABLE statement for rel, and the table to copy
+// when rel stores rows itself
+func loadTable(ctx context.Context, tx pgx.Tx, rel *nativeRelation) (*nativeTable, string, error) {
+	rows, err := tx.Query(ctx, `SELECT a.attname, format_type(a.atttypid, a.atttypmod),
+			a.attnotnull, coalesce(pg_get_expr(d.adbin, d.adrelid), ''),
+			a.attidentity::text, a.attgenerated::text,
+			CASE WHEN a.attcollation <> t.typcollation AND a.attcollation <> 0
+				THEN (SELECT quote_ident(cn.nspname) || '.' || quote_ident(co.collname)
+					FROM pg_collation co JOIN pg_namespace cn ON cn.oid = co.collnamespace
+					WHERE co.oid = a.attcollation)
+				ELSE '' END
+		FROM pg_attribute a
+		JOIN pg_type t ON t.oid = a.atttypid
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`, rel.oid)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var (
+		name, typ, def, identity, generated, collation string
+		notNull                                        bool
+		columns, copied                                []string
+	)
+	_, err = pgx.ForEachRow(rows, []any{&name, &typ, &notNull, &def, &identity, &generated, &collation}, func() error {
+		if generated == "" {
+			copied = append(copied, name)
+		}
+		columns = append(columns, columnDefinition(name, typ, collation, def, identity, generated, notNull))
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read columns of %s: %w", rel.name, err)
+	}
+
+	var b strings.Builder
+	b.WriteString("CREATE ")
+	if rel.unlogged {
+		b.WriteString("UNLOGGED ")
+	}
+	b.WriteString("TABLE " + pgx.Identifier{rel.schema, rel.name}.Sanitize())
+	if rel.partitionOf != "" {
+		// Partitions take their columns from the parent
+		b.WriteString(" PARTITION OF " + rel.partitionOf + " " + rel.bound)
+	} else {
+		b.WriteString(" (\n    " + strings.Join(columns, ",\n    ") + "\n)")
+	}
+	if rel.partitionBy != "" {
+		b.WriteString(" PARTITION BY " + rel.partitionBy)
+	}
+
+	// Partitioned tables hold no rows; their partitions are copied instead
+	if rel.kind != "r" || len(copied) == 0 {
+		return nil, b.String(), nil
+	}
+
+	return &nativeTable{schema: rel.schema, name: rel.name, columns: copied}, b.String(), nil
+}
+
+// columnDefinition returns the definition of a column in CREATE TABLE
+func columnDefinition(name, typ, collation, def, identity, generated string, notNull bool) string {
+	column := pgx.Identifier{name}.Sanitize() + " " + typ
+	if collation != "" {
+		column += " COLLATE " + collation
+	}
+
+	switch {
+	case generated == "s":
+		column += " GENERATED ALWAYS AS (" + def + ") STORED"
+	case generated != "":
+		column += " GENERATED ALWAYS AS (" + def + ")"
+	case identity == "a":
+		column += " GENERATED ALWAYS AS IDENTITY"
+	case identity == "d":
+		column += " GENERATED BY DEFAULT AS IDENTITY"
+	case def != "":
+		column += " DEFAULT " + def
+	}
+
+	if notNull {
+		column += " NOT NULL"
+	}
+	return column
+}
+
+// loadSequenceValues restores sequence positions and the ownership of serial
+// sequences once their tables exist
+func loadSequenceValues(ctx context.Context, tx pgx.Tx, schema *nativeSchema) error {
+	owned, err := queryStrings(ctx, tx, `SELECT
+			'ALTER SEQUENCE ' || quote_ident(n.nspname) || '.' || quote_ident(c.relname)
+			|| ' OWNED BY ' || quote_ident(tn.nspname) || '.' || quote_ident(t.relname)
+			|| '.' || quote_ident(a.attname)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_depend d ON d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype = 'a'
+			AND d.refclassid = 'pg_class'::regclass
+		JOIN pg_class t ON t.oid = d.refobjid
+		JOIN pg_namespace tn ON tn.oid = t.relnamespace
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = d.refobjsubid
+		WHERE c.relkind = 'S' AND `+userNamespace+` AND `+notExtensionMember("pg_class", "c.oid")+`
+		ORDER BY c.oid`)
+	if err != nil {
+		return err
+	}
+	schema.preData = append(schema.preData, owned...)
+
+	// Identity sequences are named by the server, so address them through their column
+	values, err := queryStrings(ctx, tx, `SELECT 'SELECT pg_catalog.setval('
+			|| CASE WHEN a.attname IS NULL
+				THEN quote_literal(quote_ident(s.schemaname) || '.' || quote_ident(s.sequencename))
+				ELSE 'pg_catalog.pg_get_serial_sequence('
+					|| quote_literal(quote_ident(tn.nspname) || '.' || quote_ident(t.relname))
+					|| ', ' || quote_literal(a.attname) || ')'
+				END
+			|| ', ' || s.last_value || ', true)'
+		FROM pg_sequences s
+		JOIN pg_namespace n ON n.nspname = s.schemaname
+		JOIN pg_class c ON c.relnamespace = n.oid AND c.relname = s.sequencename
+		LEFT JOIN pg_depend d ON d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype = 'i'
+		LEFT JOIN pg_class t ON t.oid = d.refobjid
+		LEFT JOIN pg_namespace tn ON tn.oid = t.relnamespace
+		LEFT JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+		WHERE s.last_value IS NOT NULL AND `+userNamespace+` AND `+notExtensionMember("pg_class", "c.oid")+`
+		ORDER BY c.oid`)
+	if err != nil {
+		return err
+	}
+	schema.postData = append(schema.postData, values...)
+	return nil
+}
+
+func loadConstraints(ctx context.Context, tx pgx.Tx, schema *nativeSchema) error {
+	definitions, err := queryStrings(ctx, tx, `SELECT
+			'ALTER TABLE ' || quote_ident(n.nspname) || '.' || quote_ident(c.relname)
+			|| ' ADD CONSTRAINT ' || quote_ident(con.conname) || ' ' || pg_get_constraintdef(con.oid)
+		FROM pg_constraint con
+		JOIN pg_class c ON c.oid = con.conrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE con.contype IN ('p', 'u', 'x', 'c', 'f') AND con.conislocal AND con.conparentid = 0
+			AND c.relkind IN ('r', 'p') AND `+userNamespace+` AND `+notExtensionMember("pg_class", "c.oid")+`
+		ORDER BY CASE con.contype WHEN 'f' THEN 1 ELSE 0 END, con.oid`)
+	if err != nil {
+		return err
+	}
+
+	schema.postData = append(schema.postData, definitions...)
+	return nil
+}
+
+// loadIndexes creates indexes that do not back a constraint. Indexes on
+// partitions that are attached to a partitioned index are created with it.
+func loadIndexes(ctx context.Context, tx pgx.Tx, schema *nativeSchema) error {
+	definitions, err := queryStrings(ctx, tx, `SELECT pg_get_indexdef(i.indexrelid)
+		FROM pg_index i
+		JOIN pg_class ic ON ic.oid = i.indexrelid
+		JOIN pg_class c ON c.oid = i.indrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p') AND NOT ic.relispartition
+			AND NOT EXISTS (SELECT 1 FROM pg_constraint con WHERE con.conindid = i.indexrelid
+				AND con.contype IN ('p', 'u', 'x'))
+			AND `+userNamespace+` AND `+notExtensionMember("pg_class", "c.oid")+`
+		ORDER BY i.indexrelid`)
+	if err != nil {
+		return err
+	}
+
+	schema.postData = append(schema.postData, definitions...)
+	return nil
+}
+
+// loadViews creates views and materialized views, then their indexes, then
+// populates the materialized views
+func loadViews(ctx context.Context, tx pgx.Tx, schema *nativeSchema) error {
+	rows, err := tx.Query(ctx, `SELECT n.nspname, c.relname, c.relkind::text,
+			rtrim(pg_get_viewdef(c.oid), ';')
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('v', 'm') AND `+userNamespace+` AND `+notExtensionMember("pg_class", "c.oid")+`
+		ORDER BY c.oid`)
+	if err != nil {
+		return err
+	}
+
+	var namespace, name, kind, definition string
+	var refresh []string
+	_, err = pgx.ForEachRow(rows, []any{&namespace, &name, &kind, &definition}, func() error {
+		qualified := pgx.Identifier{namespace, name}.Sanitize()
+		if kind == "m" {
+			schema.postData = append(schema.postData,
+				fmt.Sprintf("CREATE MATERIALIZED VIEW %s AS\n%s\nWITH NO DATA", qualified, definition))
+			refresh = append(refresh, "REFRESH MATERIALIZED VIEW "+qualified)
+		} else {
+			schema.postData = append(schema.postData, fmt.Sprintf("CREATE VIEW %s AS\n%s", qualified, definition))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	indexes, err := queryStrings(ctx, tx, `SELECT pg_get_indexdef(i.indexrelid)
+		FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind = 'm' AND `+userNamespace+` AND `+notExtensionMember("pg_class", "c.oid")+`
+		ORDER BY i.indexrelid`)
+	if err != nil {
+		return err
+	}
+
+	schema.postData = append(schema.postData, indexes...)
+	schema.postData = append(schema.postData, refresh...)
+	return nil
+}
+
+// loadTriggers creates user triggers. Triggers cloned onto partitions are
+// created with their parent's trigger.
+func loadTriggers(ctx context.Context, tx pgx.Tx, schema *nativeSchema) error {
+	definitions, err := queryStrings(ctx, tx, `SELECT pg_get_triggerdef(t.oid)
+		FROM pg_trigger t
+		JOIN pg_class c ON c.oid = t.tgrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE NOT t.tgisinternal AND t.tgparentid = 0
+			AND `+userNamespace+` AND `+notExtensionMember("pg_class", "c.oid")+`
+		ORDER BY t.oid`)
+	if err != nil {
+		return err
+	}
+
+	schema.postData = append(schema.postData, definitions...)
+	return nil
+}
+
+// quoteLiteral quotes a string as an SQL literal
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
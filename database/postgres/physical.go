@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// physicalBackup streams a base backup of the whole cluster in tar format.
+// pg_basebackup can only write a single tar to stdout, so clusters with
+// additional tablespaces are not supported. WAL needed to make the backup
+// consistent is fetched into the same archive.
+func (p *Provider) physicalBackup(ctx context.Context) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, "pg_basebackup",
+		"-d", p.settings.String(),
+		"-D", "-", // Write the tar archive to stdout
+		"-F", "t", // Tar format
+		"-X", "fetch", // Include required WAL in the archive
+		"-c", "fast", // Don't wait for a spread checkpoint
+		"--no-password",
+	)
+	cmd.Env = commandEnv(p.password)
+
+	return startBackupCommand(cmd)
+}
+
+// ExtractBaseBackup extracts a tar-format base backup into dataDir, which must
+// be empty or not exist yet. A server can then be started on dataDir.
+func ExtractBaseBackup(reader io.Reader, dataDir string) error {
+	if dataDir == "" {
+		return fmt.Errorf("physical restore requires a target data directory")
+	}
+
+	if err := prepareDataDirectory(dataDir); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read base backup: %w", err)
+		}
+
+		if err := extractEntry(tr, header, dataDir); err != nil {
+			return err
+		}
+	}
+}
+
+// prepareDataDirectory creates dataDir with PostgreSQL's required permissions,
+// refusing to overwrite an existing non-empty directory
+func prepareDataDirectory(dataDir string) error {
+	entries, err := os.ReadDir(dataDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read data directory: %w", err)
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("data directory %s is not empty", dataDir)
+	}
+
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	return os.Chmod(dataDir, 0700)
+}
+
+// extractEntry writes a single tar entry below dataDir
+func extractEntry(tr *tar.Reader, header *tar.Header, dataDir string) error {
+	target, err := safeJoin(dataDir, header.Name)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(header.Mode).Perm()
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(target, mode|0700); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", header.Name, err)
+		}
+	case tar.TypeReg:
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", header.Name, err)
+		}
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+		if err != nil {
+			return fmt.Errorf("failed to create file %s: %w", header.Name, err)
+		}
+		if _, err := io.Copy(file, tr); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write file %s: %w", header.Name, err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", header.Name, err)
+		}
+	default:
+		// Symlinks only appear for additional tablespaces, which are not supported
+		return fmt.Errorf("unsupported entry %s in base backup", header.Name)
+	}
+
+	return nil
+}
+
+// safeJoin joins name to dir, rejecting names that would escape dir
+func safeJoin(dir, name string) (string, error) {
+	target := filepath.Join(dir, name)
+	if target != filepath.Clean(dir) && !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid path %s in base backup", name)
+	}
+	return target, nil
+}
@@ -0,0 +1,102 @@
+package postgres_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"goarchive/database/postgres"
+)
+
+// buildTar creates a tar archive from the given entries
+func buildTar(t *testing.T, entries []*tar.Header, contents map[string]string) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, header := range entries {
+		body := contents[header.Name]
+		header.Size = int64(len(body))
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatalf("failed to write tar body: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar writer: %v", err)
+	}
+	return &buf
+}
+
+func TestExtractBaseBackup(t *testing.T) {
+	t.Run("extracts files and directories", func(t *testing.T) {
+		dataDir := filepath.Join(t.TempDir(), "pgdata")
+
+		archive := buildTar(t, []*tar.Header{
+			{Name: "PG_VERSION", Typeflag: tar.TypeReg, Mode: 0600},
+			{Name: "global/", Typeflag: tar.TypeDir, Mode: 0700},
+			{Name: "global/pg_control", Typeflag: tar.TypeReg, Mode: 0600},
+		}, map[string]string{
+			"PG_VERSION":        "16\n",
+			"global/pg_control": "control",
+		})
+
+		if err := postgres.ExtractBaseBackup(archive, dataDir); err != nil {
+			t.Fatalf("ExtractBaseBackup() error = %v", err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(dataDir, "PG_VERSION"))
+		if err != nil {
+			t.Fatalf("failed to read extracted file: %v", err)
+		}
+		if string(data) != "16\n" {
+			t.Errorf("expected PG_VERSION '16', got %q", string(data))
+		}
+
+		if _, err := os.Stat(filepath.Join(dataDir, "global", "pg_control")); err != nil {
+			t.Errorf("expected global/pg_control to be extracted: %v", err)
+		}
+
+		info, err := os.Stat(dataDir)
+		if err != nil {
+			t.Fatalf("failed to stat data directory: %v", err)
+		}
+		if info.Mode().Perm() != 0700 {
+			t.Errorf("expected data directory mode 0700, got %v", info.Mode().Perm())
+		}
+	})
+
+	t.Run("rejects non-empty data directory", func(t *testing.T) {
+		dataDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dataDir, "existing"), []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		archive := buildTar(t, nil, nil)
+		if err := postgres.ExtractBaseBackup(archive, dataDir); err == nil {
+			t.Error("expected error for non-empty data directory, got nil")
+		}
+	})
+
+	t.Run("rejects path traversal", func(t *testing.T) {
+		dataDir := filepath.Join(t.TempDir(), "pgdata")
+
+		archive := buildTar(t, []*tar.Header{
+			{Name: "../escape", Typeflag: tar.TypeReg, Mode: 0600},
+		}, map[string]string{"../escape": "x"})
+
+		if err := postgres.ExtractBaseBackup(archive, dataDir); err == nil {
+			t.Error("expected error for path traversal, got nil")
+		}
+	})
+
+	t.Run("requires data directory", func(t *testing.T) {
+		if err := postgres.ExtractBaseBackup(buildTar(t, nil, nil), ""); err == nil {
+			t.Error("expected error without data directory, got nil")
+		}
+	})
+}
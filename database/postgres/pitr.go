@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goarchive/core"
+)
+
+// SelectBaseBackup returns the most recent physical base backup taken at or
+// before target, which is the starting point for recovering to target
+func SelectBaseBackup(backups []*core.BackupMetadata, target time.Time) (*core.BackupMetadata, error) {
+	var selected *core.BackupMetadata
+	for _, backup := range backups {
+		if backup.BackupMode != ModePhysical || backup.Timestamp.After(target) {
+			continue
+		}
+		if selected == nil || backup.Timestamp.After(selected.Timestamp) {
+			selected = backup
+		}
+	}
+
+	if selected == nil {
+		return nil, fmt.Errorf("no physical base backup found before %s", target.Format(time.RFC3339))
+	}
+
+	return selected, nil
+}
+
+// WriteRecoveryConfig configures the restored data directory to replay
+// archived WAL with restoreCommand up to target and then promote. It appends
+// the settings to postgresql.auto.conf and creates recovery.signal
+// (PostgreSQL 12 and later).
+func WriteRecoveryConfig(dataDir, restoreCommand string, target time.Time) error {
+	settings := fmt.Sprintf(
+		"\n# Added by goarchive for point-in-time recovery\n"+
+			"restore_command = %s\n"+
+			"recovery_target_time = %s\n"+
+			"recovery_target_action = 'promote'\n",
+		quoteConfigValue(restoreCommand),
+		quoteConfigValue(target.Format("2006-01-02 15:04:05.999999-07:00")),
+	)
+
+	autoConf := filepath.Join(dataDir, "postgresql.auto.conf")
+	file, err := os.OpenFile(autoConf, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", autoConf, err)
+	}
+	if _, err := file.WriteString(settings); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write recovery settings: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write recovery settings: %w", err)
+	}
+
+	signal := filepath.Join(dataDir, "recovery.signal")
+	if err := os.WriteFile(signal, nil, 0600); err != nil {
+		return fmt.Errorf("failed to create recovery.signal: %w", err)
+	}
+
+	return nil
+}
+
+// quoteConfigValue quotes a value for postgresql.conf, which treats
+// backslashes as escape characters
+func quoteConfigValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
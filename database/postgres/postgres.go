@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"strings"
 
 	"goarchive/core"
 
@@ -18,86 +20,234 @@ func init() {
 	})
 }
 
+const (
+	// ModeLogical backs up a single database with pg_dump (the default)
+	ModeLogical = "logical"
+
+	// ModePhysical backs up the whole cluster with pg_basebackup
+	ModePhysical = "physical"
+
+	// ModeNative backs up a single database in-process, without pg_dump
+	ModeNative = "native"
+)
+
 // Provider implements the DatabaseProvider interface for PostgreSQL
 type Provider struct {
-	config *core.DatabaseConfig
-	conn   *pgx.Conn
+	config   *core.DatabaseConfig
+	conn     *pgx.Conn
+	settings connSettings // Connection parameters shared with the libpq tools
+	password string
+	database string // Name of the connected database
 }
 
 // New creates a new PostgreSQL provider
 func New(config *core.DatabaseConfig) (*Provider, error) {
-	connString := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		config.Host,
-		config.Port,
-		config.Username,
-		config.Password,
-		config.Database,
-		config.SSLMode,
-	)
-
-	conn, err := pgx.Connect(context.Background(), connString)
+	settings, password, err := buildConnSettings(config)
+	if err != nil {
+		return nil, err
+	}
+
+	pgxConfig, err := pgx.ParseConfig(settings.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection settings: %w", err)
+	}
+	if password != "" {
+		pgxConfig.Password = password
+	}
+
+	conn, err := pgx.ConnectConfig(context.Background(), pgxConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
 	}
 
+	// A URI or service may leave the database name implicit
+	var database string
+	if err := conn.QueryRow(context.Background(), "SELECT current_database()").Scan(&database); err != nil {
+		conn.Close(context.Background())
+		return nil, fmt.Errorf("failed to get current database: %w", err)
+	}
+
 	return &Provider{
-		config: config,
-		conn:   conn,
+		config:   config,
+		conn:     conn,
+		settings: settings,
+		password: password,
+		database: database,
 	}, nil
 }
 
-// Backup creates a backup using pg_dump and returns a reader
+// Backup creates a backup using pg_dump, pg_basebackup in physical mode or
+// the in-process dumper in native mode, and returns a reader
 func (p *Provider) Backup(ctx context.Context) (io.ReadCloser, error) {
-	cmd := exec.CommandContext(ctx, "pg_dump",
-		"-h", p.config.Host,
-		"-p", fmt.Sprintf("%d", p.config.Port),
-		"-U", p.config.Username,
-		"-d", p.config.Database,
+	// Abort before any data is produced if the backup cannot succeed
+	if _, err := p.Preflight(ctx); err != nil {
+		return nil, err
+	}
+
+	switch p.backupMode() {
+	case ModeLogical:
+		return p.logicalBackup(ctx)
+	case ModePhysical:
+		return p.physicalBackup(ctx)
+	case ModeNative:
+		return p.nativeBackup(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported backup mode: %s", p.config.BackupMode)
+	}
+}
+
+// logicalBackup dumps the configured database with pg_dump
+func (p *Provider) logicalBackup(ctx context.Context) (io.ReadCloser, error) {
+	args := []string{
+		"-d", p.settings.withDatabase(p.database).String(),
 		"-F", "c", // Custom format
 		"--no-password",
-	)
+	}
 
-	// Set PGPASSWORD environment variable
-	cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", p.config.Password))
+	if p.config.LockWaitTimeout > 0 {
+		args = append(args, fmt.Sprintf("--lock-wait-timeout=%d", p.config.LockWaitTimeout.Milliseconds()))
+	}
 
+	if p.config.SerializableDeferrable {
+		args = append(args, "--serializable-deferrable")
+	}
+
+	cmd := exec.CommandContext(ctx, "pg_dump", args...)
+	cmd.Env = commandEnv(p.password)
+
+	return startBackupCommand(cmd)
+}
+
+// startBackupCommand starts cmd and returns a reader over its standard output
+func startBackupCommand(cmd *exec.Cmd) (io.ReadCloser, error) {
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create pipe: %w", err)
 	}
 
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
 	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start pg_dump: %w", err)
+		return nil, fmt.Errorf("failed to start %s: %w", cmd.Args[0], err)
 	}
 
 	// Return a reader that waits for the command to complete
 	return &backupReader{
 		ReadCloser: stdout,
 		cmd:        cmd,
+		stderr:     &stderr,
 	}, nil
 }
 
+// backupMode returns the configured backup mode, defaulting to logical
+func (p *Provider) backupMode() string {
+	if p.config.BackupMode == "" {
+		return ModeLogical
+	}
+	return p.config.BackupMode
+}
+
 // Restore restores a database from backup data using pg_restore
 func (p *Provider) Restore(ctx context.Context, reader io.Reader) error {
-	cmd := exec.CommandContext(ctx, "pg_restore",
-		"-h", p.config.Host,
-		"-p", fmt.Sprintf("%d", p.config.Port),
-		"-U", p.config.Username,
-		"-d", p.config.Database,
-		"--clean",         // Clean (drop) database objects before recreating
-		"--if-exists",     // Use IF EXISTS when dropping objects
-		"--no-owner",      // Skip restoration of object ownership
-		"--no-privileges", // Skip restoration of access privileges
-		"--no-password",
-	)
+	return p.RestoreWithOptions(ctx, reader, &core.RestoreOptions{})
+}
 
-	// Set PGPASSWORD environment variable
-	cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", p.config.Password))
+// RestoreWithOptions restores backup data using pg_restore, optionally into a
+// different database, creating or recreating it first, and in parallel. Only
+// the selected tables or schemas are restored when opts selects any, and
+// opts.TargetSchema restores them into a new schema instead of in place.
+// Physical backups are extracted into opts.DataDirectory instead, and native
+// backups are loaded in-process.
+func (p *Provider) RestoreWithOptions(ctx context.Context, reader io.Reader, opts *core.RestoreOptions) error {
+	if opts == nil {
+		opts = &core.RestoreOptions{}
+	}
+
+	if opts.BackupMode != "" && opts.BackupMode != ModeLogical && (opts.Selective() || opts.TargetSchema != "") {
+		return fmt.Errorf("selective restore is not supported for %s backups", opts.BackupMode)
+	}
 
-	// Set stdin to the reader
-	cmd.Stdin = reader
+	// pg_restore --clean would drop the live tables along with the sequences,
+	// indexes and triggers the restore list leaves out
+	inPlace := opts.Selective() && opts.TargetSchema == ""
+	if inPlace && !opts.CreateDatabase && !opts.DropDatabase {
+		return fmt.Errorf("selective restores require a target schema or a newly created target database")
+	}
+
+	if p.config.MaskingProfile != "" && opts.BackupMode != ModeNative {
+		return fmt.Errorf("masking is only supported for native backups")
+	}
+
+	switch opts.BackupMode {
+	case "", ModeLogical:
+	case ModePhysical:
+		return ExtractBaseBackup(reader, opts.DataDirectory)
+	case ModeNative:
+		return p.nativeRestore(ctx, reader, opts)
+	default:
+		return fmt.Errorf("unsupported backup mode: %s", opts.BackupMode)
+	}
+
+	if opts.Jobs > 1 && opts.SingleTransaction {
+		return fmt.Errorf("parallel restore cannot be combined with a single transaction")
+	}
+
+	if opts.TargetSchema != "" && !opts.Selective() {
+		return fmt.Errorf("restoring into a target schema requires selected tables or schemas")
+	}
+
+	target := opts.TargetDatabase
+	if target == "" {
+		target = p.database
+	}
+
+	created, err := p.prepareTargetDatabase(ctx, target, opts)
+	if err != nil {
+		return err
+	}
+	if inPlace && !created {
+		return fmt.Errorf("selective restores require a target schema or a newly created target database; database %q already exists", target)
+	}
+
+	args := p.restoreArgs(target, opts, created)
+
+	// pg_restore can only run parallel jobs or list the contents of a
+	// seekable archive file
+	if opts.Jobs > 1 || opts.Selective() {
+		path, cleanup, err := spoolToFile(reader)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		if opts.Selective() {
+			listPath, cleanupList, err := p.writeRestoreList(ctx, path, opts)
+			if err != nil {
+				return err
+			}
+			defer cleanupList()
+
+			if opts.TargetSchema != "" {
+				return p.restoreIntoSchema(ctx, path, listPath, target, opts)
+			}
+			args = append(args, "-L", listPath)
+		}
+
+		args = append(args, path)
+		reader = nil
+	}
+
+	return p.runRestore(ctx, args, reader)
+}
+
+// runRestore runs pg_restore with args, reading the archive from stdin when
+// it is not nil
+func (p *Provider) runRestore(ctx context.Context, args []string, stdin io.Reader) error {
+	cmd := exec.CommandContext(ctx, "pg_restore", args...)
+	cmd.Env = commandEnv(p.password)
+	cmd.Stdin = stdin
 
-	// Execute the command
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to restore database: %w (output: %s)", err, string(output))
@@ -106,6 +256,100 @@ func (p *Provider) Restore(ctx context.Context, reader io.Reader) error {
 	return nil
 }
 
+// restoreArgs builds the pg_restore arguments for restoring into target
+func (p *Provider) restoreArgs(target string, opts *core.RestoreOptions, created bool) []string {
+	args := []string{
+		"-d", p.settings.withDatabase(target).String(),
+		"--no-owner",      // Skip restoration of object ownership
+		"--no-privileges", // Skip restoration of access privileges
+		"--no-password",
+	}
+
+	// A freshly created database has nothing to clean
+	if !created {
+		args = append(args,
+			"--clean",     // Clean (drop) database objects before recreating
+			"--if-exists", // Use IF EXISTS when dropping objects
+		)
+	}
+
+	if opts.SingleTransaction {
+		args = append(args, "--single-transaction")
+	}
+
+	if opts.Jobs > 1 {
+		args = append(args, "--jobs", fmt.Sprintf("%d", opts.Jobs))
+	}
+
+	return args
+}
+
+// prepareTargetDatabase creates or recreates the target database as requested
+// by opts and reports whether the database was newly created
+func (p *Provider) prepareTargetDatabase(ctx context.Context, target string, opts *core.RestoreOptions) (bool, error) {
+	if !opts.CreateDatabase && !opts.DropDatabase {
+		return false, nil
+	}
+
+	// The provider's own connection keeps its database open, so it cannot be dropped
+	if opts.DropDatabase && target == p.database {
+		return false, fmt.Errorf("cannot drop database %q: the provider is connected to it", target)
+	}
+
+	identifier := pgx.Identifier{target}.Sanitize()
+
+	if opts.DropDatabase {
+		if _, err := p.conn.Exec(ctx, "DROP DATABASE IF EXISTS "+identifier); err != nil {
+			return false, fmt.Errorf("failed to drop database %q: %w", target, err)
+		}
+	} else {
+		var exists bool
+		err := p.conn.QueryRow(ctx,
+			"SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", target).Scan(&exists)
+		if err != nil {
+			return false, fmt.Errorf("failed to check database %q: %w", target, err)
+		}
+		if exists {
+			return false, nil
+		}
+	}
+
+	if _, err := p.conn.Exec(ctx, "CREATE DATABASE "+identifier); err != nil {
+		return false, fmt.Errorf("failed to create database %q: %w", target, err)
+	}
+
+	return true, nil
+}
+
+// spoolToFile returns the path of a file holding the contents of reader,
+// writing reader to a temporary file unless it is already a regular file.
+// The returned cleanup function removes any temporary file.
+func spoolToFile(reader io.Reader) (string, func(), error) {
+	if file, ok := reader.(*os.File); ok {
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+			return file.Name(), func() {}, nil
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "goarchive-restore-*.dump")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	cleanup := func() { os.Remove(tmp.Name()) }
+
+	if _, err := io.Copy(tmp, reader); err != nil {
+		tmp.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("failed to spool backup data: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to spool backup data: %w", err)
+	}
+
+	return tmp.Name(), cleanup, nil
+}
+
 // GetMetadata returns metadata about the database
 func (p *Provider) GetMetadata() (*core.DatabaseMetadata, error) {
 	var version string
@@ -117,18 +361,24 @@ func (p *Provider) GetMetadata() (*core.DatabaseMetadata, error) {
 		return nil, fmt.Errorf("failed to get version: %w", err)
 	}
 
-	// Get database size
-	err = p.conn.QueryRow(context.Background(),
-		"SELECT pg_database_size($1)", p.config.Database).Scan(&size)
+	// Get database size, or the whole cluster's size for physical backups
+	if p.backupMode() == ModePhysical {
+		err = p.conn.QueryRow(context.Background(),
+			"SELECT sum(pg_database_size(datname))::bigint FROM pg_database").Scan(&size)
+	} else {
+		err = p.conn.QueryRow(context.Background(),
+			"SELECT pg_database_size(current_database())").Scan(&size)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get database size: %w", err)
 	}
 
 	return &core.DatabaseMetadata{
-		Type:    "postgres",
-		Version: version,
-		Size:    size,
-		Name:    p.config.Database,
+		Type:       "postgres",
+		Version:    version,
+		Size:       size,
+		Name:       p.database,
+		BackupMode: p.backupMode(),
 	}, nil
 }
 
@@ -143,11 +393,37 @@ func (p *Provider) Close() error {
 // backupReader wraps the stdout pipe and waits for the command to complete
 type backupReader struct {
 	io.ReadCloser
-	cmd *exec.Cmd
+	cmd    *exec.Cmd
+	stderr *strings.Builder
+	waited bool
+	err    error
+}
+
+// Read reads from the pipe and, once the command ends, reports its failure
+// instead of io.EOF
+func (r *backupReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF {
+		if waitErr := r.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
 }
 
 // Close closes the pipe and waits for the command to finish
 func (r *backupReader) Close() error {
 	r.ReadCloser.Close()
-	return r.cmd.Wait()
+	return r.wait()
+}
+
+// wait waits for the command to finish, once
+func (r *backupReader) wait() error {
+	if !r.waited {
+		r.waited = true
+		if err := r.cmd.Wait(); err != nil {
+			r.err = fmt.Errorf("%s failed: %w (output: %s)", r.cmd.Args[0], err, strings.TrimSpace(r.stderr.String()))
+		}
+	}
+	return r.err
 }
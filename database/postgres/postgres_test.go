@@ -1,9 +1,15 @@
 package postgres_test
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
 	"strconv"
+	"strings"
 	"testing"
 
 	"goarchive/core"
@@ -229,6 +235,357 @@ func TestIntegration_PostgreSQL(t *testing.T) {
 		}
 	})
 
+	t.Run("PhysicalBackupFailure", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("fake tools use shell scripts")
+		}
+
+		// A base backup that fails halfway must not read as a complete backup
+		dir := t.TempDir()
+		script := "#!/bin/sh\n" +
+			"if [ \"$1\" = --version ]; then echo 'pg_basebackup (PostgreSQL) 99.0'; exit 0; fi\n" +
+			"printf 'partial tar data'\n" +
+			"echo 'pg_basebackup: error: could not read COPY data: server closed the connection unexpectedly' >&2\n" +
+			"exit 1\n"
+		if err := os.WriteFile(filepath.Join(dir, "pg_basebackup"), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		physical := *config
+		physical.BackupMode = postgres.ModePhysical
+		provider, err := postgres.New(&physical)
+		if err != nil {
+			t.Skipf("Skipping integration test - PostgreSQL not available: %v", err)
+			return
+		}
+		defer provider.Close()
+
+		ctx := context.Background()
+		reader, err := provider.Backup(ctx)
+		if err != nil {
+			t.Skipf("Skipping physical backup test - preflight failed: %v", err)
+			return
+		}
+		defer reader.Close()
+
+		_, err = io.ReadAll(reader)
+		if err == nil || !strings.Contains(err.Error(), "server closed the connection") || !strings.Contains(err.Error(), "exit status 1") {
+			t.Errorf("expected the pg_basebackup failure from Read, got %v", err)
+		}
+		if err := reader.Close(); err == nil {
+			t.Error("expected Close() to report the failure, got nil")
+		}
+	})
+
+	t.Run("RestoreWithOptions", func(t *testing.T) {
+		provider, err := postgres.New(config)
+		if err != nil {
+			t.Skipf("Skipping integration test - PostgreSQL not available: %v", err)
+			return
+		}
+		defer provider.Close()
+
+		ctx := context.Background()
+
+		// Parallel jobs cannot run inside a single transaction
+		err = provider.RestoreWithOptions(ctx, bytes.NewReader(nil), &core.RestoreOptions{
+			Jobs:              2,
+			SingleTransaction: true,
+		})
+		if err == nil {
+			t.Error("expected error combining jobs with single transaction, got nil")
+		}
+
+		// The connected database cannot be dropped
+		err = provider.RestoreWithOptions(ctx, bytes.NewReader(nil), &core.RestoreOptions{
+			DropDatabase: true,
+		})
+		if err == nil {
+			t.Error("expected error dropping the connected database, got nil")
+		}
+
+		reader, err := provider.Backup(ctx)
+		if err != nil {
+			t.Fatalf("Backup() error = %v", err)
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("failed to read backup data: %v", err)
+		}
+		if err := reader.Close(); err != nil {
+			t.Fatalf("Backup() close error = %v", err)
+		}
+
+		// Recreate a copy of the database and restore into it in parallel
+		err = provider.RestoreWithOptions(ctx, bytes.NewReader(data), &core.RestoreOptions{
+			TargetDatabase: config.Database + "_restore_copy",
+			DropDatabase:   true,
+			Jobs:           2,
+		})
+		if err != nil {
+			t.Errorf("RestoreWithOptions() error = %v", err)
+		}
+	})
+
+	t.Run("NativeBackupRestore", func(t *testing.T) {
+		nativeConfig := *config
+		nativeConfig.BackupMode = postgres.ModeNative
+
+		provider, err := postgres.New(&nativeConfig)
+		if err != nil {
+			t.Skipf("Skipping integration test - PostgreSQL not available: %v", err)
+			return
+		}
+		defer provider.Close()
+
+		ctx := context.Background()
+
+		source := &core.DatabaseConfig{}
+		*source = nativeConfig
+		source.Database = config.Database + "_native_source"
+		if err := provider.RestoreWithOptions(ctx, bytes.NewReader([]byte(nativeFixture)), &core.RestoreOptions{
+			TargetDatabase: source.Database,
+			DropDatabase:   true,
+			BackupMode:     postgres.ModeNative,
+		}); err != nil {
+			t.Fatalf("failed to load fixture: %v", err)
+		}
+
+		sourceProvider, err := postgres.New(source)
+		if err != nil {
+			t.Fatalf("failed to connect to source database: %v", err)
+		}
+		defer sourceProvider.Close()
+
+		reader, err := sourceProvider.Backup(ctx)
+		if err != nil {
+			t.Fatalf("Backup() error = %v", err)
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("failed to read backup data: %v", err)
+		}
+		if err := reader.Close(); err != nil {
+			t.Fatalf("Backup() close error = %v", err)
+		}
+		if !bytes.HasPrefix(data, []byte("GOARCHIVE-PGNATIVE 1\n")) {
+			t.Fatalf("unexpected backup header: %q", data[:min(len(data), 40)])
+		}
+
+		// Restore the dump of the fixture and dump it again: the data must survive
+		err = provider.RestoreWithOptions(ctx, bytes.NewReader(data), &core.RestoreOptions{
+			TargetDatabase: config.Database + "_native_copy",
+			DropDatabase:   true,
+			BackupMode:     postgres.ModeNative,
+		})
+		if err != nil {
+			t.Fatalf("RestoreWithOptions() error = %v", err)
+		}
+
+		for _, want := range []string{"Ada", "tab\\there", "GENERATED ALWAYS AS IDENTITY", "CREATE VIEW"} {
+			if !bytes.Contains(data, []byte(want)) {
+				t.Errorf("expected backup to contain %q", want)
+			}
+		}
+
+		// Restoring over existing objects fails without changing the database
+		err = provider.RestoreWithOptions(ctx, bytes.NewReader(data), &core.RestoreOptions{
+			TargetDatabase: config.Database + "_native_copy",
+			BackupMode:     postgres.ModeNative,
+		})
+		if err == nil {
+			t.Error("expected error restoring over existing objects, got nil")
+		}
+	})
+
+	t.Run("MaskedNativeBackup", func(t *testing.T) {
+		dir := t.TempDir()
+		profilePath := filepath.Join(dir, "profile.json")
+		profile := `{"salt": "test", "rules": [{"table": "app.people", "column": "name", "strategy": "scramble"}]}`
+		if err := os.WriteFile(profilePath, []byte(profile), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		maskedConfig := *config
+		maskedConfig.BackupMode = postgres.ModeNative
+		maskedConfig.MaskingProfile = profilePath
+		maskedConfig.MaskingReport = filepath.Join(dir, "report.json")
+
+		provider, err := postgres.New(&maskedConfig)
+		if err != nil {
+			t.Skipf("Skipping integration test - PostgreSQL not available: %v", err)
+			return
+		}
+		defer provider.Close()
+
+		ctx := context.Background()
+
+		// Mask while restoring: the fixture's names never reach the database
+		source := maskedConfig
+		source.Database = config.Database + "_masked_source"
+		if err := provider.RestoreWithOptions(ctx, bytes.NewReader([]byte(nativeFixture)), &core.RestoreOptions{
+			TargetDatabase: source.Database,
+			DropDatabase:   true,
+			BackupMode:     postgres.ModeNative,
+		}); err != nil {
+			t.Fatalf("RestoreWithOptions() error = %v", err)
+		}
+
+		report, err := os.ReadFile(maskedConfig.MaskingReport)
+		if err != nil {
+			t.Fatalf("expected masking report: %v", err)
+		}
+		if !bytes.Contains(report, []byte(`"rows": 2`)) || !bytes.Contains(report, []byte(`"operation": "restore"`)) {
+			t.Errorf("unexpected masking report: %s", report)
+		}
+
+		// Mask while backing up, from a database holding the original names
+		plain := maskedConfig
+		plain.MaskingProfile = ""
+		plain.Database = config.Database + "_masked_plain"
+		plainProvider, err := postgres.New(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer plainProvider.Close()
+		if err := plainProvider.RestoreWithOptions(ctx, bytes.NewReader([]byte(nativeFixture)), &core.RestoreOptions{
+			TargetDatabase: plain.Database,
+			DropDatabase:   true,
+			BackupMode:     postgres.ModeNative,
+		}); err != nil {
+			t.Fatalf("failed to load fixture: %v", err)
+		}
+
+		backupConfig := maskedConfig
+		backupConfig.Database = plain.Database
+		backupProvider, err := postgres.New(&backupConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer backupProvider.Close()
+
+		reader, err := backupProvider.Backup(ctx)
+		if err != nil {
+			t.Fatalf("Backup() error = %v", err)
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("failed to read backup data: %v", err)
+		}
+		if err := reader.Close(); err != nil {
+			t.Fatalf("Backup() close error = %v", err)
+		}
+
+		if bytes.Contains(data, []byte("Ada")) || bytes.Contains(data, []byte(`tab\there`)) {
+			t.Error("expected names to be masked in the backup")
+		}
+		if !regexp.MustCompile(`\t[A-Z][a-z]{2}\t`).Match(data) || !regexp.MustCompile(`\t[a-z]{3}\\t[a-z]{4}\t`).Match(data) {
+			t.Errorf("expected scrambled names to keep their format:\n%s", data)
+		}
+		if !bytes.Contains(data, []byte(`"masked":["app.people.name (scramble)"]`)) {
+			t.Error("expected the manifest to list the masked columns")
+		}
+
+		// Logical backups cannot be masked
+		logical := maskedConfig
+		logical.BackupMode = postgres.ModeLogical
+		logicalProvider, err := postgres.New(&logical)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer logicalProvider.Close()
+		if _, err := logicalProvider.Backup(ctx); err == nil {
+			t.Error("expected masking to be rejected for logical backups, got nil")
+		}
+	})
+
+	t.Run("SelectiveRestore", func(t *testing.T) {
+		provider, err := postgres.New(config)
+		if err != nil {
+			t.Skipf("Skipping integration test - PostgreSQL not available: %v", err)
+			return
+		}
+		defer provider.Close()
+
+		ctx := context.Background()
+
+		source := *config
+		source.Database = config.Database + "_selective_source"
+		if err := provider.RestoreWithOptions(ctx, bytes.NewReader([]byte(nativeFixture)), &core.RestoreOptions{
+			TargetDatabase: source.Database,
+			DropDatabase:   true,
+			BackupMode:     postgres.ModeNative,
+		}); err != nil {
+			t.Fatalf("failed to load fixture: %v", err)
+		}
+
+		sourceProvider, err := postgres.New(&source)
+		if err != nil {
+			t.Fatalf("failed to connect to source database: %v", err)
+		}
+		defer sourceProvider.Close()
+
+		reader, err := sourceProvider.Backup(ctx)
+		if err != nil {
+			t.Fatalf("Backup() error = %v", err)
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("failed to read backup data: %v", err)
+		}
+		if err := reader.Close(); err != nil {
+			t.Fatalf("Backup() close error = %v", err)
+		}
+
+		// Restore a single table into a scratch database
+		err = provider.RestoreWithOptions(ctx, bytes.NewReader(data), &core.RestoreOptions{
+			TargetDatabase: config.Database + "_selective_copy",
+			DropDatabase:   true,
+			Tables:         []string{"app.people"},
+		})
+		if err != nil {
+			t.Errorf("RestoreWithOptions() error = %v", err)
+		}
+
+		// Restore the same table next to the live one
+		err = sourceProvider.RestoreWithOptions(ctx, bytes.NewReader(data), &core.RestoreOptions{
+			Tables:       []string{"people"},
+			TargetSchema: "recovered",
+		})
+		if err != nil {
+			t.Fatalf("RestoreWithOptions() into schema error = %v", err)
+		}
+
+		// The side schema must not be overwritten
+		err = sourceProvider.RestoreWithOptions(ctx, bytes.NewReader(data), &core.RestoreOptions{
+			Tables:       []string{"people"},
+			TargetSchema: "recovered",
+		})
+		if err == nil {
+			t.Error("expected error restoring into an existing schema, got nil")
+		}
+
+		err = provider.RestoreWithOptions(ctx, bytes.NewReader(data), &core.RestoreOptions{
+			TargetDatabase: source.Database,
+			Tables:         []string{"app.missing"},
+			TargetSchema:   "missing",
+		})
+		if err == nil {
+			t.Error("expected error selecting a missing table, got nil")
+		}
+
+		// The live table must not be replaced in place
+		err = provider.RestoreWithOptions(ctx, bytes.NewReader(data), &core.RestoreOptions{
+			TargetDatabase: source.Database,
+			CreateDatabase: true,
+			Tables:         []string{"app.people"},
+		})
+		if err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Errorf("expected an in-place selective restore to be refused, got %v", err)
+		}
+	})
+
 	t.Run("Close", func(t *testing.T) {
 		provider, err := postgres.New(config)
 		if err != nil {
@@ -242,3 +599,67 @@ func TestIntegration_PostgreSQL(t *testing.T) {
 		}
 	})
 }
+
+// Rows that cannot be masked abort the restore instead of passing through
+func TestIntegration_MaskingMalformedRow(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	dir := t.TempDir()
+	profilePath := filepath.Join(dir, "profile.json")
+	profile := `{"salt": "test", "rules": [{"table": "app.people", "column": "name", "strategy": "scramble"}]}`
+	if err := os.WriteFile(profilePath, []byte(profile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := getTestConfig()
+	config.BackupMode = postgres.ModeNative
+	config.MaskingProfile = profilePath
+
+	provider, err := postgres.New(config)
+	if err != nil {
+		t.Skipf("Skipping integration test - PostgreSQL not available: %v", err)
+	}
+	defer provider.Close()
+
+	malformed := strings.Replace(nativeFixture, "1\tAda\tok\n", "1\tAda\n", 1)
+	err = provider.RestoreWithOptions(context.Background(), strings.NewReader(malformed), &core.RestoreOptions{
+		TargetDatabase: config.Database + "_masked_malformed",
+		DropDatabase:   true,
+		BackupMode:     postgres.ModeNative,
+	})
+	if err == nil || !strings.Contains(err.Error(), "has 2 fields, expected 3") {
+		t.Errorf("expected malformed row to be rejected, got %v", err)
+	}
+}
+
+// nativeFixture is a small native backup exercising common schema objects
+const nativeFixture = `GOARCHIVE-PGNATIVE 1
+{"format":1,"database":"fixture"}
+STMT 33
+CREATE SCHEMA IF NOT EXISTS "app"
+STMT 46
+CREATE TYPE "app"."mood" AS ENUM ('ok', 'sad')
+STMT 201
+CREATE TABLE "app"."people" (
+    "id" integer GENERATED ALWAYS AS IDENTITY NOT NULL,
+    "name" text NOT NULL,
+    "mood" "app"."mood",
+    "upper_name" text GENERATED ALWAYS AS (upper(name)) STORED
+)
+COPY 53
+COPY "app"."people" ("id", "name", "mood") FROM STDIN
+1	Ada	ok
+2	tab\there	\N
+\.
+STMT 72
+ALTER TABLE "app"."people" ADD CONSTRAINT "people_pkey" PRIMARY KEY (id)
+STMT 88
+SELECT pg_catalog.setval(pg_catalog.pg_get_serial_sequence('app.people', 'id'), 2, true)
+STMT 60
+CREATE VIEW "app"."names" AS
+ SELECT name
+   FROM app.people
+END
+`
@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// toolVersionPattern extracts the major version from "pg_dump (PostgreSQL) 16.2"
+var toolVersionPattern = regexp.MustCompile(`\(PostgreSQL\) (\d+)`)
+
+// PreflightResult describes the server a backup would be taken from
+type PreflightResult struct {
+	InRecovery     bool          // The server is a standby
+	ReplicationLag time.Duration // How far a standby's replay is behind, 0 on a primary
+	ServerVersion  int           // Server major version
+	ToolVersion    int           // Major version of pg_dump or pg_basebackup, 0 in native mode
+}
+
+// Preflight checks that a backup in the configured mode can be taken: a
+// standby must be within the replication lag limit, the client tool must be
+// at least as new as the server, and the role must be able to read
+// everything the backup includes. All failed checks are reported together.
+func (p *Provider) Preflight(ctx context.Context) (*PreflightResult, error) {
+	result := &PreflightResult{}
+
+	var versionNum int
+	var lagSeconds float64
+	err := p.conn.QueryRow(ctx, `SELECT current_setting('server_version_num')::int,
+			pg_is_in_recovery(),
+			CASE WHEN NOT pg_is_in_recovery()
+					OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+				ELSE coalesce(extract(epoch FROM now() - pg_last_xact_replay_timestamp()), 0)::float8
+			END`).Scan(&versionNum, &result.InRecovery, &lagSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check server status: %w", err)
+	}
+	result.ServerVersion = versionNum / 10000
+	result.ReplicationLag = time.Duration(lagSeconds * float64(time.Second))
+
+	var failures []string
+
+	if limit := p.config.MaxReplicationLag; limit > 0 && result.ReplicationLag > limit {
+		failures = append(failures, fmt.Sprintf("standby replication lag %s exceeds %s",
+			result.ReplicationLag.Round(time.Second), limit))
+	}
+
+	if p.config.SerializableDeferrable && result.InRecovery && p.backupMode() != ModePhysical {
+		failures = append(failures, "serializable deferrable dumps cannot run on a standby")
+	}
+
+	if p.config.MaskingProfile != "" && p.backupMode() != ModeNative {
+		failures = append(failures, "masking profiles require native backup mode")
+	}
+
+	switch p.backupMode() {
+	case ModeLogical:
+		failures = append(failures, p.checkTool(ctx, "pg_dump", result)...)
+		failures = append(failures, p.checkReadPrivileges(ctx)...)
+	case ModePhysical:
+		failures = append(failures, p.checkTool(ctx, "pg_basebackup", result)...)
+		failures = append(failures, p.checkReplicationPrivilege(ctx)...)
+	case ModeNative:
+		if versionNum < nativeMinServerVersion {
+			failures = append(failures, fmt.Sprintf("native backups require PostgreSQL 13 or later, server is %d", result.ServerVersion))
+		}
+		failures = append(failures, p.checkReadPrivileges(ctx)...)
+	}
+
+	if len(failures) > 0 {
+		return result, fmt.Errorf("preflight checks failed: %s", strings.Join(failures, "; "))
+	}
+
+	return result, nil
+}
+
+// checkTool records the tool's version and checks it can handle the server
+func (p *Provider) checkTool(ctx context.Context, tool string, result *PreflightResult) []string {
+	version, err := ToolMajorVersion(ctx, tool)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	result.ToolVersion = version
+
+	if version < result.ServerVersion {
+		return []string{fmt.Sprintf("%s %d is older than server version %d; install %s %d or newer",
+			tool, version, result.ServerVersion, tool, result.ServerVersion)}
+	}
+	return nil
+}
+
+// checkReadPrivileges checks the role can read every schema, table and
+// sequence a logical dump includes
+func (p *Provider) checkReadPrivileges(ctx context.Context) []string {
+	var count int
+	var denied []string
+	err := p.conn.QueryRow(ctx, `SELECT count(*), coalesce((array_agg(name ORDER BY name))[1:5], '{}')
+		FROM (
+			SELECT 'schema ' || quote_ident(n.nspname) AS name
+			FROM pg_namespace n
+			WHERE `+userNamespace+` AND NOT has_schema_privilege(n.oid, 'USAGE')
+			UNION ALL
+			SELECT quote_ident(n.nspname) || '.' || quote_ident(c.relname)
+			FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE c.relkind IN ('r', 'p', 'S', 'm') AND `+userNamespace+`
+				AND NOT has_table_privilege(c.oid, 'SELECT')
+		) denied`).Scan(&count, &denied)
+	if err != nil {
+		return []string{fmt.Sprintf("failed to check privileges: %v", err)}
+	}
+
+	if count == 0 {
+		return nil
+	}
+	list := strings.Join(denied, ", ")
+	if count > len(denied) {
+		list += fmt.Sprintf(" and %d more", count-len(denied))
+	}
+	return []string{"role cannot read " + list}
+}
+
+// checkReplicationPrivilege checks the role may stream a base backup
+func (p *Provider) checkReplicationPrivilege(ctx context.Context) []string {
+	var allowed bool
+	err := p.conn.QueryRow(ctx,
+		"SELECT rolsuper OR rolreplication FROM pg_roles WHERE rolname = current_user").Scan(&allowed)
+	if err != nil {
+		return []string{fmt.Sprintf("failed to check privileges: %v", err)}
+	}
+
+	if !allowed {
+		return []string{"role needs the REPLICATION attribute for physical backups"}
+	}
+	return nil
+}
+
+// ToolMajorVersion returns the major version of a PostgreSQL client tool on
+// the PATH, such as pg_dump
+func ToolMajorVersion(ctx context.Context, tool string) (int, error) {
+	output, err := exec.CommandContext(ctx, tool, "--version").Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to run %s --version: %w", tool, err)
+	}
+
+	match := toolVersionPattern.FindSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("unrecognized %s version: %s", tool, strings.TrimSpace(string(output)))
+	}
+
+	return strconv.Atoi(string(match[1]))
+}
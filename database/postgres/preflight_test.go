@@ -0,0 +1,133 @@
+package postgres_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"goarchive/database/postgres"
+)
+
+// fakeTool installs an executable script named tool that prints output
+func fakeTool(t *testing.T, tool, output string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools use shell scripts")
+	}
+
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho '" + output + "'\n"
+	if err := os.WriteFile(filepath.Join(dir, tool), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestToolMajorVersion(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("release version", func(t *testing.T) {
+		fakeTool(t, "pg_dump", "pg_dump (PostgreSQL) 16.2 (Debian 16.2-1.pgdg120+2)")
+
+		version, err := postgres.ToolMajorVersion(ctx, "pg_dump")
+		if err != nil {
+			t.Fatalf("ToolMajorVersion() error = %v", err)
+		}
+		if version != 16 {
+			t.Errorf("expected version 16, got %d", version)
+		}
+	})
+
+	t.Run("development version", func(t *testing.T) {
+		fakeTool(t, "pg_basebackup", "pg_basebackup (PostgreSQL) 18beta1")
+
+		version, err := postgres.ToolMajorVersion(ctx, "pg_basebackup")
+		if err != nil {
+			t.Fatalf("ToolMajorVersion() error = %v", err)
+		}
+		if version != 18 {
+			t.Errorf("expected version 18, got %d", version)
+		}
+	})
+
+	t.Run("unrecognized output", func(t *testing.T) {
+		fakeTool(t, "pg_dump", "something else")
+
+		if _, err := postgres.ToolMajorVersion(ctx, "pg_dump"); err == nil {
+			t.Error("expected error for unrecognized version output, got nil")
+		}
+	})
+
+	t.Run("missing tool", func(t *testing.T) {
+		if _, err := postgres.ToolMajorVersion(ctx, "goarchive-missing-tool"); err == nil {
+			t.Error("expected error for missing tool, got nil")
+		}
+	})
+}
+
+func TestIntegration_Preflight(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	config := getTestConfig()
+	config.BackupMode = postgres.ModeNative
+
+	provider, err := postgres.New(config)
+	if err != nil {
+		t.Skipf("Skipping integration test - PostgreSQL not available: %v", err)
+	}
+	defer provider.Close()
+
+	ctx := context.Background()
+
+	result, err := provider.Preflight(ctx)
+	if err != nil {
+		t.Fatalf("Preflight() error = %v", err)
+	}
+	if result.ServerVersion == 0 {
+		t.Error("expected server version to be detected")
+	}
+	if result.InRecovery && result.ReplicationLag < 0 {
+		t.Errorf("unexpected replication lag %s", result.ReplicationLag)
+	}
+
+	t.Run("outdated pg_dump", func(t *testing.T) {
+		fakeTool(t, "pg_dump", "pg_dump (PostgreSQL) 9.6.24")
+
+		logical := *config
+		logical.BackupMode = postgres.ModeLogical
+		provider, err := postgres.New(&logical)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer provider.Close()
+
+		_, err = provider.Backup(ctx)
+		if err == nil || !strings.Contains(err.Error(), "older than server version") {
+			t.Errorf("expected version check to fail before dumping, got %v", err)
+		}
+	})
+
+	t.Run("lock wait timeout", func(t *testing.T) {
+		native := *config
+		native.LockWaitTimeout = 5 * time.Second
+		provider, err := postgres.New(&native)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer provider.Close()
+
+		reader, err := provider.Backup(ctx)
+		if err != nil {
+			t.Fatalf("Backup() error = %v", err)
+		}
+		if err := reader.Close(); err != nil {
+			t.Errorf("Backup() close error = %v", err)
+		}
+	})
+}
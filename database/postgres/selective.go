@@ -0,0 +1,367 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"goarchive/core"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// tocDescriptions lists the multi-word object types in a pg_restore table of
+// contents; any other type is a single word
+var tocDescriptions = []string{
+	"MATERIALIZED VIEW DATA",
+	"PUBLICATION TABLES IN SCHEMA",
+	"TEXT SEARCH CONFIGURATION",
+	"TEXT SEARCH DICTIONARY",
+	"TEXT SEARCH TEMPLATE",
+	"TEXT SEARCH PARSER",
+	"FOREIGN DATA WRAPPER",
+	"DATABASE PROPERTIES",
+	"PROCEDURAL LANGUAGE",
+	"SUBSCRIPTION TABLE",
+	"PUBLICATION TABLE",
+	"SEQUENCE OWNED BY",
+	"MATERIALIZED VIEW",
+	"CHECK CONSTRAINT",
+	"OPERATOR CLASS",
+	"OPERATOR FAMILY",
+	"STATISTICS DATA",
+	"FOREIGN SERVER",
+	"FOREIGN TABLE",
+	"ACCESS METHOD",
+	"EVENT TRIGGER",
+	"FK CONSTRAINT",
+	"BLOB METADATA",
+	"INDEX ATTACH",
+	"LARGE OBJECT",
+	"ROW SECURITY",
+	"SEQUENCE SET",
+	"TABLE ATTACH",
+	"USER MAPPING",
+	"DEFAULT ACL",
+	"SHELL TYPE",
+	"TABLE DATA",
+}
+
+// tocEntry is a line of a pg_restore table of contents
+type tocEntry struct {
+	desc   string
+	schema string
+	tag    string
+}
+
+// parseTOCEntry parses "216; 1259 16390 TABLE public users owner"
+func parseTOCEntry(line string) (tocEntry, bool) {
+	if strings.HasPrefix(line, ";") {
+		return tocEntry{}, false
+	}
+	_, rest, ok := strings.Cut(line, "; ")
+	if !ok {
+		return tocEntry{}, false
+	}
+
+	fields := strings.SplitN(rest, " ", 3)
+	if len(fields) < 3 {
+		return tocEntry{}, false
+	}
+	rest = fields[2]
+
+	entry := tocEntry{}
+	for _, desc := range tocDescriptions {
+		if strings.HasPrefix(rest, desc+" ") {
+			entry.desc = desc
+			break
+		}
+	}
+	if entry.desc == "" {
+		entry.desc, _, _ = strings.Cut(rest, " ")
+	}
+	rest = strings.TrimPrefix(rest, entry.desc+" ")
+
+	// The tag may contain spaces; the owner is the last word
+	entry.schema, rest, _ = strings.Cut(rest, " ")
+	if i := strings.LastIndex(rest, " "); i >= 0 {
+		rest = rest[:i]
+	}
+	entry.tag = rest
+
+	return entry, true
+}
+
+// FilterRestoreList reduces a pg_restore -l table of contents to the selected
+// tables and schemas, for use with pg_restore -L. Tables are given as
+// "schema.table", or "table" to match any schema, and bring their definition,
+// data and constraints; other objects such as indexes, column defaults,
+// triggers and foreign keys are left out. Schemas bring every object in them.
+// With createSchemas, the schemas, types and domains the selected tables live
+// in are included so the tables can be created in an empty database.
+func FilterRestoreList(list string, tables, schemas []string, createSchemas bool) (string, error) {
+	entries := strings.Split(list, "\n")
+	parsed := make([]tocEntry, len(entries))
+	matched := make(map[string]bool)
+	tableSchemas := make(map[string]bool)
+
+	for i, line := range entries {
+		entry, ok := parseTOCEntry(line)
+		if !ok {
+			continue
+		}
+		parsed[i] = entry
+
+		if entry.desc == "TABLE" {
+			for _, table := range tables {
+				if matchTable(table, entry.schema, entry.tag) {
+					matched[table] = true
+					tableSchemas[entry.schema] = true
+				}
+			}
+		}
+		for _, schema := range schemas {
+			if entry.schema == schema || (entry.desc == "SCHEMA" && entry.tag == schema) {
+				matched[schema] = true
+			}
+		}
+	}
+
+	var missing []string
+	for _, name := range append(append([]string{}, tables...), schemas...) {
+		if !matched[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return "", fmt.Errorf("not found in backup: %s", strings.Join(missing, ", "))
+	}
+
+	var selected []string
+	for i, line := range entries {
+		if keepTOCEntry(parsed[i], tables, schemas, tableSchemas, createSchemas) {
+			selected = append(selected, line)
+		}
+	}
+
+	return strings.Join(selected, "\n") + "\n", nil
+}
+
+// keepTOCEntry reports whether a table of contents entry is selected
+func keepTOCEntry(entry tocEntry, tables, schemas []string, tableSchemas map[string]bool, createSchemas bool) bool {
+	if entry.desc == "" {
+		return false
+	}
+
+	for _, schema := range schemas {
+		if entry.schema == schema || (entry.desc == "SCHEMA" && entry.tag == schema) {
+			return true
+		}
+	}
+
+	for _, table := range tables {
+		switch entry.desc {
+		case "TABLE", "TABLE DATA":
+			if matchTable(table, entry.schema, entry.tag) {
+				return true
+			}
+		case "CONSTRAINT", "CHECK CONSTRAINT":
+			// Constraint tags are "<table> <constraint>"
+			name, _, _ := strings.Cut(entry.tag, " ")
+			if matchTable(table, entry.schema, name) {
+				return true
+			}
+		}
+	}
+
+	if createSchemas {
+		switch entry.desc {
+		case "SCHEMA":
+			return tableSchemas[entry.tag]
+		case "TYPE", "DOMAIN":
+			return tableSchemas[entry.schema]
+		}
+	}
+
+	return false
+}
+
+// matchTable reports whether a "schema.table" or "table" selection matches
+func matchTable(selection, schema, name string) bool {
+	if selectedSchema, selectedName, ok := strings.Cut(selection, "."); ok {
+		return selectedSchema == schema && selectedName == name
+	}
+	return selection == name
+}
+
+// writeRestoreList writes the filtered table of contents of the archive at
+// archivePath to a temporary file for pg_restore -L. Selected tables are
+// always restored into an empty database, so their schemas are included.
+func (p *Provider) writeRestoreList(ctx context.Context, archivePath string, opts *core.RestoreOptions) (string, func(), error) {
+	cmd := exec.CommandContext(ctx, "pg_restore", "-l", archivePath)
+	cmd.Env = commandEnv(p.password)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list backup contents: %w", err)
+	}
+
+	list, err := FilterRestoreList(string(output), opts.Tables, opts.Schemas, true)
+	if err != nil {
+		return "", nil, err
+	}
+
+	file, err := os.CreateTemp("", "goarchive-restore-*.list")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create restore list: %w", err)
+	}
+	cleanup := func() { os.Remove(file.Name()) }
+
+	if _, err := file.WriteString(list); err != nil {
+		file.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write restore list: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write restore list: %w", err)
+	}
+
+	return file.Name(), cleanup, nil
+}
+
+// restoreIntoSchema restores the selected objects into a scratch database,
+// moves them into opts.TargetSchema and copies that schema into target, so
+// the data can be compared with or copied back into the live tables
+func (p *Provider) restoreIntoSchema(ctx context.Context, archivePath, listPath, target string, opts *core.RestoreOptions) error {
+	conn, closeConn, err := p.connectTo(ctx, target)
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	var exists bool
+	err = conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)", opts.TargetSchema).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check schema %q: %w", opts.TargetSchema, err)
+	}
+	if exists {
+		return fmt.Errorf("schema %q already exists in database %q", opts.TargetSchema, target)
+	}
+
+	scratch := fmt.Sprintf("goarchive_scratch_%d", time.Now().UnixNano())
+	scratchIdentifier := pgx.Identifier{scratch}.Sanitize()
+	if _, err := p.conn.Exec(ctx, "CREATE DATABASE "+scratchIdentifier); err != nil {
+		return fmt.Errorf("failed to create scratch database: %w", err)
+	}
+	defer p.conn.Exec(context.Background(), "DROP DATABASE IF EXISTS "+scratchIdentifier)
+
+	scratchConnString := p.settings.withDatabase(scratch).String()
+	err = p.runRestore(ctx, []string{
+		"-d", scratchConnString,
+		"--no-owner",
+		"--no-privileges",
+		"--no-password",
+		"-L", listPath,
+		archivePath,
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := p.moveToSchema(ctx, scratch, opts.TargetSchema); err != nil {
+		return err
+	}
+
+	// Copy the schema from the scratch database into the target
+	dump := exec.CommandContext(ctx, "pg_dump",
+		"-d", scratchConnString,
+		"-n", pgx.Identifier{opts.TargetSchema}.Sanitize(),
+		"-F", "c",
+		"--no-password",
+	)
+	dump.Env = commandEnv(p.password)
+	stdout, err := dump.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create pipe: %w", err)
+	}
+	if err := dump.Start(); err != nil {
+		return fmt.Errorf("failed to start pg_dump: %w", err)
+	}
+
+	args := []string{
+		"-d", p.settings.withDatabase(target).String(),
+		"--no-owner",
+		"--no-privileges",
+		"--no-password",
+	}
+	if opts.SingleTransaction {
+		args = append(args, "--single-transaction")
+	}
+	restoreErr := p.runRestore(ctx, args, stdout)
+	if err := dump.Wait(); err != nil && restoreErr == nil {
+		return fmt.Errorf("failed to dump scratch database: %w", err)
+	}
+
+	return restoreErr
+}
+
+// moveToSchema moves every relation restored into database into a new schema
+func (p *Provider) moveToSchema(ctx context.Context, database, schema string) error {
+	conn, closeConn, err := p.connectTo(ctx, database)
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	if _, err := conn.Exec(ctx, "CREATE SCHEMA "+pgx.Identifier{schema}.Sanitize()); err != nil {
+		return fmt.Errorf("failed to create schema %q: %w", schema, err)
+	}
+
+	// Owned sequences, indexes and constraints move with their tables
+	statements, err := queryStrings(ctx, conn, `SELECT 'ALTER '
+			|| CASE c.relkind WHEN 'v' THEN 'VIEW' WHEN 'm' THEN 'MATERIALIZED VIEW'
+				WHEN 'S' THEN 'SEQUENCE' WHEN 'f' THEN 'FOREIGN TABLE' ELSE 'TABLE' END
+			|| ' ' || quote_ident(n.nspname) || '.' || quote_ident(c.relname)
+			|| ' SET SCHEMA ' || quote_ident($1)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE `+userNamespace+` AND n.nspname <> $1
+			AND (c.relkind IN ('r', 'p', 'v', 'm', 'f')
+				OR (c.relkind = 'S' AND NOT EXISTS (SELECT 1 FROM pg_depend d
+					WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype IN ('a', 'i'))))
+		ORDER BY c.oid`, schema)
+	if err != nil {
+		return fmt.Errorf("failed to list restored objects: %w", err)
+	}
+
+	for _, stmt := range statements {
+		if _, err := conn.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to move restored objects into schema %q: %w", schema, err)
+		}
+	}
+
+	return nil
+}
+
+// connectTo returns a connection to database, reusing the provider's
+// connection when possible. The returned function closes it.
+func (p *Provider) connectTo(ctx context.Context, database string) (*pgx.Conn, func(), error) {
+	if database == p.database {
+		return p.conn, func() {}, nil
+	}
+
+	config := p.conn.Config().Copy()
+	config.Database = database
+
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to database %q: %w", database, err)
+	}
+
+	return conn, func() { conn.Close(context.Background()) }, nil
+}
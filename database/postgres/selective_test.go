@@ -0,0 +1,146 @@
+package postgres_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"goarchive/core"
+	"goarchive/database/postgres"
+)
+
+// restoreList is sample pg_restore -l output
+const restoreList = `;
+; Archive created at 2026-10-18 12:00:00 UTC
+;     dbname: app
+;
+; Selected TOC Entries:
+;
+6; 2615 16385 SCHEMA - billing postgres
+850; 1247 16387 TYPE public mood postgres
+851; 1247 16390 DOMAIN billing amount postgres
+216; 1259 16400 TABLE public users postgres
+217; 1259 16410 TABLE public user_events postgres
+218; 1259 16420 TABLE billing invoices postgres
+219; 1259 16430 SEQUENCE public users_id_seq postgres
+220; 0 0 SEQUENCE OWNED BY public users_id_seq postgres
+3300; 2604 16440 DEFAULT public users id postgres
+3450; 0 16400 TABLE DATA public users postgres
+3451; 0 16410 TABLE DATA public user_events postgres
+3452; 0 16420 TABLE DATA billing invoices postgres
+3460; 0 0 SEQUENCE SET public users_id_seq postgres
+3300; 2606 16450 CONSTRAINT public users users_pkey postgres
+3301; 2606 16451 CHECK CONSTRAINT public users users_age_check postgres
+3302; 2606 16452 CONSTRAINT public user_events user_events_pkey postgres
+3310; 1259 16460 INDEX public users_email_idx postgres
+3320; 2606 16470 FK CONSTRAINT public user_events user_events_user_id_fkey postgres
+3321; 2606 16471 FK CONSTRAINT billing invoices invoices_user_id_fkey postgres
+`
+
+func TestFilterRestoreList(t *testing.T) {
+	tests := []struct {
+		name          string
+		tables        []string
+		schemas       []string
+		createSchemas bool
+		want          []string
+		wantErr       string
+	}{
+		{
+			name:   "unqualified table",
+			tables: []string{"users"},
+			want: []string{
+				"216; 1259 16400 TABLE public users postgres",
+				"3450; 0 16400 TABLE DATA public users postgres",
+				"3300; 2606 16450 CONSTRAINT public users users_pkey postgres",
+				"3301; 2606 16451 CHECK CONSTRAINT public users users_age_check postgres",
+			},
+		},
+		{
+			name:          "qualified table in a new database",
+			tables:        []string{"billing.invoices"},
+			createSchemas: true,
+			want: []string{
+				"6; 2615 16385 SCHEMA - billing postgres",
+				"851; 1247 16390 DOMAIN billing amount postgres",
+				"218; 1259 16420 TABLE billing invoices postgres",
+				"3452; 0 16420 TABLE DATA billing invoices postgres",
+			},
+		},
+		{
+			name:    "schema",
+			schemas: []string{"billing"},
+			want: []string{
+				"6; 2615 16385 SCHEMA - billing postgres",
+				"851; 1247 16390 DOMAIN billing amount postgres",
+				"218; 1259 16420 TABLE billing invoices postgres",
+				"3452; 0 16420 TABLE DATA billing invoices postgres",
+				"3321; 2606 16471 FK CONSTRAINT billing invoices invoices_user_id_fkey postgres",
+			},
+		},
+		{
+			name:    "missing objects",
+			tables:  []string{"public.users", "billing.users"},
+			schemas: []string{"audit"},
+			wantErr: "not found in backup: audit, billing.users",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := postgres.FilterRestoreList(restoreList, tt.tables, tt.schemas, tt.createSchemas)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("FilterRestoreList() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FilterRestoreList() error = %v", err)
+			}
+
+			want := strings.Join(tt.want, "\n") + "\n"
+			if got != want {
+				t.Errorf("FilterRestoreList() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestRestoreWithOptions_SelectiveInPlace(t *testing.T) {
+	// The check happens before the provider touches the server
+	provider := &postgres.Provider{}
+
+	tests := []struct {
+		name string
+		opts *core.RestoreOptions
+	}{
+		{name: "table", opts: &core.RestoreOptions{Tables: []string{"public.orders"}}},
+		{name: "schema", opts: &core.RestoreOptions{Schemas: []string{"sales"}}},
+		{name: "other database", opts: &core.RestoreOptions{Tables: []string{"orders"}, TargetDatabase: "staging"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := provider.RestoreWithOptions(context.Background(), strings.NewReader(""), tt.opts)
+			if err == nil || !strings.Contains(err.Error(), "require a target schema or a newly created target database") {
+				t.Errorf("expected an in-place selective restore to be refused, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRestoreWithOptions_SelectiveUnsupportedModes(t *testing.T) {
+	// Mode checks happen before the provider touches the server
+	provider := &postgres.Provider{}
+
+	for _, mode := range []string{postgres.ModePhysical, postgres.ModeNative} {
+		err := provider.RestoreWithOptions(context.Background(), strings.NewReader(""), &core.RestoreOptions{
+			BackupMode: mode,
+			Tables:     []string{"users"},
+		})
+		if err == nil || !strings.Contains(err.Error(), "not supported") {
+			t.Errorf("expected %s selective restore to be rejected, got %v", mode, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"goarchive/core"
+)
+
+// walChecksumPrefix marks the checksum stored in the gzip header comment
+const walChecksumPrefix = "sha256:"
+
+var (
+	// walSegmentPattern matches WAL segments, partial segments and backup history files
+	walSegmentPattern = regexp.MustCompile(`^([0-9A-F]{8})[0-9A-F]{16}(\.partial|\.[0-9A-F]{8}\.backup)?$`)
+
+	// walHistoryPattern matches timeline history files
+	walHistoryPattern = regexp.MustCompile(`^([0-9A-F]{8})\.history$`)
+)
+
+// WALArchive stores WAL files in a storage provider for point-in-time recovery.
+// Files are gzip-compressed, carry a SHA-256 checksum of their contents and are
+// laid out by timeline as wal/<timeline>/<name>.gz.
+type WALArchive struct {
+	storage core.StorageProvider
+}
+
+// NewWALArchive creates a WAL archive on top of a storage provider
+func NewWALArchive(storage core.StorageProvider) *WALArchive {
+	return &WALArchive{storage: storage}
+}
+
+// WALKey returns the storage key for the WAL file name
+func WALKey(name string) (string, error) {
+	match := walSegmentPattern.FindStringSubmatch(name)
+	if match == nil {
+		match = walHistoryPattern.FindStringSubmatch(name)
+	}
+	if match == nil {
+		return "", fmt.Errorf("invalid WAL file name: %s", name)
+	}
+
+	return path.Join("wal", match[1], name+".gz"), nil
+}
+
+// Push archives the WAL file at walPath. It is meant to be called from
+// archive_command: pushing a file that is already archived with the same
+// contents succeeds, while pushing different contents under the same name fails.
+func (a *WALArchive) Push(ctx context.Context, walPath string) error {
+	name := filepath.Base(walPath)
+	key, err := WALKey(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(walPath)
+	if err != nil {
+		return fmt.Errorf("failed to read WAL file: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	// Never overwrite an archived file with different contents. Upload only
+	// when the file is known not to be archived; otherwise fail, so that
+	// PostgreSQL retries archive_command.
+	existing, err := a.storedChecksum(ctx, key)
+	switch {
+	case err == nil && existing == checksum:
+		return nil
+	case err == nil:
+		return fmt.Errorf("WAL file %s is already archived with different contents", name)
+	case !errors.Is(err, core.ErrBackupNotFound):
+		return fmt.Errorf("failed to check archived WAL file %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Name = name
+	gz.Comment = walChecksumPrefix + checksum
+	gz.ModTime = time.Now()
+	if _, err := gz.Write(data); err != nil {
+		return fmt.Errorf("failed to compress WAL file: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress WAL file: %w", err)
+	}
+
+	metadata := &core.BackupMetadata{
+		ID:           name,
+		Key:          key,
+		DatabaseType: "postgres-wal",
+		Timestamp:    time.Now(),
+		Tags:         map[string]string{"sha256": checksum},
+	}
+
+	if err := a.storage.Upload(ctx, &buf, metadata); err != nil {
+		return fmt.Errorf("failed to upload WAL file %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// Fetch retrieves the archived WAL file name into dest, verifying its checksum.
+// It is meant to be called from restore_command and returns an error when the
+// file is not archived.
+func (a *WALArchive) Fetch(ctx context.Context, name, dest string) error {
+	key, err := WALKey(name)
+	if err != nil {
+		return err
+	}
+
+	reader, err := a.storage.Download(ctx, key)
+	if err != nil {
+		return fmt.Errorf("WAL file %s not available: %w", name, err)
+	}
+	defer reader.Close()
+
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return fmt.Errorf("failed to decompress WAL file %s: %w", name, err)
+	}
+	defer gz.Close()
+
+	// Write next to dest and rename, so the server never sees a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), gz); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to decompress WAL file %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write WAL file %s: %w", name, err)
+	}
+
+	expected := strings.TrimPrefix(gz.Comment, walChecksumPrefix)
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		return fmt.Errorf("checksum mismatch for WAL file %s: expected %s, got %s", name, expected, actual)
+	}
+
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("failed to write WAL file %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// storedChecksum returns the checksum recorded for an archived WAL file
+func (a *WALArchive) storedChecksum(ctx context.Context, key string) (string, error) {
+	reader, err := a.storage.Download(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read archived WAL file: %w", err)
+	}
+	defer gz.Close()
+
+	return strings.TrimPrefix(gz.Comment, walChecksumPrefix), nil
+}
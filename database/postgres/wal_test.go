@@ -0,0 +1,257 @@
+package postgres_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"goarchive/core"
+	"goarchive/database/postgres"
+)
+
+// memoryStorage is a minimal key-addressed storage provider for WAL tests.
+// Downloads fail with downloadErr when it is set.
+type memoryStorage struct {
+	objects     map[string][]byte
+	downloadErr error
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{objects: make(map[string][]byte)}
+}
+
+func (m *memoryStorage) Upload(ctx context.Context, reader io.Reader, metadata *core.BackupMetadata) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	m.objects[metadata.Key] = data
+	return nil
+}
+
+func (m *memoryStorage) List(ctx context.Context) ([]*core.BackupMetadata, error) {
+	return nil, nil
+}
+
+func (m *memoryStorage) Download(ctx context.Context, backupID string) (io.ReadCloser, error) {
+	if m.downloadErr != nil {
+		return nil, m.downloadErr
+	}
+	data, ok := m.objects[backupID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memoryStorage) Delete(ctx context.Context, backupID string) error {
+	delete(m.objects, backupID)
+	return nil
+}
+
+func TestWALKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "000000010000000000000001", want: "wal/00000001/000000010000000000000001.gz"},
+		{name: "000000020000000A000000FF.partial", want: "wal/00000002/000000020000000A000000FF.partial.gz"},
+		{name: "000000010000000000000002.00000028.backup", want: "wal/00000001/000000010000000000000002.00000028.backup.gz"},
+		{name: "00000002.history", want: "wal/00000002/00000002.history.gz"},
+		{name: "not-a-wal-file", wantErr: true},
+		{name: "../000000010000000000000001", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := postgres.WALKey(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WALKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("WALKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWALArchive_PushFetch(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storage := newMemoryStorage()
+	archive := postgres.NewWALArchive(storage)
+
+	name := "000000010000000000000003"
+	walPath := filepath.Join(dir, "pg_wal", name)
+	if err := os.MkdirAll(filepath.Dir(walPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	segment := bytes.Repeat([]byte("wal record "), 1024)
+	if err := os.WriteFile(walPath, segment, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("push compresses under timeline layout", func(t *testing.T) {
+		if err := archive.Push(ctx, walPath); err != nil {
+			t.Fatalf("Push() error = %v", err)
+		}
+
+		stored, ok := storage.objects["wal/00000001/"+name+".gz"]
+		if !ok {
+			t.Fatal("expected WAL file to be stored under its timeline")
+		}
+		if len(stored) >= len(segment) {
+			t.Errorf("expected compressed size below %d, got %d", len(segment), len(stored))
+		}
+	})
+
+	t.Run("push of identical file succeeds", func(t *testing.T) {
+		if err := archive.Push(ctx, walPath); err != nil {
+			t.Errorf("Push() error = %v, want nil", err)
+		}
+	})
+
+	t.Run("push of different contents fails", func(t *testing.T) {
+		otherPath := filepath.Join(dir, name)
+		if err := os.WriteFile(otherPath, []byte("different"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := archive.Push(ctx, otherPath); err == nil {
+			t.Error("expected error overwriting archived WAL file, got nil")
+		}
+	})
+
+	t.Run("push fails when the archive cannot be checked", func(t *testing.T) {
+		key := "wal/00000001/" + name + ".gz"
+		original := storage.objects[key]
+		storage.downloadErr = errors.New("connection reset by peer")
+		defer func() { storage.downloadErr = nil }()
+
+		otherPath := filepath.Join(dir, name)
+		if err := os.WriteFile(otherPath, []byte("different"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := archive.Push(ctx, otherPath); err == nil || !strings.Contains(err.Error(), "connection reset") {
+			t.Errorf("expected the download error, got %v", err)
+		}
+		if !bytes.Equal(storage.objects[key], original) {
+			t.Error("expected the archived WAL file to be left as it was")
+		}
+	})
+
+	t.Run("fetch restores contents", func(t *testing.T) {
+		dest := filepath.Join(dir, "RECOVERYXLOG")
+		if err := archive.Fetch(ctx, name, dest); err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+
+		data, err := os.ReadFile(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, segment) {
+			t.Error("fetched WAL file does not match pushed file")
+		}
+	})
+
+	t.Run("fetch of missing file fails", func(t *testing.T) {
+		if err := archive.Fetch(ctx, "00000005.history", filepath.Join(dir, "history")); err == nil {
+			t.Error("expected error fetching missing WAL file, got nil")
+		}
+	})
+
+	t.Run("fetch detects corruption", func(t *testing.T) {
+		key := "wal/00000001/" + name + ".gz"
+		original := storage.objects[key]
+		defer func() { storage.objects[key] = original }()
+
+		// Store different contents under the original file's checksum
+		sum := sha256.Sum256(segment)
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Comment = "sha256:" + hex.EncodeToString(sum[:])
+		if _, err := gz.Write([]byte("tampered")); err != nil {
+			t.Fatal(err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+		storage.objects[key] = buf.Bytes()
+
+		dest := filepath.Join(dir, "corrupted")
+		if err := archive.Fetch(ctx, name, dest); err == nil {
+			t.Error("expected checksum error for corrupted WAL file, got nil")
+		}
+		if _, err := os.Stat(dest); !os.IsNotExist(err) {
+			t.Error("expected no file to be written for corrupted WAL file")
+		}
+	})
+}
+
+func TestSelectBaseBackup(t *testing.T) {
+	base := time.Date(2026, 2, 15, 0, 0, 0, 0, time.UTC)
+	backups := []*core.BackupMetadata{
+		{ID: "day1.dump", BackupMode: postgres.ModePhysical, Timestamp: base},
+		{ID: "day2.dump", BackupMode: postgres.ModePhysical, Timestamp: base.Add(24 * time.Hour)},
+		{ID: "day2-logical.dump", BackupMode: postgres.ModeLogical, Timestamp: base.Add(30 * time.Hour)},
+		{ID: "day3.dump", BackupMode: postgres.ModePhysical, Timestamp: base.Add(48 * time.Hour)},
+	}
+
+	selected, err := postgres.SelectBaseBackup(backups, base.Add(36*time.Hour))
+	if err != nil {
+		t.Fatalf("SelectBaseBackup() error = %v", err)
+	}
+	if selected.ID != "day2.dump" {
+		t.Errorf("expected day2.dump, got %s", selected.ID)
+	}
+
+	if _, err := postgres.SelectBaseBackup(backups, base.Add(-time.Hour)); err == nil {
+		t.Error("expected error when no base backup precedes the target, got nil")
+	}
+}
+
+func TestWriteRecoveryConfig(t *testing.T) {
+	dataDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dataDir, "postgresql.auto.conf"), []byte("work_mem = '8MB'\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	target := time.Date(2026, 2, 15, 10, 30, 0, 0, time.UTC)
+	restoreCommand := "goarchive wal-fetch --storage-path '/var/backups' %f %p"
+
+	if err := postgres.WriteRecoveryConfig(dataDir, restoreCommand, target); err != nil {
+		t.Fatalf("WriteRecoveryConfig() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dataDir, "postgresql.auto.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := string(data)
+
+	for _, want := range []string{
+		"work_mem = '8MB'",
+		"restore_command = 'goarchive wal-fetch --storage-path ''/var/backups'' %f %p'",
+		"recovery_target_time = '2026-02-15 10:30:00+00:00'",
+		"recovery_target_action = 'promote'",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("expected postgresql.auto.conf to contain %q, got:\n%s", want, conf)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dataDir, "recovery.signal")); err != nil {
+		t.Errorf("expected recovery.signal to be created: %v", err)
+	}
+}
@@ -0,0 +1,317 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"goarchive/core"
+)
+
+// defaultConnectTimeout bounds dialing when no timeout is configured
+const defaultConnectTimeout = 10 * time.Second
+
+// defaultPort is used when neither the configuration nor the URI sets a port
+const defaultPort = "6379"
+
+// settings holds what is needed to open a connection
+type settings struct {
+	addr     string
+	username string
+	password string
+	tls      *tls.Config
+	timeout  time.Duration
+}
+
+// connSettings builds the connection settings from config. A URI
+// ("redis://" or "rediss://" for TLS) replaces Host, Port, Username and
+// SSLMode.
+func connSettings(config *core.DatabaseConfig) (*settings, error) {
+	if config.PassFile != "" || config.Service != "" {
+		return nil, fmt.Errorf("password files and services are not supported by the redis provider")
+	}
+
+	port := defaultPort
+	if config.Port != 0 {
+		port = strconv.Itoa(config.Port)
+	}
+	s := &settings{
+		addr:     net.JoinHostPort(config.Host, port),
+		username: config.Username,
+		timeout:  defaultConnectTimeout,
+	}
+	sslMode := config.SSLMode
+	serverName := config.Host
+
+	if config.URI != "" {
+		uri, err := url.Parse(config.URI)
+		if err != nil {
+			return nil, fmt.Errorf("invalid connection URI: %w", err)
+		}
+
+		switch uri.Scheme {
+		case "redis":
+			sslMode = "disable"
+		case "rediss":
+			sslMode = "verify-full"
+		default:
+			return nil, fmt.Errorf("invalid connection URI: unsupported scheme %q", uri.Scheme)
+		}
+
+		port := uri.Port()
+		if port == "" {
+			port = defaultPort
+		}
+		s.addr = net.JoinHostPort(uri.Hostname(), port)
+		serverName = uri.Hostname()
+
+		s.username = ""
+		if uri.User != nil {
+			s.username = uri.User.Username()
+			s.password, _ = uri.User.Password()
+		}
+	}
+
+	if config.Password != "" {
+		s.password = config.Password
+	}
+	if config.ConnectTimeout > 0 {
+		s.timeout = time.Duration(config.ConnectTimeout) * time.Second
+	}
+
+	tlsConfig, err := tlsSettings(sslMode, serverName, config)
+	if err != nil {
+		return nil, err
+	}
+	s.tls = tlsConfig
+
+	return s, nil
+}
+
+// tlsSettings maps the PostgreSQL-style SSL mode and certificate files onto
+// a TLS configuration, nil for plain connections
+func tlsSettings(sslMode, serverName string, config *core.DatabaseConfig) (*tls.Config, error) {
+	switch sslMode {
+	case "", "prefer", "disable":
+		// Redis has no opportunistic TLS, so prefer connects without it
+		return nil, nil
+	case "require", "verify-ca", "verify-full":
+	default:
+		return nil, fmt.Errorf("unsupported SSL mode: %s", sslMode)
+	}
+
+	tlsConfig := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+
+	if config.SSLRootCert != "" {
+		pem, err := os.ReadFile(config.SSLRootCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", config.SSLRootCert)
+		}
+	}
+
+	if config.SSLCert != "" || config.SSLKey != "" {
+		cert, err := tls.LoadX509KeyPair(config.SSLCert, config.SSLKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	switch sslMode {
+	case "require":
+		tlsConfig.InsecureSkipVerify = true
+	case "verify-ca":
+		// Verify the chain but not the host name
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			opts := x509.VerifyOptions{Roots: tlsConfig.RootCAs, Intermediates: x509.NewCertPool()}
+			for _, cert := range state.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := state.PeerCertificates[0].Verify(opts)
+			return err
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// conn is a connection speaking the Redis serialization protocol (RESP2)
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// dial connects and authenticates. The "default" user authenticates with the
+// password alone, which also works with servers predating ACLs.
+func dial(ctx context.Context, s *settings) (*conn, error) {
+	dialer := &net.Dialer{Timeout: s.timeout}
+
+	var netConn net.Conn
+	var err error
+	if s.tls != nil {
+		netConn, err = (&tls.Dialer{NetDialer: dialer, Config: s.tls}).DialContext(ctx, "tcp", s.addr)
+	} else {
+		netConn, err = dialer.DialContext(ctx, "tcp", s.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	c := &conn{Conn: netConn, r: bufio.NewReaderSize(netConn, 64*1024)}
+
+	// Authentication must not hang on a server that does not answer
+	c.SetDeadline(time.Now().Add(s.timeout))
+	defer c.SetDeadline(time.Time{})
+
+	if s.password != "" {
+		args := []string{"AUTH", s.password}
+		if s.username != "" && s.username != "default" {
+			args = []string{"AUTH", s.username, s.password}
+		}
+		if _, err := c.do(args...); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	if _, err := c.do("PING"); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return c, nil
+}
+
+// do sends a command and reads its reply. Error replies are returned as
+// redisError.
+func (c *conn) do(args ...string) (any, error) {
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.reply()
+}
+
+// send writes a command without reading its reply, for pipelining
+func (c *conn) send(args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	if _, err := io.WriteString(c.Conn, b.String()); err != nil {
+		return fmt.Errorf("failed to send %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// reply reads one reply: a string for simple and bulk strings, an int64,
+// a []any for arrays, nil for null replies, or a redisError
+func (c *conn) reply() (any, error) {
+	line, err := c.line()
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, fmt.Errorf("invalid reply: empty line")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer reply: %q", line)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk reply: %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, fmt.Errorf("failed to read reply: %w", err)
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid array reply: %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			item, err := c.reply()
+			var redisErr redisError
+			if err != nil && !errors.As(err, &redisErr) {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("invalid reply: %q", line)
+}
+
+// line reads a line without its CRLF terminator
+func (c *conn) line() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read reply: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// info runs INFO, for the default sections when none are given, and
+// returns its fields
+func (c *conn) info(sections ...string) (map[string]string, error) {
+	reply, err := c.do(append([]string{"INFO"}, sections...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run INFO: %w", err)
+	}
+	text, ok := reply.(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected INFO reply: %v", reply)
+	}
+
+	fields := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if ok && !strings.HasPrefix(key, "#") {
+			fields[key] = value
+		}
+	}
+	return fields, nil
+}
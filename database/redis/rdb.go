@@ -0,0 +1,651 @@
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"strconv"
+)
+
+// RDB opcodes, see rdb.h in the Redis sources
+const (
+	opSlotInfo    = 0xF4
+	opFunction2   = 0xF5
+	opFunctionPre = 0xF6
+	opModuleAux   = 0xF7
+	opIdle        = 0xF8
+	opFreq        = 0xF9
+	opAux         = 0xFA
+	opResizeDB    = 0xFB
+	opExpireMS    = 0xFC
+	opExpire      = 0xFD
+	opSelectDB    = 0xFE
+	opEOF         = 0xFF
+)
+
+// RDB value types whose encoding the parser knows how to skip
+const (
+	typeString          = 0
+	typeList            = 1
+	typeSet             = 2
+	typeZSet            = 3
+	typeHash            = 4
+	typeZSet2           = 5
+	typeModule2         = 7
+	typeHashZipmap      = 9
+	typeListZiplist     = 10
+	typeSetIntset       = 11
+	typeZSetZiplist     = 12
+	typeHashZiplist     = 13
+	typeListQuicklist   = 14
+	typeStreamListpacks = 15
+	typeHashListpack    = 16
+	typeZSetListpack    = 17
+	typeListQuicklist2  = 18
+	typeStreamListpack2 = 19
+	typeSetListpack     = 20
+	typeStreamListpack3 = 21
+)
+
+// Special string encodings, flagged by the two high bits of the length
+const (
+	encInt8  = 0
+	encInt16 = 1
+	encInt32 = 2
+	encLZF   = 3
+)
+
+// Module opcodes of serialized module values
+const (
+	moduleOpEOF    = 0
+	moduleOpSInt   = 1
+	moduleOpUInt   = 2
+	moduleOpFloat  = 3
+	moduleOpDouble = 4
+	moduleOpString = 5
+)
+
+// crcTable is CRC-64/Jones, the checksum of RDB files and DUMP payloads
+var crcTable = crc64.MakeTable(0x95AC9329AC4BC9B5)
+
+// checksum continues a CRC-64/Jones checksum. The standard library inverts
+// the checksum before and after each update, which Redis does not.
+func checksum(crc uint64, data []byte) uint64 {
+	return ^crc64.Update(^crc, crcTable, data)
+}
+
+// rdbEntry is a key read from an RDB file
+type rdbEntry struct {
+	db       int
+	key      string
+	payload  []byte // DUMP payload for RESTORE
+	expireAt int64  // Expiry in Unix milliseconds, 0 for none
+	idle     int64  // LRU idle time in seconds, -1 when not recorded
+	freq     int    // LFU frequency, -1 when not recorded
+}
+
+// rdbHandler receives the contents of an RDB file
+type rdbHandler struct {
+	entry    func(entry *rdbEntry) error
+	function func(code string) error
+}
+
+// rdbReader reads an RDB file, checksumming everything it reads and
+// copying it to capture while that is set
+type rdbReader struct {
+	r       *bufio.Reader
+	crc     uint64
+	capture *bytes.Buffer
+}
+
+// parseRDB reads an RDB file and passes its keys and functions to handler.
+// Values are not decoded but copied into DUMP payloads, so only their
+// length needs to be understood. It returns the RDB version.
+func parseRDB(reader io.Reader, handler rdbHandler) (int, error) {
+	r := &rdbReader{r: bufio.NewReaderSize(reader, 64*1024)}
+
+	header, err := r.read(9)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read RDB header: %w", err)
+	}
+	if string(header[:5]) != "REDIS" {
+		return 0, fmt.Errorf("not an RDB file")
+	}
+	version, err := strconv.Atoi(string(header[5:]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid RDB version %q", header[5:])
+	}
+
+	entry := &rdbEntry{idle: -1, freq: -1}
+
+	for {
+		opcode, err := r.byte()
+		if err != nil {
+			return version, err
+		}
+
+		switch opcode {
+		case opEOF:
+			return version, r.verifyChecksum(version)
+		case opSelectDB:
+			db, err := r.length()
+			if err != nil {
+				return version, err
+			}
+			entry.db = int(db)
+		case opResizeDB:
+			err = r.skipLengths(2)
+		case opSlotInfo:
+			err = r.skipLengths(3)
+		case opAux:
+			_, err = r.string()
+			if err == nil {
+				_, err = r.string()
+			}
+		case opExpireMS:
+			var data []byte
+			data, err = r.read(8)
+			entry.expireAt = int64(binary.LittleEndian.Uint64(data))
+		case opExpire:
+			var data []byte
+			data, err = r.read(4)
+			entry.expireAt = int64(binary.LittleEndian.Uint32(data)) * 1000
+		case opIdle:
+			var idle uint64
+			idle, err = r.length()
+			entry.idle = int64(idle)
+		case opFreq:
+			var freq byte
+			freq, err = r.byte()
+			entry.freq = int(freq)
+		case opModuleAux:
+			// Module auxiliary data cannot be restored with commands
+			if err = r.skipLengths(3); err == nil {
+				err = r.skipModuleValue()
+			}
+		case opFunction2:
+			var code string
+			if code, err = r.string(); err == nil && handler.function != nil {
+				err = handler.function(code)
+			}
+		case opFunctionPre:
+			return version, fmt.Errorf("functions saved by a pre-release Redis 7 are not supported")
+		default:
+			if err = r.readEntry(opcode, version, entry); err == nil && handler.entry != nil {
+				err = handler.entry(entry)
+			}
+			entry = &rdbEntry{db: entry.db, idle: -1, freq: -1}
+		}
+
+		if err != nil {
+			return version, err
+		}
+	}
+}
+
+// readEntry reads the key and value of type valueType into entry
+func (r *rdbReader) readEntry(valueType byte, version int, entry *rdbEntry) error {
+	key, err := r.string()
+	if err != nil {
+		return err
+	}
+	entry.key = key
+
+	// The payload is the type, the serialized value, the RDB version and a
+	// checksum of all of them
+	payload := &bytes.Buffer{}
+	payload.WriteByte(valueType)
+
+	r.capture = payload
+	err = r.skipValue(valueType)
+	r.capture = nil
+	if err != nil {
+		return fmt.Errorf("failed to read key %q: %w", key, err)
+	}
+
+	binary.Write(payload, binary.LittleEndian, uint16(version))
+	binary.Write(payload, binary.LittleEndian, checksum(0, payload.Bytes()))
+	entry.payload = payload.Bytes()
+
+	return nil
+}
+
+// skipValue reads a serialized value of valueType
+func (r *rdbReader) skipValue(valueType byte) error {
+	switch valueType {
+	case typeString, typeHashZipmap, typeListZiplist, typeSetIntset, typeZSetZiplist,
+		typeHashZiplist, typeHashListpack, typeZSetListpack, typeSetListpack:
+		return r.skipStrings(1)
+	case typeList, typeSet, typeListQuicklist:
+		n, err := r.length()
+		if err != nil {
+			return err
+		}
+		return r.skipStrings(n)
+	case typeHash:
+		n, err := r.length()
+		if err != nil {
+			return err
+		}
+		return r.skipStrings(2 * n)
+	case typeZSet, typeZSet2:
+		return r.skipZSet(valueType)
+	case typeListQuicklist2:
+		n, err := r.length()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			// Container type, then the node
+			if err := r.skipLengths(1); err != nil {
+				return err
+			}
+			if err := r.skipStrings(1); err != nil {
+				return err
+			}
+		}
+		return nil
+	case typeStreamListpacks, typeStreamListpack2, typeStreamListpack3:
+		return r.skipStream(valueType)
+	case typeModule2:
+		if err := r.skipLengths(1); err != nil {
+			return err
+		}
+		return r.skipModuleValue()
+	}
+
+	return fmt.Errorf("unsupported value type %d", valueType)
+}
+
+// skipZSet reads a sorted set, whose scores are strings in the original
+// encoding and binary doubles in the second
+func (r *rdbReader) skipZSet(valueType byte) error {
+	n, err := r.length()
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < n; i++ {
+		if err := r.skipStrings(1); err != nil {
+			return err
+		}
+
+		if valueType == typeZSet2 {
+			_, err = r.read(8)
+		} else {
+			err = r.skipDoubleString()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipDoubleString reads a score saved as a length-prefixed string, where
+// the lengths 253 to 255 stand for NaN and the infinities
+func (r *rdbReader) skipDoubleString() error {
+	n, err := r.byte()
+	if err != nil || n >= 253 {
+		return err
+	}
+	_, err = r.read(int(n))
+	return err
+}
+
+// skipStream reads a stream: its listpacks, counters and consumer groups
+func (r *rdbReader) skipStream(valueType byte) error {
+	listpacks, err := r.length()
+	if err != nil {
+		return err
+	}
+	// Master entry ID and listpack of each node
+	if err := r.skipStrings(2 * listpacks); err != nil {
+		return err
+	}
+
+	// Length and last ID, then the first ID, maximal deleted ID and number of
+	// entries added
+	counters := uint64(3)
+	if valueType >= typeStreamListpack2 {
+		counters += 5
+	}
+	if err := r.skipLengths(counters); err != nil {
+		return err
+	}
+
+	groups, err := r.length()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < groups; i++ {
+		if err := r.skipConsumerGroup(valueType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipConsumerGroup reads a stream consumer group with its pending entries
+// and consumers
+func (r *rdbReader) skipConsumerGroup(valueType byte) error {
+	if err := r.skipStrings(1); err != nil {
+		return err
+	}
+
+	// Last delivered ID, then the number of entries read
+	counters := uint64(2)
+	if valueType >= typeStreamListpack2 {
+		counters++
+	}
+	if err := r.skipLengths(counters); err != nil {
+		return err
+	}
+
+	pending, err := r.length()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < pending; i++ {
+		// Entry ID and delivery time, then the delivery count
+		if _, err := r.read(16 + 8); err != nil {
+			return err
+		}
+		if err := r.skipLengths(1); err != nil {
+			return err
+		}
+	}
+
+	consumers, err := r.length()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < consumers; i++ {
+		if err := r.skipStrings(1); err != nil {
+			return err
+		}
+
+		// Seen time, then the active time
+		times := 8
+		if valueType >= typeStreamListpack3 {
+			times += 8
+		}
+		if _, err := r.read(times); err != nil {
+			return err
+		}
+
+		pending, err := r.length()
+		if err != nil {
+			return err
+		}
+		if _, err := r.read(int(16 * pending)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipModuleValue reads module opcodes up to the end marker
+func (r *rdbReader) skipModuleValue() error {
+	for {
+		opcode, err := r.length()
+		if err != nil {
+			return err
+		}
+
+		switch opcode {
+		case moduleOpEOF:
+			return nil
+		case moduleOpSInt, moduleOpUInt:
+			err = r.skipLengths(1)
+		case moduleOpFloat:
+			_, err = r.read(4)
+		case moduleOpDouble:
+			_, err = r.read(8)
+		case moduleOpString:
+			err = r.skipStrings(1)
+		default:
+			return fmt.Errorf("invalid module opcode %d", opcode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// verifyChecksum reads the trailing checksum and compares it with the
+// checksum of the file. A zero checksum means checksums were disabled.
+func (r *rdbReader) verifyChecksum(version int) error {
+	if version < 5 {
+		return nil
+	}
+
+	expected := r.crc
+	data, err := r.read(8)
+	if err != nil {
+		return fmt.Errorf("failed to read RDB checksum: %w", err)
+	}
+
+	stored := binary.LittleEndian.Uint64(data)
+	if stored != 0 && stored != expected {
+		return fmt.Errorf("RDB checksum mismatch: the backup is corrupt")
+	}
+	return nil
+}
+
+// read reads exactly n bytes
+func (r *rdbReader) read(n int) ([]byte, error) {
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("truncated RDB file: %w", err)
+	}
+
+	r.crc = checksum(r.crc, data)
+	if r.capture != nil {
+		r.capture.Write(data)
+	}
+	return data, nil
+}
+
+// byte reads a single byte
+func (r *rdbReader) byte() (byte, error) {
+	data, err := r.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+// rawLength reads a length. special reports that the value is one of the
+// special string encodings instead, which is then returned as the length.
+func (r *rdbReader) rawLength() (uint64, bool, error) {
+	first, err := r.byte()
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch first >> 6 {
+	case 0:
+		return uint64(first & 0x3F), false, nil
+	case 1:
+		next, err := r.byte()
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(first&0x3F)<<8 | uint64(next), false, nil
+	case 2:
+		switch first {
+		case 0x80:
+			data, err := r.read(4)
+			if err != nil {
+				return 0, false, err
+			}
+			return uint64(binary.BigEndian.Uint32(data)), false, nil
+		case 0x81:
+			data, err := r.read(8)
+			if err != nil {
+				return 0, false, err
+			}
+			return binary.BigEndian.Uint64(data), false, nil
+		}
+		return 0, false, fmt.Errorf("invalid length encoding 0x%02x", first)
+	}
+
+	return uint64(first & 0x3F), true, nil
+}
+
+// length reads a length that must not be a special encoding
+func (r *rdbReader) length() (uint64, error) {
+	n, special, err := r.rawLength()
+	if err == nil && special {
+		err = fmt.Errorf("unexpected string encoding where a length was expected")
+	}
+	return n, err
+}
+
+// skipLengths reads n lengths
+func (r *rdbReader) skipLengths(n uint64) error {
+	for i := uint64(0); i < n; i++ {
+		if _, err := r.length(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// string reads and decodes a string
+func (r *rdbReader) string() (string, error) {
+	n, special, err := r.rawLength()
+	if err != nil {
+		return "", err
+	}
+	if !special {
+		data, err := r.read(int(n))
+		return string(data), err
+	}
+
+	switch n {
+	case encInt8:
+		data, err := r.read(1)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(int(int8(data[0]))), nil
+	case encInt16:
+		data, err := r.read(2)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(int(int16(binary.LittleEndian.Uint16(data)))), nil
+	case encInt32:
+		data, err := r.read(4)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(int(int32(binary.LittleEndian.Uint32(data)))), nil
+	case encLZF:
+		compressedLength, err := r.length()
+		if err != nil {
+			return "", err
+		}
+		length, err := r.length()
+		if err != nil {
+			return "", err
+		}
+		compressed, err := r.read(int(compressedLength))
+		if err != nil {
+			return "", err
+		}
+		data, err := decompressLZF(compressed, int(length))
+		return string(data), err
+	}
+
+	return "", fmt.Errorf("invalid string encoding %d", n)
+}
+
+// skipStrings reads n strings without decoding them
+func (r *rdbReader) skipStrings(n uint64) error {
+	for i := uint64(0); i < n; i++ {
+		length, special, err := r.rawLength()
+		if err != nil {
+			return err
+		}
+		if special {
+			switch length {
+			case encInt8:
+				length = 1
+			case encInt16:
+				length = 2
+			case encInt32:
+				length = 4
+			case encLZF:
+				if length, err = r.length(); err != nil {
+					return err
+				}
+				if err := r.skipLengths(1); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("invalid string encoding %d", length)
+			}
+		}
+		if _, err := r.read(int(length)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decompressLZF decompresses LZF data into length bytes
+func decompressLZF(in []byte, length int) ([]byte, error) {
+	out := make([]byte, 0, length)
+
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+
+		if ctrl < 32 {
+			// Literal run of ctrl+1 bytes
+			end := i + ctrl + 1
+			if end > len(in) {
+				return nil, fmt.Errorf("invalid LZF data")
+			}
+			out = append(out, in[i:end]...)
+			i = end
+			continue
+		}
+
+		// Back reference
+		n := ctrl >> 5
+		if n == 7 {
+			if i >= len(in) {
+				return nil, fmt.Errorf("invalid LZF data")
+			}
+			n += int(in[i])
+			i++
+		}
+		if i >= len(in) {
+			return nil, fmt.Errorf("invalid LZF data")
+		}
+		ref := len(out) - ((ctrl&0x1F)<<8 | int(in[i])) - 1
+		i++
+		if ref < 0 {
+			return nil, fmt.Errorf("invalid LZF data")
+		}
+		// The reference may overlap the bytes being written
+		for j := 0; j < n+2; j++ {
+			out = append(out, out[ref+j])
+		}
+	}
+
+	if len(out) != length {
+		return nil, fmt.Errorf("invalid LZF data: expected %d bytes, got %d", length, len(out))
+	}
+	return out, nil
+}
@@ -0,0 +1,364 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"goarchive/core"
+)
+
+// restoreBatchSize is the number of commands pipelined before their replies
+// are read
+const restoreBatchSize = 128
+
+// init registers the Redis provider with the global registry
+func init() {
+	core.RegisterDatabase("redis", func(config *core.DatabaseConfig) (core.DatabaseProvider, error) {
+		return New(config)
+	})
+}
+
+// Provider implements the DatabaseProvider interface for Redis. It speaks
+// the replication protocol directly, so no Redis tools are needed.
+type Provider struct {
+	config   *core.DatabaseConfig
+	settings *settings
+}
+
+// New creates a new Redis provider and checks that the server is reachable
+func New(config *core.DatabaseConfig) (*Provider, error) {
+	s, err := connSettings(config)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := dial(context.Background(), s)
+	if err != nil {
+		return nil, err
+	}
+	c.Close()
+
+	return &Provider{config: config, settings: s}, nil
+}
+
+// Backup streams an RDB snapshot, requested with SYNC like a replica
+// performing a full resynchronization
+func (p *Provider) Backup(ctx context.Context) (io.ReadCloser, error) {
+	if p.config.BackupMode != "" {
+		return nil, fmt.Errorf("unsupported backup mode: %s", p.config.BackupMode)
+	}
+
+	c, err := dial(ctx, p.settings)
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+
+	size, err := requestSnapshot(c)
+	if err != nil {
+		stop()
+		c.Close()
+		return nil, err
+	}
+
+	// Peek at the header, which holds the RDB version
+	header, err := c.r.Peek(9)
+	if err != nil || !strings.HasPrefix(string(header), "REDIS") {
+		stop()
+		c.Close()
+		return nil, fmt.Errorf("server did not send an RDB snapshot")
+	}
+
+	return &snapshotReader{
+		c:         c,
+		stop:      stop,
+		remaining: size,
+		tags:      map[string]string{"rdb-version": strings.TrimLeft(string(header[5:]), "0")},
+	}, nil
+}
+
+// requestSnapshot asks the server for an RDB snapshot and returns its size.
+// Servers supporting it are told not to stream the commands that follow
+// the snapshot.
+func requestSnapshot(c *conn) (int64, error) {
+	var redisErr redisError
+	if _, err := c.do("REPLCONF", "rdb-only", "1"); err != nil && !errors.As(err, &redisErr) {
+		return 0, err
+	}
+
+	if err := c.send("SYNC"); err != nil {
+		return 0, err
+	}
+
+	// The server sends newlines while it writes the snapshot
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		if b != '\n' {
+			c.r.UnreadByte()
+			break
+		}
+	}
+
+	line, err := c.line()
+	if err != nil {
+		return 0, err
+	}
+	if strings.HasPrefix(line, "-") {
+		return 0, fmt.Errorf("failed to request snapshot: %s", line[1:])
+	}
+	if !strings.HasPrefix(line, "$") || strings.HasPrefix(line, "$EOF:") {
+		return 0, fmt.Errorf("unexpected reply to SYNC: %q", line)
+	}
+
+	size, err := strconv.ParseInt(line[1:], 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("unexpected reply to SYNC: %q", line)
+	}
+	return size, nil
+}
+
+// Restore loads an RDB snapshot into an empty instance
+func (p *Provider) Restore(ctx context.Context, reader io.Reader) error {
+	return p.RestoreWithOptions(ctx, reader, &core.RestoreOptions{})
+}
+
+// RestoreWithOptions loads an RDB snapshot by recreating every key with
+// RESTORE and every function library with FUNCTION LOAD. The instance must
+// be empty unless DropDatabase is set, which flushes it first. Keys that
+// have expired since the backup are skipped.
+func (p *Provider) RestoreWithOptions(ctx context.Context, reader io.Reader, opts *core.RestoreOptions) error {
+	if opts == nil {
+		opts = &core.RestoreOptions{}
+	}
+
+	switch {
+	case opts.BackupMode != "":
+		return fmt.Errorf("unsupported backup mode: %s", opts.BackupMode)
+	case opts.TargetDatabase != "":
+		return fmt.Errorf("the redis provider restores into the configured instance and does not support a target database")
+	case opts.Jobs > 1:
+		return fmt.Errorf("parallel restore is not supported by the redis provider")
+	case opts.SingleTransaction:
+		return fmt.Errorf("single-transaction restore is not supported by the redis provider")
+	case opts.Selective() || opts.TargetSchema != "":
+		return fmt.Errorf("selective restore is not supported by the redis provider")
+	}
+
+	c, err := dial(ctx, p.settings)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+	defer stop()
+
+	if err := prepareInstance(c, opts.DropDatabase); err != nil {
+		return err
+	}
+
+	r := &restorer{c: c, now: time.Now().UnixMilli()}
+	_, err = parseRDB(reader, rdbHandler{entry: r.entry, function: r.function})
+	if err == nil {
+		err = r.flush()
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to restore database: %w", err)
+	}
+
+	return nil
+}
+
+// prepareInstance checks that the instance can be restored into, flushing
+// it first when drop is set
+func prepareInstance(c *conn, drop bool) error {
+	info, err := c.info()
+	if err != nil {
+		return err
+	}
+	if info["cluster_enabled"] == "1" {
+		return fmt.Errorf("restoring into Redis Cluster is not supported")
+	}
+
+	if drop {
+		if _, err := c.do("FLUSHALL"); err != nil {
+			return fmt.Errorf("failed to flush instance: %w", err)
+		}
+		// Functions are only supported from Redis 7
+		var redisErr redisError
+		if _, err := c.do("FUNCTION", "FLUSH"); err != nil && !errors.As(err, &redisErr) {
+			return fmt.Errorf("failed to flush functions: %w", err)
+		}
+		return nil
+	}
+
+	for key, value := range info {
+		if strings.HasPrefix(key, "db") && !strings.HasPrefix(value, "keys=0,") {
+			return fmt.Errorf("instance is not empty (%s has %s): restore with --drop-db to flush it", key, value)
+		}
+	}
+	return nil
+}
+
+// restorer recreates the entries of an RDB file, pipelining the commands
+type restorer struct {
+	c       *conn
+	now     int64    // Current time in Unix milliseconds
+	db      int      // Database selected on the connection
+	pending []string // Descriptions of the commands awaiting a reply
+}
+
+// entry sends the RESTORE command for e
+func (r *restorer) entry(e *rdbEntry) error {
+	if e.expireAt > 0 && e.expireAt <= r.now {
+		return nil
+	}
+
+	if e.db != r.db {
+		if err := r.send("database "+strconv.Itoa(e.db), "SELECT", strconv.Itoa(e.db)); err != nil {
+			return err
+		}
+		r.db = e.db
+	}
+
+	args := []string{"RESTORE", e.key, strconv.FormatInt(e.expireAt, 10), string(e.payload)}
+	if e.expireAt > 0 {
+		args = append(args, "ABSTTL")
+	}
+	if e.idle >= 0 {
+		args = append(args, "IDLETIME", strconv.FormatInt(e.idle, 10))
+	} else if e.freq >= 0 {
+		args = append(args, "FREQ", strconv.Itoa(e.freq))
+	}
+
+	return r.send(fmt.Sprintf("key %q", e.key), args...)
+}
+
+// function sends the FUNCTION LOAD command for a function library
+func (r *restorer) function(code string) error {
+	return r.send("function library", "FUNCTION", "LOAD", code)
+}
+
+// send pipelines a command, reading the replies of a full batch
+func (r *restorer) send(description string, args ...string) error {
+	if err := r.c.send(args...); err != nil {
+		return err
+	}
+	r.pending = append(r.pending, description)
+
+	if len(r.pending) >= restoreBatchSize {
+		return r.flush()
+	}
+	return nil
+}
+
+// flush reads the replies of the pending commands
+func (r *restorer) flush() error {
+	pending := r.pending
+	r.pending = nil
+
+	for _, description := range pending {
+		if _, err := r.c.reply(); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", description, err)
+		}
+	}
+	return nil
+}
+
+// GetMetadata returns metadata about the server from INFO
+func (p *Provider) GetMetadata() (*core.DatabaseMetadata, error) {
+	c, err := dial(context.Background(), p.settings)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	info, err := c.info()
+	if err != nil {
+		return nil, err
+	}
+
+	size, _ := strconv.ParseInt(info["used_memory"], 10, 64)
+
+	name := p.config.Database
+	if name == "" {
+		name = "redis"
+	}
+
+	tags := map[string]string{}
+	for key, tag := range map[string]string{
+		"redis_version":     "redis-version",
+		"redis_mode":        "redis-mode",
+		"role":              "role",
+		"used_memory_human": "used-memory",
+	} {
+		if value := info[key]; value != "" {
+			tags[tag] = value
+		}
+	}
+
+	return &core.DatabaseMetadata{
+		Type:    "redis",
+		Version: info["redis_version"],
+		Size:    size,
+		Name:    name,
+		Tags:    tags,
+	}, nil
+}
+
+// Close releases the provider. Connections are opened per operation, so
+// there is nothing to close.
+func (p *Provider) Close() error {
+	return nil
+}
+
+// snapshotReader reads the RDB snapshot sent in reply to SYNC
+type snapshotReader struct {
+	c         *conn
+	stop      func() bool
+	remaining int64
+	tags      map[string]string
+}
+
+// Read reads the snapshot, failing if the connection ends before all of it
+// was received
+func (r *snapshotReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.c.r.Read(p)
+	r.remaining -= int64(n)
+	if err == io.EOF {
+		err = fmt.Errorf("snapshot truncated: %w", io.ErrUnexpectedEOF)
+	}
+	return n, err
+}
+
+// Tags returns the RDB version of the snapshot
+func (r *snapshotReader) Tags() map[string]string {
+	return r.tags
+}
+
+// Close closes the replication connection
+func (r *snapshotReader) Close() error {
+	r.stop()
+	r.c.Close()
+	if r.remaining > 0 {
+		return fmt.Errorf("snapshot closed with %d bytes unread", r.remaining)
+	}
+	return nil
+}
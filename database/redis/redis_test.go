@@ -0,0 +1,702 @@
+package redis_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goarchive/core"
+	"goarchive/database/redis"
+)
+
+// fakeRedis is a minimal Redis server that serves a fixed RDB snapshot and
+// records the commands it receives
+type fakeRedis struct {
+	listener net.Listener
+	rdb      []byte
+	keys     int    // Keys reported in INFO keyspace
+	password string // Password required by AUTH, if any
+	truncate bool   // Send half of the snapshot, then disconnect
+
+	mu       sync.Mutex
+	commands [][]string
+}
+
+func startFakeRedis(t *testing.T, rdb []byte) *fakeRedis {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &fakeRedis{listener: listener, rdb: rdb}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+// config returns the provider configuration for the server
+func (s *fakeRedis) config() *core.DatabaseConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return &core.DatabaseConfig{
+		Type:     "redis",
+		Host:     "127.0.0.1",
+		Port:     addr.Port,
+		Database: "cache",
+		Password: s.password,
+	}
+}
+
+// received returns the recorded commands named name
+func (s *fakeRedis) received(name string) [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var commands [][]string
+	for _, command := range s.commands {
+		if strings.EqualFold(command[0], name) {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for {
+		command, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, command)
+		s.mu.Unlock()
+
+		switch strings.ToUpper(command[0]) {
+		case "AUTH":
+			if command[len(command)-1] != s.password {
+				fmt.Fprint(conn, "-WRONGPASS invalid username-password pair\r\n")
+				continue
+			}
+			fmt.Fprint(conn, "+OK\r\n")
+		case "PING":
+			fmt.Fprint(conn, "+PONG\r\n")
+		case "INFO":
+			info := "# Server\r\nredis_version:7.2.4\r\nredis_mode:standalone\r\n" +
+				"# Memory\r\nused_memory:1048576\r\nused_memory_human:1.00M\r\n" +
+				"# Replication\r\nrole:master\r\n# Keyspace\r\n"
+			if s.keys > 0 {
+				info += fmt.Sprintf("db0:keys=%d,expires=0,avg_ttl=0\r\n", s.keys)
+			}
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(info), info)
+		case "SYNC":
+			fmt.Fprintf(conn, "\n\n$%d\r\n", len(s.rdb))
+			if s.truncate {
+				conn.Write(s.rdb[:len(s.rdb)/2])
+				return
+			}
+			conn.Write(s.rdb)
+		default:
+			fmt.Fprint(conn, "+OK\r\n")
+		}
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+// crc64 computes CRC-64/Jones bit by bit, independently of the provider
+func crc64(data []byte) uint64 {
+	var crc uint64
+	for _, b := range data {
+		crc ^= uint64(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 == 1 {
+				crc = crc>>1 ^ 0x95AC9329AC4BC9B5
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// rdbBuilder writes RDB files
+type rdbBuilder struct {
+	bytes.Buffer
+}
+
+func newRDB(version int) *rdbBuilder {
+	b := &rdbBuilder{}
+	fmt.Fprintf(b, "REDIS%04d", version)
+	return b
+}
+
+func (b *rdbBuilder) length(n int) {
+	switch {
+	case n < 1<<6:
+		b.WriteByte(byte(n))
+	case n < 1<<14:
+		b.WriteByte(0x40 | byte(n>>8))
+		b.WriteByte(byte(n))
+	default:
+		b.WriteByte(0x80)
+		binary.Write(b, binary.BigEndian, uint32(n))
+	}
+}
+
+func (b *rdbBuilder) str(s string) {
+	b.length(len(s))
+	b.WriteString(s)
+}
+
+func (b *rdbBuilder) finish() []byte {
+	b.WriteByte(0xFF)
+	binary.Write(b, binary.LittleEndian, crc64(b.Bytes()))
+	return b.Bytes()
+}
+
+// testRDB returns an RDB file exercising the encodings the provider must
+// understand
+func testRDB(expireAt time.Time) []byte {
+	b := newRDB(11)
+
+	b.WriteByte(0xFA) // AUX with an integer-encoded value
+	b.str("redis-bits")
+	b.Write([]byte{0xC0, 64})
+	b.WriteByte(0xF5) // Function library
+	b.str("#!lua name=lib\nredis.register_function('f', function() return 1 end)")
+
+	b.WriteByte(0xFE) // SELECTDB 0
+	b.length(0)
+	b.WriteByte(0xFB) // RESIZEDB
+	b.length(8)
+	b.length(1)
+
+	b.WriteByte(0xFC) // Expiry in milliseconds
+	binary.Write(b, binary.LittleEndian, uint64(expireAt.UnixMilli()))
+	b.WriteByte(0) // String
+	b.str("greeting")
+	b.str("hello")
+
+	b.WriteByte(0xFC) // Already expired
+	binary.Write(b, binary.LittleEndian, uint64(time.Now().Add(-time.Hour).UnixMilli()))
+	b.WriteByte(0)
+	b.str("expired")
+	b.str("gone")
+
+	b.WriteByte(0) // LZF-compressed key "abcabcabc" with an int16 value
+	b.Write([]byte{0xC3})
+	b.length(6)
+	b.length(9)
+	b.Write([]byte{0x02, 'a', 'b', 'c', 0x80, 0x02})
+	b.Write([]byte{0xC1, 0x39, 0x30})
+
+	b.WriteByte(18) // Quicklist with one packed node
+	b.str("list")
+	b.length(1)
+	b.length(2)
+	b.str("listpack bytes")
+
+	b.WriteByte(5) // Sorted set with binary scores
+	b.str("scores")
+	b.length(1)
+	b.str("member")
+	binary.Write(b, binary.LittleEndian, uint64(0x3FF8000000000000))
+
+	b.WriteByte(3) // Sorted set with string scores
+	b.str("oldscores")
+	b.length(2)
+	b.str("a")
+	b.str("1.5")
+	b.str("b")
+	b.WriteByte(253) // NaN
+
+	b.WriteByte(21) // Stream with a consumer group
+	b.str("events")
+	b.length(1)
+	b.str(strings.Repeat("\x00", 16))
+	b.str("listpack")
+	for _, n := range []int{1, 5, 0, 5, 0, 0, 0, 1} {
+		b.length(n)
+	}
+	b.length(1) // Groups
+	b.str("group")
+	b.length(5)
+	b.length(0)
+	b.length(1)
+	b.length(1) // Pending entries
+	b.Write(make([]byte, 16+8))
+	b.length(1)
+	b.length(1) // Consumers
+	b.str("consumer")
+	b.Write(make([]byte, 8+8))
+	b.length(1)
+	b.Write(make([]byte, 16))
+
+	b.WriteByte(0xF8) // LRU idle time
+	b.length(42)
+	b.WriteByte(0)
+	b.str("idle")
+	b.str("value")
+
+	b.WriteByte(0xFE) // SELECTDB 1
+	b.length(1)
+	b.WriteByte(0xF9) // LFU frequency
+	b.WriteByte(5)
+	b.WriteByte(0)
+	b.str("other")
+	b.str("x")
+
+	return b.finish()
+}
+
+func TestCRC64(t *testing.T) {
+	// Check value of CRC-64/Jones as used by Redis
+	if got := crc64([]byte("123456789")); got != 0xe9c6d914c4b8d9ca {
+		t.Fatalf("crc64() = %x, want e9c6d914c4b8d9ca", got)
+	}
+}
+
+func TestNew_InvalidConnection(t *testing.T) {
+	config := &core.DatabaseConfig{
+		Type:           "redis",
+		Host:           "invalid-host-that-does-not-exist",
+		Port:           6379,
+		ConnectTimeout: 1,
+	}
+
+	_, err := redis.New(config)
+	if err == nil {
+		t.Error("Expected error with invalid connection, got nil")
+	}
+}
+
+func TestNew_DefaultPort(t *testing.T) {
+	config := &core.DatabaseConfig{
+		Type:           "redis",
+		Host:           "127.0.0.1",
+		ConnectTimeout: 1,
+	}
+
+	_, err := redis.New(config)
+	if err == nil {
+		t.Skip("a Redis server is listening on the default port")
+	}
+	if !strings.Contains(err.Error(), "127.0.0.1:6379") {
+		t.Errorf("expected a connection to port 6379, got %v", err)
+	}
+}
+
+func TestNew_Authentication(t *testing.T) {
+	server := startFakeRedis(t, nil)
+	server.password = "s3cr3t"
+
+	config := server.config()
+	config.Username = "default"
+	if _, err := redis.New(config); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if auth := server.received("AUTH"); len(auth) != 1 || len(auth[0]) != 2 {
+		t.Errorf("expected AUTH with the password only, got %v", auth)
+	}
+
+	config.Password = "wrong"
+	if _, err := redis.New(config); err == nil {
+		t.Error("expected authentication error, got nil")
+	}
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	server := startFakeRedis(t, nil)
+
+	provider, err := core.GetDatabase("redis", server.config())
+	if err != nil {
+		t.Fatalf("GetDatabase() error = %v", err)
+	}
+	provider.Close()
+}
+
+func TestGetMetadata(t *testing.T) {
+	server := startFakeRedis(t, nil)
+
+	provider, err := redis.New(server.config())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	metadata, err := provider.GetMetadata()
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+
+	if metadata.Type != "redis" || metadata.Name != "cache" {
+		t.Errorf("unexpected Type %q and Name %q", metadata.Type, metadata.Name)
+	}
+	if metadata.Version != "7.2.4" {
+		t.Errorf("expected Version '7.2.4', got '%s'", metadata.Version)
+	}
+	if metadata.Size != 1048576 {
+		t.Errorf("expected Size 1048576, got %d", metadata.Size)
+	}
+	if metadata.Tags["used-memory"] != "1.00M" || metadata.Tags["role"] != "master" {
+		t.Errorf("unexpected Tags %v", metadata.Tags)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	rdb := testRDB(time.Now().Add(time.Hour))
+	server := startFakeRedis(t, rdb)
+
+	provider, err := redis.New(server.config())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	reader, err := provider.Backup(context.Background())
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read backup data: %v", err)
+	}
+	if !bytes.Equal(data, rdb) {
+		t.Errorf("backup differs from the snapshot: got %d bytes, want %d", len(data), len(rdb))
+	}
+
+	tagged, ok := reader.(core.TaggedReader)
+	if !ok {
+		t.Fatal("expected the backup reader to report tags")
+	}
+	if version := tagged.Tags()["rdb-version"]; version != "11" {
+		t.Errorf("expected rdb-version '11', got %q", version)
+	}
+
+	if err := reader.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if len(server.received("SYNC")) != 1 {
+		t.Error("expected the snapshot to be requested with SYNC")
+	}
+}
+
+func TestBackup_Truncated(t *testing.T) {
+	rdb := testRDB(time.Now().Add(time.Hour))
+	server := startFakeRedis(t, rdb)
+
+	provider, err := redis.New(server.config())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	server.truncate = true
+
+	reader, err := provider.Backup(context.Background())
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	defer reader.Close()
+
+	if _, err := io.ReadAll(reader); err == nil {
+		t.Error("expected error reading a truncated snapshot, got nil")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	expireAt := time.Now().Add(time.Hour)
+	server := startFakeRedis(t, nil)
+
+	provider, err := redis.New(server.config())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	if err := provider.Restore(context.Background(), bytes.NewReader(testRDB(expireAt))); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	restores := server.received("RESTORE")
+	var keys []string
+	for _, command := range restores {
+		keys = append(keys, command[1])
+	}
+	want := []string{"greeting", "abcabcabc", "list", "scores", "oldscores", "events", "idle", "other"}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Fatalf("restored keys %v, want %v", keys, want)
+	}
+
+	// RESTORE key ttl payload [ABSTTL]
+	greeting := restores[0]
+	if greeting[2] != strconv.FormatInt(expireAt.UnixMilli(), 10) || len(greeting) != 5 || greeting[4] != "ABSTTL" {
+		t.Errorf("unexpected expiry arguments %v", greeting[2:])
+	}
+
+	payload := []byte(greeting[3])
+	if body := string(payload[:len(payload)-10]); body != "\x00\x05hello" {
+		t.Errorf("unexpected payload body %q", body)
+	}
+	if version := binary.LittleEndian.Uint16(payload[len(payload)-10:]); version != 11 {
+		t.Errorf("expected payload RDB version 11, got %d", version)
+	}
+	if sum := binary.LittleEndian.Uint64(payload[len(payload)-8:]); sum != crc64(payload[:len(payload)-8]) {
+		t.Error("payload checksum mismatch")
+	}
+
+	if idle := restores[6]; strings.Join(idle[4:], " ") != "IDLETIME 42" {
+		t.Errorf("expected IDLETIME 42, got %v", idle[4:])
+	}
+	if other := restores[7]; strings.Join(other[4:], " ") != "FREQ 5" {
+		t.Errorf("expected FREQ 5, got %v", other[4:])
+	}
+	if selects := server.received("SELECT"); len(selects) != 1 || selects[0][1] != "1" {
+		t.Errorf("expected a single SELECT 1, got %v", selects)
+	}
+	if functions := server.received("FUNCTION"); len(functions) != 1 || functions[0][1] != "LOAD" {
+		t.Errorf("expected the function library to be loaded, got %v", functions)
+	}
+}
+
+func TestRestore_NonEmptyInstance(t *testing.T) {
+	server := startFakeRedis(t, nil)
+	server.keys = 3
+
+	provider, err := redis.New(server.config())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	rdb := testRDB(time.Now().Add(time.Hour))
+	if err := provider.Restore(context.Background(), bytes.NewReader(rdb)); err == nil {
+		t.Fatal("expected error restoring into a non-empty instance, got nil")
+	}
+	if len(server.received("RESTORE")) != 0 {
+		t.Fatal("expected no keys to be restored")
+	}
+
+	err = provider.RestoreWithOptions(context.Background(), bytes.NewReader(rdb), &core.RestoreOptions{DropDatabase: true})
+	if err != nil {
+		t.Fatalf("RestoreWithOptions() error = %v", err)
+	}
+	if len(server.received("FLUSHALL")) != 1 {
+		t.Error("expected the instance to be flushed")
+	}
+}
+
+func TestRestore_InvalidSnapshots(t *testing.T) {
+	server := startFakeRedis(t, nil)
+
+	provider, err := redis.New(server.config())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	corrupt := testRDB(time.Now().Add(time.Hour))
+	corrupt[len(corrupt)-1] ^= 0xFF
+
+	unknownType := newRDB(12)
+	unknownType.WriteByte(24)
+	unknownType.str("key")
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "not an RDB file", data: []byte("PGDMP")},
+		{name: "checksum mismatch", data: corrupt},
+		{name: "truncated", data: corrupt[:len(corrupt)/2]},
+		{name: "unknown value type", data: unknownType.finish()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := provider.Restore(context.Background(), bytes.NewReader(tt.data)); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestRestoreWithOptions_Unsupported(t *testing.T) {
+	server := startFakeRedis(t, nil)
+
+	provider, err := redis.New(server.config())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	tests := []struct {
+		name string
+		opts *core.RestoreOptions
+	}{
+		{name: "target database", opts: &core.RestoreOptions{TargetDatabase: "other"}},
+		{name: "parallel", opts: &core.RestoreOptions{Jobs: 4}},
+		{name: "selective", opts: &core.RestoreOptions{Tables: []string{"users"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := provider.RestoreWithOptions(context.Background(), strings.NewReader(""), tt.opts); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+// Integration tests - these require a real Redis server whose data may be
+// flushed
+
+func TestIntegration_Redis(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	host := os.Getenv("REDIS_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+	port := 6379
+	if p, err := strconv.Atoi(os.Getenv("REDIS_PORT")); err == nil {
+		port = p
+	}
+	config := &core.DatabaseConfig{
+		Type:           "redis",
+		Host:           host,
+		Port:           port,
+		Password:       os.Getenv("REDIS_PASSWORD"),
+		ConnectTimeout: 5,
+	}
+
+	provider, err := redis.New(config)
+	if err != nil {
+		t.Skipf("Skipping integration test - Redis not available: %v", err)
+	}
+	defer provider.Close()
+
+	ctx := context.Background()
+
+	// Seed the instance with every common value type, using the provider's
+	// own restore of a flushed instance as the starting point
+	conn, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	run := func(args ...string) string {
+		fmt.Fprintf(conn, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("%s failed: %v", args[0], err)
+		}
+		if strings.HasPrefix(line, "$") && line != "$-1\r\n" {
+			value, _ := r.ReadString('\n')
+			return strings.TrimSpace(value)
+		}
+		return strings.TrimSpace(line)
+	}
+	if config.Password != "" {
+		run("AUTH", config.Password)
+	}
+	run("FLUSHALL")
+	run("SET", "string", "hello", "EX", "3600")
+	run("RPUSH", "list", "a", "b", "c")
+	run("HSET", "hash", "field", "value")
+	run("SADD", "set", "1", "2", "3")
+	run("ZADD", "zset", "1.5", "member")
+	run("XADD", "stream", "*", "field", "value")
+	run("SET", strings.Repeat("long-key-", 10), strings.Repeat("compressible ", 100))
+
+	reader, err := provider.Backup(ctx)
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read backup data: %v", err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatalf("Backup() close error = %v", err)
+	}
+	if version := reader.(core.TaggedReader).Tags()["rdb-version"]; version == "" {
+		t.Error("expected the RDB version to be reported")
+	}
+
+	if err := provider.Restore(ctx, bytes.NewReader(data)); err == nil {
+		t.Error("expected error restoring into a non-empty instance, got nil")
+	}
+
+	if err := provider.RestoreWithOptions(ctx, bytes.NewReader(data), &core.RestoreOptions{DropDatabase: true}); err != nil {
+		t.Fatalf("RestoreWithOptions() error = %v", err)
+	}
+
+	if got := run("DBSIZE"); got != ":7" {
+		t.Errorf("expected 7 keys after restore, got %s", got)
+	}
+	if got := run("GET", "string"); got != "hello" {
+		t.Errorf("expected restored string 'hello', got %q", got)
+	}
+	if got := run("TTL", "string"); got == ":-1" {
+		t.Error("expected the restored string to keep its expiry")
+	}
+}
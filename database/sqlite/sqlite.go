@@ -0,0 +1,319 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"goarchive/core"
+
+	_ "modernc.org/sqlite" // Registers the "sqlite" database/sql driver
+)
+
+// init registers the SQLite provider with the global registry
+func init() {
+	core.RegisterDatabase("sqlite", func(config *core.DatabaseConfig) (core.DatabaseProvider, error) {
+		return New(config)
+	})
+}
+
+// journalSuffixes are the files SQLite keeps next to a database, which
+// belong to the file being replaced by a restore
+var journalSuffixes = []string{"-wal", "-shm", "-journal"}
+
+// Provider implements the DatabaseProvider interface for SQLite database
+// files
+type Provider struct {
+	config *core.DatabaseConfig
+	path   string
+}
+
+// New creates a new SQLite provider for the file at config.Path. The file
+// may not exist yet, so that a backup can be restored into a new file, but
+// its directory must.
+func New(config *core.DatabaseConfig) (*Provider, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("database path is required")
+	}
+
+	path, err := filepath.Abs(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database path: %w", err)
+	}
+
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("database directory does not exist: %s", filepath.Dir(path))
+	}
+
+	p := &Provider{config: config, path: path}
+
+	if _, err := os.Stat(path); err == nil {
+		if err := check(context.Background(), path, "SELECT COUNT(*) FROM sqlite_master"); err != nil {
+			return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+		}
+	}
+
+	return p, nil
+}
+
+// open opens the database file at path. Read-only connections never
+// create the file.
+func open(path string, readOnly bool) (*sql.DB, error) {
+	mode := "rwc"
+	if readOnly {
+		mode = "ro"
+	}
+
+	dsn := (&url.URL{
+		Scheme:   "file",
+		Path:     path,
+		RawQuery: "mode=" + mode + "&_pragma=busy_timeout(5000)",
+	}).String()
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+// check runs query against the database at path and fails unless it
+// succeeds with a single "ok" or numeric row
+func check(ctx context.Context, path, query string) error {
+	db, err := open(path, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRowContext(ctx, query).Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" && strings.Trim(result, "0123456789") != "" {
+		return fmt.Errorf("%s", result)
+	}
+	return nil
+}
+
+// Backup writes a consistent snapshot of the database to a temporary file
+// with VACUUM INTO and returns a reader over it. Concurrent writers are not
+// blocked and do not corrupt the snapshot.
+func (p *Provider) Backup(ctx context.Context) (io.ReadCloser, error) {
+	if p.config.BackupMode != "" {
+		return nil, fmt.Errorf("unsupported backup mode: %s", p.config.BackupMode)
+	}
+
+	if _, err := os.Stat(p.path); err != nil {
+		return nil, fmt.Errorf("database file not found: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "goarchive-sqlite-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	snapshot := filepath.Join(dir, "snapshot.db")
+	if err := p.vacuumInto(ctx, snapshot); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	file, err := os.Open(snapshot)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+
+	return &snapshotReader{File: file, cleanup: cleanup}, nil
+}
+
+// vacuumInto writes a compacted copy of the database to target, which must
+// not exist
+func (p *Provider) vacuumInto(ctx context.Context, target string) error {
+	db, err := open(p.path, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "VACUUM INTO ?", target); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the database file with a backup
+func (p *Provider) Restore(ctx context.Context, reader io.Reader) error {
+	return p.RestoreWithOptions(ctx, reader, &core.RestoreOptions{})
+}
+
+// RestoreWithOptions writes the backup next to the target file, verifies it
+// and renames it over the target, so readers see either the old or the new
+// database. TargetDatabase names a different file to restore into.
+// Applications writing to the database should be stopped first.
+func (p *Provider) RestoreWithOptions(ctx context.Context, reader io.Reader, opts *core.RestoreOptions) error {
+	if opts == nil {
+		opts = &core.RestoreOptions{}
+	}
+
+	switch {
+	case opts.BackupMode != "":
+		return fmt.Errorf("unsupported backup mode: %s", opts.BackupMode)
+	case opts.Jobs > 1:
+		return fmt.Errorf("parallel restore is not supported by the sqlite provider")
+	case opts.Selective() || opts.TargetSchema != "":
+		return fmt.Errorf("selective restore is not supported by the sqlite provider")
+	}
+
+	target := p.path
+	if opts.TargetDatabase != "" {
+		abs, err := filepath.Abs(opts.TargetDatabase)
+		if err != nil {
+			return fmt.Errorf("invalid target database path: %w", err)
+		}
+		target = abs
+	}
+
+	temp, err := writeTemp(reader, target)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp)
+
+	if err := check(ctx, temp, "PRAGMA quick_check"); err != nil {
+		return fmt.Errorf("backup is not a valid SQLite database: %w", err)
+	}
+
+	return replace(ctx, temp, target)
+}
+
+// writeTemp copies reader into a temporary file in the target's directory,
+// so it can be renamed over the target
+func writeTemp(reader io.Reader, target string) (string, error) {
+	file, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".restore-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	_, err = io.Copy(file, reader)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write backup data: %w", err)
+	}
+
+	// Keep the permissions of the file being replaced
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(target); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(file.Name(), mode); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to set file permissions: %w", err)
+	}
+
+	return file.Name(), nil
+}
+
+// replace renames temp over target after checkpointing the target's
+// write-ahead log, whose leftovers would otherwise be replayed into the new
+// file
+func replace(ctx context.Context, temp, target string) error {
+	if _, err := os.Stat(target); err == nil {
+		db, err := open(target, false)
+		if err != nil {
+			return err
+		}
+		_, err = db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)")
+		db.Close()
+		if err != nil {
+			return fmt.Errorf("failed to checkpoint database: %w", err)
+		}
+	}
+
+	for _, suffix := range journalSuffixes {
+		if err := os.Remove(target + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", target+suffix, err)
+		}
+	}
+
+	if err := os.Rename(temp, target); err != nil {
+		return fmt.Errorf("failed to replace database file: %w", err)
+	}
+
+	// Persist the rename
+	if dir, err := os.Open(filepath.Dir(target)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	return nil
+}
+
+// GetMetadata returns metadata about the database
+func (p *Provider) GetMetadata() (*core.DatabaseMetadata, error) {
+	if _, err := os.Stat(p.path); err != nil {
+		return nil, fmt.Errorf("database file not found: %w", err)
+	}
+
+	db, err := open(p.path, true)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var version string
+	var size int64
+
+	if err := db.QueryRow("SELECT sqlite_version()").Scan(&version); err != nil {
+		return nil, fmt.Errorf("failed to get version: %w", err)
+	}
+
+	err = db.QueryRow("SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()").Scan(&size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database size: %w", err)
+	}
+
+	name := filepath.Base(p.path)
+
+	return &core.DatabaseMetadata{
+		Type:    "sqlite",
+		Version: version,
+		Size:    size,
+		Name:    strings.TrimSuffix(name, filepath.Ext(name)),
+	}, nil
+}
+
+// Close releases the provider. Connections are opened per operation, so
+// there is nothing to close.
+func (p *Provider) Close() error {
+	return nil
+}
+
+// snapshotReader reads the snapshot file and removes it on Close
+type snapshotReader struct {
+	*os.File
+	cleanup func()
+}
+
+// Close closes and removes the snapshot file
+func (r *snapshotReader) Close() error {
+	err := r.File.Close()
+	r.cleanup()
+	return err
+}
@@ -0,0 +1,254 @@
+package sqlite_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goarchive/core"
+	"goarchive/database/sqlite"
+)
+
+// createDatabase creates a database in WAL mode with a table of n rows
+func createDatabase(t *testing.T, path string, n int) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	statements := []string{
+		"PRAGMA journal_mode=WAL",
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, data BLOB)",
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("failed to execute %q: %v", statement, err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if _, err := db.Exec("INSERT INTO items (name, data) VALUES (?, ?)", "item", []byte{0, 1, 2}); err != nil {
+			t.Fatalf("failed to insert row: %v", err)
+		}
+	}
+
+	return db
+}
+
+// countRows returns the number of rows in the items table of the database
+func countRows(t *testing.T, path string) int {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM items").Scan(&count); err != nil {
+		t.Fatalf("failed to count rows: %v", err)
+	}
+	return count
+}
+
+func backup(t *testing.T, provider *sqlite.Provider) []byte {
+	t.Helper()
+
+	reader, err := provider.Backup(context.Background())
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read backup data: %v", err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatalf("Backup() close error = %v", err)
+	}
+	return data
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "missing path", path: "", wantErr: true},
+		{name: "missing directory", path: filepath.Join(dir, "missing", "app.db"), wantErr: true},
+		{name: "new file", path: filepath.Join(dir, "new.db"), wantErr: false},
+		{name: "not a database", path: filepath.Join(dir, "text.db"), wantErr: true},
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "text.db"), bytes.Repeat([]byte("not a database"), 100), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := sqlite.New(&core.DatabaseConfig{Type: "sqlite", Path: tt.path})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if provider != nil {
+				provider.Close()
+			}
+		})
+	}
+
+	// Opening a missing file must not create it
+	if _, err := os.Stat(filepath.Join(dir, "new.db")); !os.IsNotExist(err) {
+		t.Errorf("expected new.db not to be created, got %v", err)
+	}
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	config := &core.DatabaseConfig{
+		Type: "sqlite",
+		Path: filepath.Join(t.TempDir(), "app.db"),
+	}
+
+	provider, err := core.GetDatabase("sqlite", config)
+	if err != nil {
+		t.Fatalf("GetDatabase() error = %v", err)
+	}
+	provider.Close()
+}
+
+func TestGetMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.db")
+	createDatabase(t, path, 10)
+
+	provider, err := sqlite.New(&core.DatabaseConfig{Type: "sqlite", Path: path})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	metadata, err := provider.GetMetadata()
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+
+	if metadata.Type != "sqlite" {
+		t.Errorf("Expected Type 'sqlite', got '%s'", metadata.Type)
+	}
+	if metadata.Name != "app" {
+		t.Errorf("Expected Name 'app', got '%s'", metadata.Name)
+	}
+	if metadata.Version == "" {
+		t.Error("Expected non-empty Version")
+	}
+	if metadata.Size <= 0 {
+		t.Errorf("Expected positive Size, got %d", metadata.Size)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.db")
+	db := createDatabase(t, path, 100)
+
+	provider, err := sqlite.New(&core.DatabaseConfig{Type: "sqlite", Path: path})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	// The snapshot includes rows only in the write-ahead log
+	data := backup(t, provider)
+	if !bytes.HasPrefix(data, []byte("SQLite format 3\x00")) {
+		t.Fatalf("expected a SQLite database file, got %q", data[:16])
+	}
+
+	// Changes after the backup are discarded by restoring it
+	if _, err := db.Exec("DELETE FROM items WHERE id > 10"); err != nil {
+		t.Fatalf("failed to delete rows: %v", err)
+	}
+	db.Close()
+
+	if err := provider.Restore(context.Background(), bytes.NewReader(data)); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if count := countRows(t, path); count != 100 {
+		t.Errorf("expected 100 rows after restore, got %d", count)
+	}
+
+	// Restore into a new file
+	copyPath := filepath.Join(dir, "copy.db")
+	err = provider.RestoreWithOptions(context.Background(), bytes.NewReader(data), &core.RestoreOptions{
+		TargetDatabase: copyPath,
+	})
+	if err != nil {
+		t.Fatalf("RestoreWithOptions() error = %v", err)
+	}
+	if count := countRows(t, copyPath); count != 100 {
+		t.Errorf("expected 100 rows in the copy, got %d", count)
+	}
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".restore-") {
+			t.Errorf("unexpected temporary file %s", entry.Name())
+		}
+	}
+}
+
+func TestRestore_InvalidBackupKeepsDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.db")
+	createDatabase(t, path, 5).Close()
+
+	provider, err := sqlite.New(&core.DatabaseConfig{Type: "sqlite", Path: path})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	garbage := bytes.Repeat([]byte("not a database"), 1000)
+	if err := provider.Restore(context.Background(), bytes.NewReader(garbage)); err == nil {
+		t.Fatal("expected error restoring invalid data, got nil")
+	}
+
+	if count := countRows(t, path); count != 5 {
+		t.Errorf("expected the original 5 rows, got %d", count)
+	}
+}
+
+func TestRestoreWithOptions_Unsupported(t *testing.T) {
+	provider, err := sqlite.New(&core.DatabaseConfig{Type: "sqlite", Path: filepath.Join(t.TempDir(), "app.db")})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer provider.Close()
+
+	tests := []struct {
+		name string
+		opts *core.RestoreOptions
+	}{
+		{name: "parallel", opts: &core.RestoreOptions{Jobs: 4}},
+		{name: "selective", opts: &core.RestoreOptions{Tables: []string{"items"}}},
+		{name: "backup mode", opts: &core.RestoreOptions{BackupMode: "physical"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := provider.RestoreWithOptions(context.Background(), strings.NewReader(""), tt.opts); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,436 @@
+package azureblob
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+
+	"goarchive/core"
+)
+
+const (
+	// blockSize is the size of each staged block. A block blob holds at
+	// most maxBlocks blocks, so backups are limited to about 780 GiB.
+	blockSize = 16 << 20
+	maxBlocks = 50000
+
+	// concurrency is the number of blocks staged at once, and so the
+	// number of blocks buffered in memory
+	concurrency = 4
+)
+
+// init registers the Azure Blob Storage provider with the global registry
+func init() {
+	core.RegisterStorage("azureblob", func(ctx context.Context, config *core.StorageConfig) (core.StorageProvider, error) {
+		return New(ctx, config)
+	})
+}
+
+// Provider implements the StorageProvider and Tierer interfaces for Azure
+// Blob Storage
+type Provider struct {
+	container *container.Client
+	tier      *blob.AccessTier
+	config    *core.StorageConfig
+}
+
+// New creates a new Azure Blob Storage provider for the container in
+// config.Bucket. It authenticates with config.ConnectionString, a SAS token
+// or the account key in config.SecretKey, in that order.
+func New(ctx context.Context, config *core.StorageConfig) (*Provider, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("storage container is required for Azure storage")
+	}
+
+	tier, err := parseAccessTier(config.AccessTier)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		container: client.ServiceClient().NewContainerClient(config.Bucket),
+		tier:      tier,
+		config:    config,
+	}, nil
+}
+
+// newClient creates a client for the storage account
+func newClient(config *core.StorageConfig) (*azblob.Client, error) {
+	if config.ConnectionString != "" {
+		client, err := azblob.NewClientFromConnectionString(config.ConnectionString, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Azure client: %w", err)
+		}
+		return client, nil
+	}
+
+	serviceURL := config.Endpoint
+	if serviceURL == "" {
+		if config.Account == "" {
+			return nil, fmt.Errorf("storage account, endpoint or connection string is required for Azure storage")
+		}
+		serviceURL = fmt.Sprintf("https://%s.blob.core.windows.net/", config.Account)
+	}
+	if u, err := url.Parse(serviceURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid storage endpoint: %s", serviceURL)
+	}
+
+	var client *azblob.Client
+	var err error
+	switch {
+	case config.SASToken != "":
+		sasURL := strings.TrimSuffix(serviceURL, "/") + "/?" + strings.TrimPrefix(config.SASToken, "?")
+		client, err = azblob.NewClientWithNoCredential(sasURL, nil)
+	case config.SecretKey != "":
+		if config.Account == "" {
+			return nil, fmt.Errorf("storage account is required with an account key")
+		}
+		var cred *azblob.SharedKeyCredential
+		cred, err = azblob.NewSharedKeyCredential(config.Account, config.SecretKey)
+		if err == nil {
+			client, err = azblob.NewClientWithSharedKeyCredential(serviceURL, cred, nil)
+		}
+	default:
+		return nil, fmt.Errorf("azure credentials are required: a connection string, SAS token or account key")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure client: %w", err)
+	}
+	return client, nil
+}
+
+// parseAccessTier returns the access tier for backups, or nil to use the
+// account's default tier
+func parseAccessTier(name string) (*blob.AccessTier, error) {
+	if name == "" {
+		return nil, nil
+	}
+	for _, tier := range []blob.AccessTier{blob.AccessTierHot, blob.AccessTierCool, blob.AccessTierCold, blob.AccessTierArchive} {
+		if strings.EqualFold(name, string(tier)) {
+			return &tier, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported access tier: %s (use Hot, Cool, Cold or Archive)", name)
+}
+
+// Upload streams the backup to a block blob. Blocks are staged in parallel
+// as they are read, and the blob only appears once the block list is
+// committed, so a failed backup leaves nothing behind.
+func (p *Provider) Upload(ctx context.Context, reader io.Reader, metadata *core.BackupMetadata) error {
+	blockBlob := p.container.NewBlockBlobClient(p.getBackupKey(metadata))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		stageErr error
+	)
+	// Buffers are allocated as needed and reused, bounding memory use to
+	// concurrency blocks
+	buffers := make(chan []byte, concurrency)
+	for i := 0; i < concurrency; i++ {
+		buffers <- nil
+	}
+
+	hash := md5.New()
+	var blockIDs []string
+	var size int64
+	for {
+		var buf []byte
+		select {
+		case buf = <-buffers:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if buf == nil {
+			buf = make([]byte, blockSize)
+		}
+
+		n, err := io.ReadFull(reader, buf)
+		if n > 0 {
+			if len(blockIDs) == maxBlocks {
+				cancel()
+				wg.Wait()
+				return fmt.Errorf("failed to upload to Azure: backup exceeds %d blocks of %d bytes", maxBlocks, blockSize)
+			}
+
+			block := buf[:n]
+			hash.Write(block)
+			size += int64(n)
+			id := blockID(len(blockIDs))
+			blockIDs = append(blockIDs, id)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { buffers <- buf }()
+
+				if err := p.stageBlock(ctx, blockBlob, id, block); err != nil {
+					mu.Lock()
+					if stageErr == nil {
+						stageErr = err
+					}
+					mu.Unlock()
+					cancel()
+				}
+			}()
+		} else {
+			buffers <- buf
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			cancel()
+			wg.Wait()
+			return fmt.Errorf("failed to read backup data: %w", err)
+		}
+	}
+	wg.Wait()
+
+	if stageErr != nil {
+		return fmt.Errorf("failed to upload to Azure: %w", stageErr)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sum := hash.Sum(nil)
+	_, err := blockBlob.CommitBlockList(ctx, blockIDs, &blockblob.CommitBlockListOptions{
+		HTTPHeaders: &blob.HTTPHeaders{
+			BlobContentType: to.Ptr("application/octet-stream"),
+			BlobContentMD5:  sum,
+		},
+		Metadata: blobMetadata(metadata),
+		Tier:     p.tier,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload to Azure: %w", err)
+	}
+
+	metadata.Checksum = hex.EncodeToString(sum)
+	metadata.Size = size
+
+	return nil
+}
+
+// stageBlock uploads one block, which the service checks against its MD5
+// checksum
+func (p *Provider) stageBlock(ctx context.Context, blockBlob *blockblob.Client, id string, block []byte) error {
+	sum := md5.Sum(block)
+	_, err := blockBlob.StageBlock(ctx, id, streaming.NopCloser(bytes.NewReader(block)), &blockblob.StageBlockOptions{
+		TransactionalValidation: blob.TransferValidationTypeMD5(sum[:]),
+	})
+	return err
+}
+
+// blockID returns the ID of the block at index. IDs within a blob must all
+// have the same length.
+func blockID(index int) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", index)))
+}
+
+// List lists available backups, reading every page of results
+func (p *Provider) List(ctx context.Context) ([]*core.BackupMetadata, error) {
+	// The delimiter leaves out blobs stored under nested keys (e.g. WAL
+	// archives)
+	pager := p.container.NewListBlobsHierarchyPager("/", &container.ListBlobsHierarchyOptions{
+		Prefix:  to.Ptr(p.listPrefix()),
+		Include: container.ListBlobsInclude{Metadata: true},
+	})
+
+	var backups []*core.BackupMetadata
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list blobs: %w", err)
+		}
+
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil {
+				continue
+			}
+
+			backup := &core.BackupMetadata{ID: path.Base(*item.Name)}
+			if props := item.Properties; props != nil {
+				if props.CreationTime != nil {
+					backup.Timestamp = *props.CreationTime
+				}
+				if props.ContentLength != nil {
+					backup.Size = *props.ContentLength
+				}
+				if len(props.ContentMD5) > 0 {
+					backup.Checksum = hex.EncodeToString(props.ContentMD5)
+				}
+			}
+			applyBlobMetadata(backup, item.Metadata)
+			if item.Properties != nil && item.Properties.AccessTier != nil && *item.Properties.AccessTier != "" {
+				if backup.Tags == nil {
+					backup.Tags = make(map[string]string)
+				}
+				backup.Tags["access-tier"] = string(*item.Properties.AccessTier)
+				backup.Tier = string(*item.Properties.AccessTier)
+			}
+
+			backups = append(backups, backup)
+		}
+	}
+
+	return backups, nil
+}
+
+// Download downloads a backup from Azure. Backups in the Archive tier must
+// be rehydrated to an online tier first.
+func (p *Provider) Download(ctx context.Context, backupID string) (io.ReadCloser, error) {
+	key := path.Join(p.config.Prefix, backupID)
+
+	resp, err := p.container.NewBlobClient(key).DownloadStream(ctx, nil)
+	if bloberror.HasCode(err, bloberror.BlobArchived) {
+		return nil, fmt.Errorf("backup %s is in the Archive tier; rehydrate it to an online tier before downloading", backupID)
+	}
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil, fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from Azure: %w", err)
+	}
+
+	return resp.Body, nil
+}
+
+// Delete deletes a backup from Azure
+func (p *Provider) Delete(ctx context.Context, backupID string) error {
+	key := path.Join(p.config.Prefix, backupID)
+
+	_, err := p.container.NewBlobClient(key).Delete(ctx, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete from Azure: %w", err)
+	}
+
+	return nil
+}
+
+// Tier moves a backup to an access tier: Hot, Cool, Cold or Archive. Moving
+// a backup out of the Archive tier starts its rehydration, which can take
+// hours; downloads fail until it completes.
+func (p *Provider) Tier(ctx context.Context, backupID string, tier string) error {
+	accessTier, err := parseAccessTier(tier)
+	if err != nil {
+		return err
+	}
+	if accessTier == nil {
+		return fmt.Errorf("access tier is required")
+	}
+
+	key := path.Join(p.config.Prefix, backupID)
+	_, err = p.container.NewBlobClient(key).SetTier(ctx, *accessTier, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set access tier: %w", err)
+	}
+
+	return nil
+}
+
+// listPrefix returns the prefix of the blobs holding backups, which ends
+// with a slash unless backups are stored at the root of the container
+func (p *Provider) listPrefix() string {
+	prefix := strings.Trim(p.config.Prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// getBackupKey generates the blob name for a backup
+func (p *Provider) getBackupKey(metadata *core.BackupMetadata) string {
+	if metadata.Key != "" {
+		return path.Join(p.config.Prefix, metadata.Key)
+	}
+	filename := fmt.Sprintf("%s_%s_%s.dump",
+		metadata.DatabaseName,
+		metadata.DatabaseType,
+		metadata.Timestamp.Format("20060102-150405"),
+	)
+	return path.Join(p.config.Prefix, filename)
+}
+
+// blobMetadata returns the metadata stored with a backup blob. Metadata
+// names must be valid C# identifiers, so tags, whose names contain hyphens,
+// are stored together as a query string.
+func blobMetadata(metadata *core.BackupMetadata) map[string]*string {
+	blobMeta := map[string]*string{
+		"database_name": to.Ptr(metadata.DatabaseName),
+		"database_type": to.Ptr(metadata.DatabaseType),
+		"backup_id":     to.Ptr(metadata.ID),
+		"timestamp":     to.Ptr(metadata.Timestamp.Format(time.RFC3339)),
+		"backup_mode":   to.Ptr(metadata.BackupMode),
+	}
+	if len(metadata.Tags) > 0 {
+		tags := url.Values{}
+		for key, value := range metadata.Tags {
+			tags.Set(key, value)
+		}
+		blobMeta["tags"] = to.Ptr(tags.Encode())
+	}
+	return blobMeta
+}
+
+// applyBlobMetadata copies the metadata stored by Upload into backup
+func applyBlobMetadata(backup *core.BackupMetadata, metadata map[string]*string) {
+	get := func(key string) string {
+		for k, v := range metadata {
+			if strings.EqualFold(k, key) && v != nil {
+				return *v
+			}
+		}
+		return ""
+	}
+
+	backup.DatabaseName = get("database_name")
+	backup.DatabaseType = get("database_type")
+	backup.BackupMode = get("backup_mode")
+
+	if tags, err := url.ParseQuery(get("tags")); err == nil && len(tags) > 0 {
+		backup.Tags = make(map[string]string, len(tags))
+		for key := range tags {
+			backup.Tags[key] = tags.Get(key)
+		}
+	}
+
+	if t, err := time.Parse(time.RFC3339, get("timestamp")); err == nil {
+		backup.Timestamp = t
+	}
+}
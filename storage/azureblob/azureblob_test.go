@@ -0,0 +1,367 @@
+package azureblob_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+
+	"goarchive/core"
+	"goarchive/storage/azureblob"
+)
+
+// Azurite's well-known development account
+const (
+	azuriteAccount = "devstoreaccount1"
+	azuriteKey     = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+	testContainer  = "test-backup-container"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *core.StorageConfig
+		wantErr bool
+	}{
+		{
+			name:    "missing container",
+			config:  &core.StorageConfig{Account: "backups", SecretKey: azuriteKey},
+			wantErr: true,
+		},
+		{
+			name:    "missing account",
+			config:  &core.StorageConfig{Bucket: testContainer, SASToken: "sv=2024-08-04&sig=abc"},
+			wantErr: true,
+		},
+		{
+			name:    "missing credentials",
+			config:  &core.StorageConfig{Bucket: testContainer, Account: "backups"},
+			wantErr: true,
+		},
+		{
+			name:    "account key without account",
+			config:  &core.StorageConfig{Bucket: testContainer, Endpoint: "http://127.0.0.1:10000/devstoreaccount1", SecretKey: azuriteKey},
+			wantErr: true,
+		},
+		{
+			name:    "invalid account key",
+			config:  &core.StorageConfig{Bucket: testContainer, Account: "backups", SecretKey: "not base64!"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid connection string",
+			config:  &core.StorageConfig{Bucket: testContainer, ConnectionString: "AccountName=backups"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid endpoint",
+			config:  &core.StorageConfig{Bucket: testContainer, Endpoint: "127.0.0.1:10000", Account: azuriteAccount, SecretKey: azuriteKey},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported access tier",
+			config:  &core.StorageConfig{Bucket: testContainer, Account: "backups", SecretKey: azuriteKey, AccessTier: "Premium"},
+			wantErr: true,
+		},
+		{
+			name:   "account key",
+			config: &core.StorageConfig{Bucket: testContainer, Account: "backups", SecretKey: azuriteKey, AccessTier: "cool"},
+		},
+		{
+			name:   "SAS token",
+			config: &core.StorageConfig{Bucket: testContainer, Account: "backups", SASToken: "?sv=2024-08-04&sig=abc", AccessTier: "Archive"},
+		},
+		{
+			name: "connection string",
+			config: &core.StorageConfig{
+				Bucket:           testContainer,
+				ConnectionString: "DefaultEndpointsProtocol=https;AccountName=backups;AccountKey=" + azuriteKey + ";EndpointSuffix=core.windows.net",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := azureblob.New(context.Background(), tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	provider, err := core.GetStorage(context.Background(), "azureblob", &core.StorageConfig{
+		Bucket:    testContainer,
+		Account:   azuriteAccount,
+		SecretKey: azuriteKey,
+	})
+	if err != nil {
+		t.Fatalf("GetStorage() error = %v", err)
+	}
+	if provider == nil {
+		t.Error("expected non-nil provider")
+	}
+}
+
+// Integration tests - these require Azurite or a real storage account
+
+func getTestConfig(t *testing.T) *core.StorageConfig {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	endpoint := os.Getenv("STORAGE_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("Skipping Azure integration test - STORAGE_ENDPOINT not set")
+	}
+
+	account := os.Getenv("STORAGE_ACCOUNT")
+	if account == "" {
+		account = azuriteAccount
+	}
+	key := os.Getenv("STORAGE_SECRET_KEY")
+	if key == "" {
+		key = azuriteKey
+	}
+
+	config := &core.StorageConfig{
+		Type:      "azureblob",
+		Bucket:    testContainer,
+		Endpoint:  endpoint,
+		Account:   account,
+		SecretKey: key,
+		Prefix:    "test-backups/" + time.Now().Format("20060102-150405.000000") + "/",
+	}
+
+	// Azurite starts without containers
+	cred, err := azblob.NewSharedKeyCredential(account, key)
+	if err != nil {
+		t.Fatalf("failed to create credential: %v", err)
+	}
+	client, err := azblob.NewClientWithSharedKeyCredential(endpoint, cred, nil)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	_, err = client.CreateContainer(context.Background(), testContainer, nil)
+	if err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	return config
+}
+
+func TestIntegration_UploadListDownloadDelete(t *testing.T) {
+	config := getTestConfig(t)
+	ctx := context.Background()
+
+	provider, err := azureblob.New(ctx, config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Several blocks, the last one partial
+	data := bytes.Repeat([]byte("backup data "), (40<<20)/12)
+	metadata := &core.BackupMetadata{
+		ID:           "20260215-103020",
+		DatabaseName: "orders",
+		DatabaseType: "postgres",
+		BackupMode:   "logical",
+		Timestamp:    time.Date(2026, 2, 15, 10, 30, 20, 0, time.UTC),
+		Tags:         map[string]string{"server-version": "16.2"},
+	}
+	if err := provider.Upload(ctx, bytes.NewReader(data), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	sum := md5.Sum(data)
+	if metadata.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("Checksum = %s, want %s", metadata.Checksum, hex.EncodeToString(sum[:]))
+	}
+	if metadata.Size != int64(len(data)) {
+		t.Errorf("Size = %d, want %d", metadata.Size, len(data))
+	}
+
+	// Blobs below the backups, such as WAL archives, are not listed
+	wal := &core.BackupMetadata{Key: "wal/00000001/000000010000000000000001.gz"}
+	if err := provider.Upload(ctx, bytes.NewReader([]byte("wal")), wal); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %d", len(backups))
+	}
+
+	backup := backups[0]
+	if backup.ID != "orders_postgres_20260215-103020.dump" {
+		t.Errorf("ID = %s", backup.ID)
+	}
+	if backup.DatabaseName != "orders" || backup.DatabaseType != "postgres" || backup.BackupMode != "logical" {
+		t.Errorf("unexpected metadata: %+v", backup)
+	}
+	if !backup.Timestamp.Equal(metadata.Timestamp) {
+		t.Errorf("Timestamp = %v, want %v", backup.Timestamp, metadata.Timestamp)
+	}
+	if backup.Checksum != metadata.Checksum || backup.Size != metadata.Size {
+		t.Errorf("listed checksum %s and size %d, want %s and %d", backup.Checksum, backup.Size, metadata.Checksum, metadata.Size)
+	}
+	if backup.Tags["server-version"] != "16.2" {
+		t.Errorf("expected tags to round-trip, got %v", backup.Tags)
+	}
+
+	reader, err := provider.Download(ctx, backup.ID)
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	downloaded, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatalf("failed to read download: %v", err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Error("downloaded data differs from the upload")
+	}
+
+	for _, id := range []string{backup.ID, "wal/00000001/000000010000000000000001.gz"} {
+		if err := provider.Delete(ctx, id); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+	}
+	backups, err = provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("expected no backups after delete, got %d", len(backups))
+	}
+}
+
+// failingReader returns some data and then an error
+type failingReader struct {
+	sent bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if !r.sent {
+		r.sent = true
+		return copy(p, "partial backup"), nil
+	}
+	return 0, errors.New("database connection lost")
+}
+
+func TestIntegration_FailedBackupLeavesNoBlob(t *testing.T) {
+	config := getTestConfig(t)
+	ctx := context.Background()
+
+	provider, err := azureblob.New(ctx, config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	metadata := &core.BackupMetadata{DatabaseName: "orders", DatabaseType: "postgres", Timestamp: time.Now()}
+	if err := provider.Upload(ctx, &failingReader{}, metadata); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("expected no backups after a failed upload, got %d", len(backups))
+	}
+}
+
+func TestIntegration_AccessTier(t *testing.T) {
+	config := getTestConfig(t)
+	config.AccessTier = "Cool"
+	ctx := context.Background()
+
+	provider, err := azureblob.New(ctx, config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	metadata := &core.BackupMetadata{DatabaseName: "orders", DatabaseType: "postgres", Timestamp: time.Now()}
+	if err := provider.Upload(ctx, bytes.NewReader([]byte("cool backup")), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 || backups[0].Tags["access-tier"] != "Cool" {
+		t.Errorf("expected a backup in the Cool tier, got %+v", backups)
+	}
+	for _, backup := range backups {
+		provider.Delete(ctx, backup.ID)
+	}
+}
+
+func TestIntegration_Tier(t *testing.T) {
+	config := getTestConfig(t)
+	ctx := context.Background()
+
+	provider, err := azureblob.New(ctx, config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	metadata := &core.BackupMetadata{DatabaseName: "orders", DatabaseType: "postgres", Timestamp: time.Now()}
+	if err := provider.Upload(ctx, bytes.NewReader([]byte("cold backup")), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	defer func() {
+		backups, _ := provider.List(ctx)
+		for _, backup := range backups {
+			provider.Delete(ctx, backup.ID)
+		}
+	}()
+
+	backups, err := provider.List(ctx)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %v (%v)", backups, err)
+	}
+
+	if err := provider.Tier(ctx, backups[0].ID, "cold"); err != nil {
+		t.Fatalf("Tier() error = %v", err)
+	}
+	if backups, err = provider.List(ctx); err != nil || backups[0].Tier != "Cold" {
+		t.Errorf("expected a backup in the Cold tier, got %+v (%v)", backups, err)
+	}
+
+	if err := provider.Tier(ctx, "missing.dump", "Cool"); err == nil {
+		t.Error("expected an error for a missing backup")
+	}
+	if err := provider.Tier(ctx, backups[0].ID, "Glacier"); err == nil {
+		t.Error("expected an error for an unknown tier")
+	}
+}
+
+func TestIntegration_DownloadMissing(t *testing.T) {
+	config := getTestConfig(t)
+
+	provider, err := azureblob.New(context.Background(), config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := provider.Download(context.Background(), "missing.dump"); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
@@ -4,13 +4,14 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
-	"time"
+	"syscall"
 
 	"goarchive/core"
 )
@@ -22,10 +23,18 @@ func init() {
 	})
 }
 
-// Provider implements the StorageProvider interface for local disk storage
+// Storage tiers of the disk provider
+const (
+	TierStandard = "standard" // The backup directory
+	TierArchive  = "archive"  // The archive directory, StorageConfig.ArchivePath
+)
+
+// Provider implements the StorageProvider and Tierer interfaces for local
+// disk storage
 type Provider struct {
-	config *core.StorageConfig
-	path   string
+	config      *core.StorageConfig
+	path        string
+	archivePath string // Empty when no archive tier is configured
 }
 
 // New creates a new disk storage provider
@@ -41,46 +50,46 @@ func New(config *core.StorageConfig) (*Provider, error) {
 		return nil, fmt.Errorf("failed to create backup directory: %w", err)
 	}
 
+	if config.ArchivePath != "" {
+		if err := os.MkdirAll(config.ArchivePath, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create archive directory: %w", err)
+		}
+	}
+
 	return &Provider{
-		config: config,
-		path:   path,
+		config:      config,
+		path:        path,
+		archivePath: config.ArchivePath,
 	}, nil
 }
 
-// Upload saves the backup data to local disk
+// Upload streams the backup data to a temporary file on local disk and
+// renames it into place once complete
 func (p *Provider) Upload(ctx context.Context, reader io.Reader, metadata *core.BackupMetadata) error {
-	// Read all data and calculate checksum
-	data, err := io.ReadAll(reader)
-	if err != nil {
-		return fmt.Errorf("failed to read backup data: %w", err)
-	}
-
-	// Calculate MD5 checksum
-	hash := md5.Sum(data)
-	checksum := hex.EncodeToString(hash[:])
-	metadata.Checksum = checksum
-	metadata.Size = int64(len(data))
-
 	// Create filename
 	filename := p.getBackupFilename(metadata)
 	fullPath := filepath.Join(p.path, filename)
+	if !strings.HasPrefix(fullPath, filepath.Clean(p.path)+string(os.PathSeparator)) {
+		return fmt.Errorf("invalid backup key: %s", filename)
+	}
 
-	// Write to disk
-	if err := os.WriteFile(fullPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write backup file: %w", err)
+	// Keys may place backups in subdirectories
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	// Write to disk, calculating the MD5 checksum on the way
+	hash := md5.New()
+	size, err := writeFileAtomic(fullPath, io.TeeReader(reader, hash))
+	if err != nil {
+		return err
 	}
+	metadata.Checksum = hex.EncodeToString(hash.Sum(nil))
+	metadata.Size = size
 
 	// Write metadata file
 	metadataPath := fullPath + ".meta"
-	metadataContent := fmt.Sprintf(
-		"ID: %s\nDatabaseName: %s\nDatabaseType: %s\nTimestamp: %s\nSize: %d\nChecksum: %s\n",
-		metadata.ID,
-		metadata.DatabaseName,
-		metadata.DatabaseType,
-		metadata.Timestamp.Format(time.RFC3339),
-		metadata.Size,
-		metadata.Checksum,
-	)
+	metadataContent := core.FormatManifest(metadata)
 	if err := os.WriteFile(metadataPath, []byte(metadataContent), 0644); err != nil {
 		// Non-fatal, just log
 		fmt.Fprintf(os.Stderr, "Warning: failed to write metadata file: %v\n", err)
@@ -89,13 +98,71 @@ func (p *Provider) Upload(ctx context.Context, reader io.Reader, metadata *core.
 	return nil
 }
 
-// List lists available backups in the local directory
+// writeFileAtomic writes the data read from reader to a temporary file next
+// to path and renames it to path, so that a failed write never leaves a
+// partial backup in place. It returns the number of bytes written.
+func writeFileAtomic(path string, reader io.Reader) (int64, error) {
+	// The temporary name doesn't end with .dump, so it is never listed
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	size, err := io.Copy(file, reader)
+	if err != nil {
+		file.Close()
+		return 0, fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		return 0, fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return 0, fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write backup file: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to write backup file: %w", err)
+	}
+	return size, nil
+}
+
+// List lists available backups in the local directory and, when
+// configured, the archive directory
 func (p *Provider) List(ctx context.Context) ([]*core.BackupMetadata, error) {
-	entries, err := os.ReadDir(p.path)
+	backups, err := p.listDir(p.path, TierStandard)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read backup directory: %w", err)
 	}
 
+	if p.archivePath != "" {
+		archived, err := p.listDir(p.archivePath, TierArchive)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive directory: %w", err)
+		}
+		backups = append(backups, archived...)
+	}
+
+	// Sort by timestamp descending (newest first)
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Timestamp.After(backups[j].Timestamp)
+	})
+
+	return backups, nil
+}
+
+// listDir lists the backups in dir, which holds the given tier
+func (p *Provider) listDir(dir string, tier string) ([]*core.BackupMetadata, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
 	var backups []*core.BackupMetadata
 	for _, entry := range entries {
 		if entry.IsDir() {
@@ -124,30 +191,30 @@ func (p *Provider) List(ctx context.Context) ([]*core.BackupMetadata, error) {
 		}
 
 		// Try to read metadata file if it exists
-		metadataPath := filepath.Join(p.path, entry.Name()+".meta")
+		metadataPath := filepath.Join(dir, entry.Name()+".meta")
 		if metaData, err := os.ReadFile(metadataPath); err == nil {
-			p.parseMetadata(backup, string(metaData))
+			core.ParseManifest(backup, string(metaData))
+		}
+
+		// Tiers are only reported when there is more than one
+		if p.archivePath != "" {
+			backup.Tier = tier
 		}
 
 		backups = append(backups, backup)
 	}
 
-	// Sort by timestamp descending (newest first)
-	sort.Slice(backups, func(i, j int) bool {
-		return backups[i].Timestamp.After(backups[j].Timestamp)
-	})
-
 	return backups, nil
 }
 
 // Download reads a backup from local disk
 func (p *Provider) Download(ctx context.Context, backupID string) (io.ReadCloser, error) {
-	fullPath := filepath.Join(p.path, backupID)
+	fullPath, _ := p.locate(backupID)
 
 	file, err := os.Open(fullPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("backup not found: %s", backupID)
+			return nil, fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
 		}
 		return nil, fmt.Errorf("failed to open backup file: %w", err)
 	}
@@ -157,12 +224,12 @@ func (p *Provider) Download(ctx context.Context, backupID string) (io.ReadCloser
 
 // Delete removes a backup from local disk
 func (p *Provider) Delete(ctx context.Context, backupID string) error {
-	fullPath := filepath.Join(p.path, backupID)
+	fullPath, _ := p.locate(backupID)
 
 	// Delete the backup file
 	if err := os.Remove(fullPath); err != nil {
 		if os.IsNotExist(err) {
-			return fmt.Errorf("backup not found: %s", backupID)
+			return fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
 		}
 		return fmt.Errorf("failed to delete backup file: %w", err)
 	}
@@ -174,40 +241,123 @@ func (p *Provider) Delete(ctx context.Context, backupID string) error {
 	return nil
 }
 
+// Tier moves a backup between the backup directory ("standard") and the
+// archive directory ("archive"), together with its metadata file
+func (p *Provider) Tier(ctx context.Context, backupID string, tier string) error {
+	var dir string
+	switch strings.ToLower(tier) {
+	case TierStandard:
+		dir = p.path
+	case TierArchive:
+		dir = p.archivePath
+	default:
+		return fmt.Errorf("unsupported disk tier: %s (use %s or %s)", tier, TierStandard, TierArchive)
+	}
+	if p.archivePath == "" {
+		return fmt.Errorf("disk tiering requires an archive path")
+	}
+
+	fullPath, current := p.locate(backupID)
+	if _, err := os.Stat(fullPath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+		}
+		return fmt.Errorf("failed to stat backup file: %w", err)
+	}
+	if current == dir {
+		return nil
+	}
+
+	target := filepath.Join(dir, backupID)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	// Move the metadata first, so that a failure leaves the backup listed
+	// in one place only
+	if err := moveFile(fullPath+".meta", target+".meta"); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to move metadata file: %w", err)
+	}
+	if err := moveFile(fullPath, target); err != nil {
+		// Put the metadata back with the backup
+		moveFile(target+".meta", fullPath+".meta")
+		return fmt.Errorf("failed to move backup file: %w", err)
+	}
+
+	return nil
+}
+
+// locate returns the path of a backup and the directory holding it. Backups
+// not found in the archive directory are looked for in the backup directory.
+func (p *Provider) locate(backupID string) (string, string) {
+	if p.archivePath != "" {
+		archived := filepath.Join(p.archivePath, backupID)
+		if _, err := os.Stat(archived); err == nil {
+			return archived, p.archivePath
+		}
+	}
+	return filepath.Join(p.path, backupID), p.path
+}
+
+// moveFile renames src to dst, copying and removing it when they are on
+// different filesystems. The copy is synced to disk, together with the
+// directory entry naming it, before the source is removed.
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	if err := syncDir(filepath.Dir(dst)); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// syncDir flushes the entries of directory dir to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
 // getBackupFilename generates the filename for a backup
 func (p *Provider) getBackupFilename(metadata *core.BackupMetadata) string {
+	if metadata.Key != "" {
+		return filepath.FromSlash(metadata.Key)
+	}
 	return fmt.Sprintf("%s_%s_%s.dump",
 		metadata.DatabaseName,
 		metadata.DatabaseType,
 		metadata.Timestamp.Format("20060102-150405"),
 	)
 }
-
-// parseMetadata parses metadata from the .meta file
-func (p *Provider) parseMetadata(backup *core.BackupMetadata, content string) {
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		parts := strings.SplitN(line, ": ", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		key, value := parts[0], parts[1]
-
-		switch key {
-		case "ID":
-			// Note: We don't overwrite backup.ID here because it's already set to the filename
-			// The filename is what Download() and Delete() expect
-			continue
-		case "DatabaseName":
-			backup.DatabaseName = value
-		case "DatabaseType":
-			backup.DatabaseType = value
-		case "Checksum":
-			backup.Checksum = value
-		case "Timestamp":
-			if t, err := time.Parse(time.RFC3339, value); err == nil {
-				backup.Timestamp = t
-			}
-		}
-	}
-}
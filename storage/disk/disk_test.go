@@ -3,9 +3,14 @@ package disk_test
 import (
 	"bytes"
 	"context"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"goarchive/core"
@@ -76,6 +81,47 @@ func TestProvider_Upload(t *testing.T) {
 	}
 }
 
+func TestProvider_UploadFailure(t *testing.T) {
+	dir := t.TempDir()
+	provider, err := disk.New(&core.StorageConfig{Type: "disk", Path: dir})
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	ctx := context.Background()
+
+	metadata := &core.BackupMetadata{DatabaseName: "testdb", DatabaseType: "postgres", Timestamp: time.Now()}
+	if err := provider.Upload(ctx, strings.NewReader("complete backup"), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	// A failed upload neither replaces the backup nor leaves files behind
+	reader := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("dump failed")))
+	if err := provider.Upload(ctx, reader, metadata); err == nil || !strings.Contains(err.Error(), "dump failed") {
+		t.Errorf("expected the read failure, got %v", err)
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %d", len(backups))
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("expected the backup and its metadata only, got %d files", len(entries))
+	}
+
+	downloaded, err := provider.Download(ctx, backups[0].ID)
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	defer downloaded.Close()
+	if data, _ := io.ReadAll(downloaded); string(data) != "complete backup" {
+		t.Errorf("expected the earlier backup to be kept, got %q", data)
+	}
+}
+
 func TestProvider_List(t *testing.T) {
 	tmpDir := filepath.Join(os.TempDir(), "goarchive-list-test")
 	defer os.RemoveAll(tmpDir)
@@ -132,6 +178,123 @@ func TestProvider_List(t *testing.T) {
 	}
 }
 
+func TestProvider_ListBackupMode(t *testing.T) {
+	config := &core.StorageConfig{
+		Type: "disk",
+		Path: t.TempDir(),
+	}
+
+	provider, err := disk.New(config)
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+
+	ctx := context.Background()
+	metadata := &core.BackupMetadata{
+		DatabaseName: "testdb",
+		DatabaseType: "postgres",
+		BackupMode:   "physical",
+		Timestamp:    time.Now(),
+	}
+
+	if err := provider.Upload(ctx, bytes.NewReader([]byte("base backup")), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %d", len(backups))
+	}
+	if backups[0].BackupMode != "physical" {
+		t.Errorf("expected BackupMode 'physical', got '%s'", backups[0].BackupMode)
+	}
+}
+
+func TestProvider_ListTags(t *testing.T) {
+	provider, err := disk.New(&core.StorageConfig{Type: "disk", Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+
+	ctx := context.Background()
+	metadata := &core.BackupMetadata{
+		DatabaseName: "cache",
+		DatabaseType: "redis",
+		Timestamp:    time.Now(),
+		Tags:         map[string]string{"rdb-version": "11", "redis-version": "7.2.4"},
+	}
+
+	if err := provider.Upload(ctx, bytes.NewReader([]byte("REDIS0011")), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %d", len(backups))
+	}
+	if !reflect.DeepEqual(backups[0].Tags, metadata.Tags) {
+		t.Errorf("expected Tags %v, got %v", metadata.Tags, backups[0].Tags)
+	}
+}
+
+func TestProvider_UploadWithKey(t *testing.T) {
+	config := &core.StorageConfig{
+		Type: "disk",
+		Path: t.TempDir(),
+	}
+
+	provider, err := disk.New(config)
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+
+	ctx := context.Background()
+	metadata := &core.BackupMetadata{
+		ID:        "000000010000000000000001",
+		Key:       "wal/00000001/000000010000000000000001.gz",
+		Timestamp: time.Now(),
+	}
+
+	if err := provider.Upload(ctx, bytes.NewReader([]byte("wal data")), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	reader, err := provider.Download(ctx, metadata.Key)
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	defer reader.Close()
+
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(reader); err != nil {
+		t.Fatalf("failed to read download: %v", err)
+	}
+	if buf.String() != "wal data" {
+		t.Errorf("expected 'wal data', got %q", buf.String())
+	}
+
+	// Backups stored under nested keys are not listed as regular backups
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("expected 0 listed backups, got %d", len(backups))
+	}
+
+	// Keys cannot escape the storage directory
+	metadata.Key = "../outside.dump"
+	if err := provider.Upload(ctx, bytes.NewReader([]byte("x")), metadata); err == nil {
+		t.Error("expected error for key outside storage directory, got nil")
+	}
+}
+
 func TestProvider_Download(t *testing.T) {
 	tmpDir := filepath.Join(os.TempDir(), "goarchive-download-test")
 	defer os.RemoveAll(tmpDir)
@@ -248,6 +411,151 @@ func TestProvider_Delete(t *testing.T) {
 	}
 }
 
+func TestProvider_Tier(t *testing.T) {
+	tmpDir := t.TempDir()
+	config := &core.StorageConfig{
+		Type:        "disk",
+		Path:        filepath.Join(tmpDir, "backups"),
+		ArchivePath: filepath.Join(tmpDir, "archive"),
+	}
+
+	provider, err := disk.New(config)
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+
+	ctx := context.Background()
+	testData := []byte("test backup data for tiering")
+	metadata := &core.BackupMetadata{
+		DatabaseName: "testdb",
+		DatabaseType: "postgres",
+		Timestamp:    time.Date(2024, 2, 15, 12, 0, 0, 0, time.UTC),
+		Tags:         map[string]string{"env": "prod"},
+	}
+	if err := provider.Upload(ctx, bytes.NewReader(testData), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	backupID := "testdb_postgres_20240215-120000.dump"
+
+	listTier := func() *core.BackupMetadata {
+		backups, err := provider.List(ctx)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if len(backups) != 1 {
+			t.Fatalf("expected 1 backup, got %d", len(backups))
+		}
+		return backups[0]
+	}
+
+	if tier := listTier().Tier; tier != disk.TierStandard {
+		t.Errorf("expected tier %q, got %q", disk.TierStandard, tier)
+	}
+
+	if err := provider.Tier(ctx, backupID, "ARCHIVE"); err != nil {
+		t.Fatalf("Tier() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.ArchivePath, backupID+".meta")); err != nil {
+		t.Errorf("expected the metadata file to move: %v", err)
+	}
+
+	backup := listTier()
+	if backup.Tier != disk.TierArchive || backup.Tags["env"] != "prod" {
+		t.Errorf("expected an archived backup with its tags, got %+v", backup)
+	}
+
+	// Moving to the current tier does nothing
+	if err := provider.Tier(ctx, backupID, disk.TierArchive); err != nil {
+		t.Errorf("Tier() error = %v", err)
+	}
+
+	reader, err := provider.Download(ctx, backupID)
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(reader)
+	reader.Close()
+	if !bytes.Equal(buf.Bytes(), testData) {
+		t.Error("downloaded data doesn't match")
+	}
+
+	if err := provider.Tier(ctx, backupID, disk.TierStandard); err != nil {
+		t.Fatalf("Tier() error = %v", err)
+	}
+	if tier := listTier().Tier; tier != disk.TierStandard {
+		t.Errorf("expected tier %q, got %q", disk.TierStandard, tier)
+	}
+
+	if err := provider.Tier(ctx, backupID, "GLACIER"); err == nil {
+		t.Error("expected an error for an unknown tier")
+	}
+	if err := provider.Tier(ctx, "missing.dump", disk.TierArchive); err == nil {
+		t.Error("expected an error for a missing backup")
+	}
+
+	if err := provider.Tier(ctx, backupID, disk.TierArchive); err != nil {
+		t.Fatalf("Tier() error = %v", err)
+	}
+	if err := provider.Delete(ctx, backupID); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if backups, _ := provider.List(ctx); len(backups) != 0 {
+		t.Errorf("expected 0 backups after deletion, got %d", len(backups))
+	}
+}
+
+func TestProvider_TierAcrossFilesystems(t *testing.T) {
+	archivePath, err := os.MkdirTemp("/dev/shm", "goarchive-archive-")
+	if err != nil {
+		t.Skipf("no second filesystem available: %v", err)
+	}
+	defer os.RemoveAll(archivePath)
+
+	tmpDir := t.TempDir()
+	probe := filepath.Join(tmpDir, "probe")
+	if err := os.WriteFile(probe, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(probe, filepath.Join(archivePath, "probe")); err == nil {
+		t.Skip("the archive directory is on the same filesystem")
+	}
+
+	provider, err := disk.New(&core.StorageConfig{Type: "disk", Path: tmpDir, ArchivePath: archivePath})
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	ctx := context.Background()
+
+	metadata := &core.BackupMetadata{DatabaseName: "testdb", DatabaseType: "postgres", Timestamp: time.Date(2024, 2, 15, 12, 0, 0, 0, time.UTC)}
+	if err := provider.Upload(ctx, strings.NewReader("cross-device data"), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	backupID := "testdb_postgres_20240215-120000.dump"
+
+	if err := provider.Tier(ctx, backupID, disk.TierArchive); err != nil {
+		t.Fatalf("Tier() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, backupID)); !os.IsNotExist(err) {
+		t.Errorf("expected the source to be removed, got %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(archivePath, backupID))
+	if err != nil || string(data) != "cross-device data" {
+		t.Errorf("expected the backup to be copied, got %q (%v)", data, err)
+	}
+}
+
+func TestProvider_TierWithoutArchivePath(t *testing.T) {
+	provider, err := disk.New(&core.StorageConfig{Type: "disk", Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+
+	if err := provider.Tier(context.Background(), "backup.dump", disk.TierArchive); err == nil {
+		t.Error("expected an error without an archive path")
+	}
+}
+
 func TestProvider_AutoRegistration(t *testing.T) {
 	// The disk provider should automatically register itself
 	ctx := context.Background()
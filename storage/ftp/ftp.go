@@ -0,0 +1,497 @@
+package ftp
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+
+	"goarchive/core"
+)
+
+const (
+	defaultPort = "21"
+
+	// defaultImplicitTLSPort is the port of FTPS servers using implicit TLS
+	defaultImplicitTLSPort = "990"
+
+	// dialTimeout bounds connecting to the server, including data connections
+	dialTimeout = 30 * time.Second
+
+	// maxManifestSize bounds the manifests read when listing backups
+	maxManifestSize = 1 << 20
+)
+
+// init registers the FTP provider with the global registry
+func init() {
+	core.RegisterStorage("ftp", func(ctx context.Context, config *core.StorageConfig) (core.StorageProvider, error) {
+		return New(ctx, config)
+	})
+}
+
+// Provider implements the StorageProvider interface for FTP and FTPS servers
+type Provider struct {
+	config  *core.StorageConfig
+	addr    string
+	options []ftp.DialOption
+	path    string
+
+	// mu guards the control connection, which handles one command at a time
+	mu   sync.Mutex
+	conn *ftp.ServerConn
+	dirs map[string]bool
+}
+
+// New connects to the FTP server at config.Endpoint and creates the backup
+// directory config.Path. The endpoint's scheme selects the protocol:
+// "ftp://" (or no scheme) for plain FTP, "ftpes://" for explicit TLS with
+// AUTH TLS and "ftps://" for implicit TLS. Data connections use passive
+// mode.
+func New(ctx context.Context, config *core.StorageConfig) (*Provider, error) {
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("storage endpoint is required for FTP storage")
+	}
+
+	addr, scheme, err := parseEndpoint(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []ftp.DialOption{ftp.DialWithTimeout(dialTimeout)}
+	if scheme != "ftp" {
+		host, _, _ := net.SplitHostPort(addr)
+		tlsConfig, err := newTLSConfig(host, config.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		if scheme == "ftpes" {
+			options = append(options, ftp.DialWithExplicitTLS(tlsConfig))
+		} else {
+			options = append(options, ftp.DialWithTLS(tlsConfig))
+		}
+	}
+
+	dir := config.Path
+	if dir == "" {
+		dir = "backups"
+	}
+
+	p := &Provider{
+		config:  config,
+		addr:    addr,
+		options: options,
+		path:    path.Clean(dir),
+		dirs:    make(map[string]bool),
+	}
+
+	conn, err := p.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.mkdirAll(conn, p.path); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	return p, nil
+}
+
+// parseEndpoint returns the host and port to connect to and the endpoint's
+// scheme, adding the scheme's default port
+func parseEndpoint(endpoint string) (addr, scheme string, err error) {
+	scheme = "ftp"
+	if before, after, ok := strings.Cut(endpoint, "://"); ok {
+		scheme, endpoint = strings.ToLower(before), after
+	}
+
+	port := defaultPort
+	switch scheme {
+	case "ftp", "ftpes":
+	case "ftps":
+		port = defaultImplicitTLSPort
+	default:
+		return "", "", fmt.Errorf("unsupported FTP endpoint scheme %q, use ftp, ftpes or ftps", scheme)
+	}
+
+	endpoint, _, _ = strings.Cut(endpoint, "/")
+	if endpoint == "" {
+		return "", "", fmt.Errorf("storage endpoint has no host")
+	}
+	if _, _, err := net.SplitHostPort(endpoint); err == nil {
+		return endpoint, scheme, nil
+	}
+	return net.JoinHostPort(strings.Trim(endpoint, "[]"), port), scheme, nil
+}
+
+// newTLSConfig returns the TLS settings verifying the server as host,
+// against caFile when set. Sessions are cached so data connections can
+// resume the control connection's session, which many servers require.
+func newTLSConfig(host, caFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		MinVersion:         tls.VersionTLS12,
+		ClientSessionCache: tls.NewLRUClientSessionCache(0),
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// connection returns the control connection, reconnecting if the server
+// closed it, as servers do with idle connections. The caller holds p.mu.
+func (p *Provider) connection(ctx context.Context) (*ftp.ServerConn, error) {
+	if p.conn != nil {
+		if err := p.conn.NoOp(); err == nil {
+			return p.conn, nil
+		}
+		p.conn.Quit()
+		p.conn = nil
+	}
+
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.conn = conn
+	return conn, nil
+}
+
+// dial connects and logs in to the server, anonymously without a username
+func (p *Provider) dial(ctx context.Context) (*ftp.ServerConn, error) {
+	options := append(slices.Clip(p.options), ftp.DialWithContext(ctx))
+	conn, err := ftp.Dial(p.addr, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to FTP server: %w", err)
+	}
+
+	username, password := p.config.Username, p.config.Password
+	if username == "" {
+		username, password = "anonymous", "anonymous"
+	}
+	if err := conn.Login(username, password); err != nil {
+		conn.Quit()
+		return nil, fmt.Errorf("failed to log in to FTP server: %w", err)
+	}
+
+	return conn, nil
+}
+
+// mkdirAll creates dir and its parents. Servers report existing
+// directories like other failures, so a directory counts as created when
+// it can be listed.
+func (p *Provider) mkdirAll(conn *ftp.ServerConn, dir string) error {
+	current := ""
+	if path.IsAbs(dir) {
+		current = "/"
+	}
+
+	for _, segment := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if segment == "" || segment == "." {
+			continue
+		}
+		current = path.Join(current, segment)
+		if p.dirs[current] {
+			continue
+		}
+
+		if err := conn.MakeDir(current); err != nil {
+			if _, listErr := conn.List(current); listErr != nil {
+				return err
+			}
+		}
+		p.dirs[current] = true
+	}
+
+	return nil
+}
+
+// Upload streams the backup to a temporary file next to its final name
+// and renames it into place once complete, with its manifest alongside
+func (p *Provider) Upload(ctx context.Context, reader io.Reader, metadata *core.BackupMetadata) error {
+	fullPath, err := p.resolve(p.getBackupFilename(metadata))
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conn, err := p.connection(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Keys may place backups in subdirectories
+	if err := p.mkdirAll(conn, path.Dir(fullPath)); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	temp := path.Join(path.Dir(fullPath), fmt.Sprintf(".%s.tmp-%d", path.Base(fullPath), time.Now().UnixNano()))
+	data := &hashingReader{r: &contextReader{ctx: ctx, r: reader}, hash: md5.New()}
+	if err := conn.Stor(temp, data); err != nil {
+		// The server keeps what it received, so remove the partial file
+		conn.Delete(temp)
+		if data.err != nil {
+			return fmt.Errorf("failed to read backup data: %w", data.err)
+		}
+		return fmt.Errorf("failed to upload backup file: %w", err)
+	}
+
+	metadata.Checksum = hex.EncodeToString(data.hash.Sum(nil))
+	metadata.Size = data.n
+
+	// The manifest goes first, so a listed backup always has one
+	manifestPath := fullPath + ".meta"
+	if err := conn.Stor(manifestPath, strings.NewReader(core.FormatManifest(metadata))); err != nil {
+		conn.Delete(temp)
+		return fmt.Errorf("failed to upload manifest: %w", err)
+	}
+	if err := rename(conn, temp, fullPath); err != nil {
+		conn.Delete(temp)
+		conn.Delete(manifestPath)
+		return fmt.Errorf("failed to rename backup file: %w", err)
+	}
+
+	return nil
+}
+
+// rename moves oldname to newname. Some servers refuse to rename onto an
+// existing file, so newname is removed and the rename retried.
+func rename(conn *ftp.ServerConn, oldname, newname string) error {
+	err := conn.Rename(oldname, newname)
+	if err == nil {
+		return nil
+	}
+	if conn.Delete(newname) != nil {
+		return err
+	}
+	return conn.Rename(oldname, newname)
+}
+
+// List lists available backups in the remote directory, using MLSD when
+// the server supports it and LIST otherwise
+func (p *Provider) List(ctx context.Context) ([]*core.BackupMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conn, err := p.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := conn.List(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup directory: %w", err)
+	}
+
+	var backups []*core.BackupMetadata
+	for _, entry := range entries {
+		// LIST output of some servers includes the directory
+		name := path.Base(entry.Name)
+
+		// Skip directories, manifests and uploads in progress
+		if entry.Type != ftp.EntryTypeFile || !strings.HasSuffix(name, ".dump") {
+			continue
+		}
+
+		backup := &core.BackupMetadata{
+			ID:        name,
+			Timestamp: entry.Time,
+			Size:      int64(entry.Size),
+		}
+		if manifest, err := readManifest(conn, path.Join(p.path, name+".meta")); err == nil {
+			core.ParseManifest(backup, manifest)
+		}
+
+		backups = append(backups, backup)
+	}
+
+	// Sort by timestamp descending (newest first)
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Timestamp.After(backups[j].Timestamp)
+	})
+
+	return backups, nil
+}
+
+// readManifest reads the manifest at name
+func readManifest(conn *ftp.ServerConn, name string) (string, error) {
+	resp, err := conn.Retr(name)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp, maxManifestSize))
+	return string(data), err
+}
+
+// Download reads a backup from the FTP server. A connection handles one
+// transfer at a time, so each download uses its own connection, closed
+// with the returned reader.
+func (p *Provider) Download(ctx context.Context, backupID string) (io.ReadCloser, error) {
+	fullPath, err := p.resolve(backupID)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := conn.Retr(fullPath)
+	if err != nil {
+		conn.Quit()
+		if isNotFound(err) {
+			return nil, fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+		}
+		return nil, fmt.Errorf("failed to download backup file: %w", err)
+	}
+
+	return &download{Response: resp, conn: conn}, nil
+}
+
+// Delete removes a backup and its manifest from the FTP server
+func (p *Provider) Delete(ctx context.Context, backupID string) error {
+	fullPath, err := p.resolve(backupID)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conn, err := p.connection(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := conn.Delete(fullPath); err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+		}
+		return fmt.Errorf("failed to delete backup file: %w", err)
+	}
+
+	// Try to delete the manifest too (non-fatal if it doesn't exist)
+	conn.Delete(fullPath + ".meta")
+
+	return nil
+}
+
+// Close logs out and closes the control connection
+func (p *Provider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Quit()
+	p.conn = nil
+	return err
+}
+
+// isNotFound reports whether the server refused a command because the file
+// does not exist
+func isNotFound(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code == ftp.StatusFileUnavailable
+}
+
+// resolve returns the remote path of a backup, rejecting names that leave
+// the backup directory
+func (p *Provider) resolve(name string) (string, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid backup key: %s", name)
+	}
+	return path.Join(p.path, clean), nil
+}
+
+// getBackupFilename generates the filename for a backup
+func (p *Provider) getBackupFilename(metadata *core.BackupMetadata) string {
+	if metadata.Key != "" {
+		return metadata.Key
+	}
+	return fmt.Sprintf("%s_%s_%s.dump",
+		metadata.DatabaseName,
+		metadata.DatabaseType,
+		metadata.Timestamp.Format("20060102-150405"),
+	)
+}
+
+// download closes its connection along with the transfer
+type download struct {
+	*ftp.Response
+	conn *ftp.ServerConn
+}
+
+// Close ends the transfer and logs out
+func (d *download) Close() error {
+	err := d.Response.Close()
+	d.conn.Quit()
+	return err
+}
+
+// hashingReader hashes and counts the data read through it, and records
+// the reader's error to tell it apart from transfer errors
+type hashingReader struct {
+	r    io.Reader
+	hash hash.Hash
+	n    int64
+	err  error
+}
+
+// Read reads from the underlying reader
+func (r *hashingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.hash.Write(p[:n])
+	r.n += int64(n)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// contextReader stops reading once its context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read reads from the underlying reader unless the context is done
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,513 @@
+package ftp_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/spf13/afero"
+
+	"goarchive/core"
+	"goarchive/storage/ftp"
+)
+
+const (
+	testUser     = "partner"
+	testPassword = "s3cret"
+)
+
+// testServer is an in-process FTP server storing files in a temporary
+// directory and recording the commands it receives
+type testServer struct {
+	settings  *ftpserver.Settings
+	tlsConfig *tls.Config
+	fs        afero.Fs
+
+	dir      string
+	addr     string
+	caFile   string
+	commands *recorder
+}
+
+// GetSettings returns the server settings
+func (s *testServer) GetSettings() (*ftpserver.Settings, error) {
+	return s.settings, nil
+}
+
+// ClientConnected returns the welcome message
+func (s *testServer) ClientConnected(cc ftpserver.ClientContext) (string, error) {
+	return "goarchive test server", nil
+}
+
+// ClientDisconnected does nothing
+func (s *testServer) ClientDisconnected(cc ftpserver.ClientContext) {}
+
+// AuthUser accepts testUser and anonymous logins
+func (s *testServer) AuthUser(cc ftpserver.ClientContext, user, pass string) (ftpserver.ClientDriver, error) {
+	if (user == testUser && pass == testPassword) || user == "anonymous" {
+		return s.fs, nil
+	}
+	return nil, errors.New("invalid credentials")
+}
+
+// GetTLSConfig returns the server's certificate
+func (s *testServer) GetTLSConfig() (*tls.Config, error) {
+	return s.tlsConfig, nil
+}
+
+// recorder records what clients send on the control connection
+type recorder struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (r *recorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.String()
+}
+
+type recordingListener struct {
+	net.Listener
+	recorder *recorder
+}
+
+func (l *recordingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &recordingConn{Conn: conn, recorder: l.recorder}, nil
+}
+
+type recordingConn struct {
+	net.Conn
+	recorder *recorder
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.recorder.mu.Lock()
+	c.recorder.buf.Write(p[:n])
+	c.recorder.mu.Unlock()
+	return n, err
+}
+
+// startServer runs an in-process FTP server with the given TLS mode
+func startServer(t *testing.T, tlsMode ftpserver.TLSRequirement, configure func(*ftpserver.Settings)) *testServer {
+	t.Helper()
+
+	s := &testServer{
+		settings: &ftpserver.Settings{TLSRequired: tlsMode},
+		dir:      t.TempDir(),
+		commands: &recorder{},
+	}
+	s.fs = afero.NewBasePathFs(afero.NewOsFs(), s.dir)
+	s.tlsConfig, s.caFile = newCertificate(t)
+	if configure != nil {
+		configure(s.settings)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s.addr = listener.Addr().String()
+
+	var wrapped net.Listener = &recordingListener{Listener: listener, recorder: s.commands}
+	if tlsMode == ftpserver.ImplicitEncryption {
+		wrapped = tls.NewListener(wrapped, s.tlsConfig)
+	}
+	s.settings.Listener = wrapped
+
+	server := ftpserver.NewFtpServer(s)
+	if err := server.Listen(); err != nil {
+		t.Fatalf("failed to start FTP server: %v", err)
+	}
+	go server.Serve()
+	t.Cleanup(func() { server.Stop() })
+
+	return s
+}
+
+// newCertificate creates a self-signed certificate for 127.0.0.1, returning
+// the server's TLS settings and a CA file trusting it
+func newCertificate(t *testing.T) (*tls.Config, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "goarchive test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write CA file: %v", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}, caFile
+}
+
+func newProvider(t *testing.T, config *core.StorageConfig) *ftp.Provider {
+	t.Helper()
+
+	provider, err := ftp.New(context.Background(), config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { provider.Close() })
+	return provider
+}
+
+// listFiles returns the files below dir, relative to it
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to list files: %v", err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	server := startServer(t, ftpserver.ClearOrEncrypted, nil)
+
+	tests := []struct {
+		name   string
+		config *core.StorageConfig
+	}{
+		{name: "missing endpoint", config: &core.StorageConfig{Username: testUser, Password: testPassword}},
+		{name: "unsupported scheme", config: &core.StorageConfig{Endpoint: "sftp://" + server.addr}},
+		{name: "wrong password", config: &core.StorageConfig{Endpoint: server.addr, Username: testUser, Password: "wrong"}},
+		{name: "unknown user", config: &core.StorageConfig{Endpoint: server.addr, Username: "mallory", Password: testPassword}},
+		{name: "untrusted certificate", config: &core.StorageConfig{Endpoint: "ftpes://" + server.addr, Username: testUser, Password: testPassword}},
+		{name: "missing CA file", config: &core.StorageConfig{Endpoint: "ftpes://" + server.addr, CACertFile: filepath.Join(t.TempDir(), "missing.pem")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if provider, err := ftp.New(context.Background(), tt.config); err == nil {
+				provider.Close()
+				t.Error("New() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	server := startServer(t, ftpserver.ClearOrEncrypted, nil)
+
+	provider, err := core.GetStorage(context.Background(), "ftp", &core.StorageConfig{
+		Endpoint: "ftp://" + server.addr,
+		Username: testUser,
+		Password: testPassword,
+		Path:     "backups",
+	})
+	if err != nil {
+		t.Fatalf("GetStorage() error = %v", err)
+	}
+	defer provider.(io.Closer).Close()
+
+	if _, err := os.Stat(filepath.Join(server.dir, "backups")); err != nil {
+		t.Errorf("expected the backup directory to be created: %v", err)
+	}
+}
+
+func TestUploadListDownloadDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		tlsMode  ftpserver.TLSRequirement
+		scheme   string
+		disable  bool
+		wantList string
+	}{
+		{name: "plain FTP with MLSD", tlsMode: ftpserver.ClearOrEncrypted, scheme: "ftp://", wantList: "MLSD"},
+		{name: "plain FTP with LIST", tlsMode: ftpserver.ClearOrEncrypted, scheme: "", disable: true, wantList: "LIST"},
+		{name: "explicit TLS", tlsMode: ftpserver.MandatoryEncryption, scheme: "ftpes://"},
+		{name: "implicit TLS", tlsMode: ftpserver.ImplicitEncryption, scheme: "ftps://"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startServer(t, tt.tlsMode, func(settings *ftpserver.Settings) {
+				settings.DisableMLSD = tt.disable
+				settings.DisableMLST = tt.disable
+			})
+			provider := newProvider(t, &core.StorageConfig{
+				Endpoint:   tt.scheme + server.addr,
+				Username:   testUser,
+				Password:   testPassword,
+				CACertFile: server.caFile,
+				Path:       "partners/acme/backups",
+			})
+			ctx := context.Background()
+
+			data := bytes.Repeat([]byte("backup data "), 100000)
+			metadata := &core.BackupMetadata{
+				ID:           "20260215-103020",
+				DatabaseName: "orders",
+				DatabaseType: "postgres",
+				BackupMode:   "logical",
+				Timestamp:    time.Date(2026, 2, 15, 10, 30, 20, 0, time.UTC),
+				Tags:         map[string]string{"server-version": "16.2"},
+			}
+			if err := provider.Upload(ctx, bytes.NewReader(data), metadata); err != nil {
+				t.Fatalf("Upload() error = %v", err)
+			}
+
+			sum := md5.Sum(data)
+			if metadata.Checksum != hex.EncodeToString(sum[:]) {
+				t.Errorf("Checksum = %s, want %s", metadata.Checksum, hex.EncodeToString(sum[:]))
+			}
+			if metadata.Size != int64(len(data)) {
+				t.Errorf("Size = %d, want %d", metadata.Size, len(data))
+			}
+
+			// No temporary files are left behind
+			files := listFiles(t, server.dir)
+			want := []string{
+				"partners/acme/backups/orders_postgres_20260215-103020.dump",
+				"partners/acme/backups/orders_postgres_20260215-103020.dump.meta",
+			}
+			if strings.Join(files, ",") != strings.Join(want, ",") {
+				t.Errorf("files = %v, want %v", files, want)
+			}
+
+			// Files in subdirectories, such as WAL archives, are not listed
+			wal := &core.BackupMetadata{Key: "wal/00000001/000000010000000000000001.gz"}
+			if err := provider.Upload(ctx, strings.NewReader("wal"), wal); err != nil {
+				t.Fatalf("Upload() error = %v", err)
+			}
+
+			backups, err := provider.List(ctx)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(backups) != 1 {
+				t.Fatalf("expected 1 backup, got %d", len(backups))
+			}
+			if tt.wantList != "" && !strings.Contains(server.commands.String(), tt.wantList+" ") {
+				t.Errorf("expected the backups to be listed with %s", tt.wantList)
+			}
+
+			backup := backups[0]
+			if backup.ID != "orders_postgres_20260215-103020.dump" {
+				t.Errorf("ID = %s", backup.ID)
+			}
+			if backup.DatabaseName != "orders" || backup.DatabaseType != "postgres" || backup.BackupMode != "logical" {
+				t.Errorf("unexpected metadata: %+v", backup)
+			}
+			if !backup.Timestamp.Equal(metadata.Timestamp) {
+				t.Errorf("Timestamp = %v, want %v", backup.Timestamp, metadata.Timestamp)
+			}
+			if backup.Checksum != metadata.Checksum || backup.Size != metadata.Size {
+				t.Errorf("listed checksum %s and size %d, want %s and %d", backup.Checksum, backup.Size, metadata.Checksum, metadata.Size)
+			}
+			if backup.Tags["server-version"] != "16.2" {
+				t.Errorf("expected tags to round-trip, got %v", backup.Tags)
+			}
+
+			reader, err := provider.Download(ctx, backup.ID)
+			if err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+
+			// Downloads have their own connection, so other operations
+			// can run while a backup is read
+			if _, err := provider.List(ctx); err != nil {
+				t.Errorf("List() during a download error = %v", err)
+			}
+
+			downloaded, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("failed to read download: %v", err)
+			}
+			if err := reader.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+			if !bytes.Equal(downloaded, data) {
+				t.Error("downloaded data differs from the upload")
+			}
+
+			if err := provider.Delete(ctx, backup.ID); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			files = listFiles(t, server.dir)
+			if len(files) != 2 || !strings.Contains(files[0], "/wal/") || !strings.Contains(files[1], "/wal/") {
+				t.Errorf("expected the backup and its manifest to be deleted, got %v", files)
+			}
+			if err := provider.Delete(ctx, backup.ID); err == nil || !strings.Contains(err.Error(), "backup not found") {
+				t.Errorf("expected not found error deleting a missing backup, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUpload_ReplacesExistingBackup(t *testing.T) {
+	server := startServer(t, ftpserver.ClearOrEncrypted, nil)
+	provider := newProvider(t, &core.StorageConfig{Endpoint: server.addr, Username: testUser, Password: testPassword})
+	ctx := context.Background()
+
+	for _, content := range []string{"first", "second"} {
+		metadata := &core.BackupMetadata{Key: "latest.dump", BackupMode: content}
+		if err := provider.Upload(ctx, strings.NewReader(content), metadata); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(server.dir, "backups", "latest.dump"))
+	if err != nil {
+		t.Fatalf("failed to read backup: %v", err)
+	}
+	if string(data) != "second" {
+		t.Errorf("backup = %q, want %q", data, "second")
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 || backups[0].BackupMode != "second" {
+		t.Errorf("expected the manifest of the second upload, got %+v", backups)
+	}
+}
+
+// failingReader returns some data and then an error
+type failingReader struct {
+	sent bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if !r.sent {
+		r.sent = true
+		return copy(p, "partial backup"), nil
+	}
+	return 0, errors.New("database connection lost")
+}
+
+func TestUpload_FailedBackupLeavesNoFile(t *testing.T) {
+	server := startServer(t, ftpserver.ClearOrEncrypted, nil)
+	provider := newProvider(t, &core.StorageConfig{Endpoint: server.addr, Username: testUser, Password: testPassword})
+
+	metadata := &core.BackupMetadata{DatabaseName: "orders", DatabaseType: "postgres", Timestamp: time.Now()}
+	err := provider.Upload(context.Background(), &failingReader{}, metadata)
+	if err == nil || !strings.Contains(err.Error(), "database connection lost") {
+		t.Fatalf("expected the read error, got %v", err)
+	}
+
+	if files := listFiles(t, server.dir); len(files) != 0 {
+		t.Errorf("expected no files after a failed upload, got %v", files)
+	}
+
+	// The connection is still usable
+	if _, err := provider.List(context.Background()); err != nil {
+		t.Errorf("List() error = %v", err)
+	}
+}
+
+func TestProvider_ReconnectsAfterIdleTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping idle timeout test in short mode")
+	}
+
+	server := startServer(t, ftpserver.ClearOrEncrypted, func(settings *ftpserver.Settings) {
+		settings.IdleTimeout = 1
+	})
+	provider := newProvider(t, &core.StorageConfig{Endpoint: server.addr, Username: testUser, Password: testPassword})
+
+	// The server closes the control connection while it is idle
+	time.Sleep(2 * time.Second)
+
+	metadata := &core.BackupMetadata{DatabaseName: "orders", DatabaseType: "postgres", Timestamp: time.Now()}
+	if err := provider.Upload(context.Background(), strings.NewReader("data"), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+}
+
+func TestAnonymousLogin(t *testing.T) {
+	server := startServer(t, ftpserver.ClearOrEncrypted, nil)
+	provider := newProvider(t, &core.StorageConfig{Endpoint: server.addr, Path: "/incoming"})
+
+	metadata := &core.BackupMetadata{Key: "orders.dump"}
+	if err := provider.Upload(context.Background(), strings.NewReader("data"), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(server.dir, "incoming", "orders.dump")); err != nil {
+		t.Errorf("expected the backup on the server: %v", err)
+	}
+	if !strings.Contains(server.commands.String(), "USER anonymous") {
+		t.Error("expected an anonymous login")
+	}
+}
+
+func TestInvalidBackupID(t *testing.T) {
+	server := startServer(t, ftpserver.ClearOrEncrypted, nil)
+	provider := newProvider(t, &core.StorageConfig{Endpoint: server.addr, Username: testUser, Password: testPassword})
+	ctx := context.Background()
+
+	for _, id := range []string{"../outside.dump", "/etc/passwd", ".."} {
+		if _, err := provider.Download(ctx, id); err == nil {
+			t.Errorf("Download(%q) expected error, got nil", id)
+		}
+		if err := provider.Delete(ctx, id); err == nil {
+			t.Errorf("Delete(%q) expected error, got nil", id)
+		}
+	}
+}
+
+func TestDownload_Missing(t *testing.T) {
+	server := startServer(t, ftpserver.ClearOrEncrypted, nil)
+	provider := newProvider(t, &core.StorageConfig{Endpoint: server.addr, Username: testUser, Password: testPassword})
+
+	_, err := provider.Download(context.Background(), "missing.dump")
+	if err == nil || !strings.Contains(err.Error(), "backup not found") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
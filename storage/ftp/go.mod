@@ -0,0 +1,17 @@
+module goarchive/storage/ftp
+
+go 1.24.0
+
+require (
+	github.com/fclairamb/ftpserverlib v0.29.0
+	github.com/jlaffaye/ftp v0.2.4
+	github.com/spf13/afero v1.15.0
+	goarchive v0.0.0
+)
+
+require (
+	golang.org/x/sys v0.40.0 // indirect
+	golang.org/x/text v0.28.0 // indirect
+)
+
+replace goarchive => ../../
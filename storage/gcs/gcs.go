@@ -0,0 +1,268 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+
+	"goarchive/core"
+)
+
+// chunkSize is the size of each request of a resumable upload, and the
+// amount of backup data buffered in memory
+const chunkSize = 16 << 20
+
+// init registers the GCS provider with the global registry
+func init() {
+	core.RegisterStorage("gcs", func(ctx context.Context, config *core.StorageConfig) (core.StorageProvider, error) {
+		return New(ctx, config)
+	})
+}
+
+// Provider implements the StorageProvider interface for Google Cloud
+// Storage
+type Provider struct {
+	client *storage.Client
+	bucket *storage.BucketHandle
+	config *core.StorageConfig
+}
+
+// New creates a new GCS provider. Credentials come from the service account
+// key in config.CredentialsFile, or from Application Default Credentials.
+func New(ctx context.Context, config *core.StorageConfig) (*Provider, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("storage bucket is required for GCS storage")
+	}
+
+	var opts []option.ClientOption
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(config.CredentialsFile), "{"):
+		// The key itself, as passed through an environment variable
+		opts = append(opts, option.WithAuthCredentialsJSON(option.ServiceAccount, []byte(config.CredentialsFile)))
+	case config.CredentialsFile != "":
+		if _, err := os.Stat(config.CredentialsFile); err != nil {
+			return nil, fmt.Errorf("failed to read credentials file: %w", err)
+		}
+		opts = append(opts, option.WithAuthCredentialsFile(option.ServiceAccount, config.CredentialsFile))
+	case config.Endpoint != "":
+		// Emulators accept unauthenticated requests
+		opts = append(opts, option.WithoutAuthentication())
+	}
+
+	if config.Endpoint != "" {
+		endpoint, err := jsonEndpoint(config.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		// Emulators implement the JSON API, not the XML API used for reads
+		opts = append(opts, option.WithEndpoint(endpoint), storage.WithJSONReads())
+	}
+
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	return &Provider{
+		client: client,
+		bucket: client.Bucket(config.Bucket),
+		config: config,
+	}, nil
+}
+
+// jsonEndpoint returns the JSON API endpoint for a custom endpoint, adding
+// the API path when only a host is given, as for emulators
+func jsonEndpoint(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid storage endpoint: %s", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/storage/v1/"
+	}
+	return u.String(), nil
+}
+
+// Upload streams the backup to GCS with a resumable upload, verifying the
+// MD5 checksum computed by GCS against the data sent
+func (p *Provider) Upload(ctx context.Context, reader io.Reader, metadata *core.BackupMetadata) error {
+	key := p.getBackupKey(metadata)
+
+	// Cancelling the context is the only way to abandon an upload, so a
+	// failed backup never leaves a partial object
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := p.bucket.Object(key).NewWriter(ctx)
+	w.ChunkSize = chunkSize
+	w.ContentType = "application/octet-stream"
+	w.Metadata = objectMetadata(metadata)
+
+	hash := md5.New()
+	size, err := io.Copy(io.MultiWriter(w, hash), reader)
+	if err != nil {
+		cancel()
+		w.Close()
+		return fmt.Errorf("failed to read backup data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to upload to GCS: %w", err)
+	}
+
+	sum := hash.Sum(nil)
+	if attrs := w.Attrs(); attrs != nil && len(attrs.MD5) > 0 && !bytes.Equal(attrs.MD5, sum) {
+		p.bucket.Object(key).Delete(context.WithoutCancel(ctx))
+		return fmt.Errorf("failed to upload to GCS: checksum mismatch")
+	}
+
+	metadata.Checksum = hex.EncodeToString(sum)
+	metadata.Size = size
+
+	return nil
+}
+
+// List lists available backups, reading every page of results
+func (p *Provider) List(ctx context.Context) ([]*core.BackupMetadata, error) {
+	// The delimiter leaves out objects stored under nested keys (e.g. WAL
+	// archives)
+	it := p.bucket.Objects(ctx, &storage.Query{Prefix: p.listPrefix(), Delimiter: "/"})
+
+	var backups []*core.BackupMetadata
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		if attrs.Prefix != "" {
+			continue
+		}
+
+		backup := &core.BackupMetadata{
+			ID:        path.Base(attrs.Name),
+			Timestamp: attrs.Created,
+			Size:      attrs.Size,
+		}
+		applyObjectMetadata(backup, attrs.Metadata)
+		if len(attrs.MD5) > 0 {
+			backup.Checksum = hex.EncodeToString(attrs.MD5)
+		}
+
+		backups = append(backups, backup)
+	}
+
+	return backups, nil
+}
+
+// Download downloads a backup from GCS
+func (p *Provider) Download(ctx context.Context, backupID string) (io.ReadCloser, error) {
+	key := path.Join(p.config.Prefix, backupID)
+
+	reader, err := p.bucket.Object(key).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from GCS: %w", err)
+	}
+
+	return reader, nil
+}
+
+// Delete deletes a backup from GCS
+func (p *Provider) Delete(ctx context.Context, backupID string) error {
+	key := path.Join(p.config.Prefix, backupID)
+
+	err := p.bucket.Object(key).Delete(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete from GCS: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the GCS client
+func (p *Provider) Close() error {
+	return p.client.Close()
+}
+
+// listPrefix returns the prefix of the objects holding backups, which ends
+// with a slash unless backups are stored at the root of the bucket
+func (p *Provider) listPrefix() string {
+	prefix := strings.Trim(p.config.Prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// getBackupKey generates the object name for a backup
+func (p *Provider) getBackupKey(metadata *core.BackupMetadata) string {
+	if metadata.Key != "" {
+		return path.Join(p.config.Prefix, metadata.Key)
+	}
+	filename := fmt.Sprintf("%s_%s_%s.dump",
+		metadata.DatabaseName,
+		metadata.DatabaseType,
+		metadata.Timestamp.Format("20060102-150405"),
+	)
+	return path.Join(p.config.Prefix, filename)
+}
+
+// tagPrefix prefixes the custom metadata keys holding backup tags
+const tagPrefix = "tag-"
+
+// objectMetadata returns the custom metadata stored with a backup object.
+// The checksum is not known until the upload ends; GCS records it instead.
+func objectMetadata(metadata *core.BackupMetadata) map[string]string {
+	objectMeta := map[string]string{
+		"database-name": metadata.DatabaseName,
+		"database-type": metadata.DatabaseType,
+		"backup-id":     metadata.ID,
+		"timestamp":     metadata.Timestamp.Format(time.RFC3339),
+		"backup-mode":   metadata.BackupMode,
+	}
+	for key, value := range metadata.Tags {
+		objectMeta[tagPrefix+key] = value
+	}
+	return objectMeta
+}
+
+// applyObjectMetadata copies the custom metadata stored by Upload into
+// backup
+func applyObjectMetadata(backup *core.BackupMetadata, metadata map[string]string) {
+	backup.DatabaseName = metadata["database-name"]
+	backup.DatabaseType = metadata["database-type"]
+	backup.BackupMode = metadata["backup-mode"]
+
+	for key, value := range metadata {
+		if tag, ok := strings.CutPrefix(key, tagPrefix); ok {
+			if backup.Tags == nil {
+				backup.Tags = make(map[string]string)
+			}
+			backup.Tags[tag] = value
+		}
+	}
+
+	if t, err := time.Parse(time.RFC3339, metadata["timestamp"]); err == nil {
+		backup.Timestamp = t
+	}
+}
@@ -0,0 +1,247 @@
+package gcs_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+
+	"goarchive/core"
+	"goarchive/storage/gcs"
+)
+
+const testBucket = "test-backup-bucket"
+
+// startServer runs an in-process fake GCS server with an empty bucket and
+// returns its endpoint
+func startServer(t *testing.T) (*fakestorage.Server, string) {
+	t.Helper()
+
+	// The server must know its address to hand out resumable upload URLs
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	endpoint := fmt.Sprintf("http://127.0.0.1:%d", port)
+	server, err := fakestorage.NewServerWithOptions(fakestorage.Options{
+		Scheme:      "http",
+		Host:        "127.0.0.1",
+		Port:        uint16(port),
+		ExternalURL: endpoint,
+		Writer:      io.Discard,
+	})
+	if err != nil {
+		t.Fatalf("failed to start fake GCS server: %v", err)
+	}
+	t.Cleanup(server.Stop)
+
+	server.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: testBucket})
+	return server, endpoint
+}
+
+func newProvider(t *testing.T, endpoint, prefix string) *gcs.Provider {
+	t.Helper()
+
+	provider, err := gcs.New(context.Background(), &core.StorageConfig{
+		Type:     "gcs",
+		Bucket:   testBucket,
+		Endpoint: endpoint,
+		Prefix:   prefix,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { provider.Close() })
+	return provider
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *core.StorageConfig
+	}{
+		{name: "missing bucket", config: &core.StorageConfig{Type: "gcs", Endpoint: "http://localhost:4443"}},
+		{name: "missing credentials file", config: &core.StorageConfig{Type: "gcs", Bucket: testBucket, CredentialsFile: filepath.Join(t.TempDir(), "missing.json")}},
+		{name: "invalid credentials JSON", config: &core.StorageConfig{Type: "gcs", Bucket: testBucket, CredentialsFile: `{"type": "service_account"`}},
+		{name: "invalid endpoint", config: &core.StorageConfig{Type: "gcs", Bucket: testBucket, Endpoint: "localhost:4443"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := gcs.New(context.Background(), tt.config); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	provider, err := core.GetStorage(context.Background(), "gcs", &core.StorageConfig{
+		Bucket:   testBucket,
+		Endpoint: "http://localhost:4443",
+	})
+	if err != nil {
+		t.Fatalf("GetStorage() error = %v", err)
+	}
+	if provider == nil {
+		t.Error("expected non-nil provider")
+	}
+}
+
+func TestUploadListDownloadDelete(t *testing.T) {
+	server, endpoint := startServer(t)
+	provider := newProvider(t, endpoint, "backups/")
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("backup data "), 10000)
+	metadata := &core.BackupMetadata{
+		ID:           "20260215-103020",
+		DatabaseName: "orders",
+		DatabaseType: "postgres",
+		BackupMode:   "logical",
+		Timestamp:    time.Date(2026, 2, 15, 10, 30, 20, 0, time.UTC),
+		Tags:         map[string]string{"server-version": "16.2"},
+	}
+	if err := provider.Upload(ctx, bytes.NewReader(data), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	sum := md5.Sum(data)
+	if metadata.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("Checksum = %s, want %s", metadata.Checksum, hex.EncodeToString(sum[:]))
+	}
+	if metadata.Size != int64(len(data)) {
+		t.Errorf("Size = %d, want %d", metadata.Size, len(data))
+	}
+
+	// Objects below the backups, such as WAL archives, are not listed
+	server.CreateObject(fakestorage.Object{
+		ObjectAttrs: fakestorage.ObjectAttrs{BucketName: testBucket, Name: "backups/wal/00000001/000000010000000000000001.gz"},
+		Content:     []byte("wal"),
+	})
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %d", len(backups))
+	}
+
+	backup := backups[0]
+	if backup.ID != "orders_postgres_20260215-103020.dump" {
+		t.Errorf("ID = %s", backup.ID)
+	}
+	if backup.DatabaseName != "orders" || backup.DatabaseType != "postgres" || backup.BackupMode != "logical" {
+		t.Errorf("unexpected metadata: %+v", backup)
+	}
+	if !backup.Timestamp.Equal(metadata.Timestamp) {
+		t.Errorf("Timestamp = %v, want %v", backup.Timestamp, metadata.Timestamp)
+	}
+	if backup.Checksum != metadata.Checksum || backup.Size != metadata.Size {
+		t.Errorf("listed checksum %s and size %d, want %s and %d", backup.Checksum, backup.Size, metadata.Checksum, metadata.Size)
+	}
+	if backup.Tags["server-version"] != "16.2" {
+		t.Errorf("expected tags to round-trip, got %v", backup.Tags)
+	}
+
+	reader, err := provider.Download(ctx, backup.ID)
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	downloaded, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatalf("failed to read download: %v", err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Error("downloaded data differs from the upload")
+	}
+
+	if err := provider.Delete(ctx, backup.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	backups, err = provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("expected no backups after delete, got %d", len(backups))
+	}
+}
+
+// failingReader returns some data and then an error
+type failingReader struct {
+	sent bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if !r.sent {
+		r.sent = true
+		return copy(p, "partial backup"), nil
+	}
+	return 0, errors.New("database connection lost")
+}
+
+func TestUpload_FailedBackupLeavesNoObject(t *testing.T) {
+	_, endpoint := startServer(t)
+	provider := newProvider(t, endpoint, "backups/")
+	ctx := context.Background()
+
+	metadata := &core.BackupMetadata{DatabaseName: "orders", DatabaseType: "postgres", Timestamp: time.Now()}
+	if err := provider.Upload(ctx, &failingReader{}, metadata); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("expected no backups after a failed upload, got %d", len(backups))
+	}
+}
+
+func TestList_Paginates(t *testing.T) {
+	server, endpoint := startServer(t)
+	provider := newProvider(t, endpoint, "")
+
+	// More objects than a single page of results holds
+	const count = 1005
+	for i := 0; i < count; i++ {
+		server.CreateObject(fakestorage.Object{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: testBucket, Name: "db_postgres_" + strconv.Itoa(i) + ".dump"},
+			Content:     []byte("x"),
+		})
+	}
+
+	backups, err := provider.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != count {
+		t.Errorf("expected %d backups, got %d", count, len(backups))
+	}
+}
+
+func TestDownload_Missing(t *testing.T) {
+	_, endpoint := startServer(t)
+	provider := newProvider(t, endpoint, "backups/")
+
+	if _, err := provider.Download(context.Background(), "missing.dump"); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
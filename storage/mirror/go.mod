@@ -0,0 +1,7 @@
+module goarchive/storage/mirror
+
+go 1.24.0
+
+require goarchive v0.0.0
+
+replace goarchive => ../../
@@ -0,0 +1,443 @@
+package mirror
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"goarchive/core"
+)
+
+// bufferSize is the size of the chunks passed to the children
+const bufferSize = 1 << 20
+
+// errStoppedReading is reported for children that finish an upload before
+// reading the whole backup
+var errStoppedReading = errors.New("upload finished before the end of the backup")
+
+// init registers the mirror provider with the global registry
+func init() {
+	core.RegisterStorage("mirror", func(ctx context.Context, config *core.StorageConfig) (core.StorageProvider, error) {
+		return New(ctx, config)
+	})
+}
+
+// Provider implements the StorageProvider interface by writing every
+// backup to several child providers
+type Provider struct {
+	config   *core.StorageConfig
+	children []*child
+	required int
+}
+
+// child is a provider the mirror writes to
+type child struct {
+	name     string
+	provider core.StorageProvider
+}
+
+// New creates the children listed in config.Backends through the storage
+// registry, so their providers must be imported. config.MirrorPolicy sets
+// how many of them must store a backup for the upload to succeed.
+func New(ctx context.Context, config *core.StorageConfig) (*Provider, error) {
+	if len(config.Backends) == 0 {
+		return nil, fmt.Errorf("storage backends are required for mirror storage")
+	}
+
+	required, err := parsePolicy(config.MirrorPolicy, len(config.Backends))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Provider{config: config, required: required}
+
+	counts := make(map[string]int)
+	for _, spec := range config.Backends {
+		childConfig, err := core.ParseBackend(spec, config)
+		if err == nil && childConfig.Type == "mirror" {
+			err = fmt.Errorf("storage backend %q cannot be a mirror", spec)
+		}
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+
+		provider, err := core.GetStorage(ctx, childConfig.Type, childConfig)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("failed to create %s storage: %w", childConfig.Type, err)
+		}
+
+		// Children of the same type are numbered to tell them apart
+		counts[childConfig.Type]++
+		name := childConfig.Type
+		if counts[name] > 1 {
+			name = fmt.Sprintf("%s#%d", name, counts[name])
+		}
+
+		p.children = append(p.children, &child{name: name, provider: provider})
+	}
+
+	return p, nil
+}
+
+// Upload streams the backup to every child at once. The backup is stored
+// when the policy's number of children succeed; otherwise the copies that
+// were written are deleted.
+func (p *Provider) Upload(ctx context.Context, reader io.Reader, metadata *core.BackupMetadata) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	writers := make([]*io.PipeWriter, len(p.children))
+	errs := make([]error, len(p.children))
+
+	var wg sync.WaitGroup
+	for i, c := range p.children {
+		pr, pw := io.Pipe()
+		writers[i] = pw
+
+		// Children fill in the checksum and size, so each gets its own copy
+		childMetadata := metadata.Clone()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = c.provider.Upload(ctx, pr, childMetadata)
+
+			// Unblock writes to a child that stopped reading
+			if errs[i] != nil {
+				pr.CloseWithError(errs[i])
+			} else {
+				pr.CloseWithError(errStoppedReading)
+			}
+		}()
+	}
+
+	hash := md5.New()
+	var size int64
+	live := len(p.children)
+	failed := make([]bool, len(p.children))
+	buf := make([]byte, bufferSize)
+
+	var readErr error
+	for live >= p.required {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			hash.Write(buf[:n])
+			size += int64(n)
+			live -= p.write(writers, failed, buf[:n])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+
+	switch {
+	case readErr != nil:
+		closeAll(writers, readErr)
+	case live < p.required:
+		cancel()
+		closeAll(writers, errors.New("mirror upload aborted"))
+	default:
+		closeAll(writers, nil)
+	}
+	wg.Wait()
+
+	var stored []*child
+	var failures []error
+	for i, c := range p.children {
+		if errs[i] == nil && failed[i] {
+			errs[i] = errStoppedReading
+		}
+		if errs[i] == nil {
+			stored = append(stored, c)
+			continue
+		}
+		failures = append(failures, fmt.Errorf("%s: %w", c.name, errs[i]))
+	}
+
+	if readErr != nil || len(stored) < p.required {
+		p.deleteCopies(context.WithoutCancel(ctx), stored, metadata)
+		if readErr != nil {
+			return fmt.Errorf("failed to read backup data: %w", readErr)
+		}
+		return fmt.Errorf("backup stored on %d of %d storage backends, %d required: %w", len(stored), len(p.children), p.required, errors.Join(failures...))
+	}
+
+	for _, err := range failures {
+		fmt.Fprintf(os.Stderr, "Warning: failed to store backup on mirror backend %v\n", err)
+	}
+
+	metadata.Checksum = hex.EncodeToString(hash.Sum(nil))
+	metadata.Size = size
+
+	return nil
+}
+
+// write passes data to the children still being written to, all at once,
+// and returns how many of them failed
+func (p *Provider) write(writers []*io.PipeWriter, failed []bool, data []byte) int {
+	var wg sync.WaitGroup
+	for i, w := range writers {
+		if failed[i] {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := w.Write(data); err != nil {
+				failed[i] = true
+			}
+		}()
+	}
+	wg.Wait()
+
+	count := 0
+	for i := range writers {
+		if failed[i] && writers[i] != nil {
+			writers[i] = nil
+			count++
+		}
+	}
+	return count
+}
+
+// closeAll ends the uploads still being written to, with err or at the end
+// of the backup when err is nil
+func closeAll(writers []*io.PipeWriter, err error) {
+	for _, w := range writers {
+		if w != nil {
+			w.CloseWithError(err)
+		}
+	}
+}
+
+// deleteCopies removes a backup that did not reach the policy from the
+// children that stored it
+func (p *Provider) deleteCopies(ctx context.Context, children []*child, metadata *core.BackupMetadata) {
+	id := p.getBackupFilename(metadata)
+	for _, c := range children {
+		c.provider.Delete(ctx, id)
+	}
+}
+
+// getBackupFilename returns the ID the children give a backup
+func (p *Provider) getBackupFilename(metadata *core.BackupMetadata) string {
+	if metadata.Key != "" {
+		return metadata.Key
+	}
+	return fmt.Sprintf("%s_%s_%s.dump",
+		metadata.DatabaseName,
+		metadata.DatabaseType,
+		metadata.Timestamp.Format("20060102-150405"),
+	)
+}
+
+// List merges the backups of every child. Backups stored by several
+// children are listed once, with the "mirrors" tag naming the children
+// holding a copy. Children that cannot be listed are skipped with a
+// warning, unless none can.
+func (p *Provider) List(ctx context.Context) ([]*core.BackupMetadata, error) {
+	lists := make([][]*core.BackupMetadata, len(p.children))
+	errs := make([]error, len(p.children))
+
+	var wg sync.WaitGroup
+	for i, c := range p.children {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lists[i], errs[i] = c.provider.List(ctx)
+		}()
+	}
+	wg.Wait()
+
+	merged := make(map[string]*core.BackupMetadata)
+	copies := make(map[string][]string)
+	var failures []error
+	for i, c := range p.children {
+		if errs[i] != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", c.name, errs[i]))
+			continue
+		}
+		for _, backup := range lists[i] {
+			if _, ok := merged[backup.ID]; !ok {
+				merged[backup.ID] = backup.Clone()
+			}
+			copies[backup.ID] = append(copies[backup.ID], c.name)
+		}
+	}
+
+	if len(failures) == len(p.children) {
+		return nil, fmt.Errorf("failed to list backups: %w", errors.Join(failures...))
+	}
+	for _, err := range failures {
+		fmt.Fprintf(os.Stderr, "Warning: failed to list mirror backend %v\n", err)
+	}
+
+	backups := make([]*core.BackupMetadata, 0, len(merged))
+	for id, backup := range merged {
+		if backup.Tags == nil {
+			backup.Tags = make(map[string]string)
+		}
+		backup.Tags["mirrors"] = strings.Join(copies[id], ",")
+		backups = append(backups, backup)
+	}
+
+	// Sort by timestamp descending (newest first)
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Timestamp.After(backups[j].Timestamp)
+	})
+
+	return backups, nil
+}
+
+// Download reads a backup from the first child that has it. If reading
+// fails part way, the rest is read from the next child holding a copy.
+func (p *Provider) Download(ctx context.Context, backupID string) (io.ReadCloser, error) {
+	r := &failoverReader{ctx: ctx, children: p.children, backupID: backupID}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Delete removes a backup from every child holding a copy
+func (p *Provider) Delete(ctx context.Context, backupID string) error {
+	errs := make([]error, len(p.children))
+
+	var wg sync.WaitGroup
+	for i, c := range p.children {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = c.provider.Delete(ctx, backupID)
+		}()
+	}
+	wg.Wait()
+
+	deleted := 0
+	var failures []error
+	for i, c := range p.children {
+		switch {
+		case errs[i] == nil:
+			deleted++
+		case !errors.Is(errs[i], core.ErrBackupNotFound):
+			failures = append(failures, fmt.Errorf("%s: %w", c.name, errs[i]))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to delete backup: %w", errors.Join(failures...))
+	}
+	if deleted == 0 {
+		return fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+	}
+	return nil
+}
+
+// Close closes the children that hold connections
+func (p *Provider) Close() error {
+	var errs []error
+	for _, c := range p.children {
+		if closer, ok := c.provider.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// failoverReader reads a backup from one child, moving to the next child
+// holding a copy when a download fails
+type failoverReader struct {
+	ctx      context.Context
+	children []*child
+	backupID string
+
+	next     int // index of the next child to try
+	current  io.ReadCloser
+	name     string
+	offset   int64 // bytes returned so far
+	failures []error
+}
+
+// open starts reading from the next child holding the backup, skipping
+// the bytes already returned
+func (r *failoverReader) open() error {
+	for r.next < len(r.children) {
+		c := r.children[r.next]
+		r.next++
+
+		reader, err := c.provider.Download(r.ctx, r.backupID)
+		if err != nil {
+			r.failures = append(r.failures, fmt.Errorf("%s: %w", c.name, err))
+			continue
+		}
+		if r.offset > 0 {
+			if _, err := io.CopyN(io.Discard, reader, r.offset); err != nil {
+				reader.Close()
+				r.failures = append(r.failures, fmt.Errorf("%s: %w", c.name, err))
+				continue
+			}
+		}
+
+		r.current, r.name = reader, c.name
+		return nil
+	}
+
+	for _, err := range r.failures {
+		if !errors.Is(err, core.ErrBackupNotFound) {
+			return fmt.Errorf("failed to download backup: %w", errors.Join(r.failures...))
+		}
+	}
+	return fmt.Errorf("%w: %s", core.ErrBackupNotFound, r.backupID)
+}
+
+// Read reads from the current child, failing over when it fails
+func (r *failoverReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.current.Read(p)
+		r.offset += int64(n)
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+
+		r.current.Close()
+		r.failures = append(r.failures, fmt.Errorf("%s: %w", r.name, err))
+		fmt.Fprintf(os.Stderr, "Warning: failed to read backup from mirror backend %s, trying the next one: %v\n", r.name, err)
+		if openErr := r.open(); openErr != nil {
+			r.current = io.NopCloser(errReader{openErr})
+			return n, openErr
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// Close closes the current download
+func (r *failoverReader) Close() error {
+	return r.current.Close()
+}
+
+// errReader fails every read with err
+type errReader struct {
+	err error
+}
+
+// Read returns the reader's error
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,577 @@
+package mirror_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goarchive/core"
+	"goarchive/storage/mirror"
+)
+
+// memoryStorage is a storage provider keeping backups in memory, with
+// failures that tests can inject
+type memoryStorage struct {
+	config *core.StorageConfig
+
+	mu      sync.Mutex
+	backups map[string][]byte
+	meta    map[string]*core.BackupMetadata
+
+	uploadErr     error // fails uploads after reading uploadAfter bytes
+	uploadAfter   int
+	listErr       error
+	downloadErr   error // fails downloads after returning downloadAfter bytes
+	downloadAfter int
+	deleteErr     error
+}
+
+var (
+	storesMu sync.Mutex
+	stores   = make(map[string]*memoryStorage)
+)
+
+func init() {
+	core.RegisterStorage("memory", func(ctx context.Context, config *core.StorageConfig) (core.StorageProvider, error) {
+		storesMu.Lock()
+		defer storesMu.Unlock()
+
+		store, ok := stores[config.Bucket]
+		if !ok {
+			return nil, fmt.Errorf("no memory store named %q", config.Bucket)
+		}
+		store.config = config
+		return store, nil
+	})
+}
+
+// newStore creates a memory store, used by backends "memory?bucket=<name>"
+func newStore(t *testing.T, name string) *memoryStorage {
+	t.Helper()
+
+	store := &memoryStorage{
+		backups: make(map[string][]byte),
+		meta:    make(map[string]*core.BackupMetadata),
+	}
+	storesMu.Lock()
+	stores[name] = store
+	storesMu.Unlock()
+	t.Cleanup(func() {
+		storesMu.Lock()
+		delete(stores, name)
+		storesMu.Unlock()
+	})
+	return store
+}
+
+func (s *memoryStorage) Upload(ctx context.Context, reader io.Reader, metadata *core.BackupMetadata) error {
+	if s.uploadErr != nil {
+		if _, err := io.CopyN(io.Discard, reader, int64(s.uploadAfter)); err != nil {
+			return err
+		}
+		return s.uploadErr
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	id := metadata.Key
+	if id == "" {
+		id = fmt.Sprintf("%s_%s_%s.dump", metadata.DatabaseName, metadata.DatabaseType, metadata.Timestamp.Format("20060102-150405"))
+	}
+	sum := md5.Sum(data)
+	metadata.ID = id
+	metadata.Checksum = hex.EncodeToString(sum[:])
+	metadata.Size = int64(len(data))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backups[id] = data
+	stored := *metadata
+	s.meta[id] = &stored
+	return nil
+}
+
+func (s *memoryStorage) List(ctx context.Context) ([]*core.BackupMetadata, error) {
+	if s.listErr != nil {
+		return nil, s.listErr
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var backups []*core.BackupMetadata
+	for _, metadata := range s.meta {
+		backup := *metadata
+		backups = append(backups, &backup)
+	}
+	return backups, nil
+}
+
+func (s *memoryStorage) Download(ctx context.Context, backupID string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	data, ok := s.backups[backupID]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+	}
+
+	var reader io.Reader = bytes.NewReader(data)
+	if s.downloadErr != nil {
+		reader = io.MultiReader(io.LimitReader(reader, int64(s.downloadAfter)), errorReader{s.downloadErr})
+	}
+	return io.NopCloser(reader), nil
+}
+
+func (s *memoryStorage) Delete(ctx context.Context, backupID string) error {
+	if s.deleteErr != nil {
+		return s.deleteErr
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.backups[backupID]; !ok {
+		return fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+	}
+	delete(s.backups, backupID)
+	delete(s.meta, backupID)
+	return nil
+}
+
+func (s *memoryStorage) has(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.backups[id]
+	return ok
+}
+
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func newMirror(t *testing.T, policy string, backends ...string) *mirror.Provider {
+	t.Helper()
+
+	provider, err := mirror.New(context.Background(), &core.StorageConfig{
+		Type:         "mirror",
+		Backends:     backends,
+		MirrorPolicy: policy,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return provider
+}
+
+func testMetadata() *core.BackupMetadata {
+	return &core.BackupMetadata{
+		DatabaseName: "orders",
+		DatabaseType: "postgres",
+		Timestamp:    time.Date(2026, 2, 15, 10, 30, 20, 0, time.UTC),
+		Tags:         map[string]string{"server-version": "16.2"},
+	}
+}
+
+const backupID = "orders_postgres_20260215-103020.dump"
+
+func TestNew(t *testing.T) {
+	newStore(t, "a")
+	newStore(t, "b")
+
+	tests := []struct {
+		name     string
+		backends []string
+		policy   string
+		wantErr  string
+	}{
+		{name: "two backends", backends: []string{"memory?bucket=a", "memory?bucket=b"}},
+		{name: "quorum", backends: []string{"memory?bucket=a", "memory?bucket=b"}, policy: "quorum"},
+		{name: "count", backends: []string{"memory?bucket=a", "memory?bucket=b"}, policy: "1"},
+		{name: "no backends", wantErr: "storage backends are required"},
+		{name: "count above backends", backends: []string{"memory?bucket=a"}, policy: "2", wantErr: "invalid mirror policy"},
+		{name: "zero count", backends: []string{"memory?bucket=a"}, policy: "0", wantErr: "invalid mirror policy"},
+		{name: "unknown policy", backends: []string{"memory?bucket=a"}, policy: "most", wantErr: "invalid mirror policy"},
+		{name: "nested mirror", backends: []string{"mirror"}, wantErr: "cannot be a mirror"},
+		{name: "missing type", backends: []string{"?bucket=a"}, wantErr: "has no type"},
+		{name: "unknown setting", backends: []string{"memory?bucket=a&colour=red"}, wantErr: "unknown setting"},
+		{name: "type override", backends: []string{"memory?bucket=a&type=disk"}, wantErr: "unknown setting"},
+		{name: "list setting", backends: []string{"memory?bucket=a&backends=disk"}, wantErr: "unknown setting"},
+		{name: "unknown storage type", backends: []string{"tape"}, wantErr: "failed to create tape storage"},
+		{name: "child fails", backends: []string{"memory?bucket=missing"}, wantErr: "no memory store"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := mirror.New(context.Background(), &core.StorageConfig{Backends: tt.backends, MirrorPolicy: tt.policy})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("New() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNew_BackendSettings(t *testing.T) {
+	local := newStore(t, "local")
+	offsite := newStore(t, "offsite")
+
+	_, err := mirror.New(context.Background(), &core.StorageConfig{
+		Type:     "mirror",
+		Region:   "us-east-1",
+		Prefix:   "backups/",
+		Path:     "/var/backups",
+		Backends: []string{"memory?bucket=local", "memory?bucket=offsite&region=eu-west-1&access_key=AKIA&Secret-Key=secret"},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Children inherit the mirror's settings
+	if local.config.Type != "memory" || local.config.Region != "us-east-1" || local.config.Path != "/var/backups" {
+		t.Errorf("unexpected local settings: %+v", local.config)
+	}
+	if len(local.config.Backends) != 0 {
+		t.Errorf("expected children without backends, got %v", local.config.Backends)
+	}
+
+	// Overrides apply to their own child only
+	if offsite.config.Region != "eu-west-1" || offsite.config.AccessKey != "AKIA" || offsite.config.SecretKey != "secret" {
+		t.Errorf("unexpected offsite settings: %+v", offsite.config)
+	}
+	if offsite.config.Prefix != "backups/" {
+		t.Errorf("Prefix = %q, want the mirror's", offsite.config.Prefix)
+	}
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	newStore(t, "a")
+
+	provider, err := core.GetStorage(context.Background(), "mirror", &core.StorageConfig{Backends: []string{"memory?bucket=a"}})
+	if err != nil {
+		t.Fatalf("GetStorage() error = %v", err)
+	}
+	if provider == nil {
+		t.Error("expected non-nil provider")
+	}
+}
+
+func TestUpload_WritesEveryBackend(t *testing.T) {
+	a, b, c := newStore(t, "a"), newStore(t, "b"), newStore(t, "c")
+	provider := newMirror(t, "", "memory?bucket=a", "memory?bucket=b", "memory?bucket=c")
+
+	// More than one chunk, so children receive the stream in parts
+	data := bytes.Repeat([]byte("backup data "), 300000)
+	metadata := testMetadata()
+	if err := provider.Upload(context.Background(), bytes.NewReader(data), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	sum := md5.Sum(data)
+	if metadata.Checksum != hex.EncodeToString(sum[:]) || metadata.Size != int64(len(data)) {
+		t.Errorf("checksum %s and size %d, want %s and %d", metadata.Checksum, metadata.Size, hex.EncodeToString(sum[:]), len(data))
+	}
+
+	for name, store := range map[string]*memoryStorage{"a": a, "b": b, "c": c} {
+		if !bytes.Equal(store.backups[backupID], data) {
+			t.Errorf("store %s does not hold the backup", name)
+		}
+		if store.meta[backupID].Tags["server-version"] != "16.2" {
+			t.Errorf("store %s lost the tags: %v", name, store.meta[backupID].Tags)
+		}
+	}
+}
+
+func TestUpload_Policy(t *testing.T) {
+	failure := errors.New("bucket unavailable")
+
+	tests := []struct {
+		name       string
+		policy     string
+		failing    int // how many of the three children fail
+		wantErr    bool
+		wantStored bool
+	}{
+		{name: "all, none failing", policy: "all", failing: 0, wantStored: true},
+		{name: "all, one failing", policy: "all", failing: 1, wantErr: true},
+		{name: "quorum, one failing", policy: "quorum", failing: 1, wantStored: true},
+		{name: "quorum, two failing", policy: "quorum", failing: 2, wantErr: true},
+		{name: "one, two failing", policy: "1", failing: 2, wantStored: true},
+		{name: "one, all failing", policy: "1", failing: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stores := []*memoryStorage{newStore(t, "a"), newStore(t, "b"), newStore(t, "c")}
+			for i := 0; i < tt.failing; i++ {
+				stores[len(stores)-1-i].uploadErr = failure
+				stores[len(stores)-1-i].uploadAfter = 1 << 20
+			}
+			provider := newMirror(t, tt.policy, "memory?bucket=a", "memory?bucket=b", "memory?bucket=c")
+
+			data := bytes.Repeat([]byte("x"), 3<<20)
+			err := provider.Upload(context.Background(), bytes.NewReader(data), testMetadata())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Upload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "bucket unavailable") {
+				t.Errorf("expected the children's errors, got %v", err)
+			}
+
+			// A backup that misses the policy is removed from every child
+			for i, store := range stores[:len(stores)-tt.failing] {
+				if store.has(backupID) != tt.wantStored {
+					t.Errorf("store %d holds the backup = %v, want %v", i, store.has(backupID), tt.wantStored)
+				}
+			}
+		})
+	}
+}
+
+func TestUpload_ReadError(t *testing.T) {
+	a, b := newStore(t, "a"), newStore(t, "b")
+	provider := newMirror(t, "", "memory?bucket=a", "memory?bucket=b")
+
+	reader := io.MultiReader(strings.NewReader("partial backup"), errorReader{errors.New("database connection lost")})
+	err := provider.Upload(context.Background(), reader, testMetadata())
+	if err == nil || !strings.Contains(err.Error(), "database connection lost") {
+		t.Fatalf("expected the read error, got %v", err)
+	}
+	if a.has(backupID) || b.has(backupID) {
+		t.Error("expected no backup after a failed read")
+	}
+}
+
+// stoppingStorage finishes uploads without reading the backup
+type stoppingStorage struct {
+	*memoryStorage
+}
+
+func (s stoppingStorage) Upload(ctx context.Context, reader io.Reader, metadata *core.BackupMetadata) error {
+	return nil
+}
+
+func TestUpload_ChildStopsReading(t *testing.T) {
+	a := newStore(t, "a")
+	core.RegisterStorage("stopping", func(ctx context.Context, config *core.StorageConfig) (core.StorageProvider, error) {
+		return stoppingStorage{newStore(t, "stopping")}, nil
+	})
+	provider := newMirror(t, "all", "memory?bucket=a", "stopping")
+
+	err := provider.Upload(context.Background(), strings.NewReader("backup"), testMetadata())
+	if err == nil || !strings.Contains(err.Error(), "before the end of the backup") {
+		t.Fatalf("expected an error for the child that stopped reading, got %v", err)
+	}
+	if a.has(backupID) {
+		t.Error("expected the copy on the other child to be removed")
+	}
+}
+
+func TestList_MergesBackends(t *testing.T) {
+	a, b := newStore(t, "a"), newStore(t, "b")
+	provider := newMirror(t, "1", "memory?bucket=a", "memory?bucket=b")
+	ctx := context.Background()
+
+	if err := provider.Upload(ctx, strings.NewReader("both"), testMetadata()); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	// Backups written to a single child, e.g. before it was mirrored
+	older := testMetadata()
+	older.Timestamp = older.Timestamp.Add(-24 * time.Hour)
+	if err := a.Upload(ctx, strings.NewReader("older"), older); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	newer := testMetadata()
+	newer.Timestamp = newer.Timestamp.Add(24 * time.Hour)
+	if err := b.Upload(ctx, strings.NewReader("newer"), newer); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	var got []string
+	for _, backup := range backups {
+		got = append(got, backup.ID+"="+backup.Tags["mirrors"])
+	}
+	want := []string{
+		"orders_postgres_20260216-103020.dump=memory#2",
+		"orders_postgres_20260215-103020.dump=memory,memory#2",
+		"orders_postgres_20260214-103020.dump=memory",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+	if backups[1].Tags["server-version"] != "16.2" || backups[1].Checksum == "" {
+		t.Errorf("expected the children's details, got %+v", backups[1])
+	}
+
+	// A child that cannot be listed is skipped
+	b.listErr = errors.New("bucket unavailable")
+	backups, err = provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 2 {
+		t.Errorf("expected the backups of the other child, got %d", len(backups))
+	}
+
+	// Unless none can
+	a.listErr = errors.New("disk unavailable")
+	if _, err := provider.List(ctx); err == nil {
+		t.Error("expected error when no child can be listed, got nil")
+	}
+}
+
+func TestDownload_FallsBack(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 200000)
+
+	tests := []struct {
+		name    string
+		setup   func(a, b *memoryStorage)
+		wantErr string
+	}{
+		{
+			name:  "first child",
+			setup: func(a, b *memoryStorage) {},
+		},
+		{
+			name:  "missing from the first child",
+			setup: func(a, b *memoryStorage) { a.Delete(context.Background(), backupID) },
+		},
+		{
+			name: "first child fails part way",
+			setup: func(a, b *memoryStorage) {
+				a.downloadErr = errors.New("connection reset")
+				a.downloadAfter = 123457
+			},
+		},
+		{
+			name: "every child fails part way",
+			setup: func(a, b *memoryStorage) {
+				a.downloadErr, a.downloadAfter = errors.New("connection reset"), 1000
+				b.downloadErr, b.downloadAfter = errors.New("timeout"), 5000
+			},
+			wantErr: "timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := newStore(t, "a"), newStore(t, "b")
+			provider := newMirror(t, "", "memory?bucket=a", "memory?bucket=b")
+			if err := provider.Upload(context.Background(), bytes.NewReader(data), testMetadata()); err != nil {
+				t.Fatalf("Upload() error = %v", err)
+			}
+			tt.setup(a, b)
+
+			reader, err := provider.Download(context.Background(), backupID)
+			if err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			defer reader.Close()
+
+			downloaded, err := io.ReadAll(reader)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to read download: %v", err)
+			}
+			if !bytes.Equal(downloaded, data) {
+				t.Errorf("downloaded %d bytes differing from the %d uploaded", len(downloaded), len(data))
+			}
+		})
+	}
+}
+
+func TestDownload_Missing(t *testing.T) {
+	newStore(t, "a")
+	newStore(t, "b")
+	provider := newMirror(t, "", "memory?bucket=a", "memory?bucket=b")
+
+	_, err := provider.Download(context.Background(), "missing.dump")
+	if !errors.Is(err, core.ErrBackupNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	a, b := newStore(t, "a"), newStore(t, "b")
+	provider := newMirror(t, "", "memory?bucket=a", "memory?bucket=b")
+	ctx := context.Background()
+
+	if err := provider.Upload(ctx, strings.NewReader("data"), testMetadata()); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	// A copy missing from one child does not fail the delete
+	if err := a.Delete(ctx, backupID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := provider.Delete(ctx, backupID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if b.has(backupID) {
+		t.Error("expected the backup to be deleted")
+	}
+
+	if err := provider.Delete(ctx, backupID); !errors.Is(err, core.ErrBackupNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	// Other failures are reported
+	if err := provider.Upload(ctx, strings.NewReader("data"), testMetadata()); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	b.deleteErr = errors.New("permission denied")
+	if err := provider.Delete(ctx, backupID); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("expected the child's error, got %v", err)
+	}
+}
+
+func TestList_SortedNewestFirst(t *testing.T) {
+	newStore(t, "a")
+	provider := newMirror(t, "", "memory?bucket=a")
+	ctx := context.Background()
+
+	for _, day := range []int{3, 1, 2} {
+		metadata := testMetadata()
+		metadata.Timestamp = metadata.Timestamp.AddDate(0, 0, day)
+		if err := provider.Upload(ctx, strings.NewReader("data"), metadata); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if !sort.SliceIsSorted(backups, func(i, j int) bool { return backups[i].Timestamp.After(backups[j].Timestamp) }) {
+		t.Error("expected backups sorted newest first")
+	}
+}
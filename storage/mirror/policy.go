@@ -0,0 +1,23 @@
+package mirror
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parsePolicy returns how many of count children must store each backup
+func parsePolicy(policy string, count int) (int, error) {
+	switch strings.ToLower(policy) {
+	case "", "all":
+		return count, nil
+	case "quorum":
+		return count/2 + 1, nil
+	}
+
+	required, err := strconv.Atoi(policy)
+	if err != nil || required < 1 || required > count {
+		return 0, fmt.Errorf("invalid mirror policy %q: use all, quorum or a number from 1 to %d", policy, count)
+	}
+	return required, nil
+}
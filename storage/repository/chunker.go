@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"errors"
+	"io"
+)
+
+// Chunk sizes. Boundaries depend on the content, so data inserted or
+// removed in one place of a backup only changes the chunks around it.
+const (
+	minChunkSize = 512 << 10
+	avgChunkSize = 1 << 20
+	maxChunkSize = 8 << 20
+)
+
+// Boundary masks for FastCDC's normalized chunking: chunks shorter than
+// the average need a rarer hash to end, longer ones a more common one, so
+// sizes cluster around the average
+const (
+	maskSmall = (1<<22 - 1) << (64 - 22)
+	maskLarge = (1<<18 - 1) << (64 - 18)
+)
+
+// gear maps each byte to a random value for the rolling hash. Changing it
+// moves every chunk boundary, so new backups would share no chunks with
+// those already stored.
+var gear = func() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x676f617263686976) // "goarchiv"
+	for i := range table {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// chunker splits a stream into content-defined chunks
+type chunker struct {
+	r          io.Reader
+	buf        []byte
+	start, end int // buffered data not yet returned
+	eof        bool
+}
+
+// newChunker creates a chunker reading from r
+func newChunker(r io.Reader) *chunker {
+	return &chunker{r: r, buf: make([]byte, 2*maxChunkSize)}
+}
+
+// Next returns the next chunk, or io.EOF after the last one. The chunk is
+// only valid until the next call.
+func (c *chunker) Next() ([]byte, error) {
+	if c.end-c.start < maxChunkSize && !c.eof {
+		if err := c.fill(); err != nil {
+			return nil, err
+		}
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+
+	chunk := c.buf[c.start:c.end]
+	chunk = chunk[:cut(chunk)]
+	c.start += len(chunk)
+	return chunk, nil
+}
+
+// fill reads until a whole chunk of the largest size is buffered or the
+// stream ends, moving the buffered data to the front of the buffer when
+// there is no room left after it
+func (c *chunker) fill() error {
+	if len(c.buf)-c.start < maxChunkSize {
+		c.end = copy(c.buf, c.buf[c.start:c.end])
+		c.start = 0
+	}
+
+	n, err := io.ReadAtLeast(c.r, c.buf[c.end:], maxChunkSize-(c.end-c.start))
+	c.end += n
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		c.eof = true
+		return nil
+	}
+	return err
+}
+
+// cut returns the length of the chunk starting data
+func cut(data []byte) int {
+	n := len(data)
+	if n > maxChunkSize {
+		n = maxChunkSize
+	}
+	if n <= minChunkSize {
+		return n
+	}
+
+	normal := avgChunkSize
+	if n < normal {
+		normal = n
+	}
+
+	var hash uint64
+	i := minChunkSize
+	for ; i < normal; i++ {
+		hash = hash<<1 + gear[data[i]]
+		if hash&maskSmall == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		hash = hash<<1 + gear[data[i]]
+		if hash&maskLarge == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
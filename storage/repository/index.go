@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"goarchive/core"
+)
+
+// indexKey keys the reference index in the backend. The key is nested, so
+// listings leave it out.
+const indexKey = "index/references.json"
+
+// referenceIndex counts the references of the indexed snapshots to each
+// chunk, so that uploads and deletes need not read every snapshot. Only
+// snapshots stored since the index was last written are read. The index is
+// written by uploads and deletes under their repository locks; concurrent
+// uploads may each leave out the other's snapshot, which is then read and
+// added by the next operation.
+type referenceIndex struct {
+	Snapshots  map[string]bool `json:"snapshots"`  // Includes backups stored in full, which refer to no chunks
+	References map[string]int  `json:"references"` // Chunks referred to, by hash
+}
+
+// loadIndex returns the reference index, brought up to date with the
+// snapshots the backend lists. Snapshots that are indexed but not listed
+// are kept: their chunks are only removed by deletes, which update the
+// index first. An index that cannot be decoded is rebuilt. required, when
+// set, must be listed: a listing without it is incomplete.
+func (p *Provider) loadIndex(ctx context.Context, required string) (*referenceIndex, error) {
+	backups, err := p.backend.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	listed := required == ""
+	for _, backup := range backups {
+		if backup.ID == required {
+			listed = true
+		}
+	}
+	if !listed {
+		return nil, fmt.Errorf("listing of the repository is incomplete: %s is missing", required)
+	}
+
+	index, err := p.readIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	added := false
+	for _, backup := range backups {
+		if isNested(backup.ID) || isLock(backup.ID) || index.Snapshots[backup.ID] {
+			continue
+		}
+
+		s, err := p.readSnapshot(ctx, backup.ID)
+		if errors.Is(err, errNotSnapshot) {
+			s = &snapshot{}
+		} else if err != nil {
+			return nil, err
+		}
+		index.add(backup.ID, s)
+		added = true
+	}
+
+	// Save the snapshots read for the next operation
+	if added {
+		if err := p.writeIndex(ctx, index); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update the repository index: %v\n", err)
+		}
+	}
+
+	return index, nil
+}
+
+// readIndex downloads the reference index, returning an empty index when
+// there is none or it is damaged
+func (p *Provider) readIndex(ctx context.Context) (*referenceIndex, error) {
+	index := &referenceIndex{Snapshots: make(map[string]bool), References: make(map[string]int)}
+
+	rc, err := p.backend.Download(ctx, indexKey)
+	if errors.Is(err, core.ErrBackupNotFound) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the repository index: %w", err)
+	}
+	defer rc.Close()
+
+	var stored referenceIndex
+	if err := json.NewDecoder(rc).Decode(&stored); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: rebuilding the repository index: %v\n", err)
+		return index, nil
+	}
+	for id := range stored.Snapshots {
+		index.Snapshots[id] = true
+	}
+	for hash, count := range stored.References {
+		if count > 0 {
+			index.References[hash] = count
+		}
+	}
+	return index, nil
+}
+
+// writeIndex stores the reference index, or deletes it once it indexes
+// nothing
+func (p *Provider) writeIndex(ctx context.Context, index *referenceIndex) error {
+	if len(index.Snapshots) == 0 && len(index.References) == 0 {
+		err := p.backend.Delete(ctx, indexKey)
+		if errors.Is(err, core.ErrBackupNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to encode the repository index: %w", err)
+	}
+	return p.backend.Upload(ctx, bytes.NewReader(data), &core.BackupMetadata{
+		ID:        indexKey,
+		Key:       indexKey,
+		Timestamp: time.Now(),
+	})
+}
+
+// add indexes snapshot id
+func (index *referenceIndex) add(id string, s *snapshot) {
+	index.Snapshots[id] = true
+	for _, chunk := range s.Chunks {
+		index.References[chunk.Hash]++
+	}
+}
+
+// remove drops snapshot id from the index and returns the chunks no
+// indexed snapshot refers to any more
+func (index *referenceIndex) remove(id string, s *snapshot) []string {
+	delete(index.Snapshots, id)
+
+	var unused []string
+	for _, chunk := range s.Chunks {
+		count, ok := index.References[chunk.Hash]
+		if !ok {
+			continue // Listed once
+		}
+		if count > 1 {
+			index.References[chunk.Hash] = count - 1
+			continue
+		}
+		delete(index.References, chunk.Hash)
+		unused = append(unused, chunk.Hash)
+	}
+	return unused
+}
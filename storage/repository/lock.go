@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"goarchive/core"
+)
+
+// Locks keep deletes from removing chunks that uploads in progress reuse.
+// Uploads hold shared locks and deletes exclusive ones. Locks are objects
+// at the root of the backend, named with the .dump suffix every backend
+// lists, so that every process sees the locks of the others.
+const (
+	lockPrefix    = "repository-lock-"
+	lockShared    = "shared"
+	lockExclusive = "exclusive"
+	lockSuffix    = ".dump"
+
+	// lockRefresh is how often held locks are renewed, and locks not renewed
+	// for lockStale were left by processes that stopped
+	lockRefresh = 5 * time.Minute
+	lockStale   = 30 * time.Minute
+
+	// Shared locks wait up to lockWait for exclusive locks to be released,
+	// checking every lockPoll
+	lockWait = 5 * time.Minute
+	lockPoll = 5 * time.Second
+)
+
+// repositoryLock is a lock held on the repository
+type repositoryLock struct {
+	p    *Provider
+	id   string
+	once sync.Once
+	stop chan struct{}
+	done chan struct{}
+}
+
+// lock takes a shared or exclusive lock on the repository. Exclusive locks
+// fail at once when another lock is held; shared locks wait for exclusive
+// ones to be released.
+func (p *Provider) lock(ctx context.Context, exclusive bool) (*repositoryLock, error) {
+	kind := lockShared
+	if exclusive {
+		kind = lockExclusive
+	}
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	id := lockPrefix + kind + "-" + hex.EncodeToString(suffix) + lockSuffix
+
+	deadline := time.Now().Add(lockWait)
+	for {
+		if err := p.writeLock(ctx, id); err != nil {
+			return nil, fmt.Errorf("failed to lock repository: %w", err)
+		}
+
+		// Locks taken at the same time both see the other and both back off
+		holder, err := p.conflictingLock(ctx, id, exclusive)
+		if err == nil && holder == "" {
+			l := &repositoryLock{p: p, id: id, stop: make(chan struct{}), done: make(chan struct{})}
+			go l.refresh()
+			return l, nil
+		}
+
+		p.removeLock(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock repository: %w", err)
+		}
+		if exclusive || !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("repository is locked by %s; try again later", holder)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+}
+
+// writeLock stores or renews lock id
+func (p *Provider) writeLock(ctx context.Context, id string) error {
+	host, _ := os.Hostname()
+	content := fmt.Sprintf("Host: %s\nPID: %d\n", host, os.Getpid())
+
+	return p.backend.Upload(ctx, strings.NewReader(content), &core.BackupMetadata{
+		ID:        id,
+		Key:       id,
+		Timestamp: time.Now(),
+	})
+}
+
+// conflictingLock returns a lock held by another operation that conflicts
+// with lock id, or an empty string. Locks left by stopped processes are
+// ignored.
+func (p *Provider) conflictingLock(ctx context.Context, id string, exclusive bool) (string, error) {
+	backups, err := p.backend.List(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, backup := range backups {
+		if !isLock(backup.ID) || backup.ID == id || time.Since(backup.Timestamp) > lockStale {
+			continue
+		}
+		if exclusive || strings.HasPrefix(backup.ID, lockPrefix+lockExclusive) {
+			return backup.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// removeLock deletes lock id, even when the operation was cancelled
+func (p *Provider) removeLock(ctx context.Context, id string) {
+	if err := p.backend.Delete(context.WithoutCancel(ctx), id); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove repository lock %s: %v\n", id, err)
+	}
+}
+
+// refresh renews the lock until it is released
+func (l *repositoryLock) refresh() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(lockRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := l.p.writeLock(context.Background(), l.id); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to renew repository lock %s: %v\n", l.id, err)
+			}
+		}
+	}
+}
+
+// unlock releases the lock. Releasing it again does nothing.
+func (l *repositoryLock) unlock(ctx context.Context) {
+	l.once.Do(func() {
+		close(l.stop)
+		<-l.done
+		l.p.removeLock(ctx, l.id)
+	})
+}
+
+// isLock reports whether id names a lock object
+func isLock(id string) bool {
+	return strings.HasPrefix(id, lockPrefix) && strings.HasSuffix(id, lockSuffix)
+}
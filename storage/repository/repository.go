@@ -0,0 +1,428 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"goarchive/core"
+)
+
+// chunkPrefix keys the chunks in the backend. Backends list the objects at
+// the root only, every page of them, so listings return the snapshots and
+// leave out the chunks.
+const chunkPrefix = "chunks/"
+
+// Tags recorded with each snapshot, so listing needs no snapshot downloads
+const (
+	tagSize      = "repository-size"
+	tagChecksum  = "repository-checksum"
+	tagChunks    = "repository-chunks"
+	tagNewChunks = "repository-new-chunks"
+)
+
+// init registers the repository provider with the global registry
+func init() {
+	core.RegisterStorage("repository", func(ctx context.Context, config *core.StorageConfig) (core.StorageProvider, error) {
+		return New(ctx, config)
+	})
+}
+
+// Provider implements the StorageProvider interface as a deduplicating
+// repository on another provider. Backups are split into content-defined
+// chunks stored once by hash, and each backup is stored as a snapshot
+// listing its chunks.
+type Provider struct {
+	config  *core.StorageConfig
+	backend core.StorageProvider
+}
+
+// New creates the provider holding the repository, the single entry of
+// config.Backends, through the storage registry, so its provider must be
+// imported
+func New(ctx context.Context, config *core.StorageConfig) (*Provider, error) {
+	if len(config.Backends) == 0 {
+		return nil, fmt.Errorf("storage backend is required for repository storage")
+	}
+	if len(config.Backends) > 1 {
+		return nil, fmt.Errorf("repository storage takes one storage backend, got %d", len(config.Backends))
+	}
+
+	backendConfig, err := core.ParseBackend(config.Backends[0], config)
+	if err != nil {
+		return nil, err
+	}
+	if backendConfig.Type == "repository" {
+		return nil, fmt.Errorf("storage backend %q cannot be a repository", config.Backends[0])
+	}
+
+	backend, err := core.GetStorage(ctx, backendConfig.Type, backendConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s storage: %w", backendConfig.Type, err)
+	}
+
+	return &Provider{config: config, backend: backend}, nil
+}
+
+// Upload splits the backup into chunks, stores the chunks the repository
+// does not hold yet, then stores the snapshot listing them. It holds a
+// shared lock throughout, so that no delete removes the chunks it reuses.
+// Backups under nested keys, such as WAL segments, are stored as they are.
+func (p *Provider) Upload(ctx context.Context, reader io.Reader, metadata *core.BackupMetadata) error {
+	if isNested(metadata.Key) {
+		return p.backend.Upload(ctx, reader, metadata)
+	}
+
+	lock, err := p.lock(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock(ctx)
+
+	// The chunks held are learned under the lock, so none are removed
+	// before the snapshot reusing them is stored
+	index, err := p.loadIndex(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	hash := md5.New()
+	chunks := newChunker(io.TeeReader(reader, hash))
+
+	var s snapshot
+	var added []string // chunks stored by this upload, removed if it fails
+	stored := make(map[string]bool)
+	for {
+		data, err := chunks.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			p.discardChunks(ctx, lock, added)
+			return fmt.Errorf("failed to read backup data: %w", err)
+		}
+
+		sum := sha256.Sum256(data)
+		id := hex.EncodeToString(sum[:])
+		s.Chunks = append(s.Chunks, chunkRef{Hash: id, Size: int64(len(data))})
+		s.Size += int64(len(data))
+
+		if stored[id] || index.References[id] > 0 {
+			continue
+		}
+		chunkMetadata := &core.BackupMetadata{
+			ID:        id,
+			Timestamp: metadata.Timestamp,
+			Key:       chunkKey(id),
+		}
+		if err := p.backend.Upload(ctx, bytes.NewReader(data), chunkMetadata); err != nil {
+			p.discardChunks(ctx, lock, added)
+			return fmt.Errorf("failed to store chunk %s: %w", id, err)
+		}
+		stored[id] = true
+		added = append(added, id)
+	}
+	s.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	encoded, err := s.encode()
+	if err != nil {
+		p.discardChunks(ctx, lock, added)
+		return err
+	}
+
+	// The backend fills in the snapshot's own checksum and size
+	snapshotMetadata := metadata.Clone()
+	if snapshotMetadata.Tags == nil {
+		snapshotMetadata.Tags = make(map[string]string)
+	}
+	snapshotMetadata.Tags[tagSize] = strconv.FormatInt(s.Size, 10)
+	snapshotMetadata.Tags[tagChecksum] = s.Checksum
+	snapshotMetadata.Tags[tagChunks] = strconv.Itoa(len(s.Chunks))
+	snapshotMetadata.Tags[tagNewChunks] = strconv.Itoa(len(added))
+
+	// A snapshot stored again under the same name replaces the indexed one
+	name := snapshotName(metadata)
+	if index.Snapshots[name] {
+		if previous, err := p.readSnapshot(ctx, name); err == nil {
+			index.remove(name, previous)
+		}
+	}
+
+	if err := p.backend.Upload(ctx, bytes.NewReader(encoded), snapshotMetadata); err != nil {
+		p.discardChunks(ctx, lock, added)
+		return fmt.Errorf("failed to store snapshot: %w", err)
+	}
+
+	// The next operation reads the snapshot if the index is not updated
+	index.add(name, &s)
+	if err := p.writeIndex(ctx, index); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update the repository index: %v\n", err)
+	}
+
+	metadata.Checksum = s.Checksum
+	metadata.Size = s.Size
+
+	return nil
+}
+
+// List lists the backups of the repository, with the size and checksum of
+// the backups rather than of their snapshots
+func (p *Provider) List(ctx context.Context) ([]*core.BackupMetadata, error) {
+	listed, err := p.backend.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]*core.BackupMetadata, 0, len(listed))
+	for _, backup := range listed {
+		if isNested(backup.ID) || isLock(backup.ID) {
+			continue
+		}
+
+		if size, ok := backup.Tags[tagSize]; ok {
+			if n, err := strconv.ParseInt(size, 10, 64); err == nil {
+				backup.Size = n
+			}
+			backup.Checksum = backup.Tags[tagChecksum]
+			delete(backup.Tags, tagSize)
+			delete(backup.Tags, tagChecksum)
+		}
+		backups = append(backups, backup)
+	}
+
+	return backups, nil
+}
+
+// Download returns a reader reassembling the backup from its chunks,
+// verifying each against its hash. Backups stored in full, before the
+// storage became a repository, are returned as they are.
+func (p *Provider) Download(ctx context.Context, backupID string) (io.ReadCloser, error) {
+	if isNested(backupID) {
+		return p.backend.Download(ctx, backupID)
+	}
+
+	rc, err := p.backend.Download(ctx, backupID)
+	if err != nil {
+		return nil, err
+	}
+
+	s, whole, err := decodeSnapshot(rc)
+	if errors.Is(err, errNotSnapshot) {
+		return &storedReader{Reader: whole, Closer: rc}, nil
+	}
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", backupID, err)
+	}
+
+	return &snapshotReader{ctx: ctx, backend: p.backend, chunks: s.Chunks}, nil
+}
+
+// Delete removes the backup's snapshot, then the chunks no other snapshot
+// refers to. It holds an exclusive lock, so it fails while uploads are in
+// progress. The backup is kept when the other snapshots cannot all be
+// read, since chunks they share could otherwise be removed.
+func (p *Provider) Delete(ctx context.Context, backupID string) error {
+	if isNested(backupID) {
+		return p.backend.Delete(ctx, backupID)
+	}
+
+	lock, err := p.lock(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock(ctx)
+
+	s, err := p.readSnapshot(ctx, backupID)
+	if errors.Is(err, errNotSnapshot) {
+		return p.backend.Delete(ctx, backupID)
+	}
+	if err != nil {
+		return err
+	}
+
+	index, err := p.loadIndex(ctx, backupID)
+	if err != nil {
+		return fmt.Errorf("failed to count chunk references, %s was not deleted: %w", backupID, err)
+	}
+
+	// The index stops counting the snapshot before it is deleted, so a
+	// failure leaves the snapshot to be indexed again, never chunks counted
+	// that are gone
+	unused := index.remove(backupID, s)
+	if err := p.writeIndex(ctx, index); err != nil {
+		return fmt.Errorf("failed to update the repository index, %s was not deleted: %w", backupID, err)
+	}
+
+	// Chunks are only removed once the snapshot is gone, so failures here
+	// leave unused chunks behind rather than incomplete backups
+	if err := p.backend.Delete(ctx, backupID); err != nil {
+		return err
+	}
+	p.removeChunks(ctx, unused)
+
+	return nil
+}
+
+// Close closes the backend if it holds resources
+func (p *Provider) Close() error {
+	if closer, ok := p.backend.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// readSnapshot downloads and decodes the snapshot of backupID
+func (p *Provider) readSnapshot(ctx context.Context, backupID string) (*snapshot, error) {
+	rc, err := p.backend.Download(ctx, backupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	s, _, err := decodeSnapshot(rc)
+	if err != nil && !errors.Is(err, errNotSnapshot) {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", backupID, err)
+	}
+	return s, err
+}
+
+// removeChunks deletes chunks from the backend. Failures only leave unused
+// chunks behind, so they are reported as warnings.
+func (p *Provider) removeChunks(ctx context.Context, ids []string) {
+	// Clean up even when the operation was cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	for _, id := range ids {
+		if err := p.backend.Delete(ctx, chunkKey(id)); err != nil && !errors.Is(err, core.ErrBackupNotFound) {
+			fmt.Fprintf(os.Stderr, "Warning: failed to delete chunk %s: %v\n", id, err)
+		}
+	}
+}
+
+// discardChunks removes the chunks a failed upload stored. Other uploads
+// may have reused them meanwhile, so the upload's shared lock is exchanged
+// for an exclusive one, and only chunks no snapshot refers to are removed.
+// While other uploads hold locks, the chunks are left in place.
+func (p *Provider) discardChunks(ctx context.Context, shared *repositoryLock, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+
+	// Clean up even when the operation was cancelled
+	ctx = context.WithoutCancel(ctx)
+	shared.unlock(ctx)
+
+	lock, err := p.lock(ctx, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: leaving %d chunks of the failed upload in place: %v\n", len(ids), err)
+		return
+	}
+	defer lock.unlock(ctx)
+
+	index, err := p.loadIndex(ctx, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: leaving %d chunks of the failed upload in place: %v\n", len(ids), err)
+		return
+	}
+
+	var unused []string
+	for _, id := range ids {
+		if index.References[id] == 0 {
+			unused = append(unused, id)
+		}
+	}
+	p.removeChunks(ctx, unused)
+}
+
+// chunkKey returns the backend key of chunk id, spread over directories by
+// the first byte of the hash
+func chunkKey(id string) string {
+	return chunkPrefix + id[:2] + "/" + id
+}
+
+// snapshotName returns the name the backend stores the snapshot of a
+// backup under, and lists it by
+func snapshotName(metadata *core.BackupMetadata) string {
+	if metadata.Key != "" {
+		return metadata.Key
+	}
+	return fmt.Sprintf("%s_%s_%s.dump",
+		metadata.DatabaseName,
+		metadata.DatabaseType,
+		metadata.Timestamp.Format("20060102-150405"),
+	)
+}
+
+// isNested reports whether key places a backup below the repository root
+func isNested(key string) bool {
+	return strings.Contains(key, "/")
+}
+
+// storedReader returns a backup stored in full
+type storedReader struct {
+	io.Reader
+	io.Closer
+}
+
+// snapshotReader reassembles a backup from its chunks
+type snapshotReader struct {
+	ctx     context.Context
+	backend core.StorageProvider
+	chunks  []chunkRef
+	data    []byte // unread part of the current chunk
+}
+
+// Read returns the backup's data, downloading chunks as they are needed
+func (r *snapshotReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		if len(r.chunks) == 0 {
+			return 0, io.EOF
+		}
+		data, err := r.readChunk(r.chunks[0])
+		if err != nil {
+			return 0, err
+		}
+		r.chunks = r.chunks[1:]
+		r.data = data
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// readChunk downloads a chunk and checks it against its hash
+func (r *snapshotReader) readChunk(chunk chunkRef) ([]byte, error) {
+	rc, err := r.backend.Download(r.ctx, chunkKey(chunk.Hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download chunk %s: %w", chunk.Hash, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, chunk.Size+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk %s: %w", chunk.Hash, err)
+	}
+
+	sum := sha256.Sum256(data)
+	if int64(len(data)) != chunk.Size || hex.EncodeToString(sum[:]) != chunk.Hash {
+		return nil, fmt.Errorf("chunk %s is corrupt", chunk.Hash)
+	}
+	return data, nil
+}
+
+// Close stops the download
+func (r *snapshotReader) Close() error {
+	r.chunks = nil
+	r.data = nil
+	return nil
+}
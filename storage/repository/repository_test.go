@@ -0,0 +1,644 @@
+package repository_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"goarchive/core"
+	"goarchive/core/coretest"
+	"goarchive/storage/repository"
+)
+
+// newRepository creates a repository on an empty memory storage
+func newRepository(t *testing.T) (*repository.Provider, *coretest.MemoryStorage) {
+	t.Helper()
+	t.Cleanup(coretest.ResetSharedMemoryStorages)
+
+	provider, err := repository.New(context.Background(), &core.StorageConfig{
+		Type:     "repository",
+		Backends: []string{"memory?bucket=repository"},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return provider, coretest.SharedMemoryStorage("repository")
+}
+
+// randomData returns reproducible data that does not compress or repeat
+func randomData(seed int64, size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func testMetadata(day int) *core.BackupMetadata {
+	return &core.BackupMetadata{
+		DatabaseName: "orders",
+		DatabaseType: "postgres",
+		Timestamp:    time.Date(2026, 3, day, 2, 0, 0, 0, time.UTC),
+		Tags:         map[string]string{"server-version": "16.2"},
+	}
+}
+
+func upload(t *testing.T, provider *repository.Provider, data []byte, day int) *core.BackupMetadata {
+	t.Helper()
+
+	metadata := testMetadata(day)
+	if err := provider.Upload(context.Background(), bytes.NewReader(data), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	return metadata
+}
+
+func download(t *testing.T, provider *repository.Provider, backupID string) []byte {
+	t.Helper()
+
+	reader, err := provider.Download(context.Background(), backupID)
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read download: %v", err)
+	}
+	return data
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		backends []string
+		wantErr  string
+	}{
+		{name: "memory backend", backends: []string{"memory?bucket=a"}},
+		{name: "no backend", wantErr: "storage backend is required"},
+		{name: "two backends", backends: []string{"memory?bucket=a", "memory?bucket=b"}, wantErr: "takes one storage backend"},
+		{name: "nested repository", backends: []string{"repository"}, wantErr: "cannot be a repository"},
+		{name: "missing type", backends: []string{"?bucket=a"}, wantErr: "has no type"},
+		{name: "unknown setting", backends: []string{"memory?colour=red"}, wantErr: "unknown setting"},
+		{name: "unknown storage type", backends: []string{"tape"}, wantErr: "failed to create tape storage"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(coretest.ResetSharedMemoryStorages)
+
+			_, err := repository.New(context.Background(), &core.StorageConfig{Type: "repository", Backends: tt.backends})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("New() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	t.Cleanup(coretest.ResetSharedMemoryStorages)
+
+	provider, err := core.GetStorage(context.Background(), "repository", &core.StorageConfig{Backends: []string{"memory"}})
+	if err != nil {
+		t.Fatalf("GetStorage() error = %v", err)
+	}
+	if provider == nil {
+		t.Error("expected non-nil provider")
+	}
+}
+
+func TestUpload_Deduplicates(t *testing.T) {
+	provider, store := newRepository(t)
+
+	monday := randomData(1, 12<<20)
+	mondayMeta := upload(t, provider, monday, 2)
+	stored := store.Len()
+
+	sum := md5.Sum(monday)
+	if mondayMeta.Checksum != hex.EncodeToString(sum[:]) || mondayMeta.Size != int64(len(monday)) {
+		t.Errorf("checksum %s and size %d, want those of the backup", mondayMeta.Checksum, mondayMeta.Size)
+	}
+
+	// A day later, rows were inserted near the start and appended at the
+	// end, shifting everything after the insert
+	tuesday := append(append(append([]byte{}, monday[:1<<20]...), []byte("INSERT INTO orders ...")...), monday[1<<20:]...)
+	tuesday = append(tuesday, randomData(2, 100<<10)...)
+	upload(t, provider, tuesday, 3)
+
+	// Chunk boundaries follow the content, so only the chunks around the
+	// changes are new
+	if stored < 8 {
+		t.Fatalf("expected the first backup split into several chunks, got %d objects", stored)
+	}
+	newObjects := store.Len() - stored
+	if newObjects < 2 || newObjects > 4 {
+		t.Errorf("second backup stored %d objects, want its snapshot and one to three chunks", newObjects)
+	}
+
+	// Identical backups only add a snapshot
+	stored = store.Len()
+	upload(t, provider, tuesday, 4)
+	if store.Len() != stored+1 {
+		t.Errorf("identical backup stored %d objects, want only its snapshot", store.Len()-stored)
+	}
+
+	backups, err := provider.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 3 {
+		t.Fatalf("expected 3 backups, got %d", len(backups))
+	}
+	if backups[0].Tags["repository-new-chunks"] != "0" {
+		t.Errorf("expected no new chunks for the identical backup, got %v", backups[0].Tags)
+	}
+
+	if got := download(t, provider, "orders_postgres_20260302-020000.dump"); !bytes.Equal(got, monday) {
+		t.Error("first backup does not round-trip")
+	}
+	if got := download(t, provider, "orders_postgres_20260303-020000.dump"); !bytes.Equal(got, tuesday) {
+		t.Error("second backup does not round-trip")
+	}
+}
+
+func TestUpload_SmallAndEmpty(t *testing.T) {
+	provider, _ := newRepository(t)
+
+	for day, data := range [][]byte{{}, []byte("small backup")} {
+		metadata := upload(t, provider, data, day+1)
+		if metadata.Size != int64(len(data)) {
+			t.Errorf("Size = %d, want %d", metadata.Size, len(data))
+		}
+		id := "orders_postgres_" + metadata.Timestamp.Format("20060102-150405") + ".dump"
+		if got := download(t, provider, id); !bytes.Equal(got, data) {
+			t.Errorf("Download() = %q, want %q", got, data)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	provider, store := newRepository(t)
+
+	data := randomData(1, 3<<20)
+	metadata := upload(t, provider, data, 2)
+
+	backups, err := provider.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	// Chunks are stored under nested keys and not listed
+	if len(backups) != 1 || store.Len() < 2 {
+		t.Fatalf("expected one backup over several stored objects, got %d of %d", len(backups), store.Len())
+	}
+	backup := backups[0]
+	if backup.ID != "orders_postgres_20260302-020000.dump" {
+		t.Errorf("ID = %q", backup.ID)
+	}
+	if backup.Size != int64(len(data)) || backup.Checksum != metadata.Checksum {
+		t.Errorf("size %d and checksum %s, want those of the backup", backup.Size, backup.Checksum)
+	}
+	if backup.Tags["server-version"] != "16.2" || backup.Tags["repository-chunks"] == "" {
+		t.Errorf("unexpected tags: %v", backup.Tags)
+	}
+	if _, ok := backup.Tags["repository-size"]; ok {
+		t.Error("expected the size tag to be applied, not listed")
+	}
+}
+
+func TestDelete_CollectsGarbage(t *testing.T) {
+	provider, store := newRepository(t)
+	ctx := context.Background()
+
+	first := randomData(1, 6<<20)
+	second := append(append([]byte{}, first[:3<<20]...), randomData(2, 3<<20)...)
+	upload(t, provider, first, 2)
+	upload(t, provider, second, 3)
+	both := store.Len()
+
+	// Chunks only the first backup uses are removed, shared ones are kept
+	if err := provider.Delete(ctx, "orders_postgres_20260302-020000.dump"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if store.Len() >= both-1 {
+		t.Errorf("expected unused chunks to be removed, %d of %d objects remain", store.Len(), both)
+	}
+	if got := download(t, provider, "orders_postgres_20260303-020000.dump"); !bytes.Equal(got, second) {
+		t.Error("remaining backup does not round-trip")
+	}
+
+	if err := provider.Delete(ctx, "orders_postgres_20260303-020000.dump"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if store.Len() != 0 {
+		t.Errorf("expected an empty repository, %d objects remain", store.Len())
+	}
+
+	if err := provider.Delete(ctx, "missing.dump"); !errors.Is(err, core.ErrBackupNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestDelete_FailsClosed(t *testing.T) {
+	ctx := context.Background()
+	first, second := "orders_postgres_20260302-020000.dump", "orders_postgres_20260303-020000.dump"
+
+	tests := []struct {
+		name    string
+		backend string
+		fault   func(store *coretest.MemoryStorage)
+		wantErr string
+	}{
+		{
+			name:    "listing fails",
+			backend: "memory?bucket=repository",
+			fault: func(store *coretest.MemoryStorage) {
+				// The first listing checks the locks, the second counts references
+				store.Queue(coretest.OpList, coretest.Fault{}, coretest.Fault{Err: errors.New("bucket unavailable")})
+			},
+			wantErr: "bucket unavailable",
+		},
+		{
+			name:    "index unreadable",
+			backend: "memory?bucket=repository",
+			fault: func(store *coretest.MemoryStorage) {
+				// The deleted snapshot is read first, then the index
+				store.Queue(coretest.OpDownload, coretest.Fault{}, coretest.Fault{Err: errors.New("connection reset")})
+			},
+			wantErr: "connection reset",
+		},
+		{
+			name:    "other snapshot unreadable",
+			backend: "memory?bucket=repository",
+			fault: func(store *coretest.MemoryStorage) {
+				// Without an index, the deleted snapshot is read first, then
+				// the missing index, then the other snapshot, listed first
+				if err := store.Delete(ctx, "index/references.json"); err != nil {
+					t.Fatal(err)
+				}
+				store.Queue(coretest.OpDownload, coretest.Fault{}, coretest.Fault{}, coretest.Fault{Err: errors.New("connection reset")})
+			},
+			wantErr: "connection reset",
+		},
+		{
+			name:    "listing incomplete",
+			backend: "hiding?bucket=repository",
+			wantErr: "listing of the repository is incomplete",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(coretest.ResetSharedMemoryStorages)
+			provider, err := repository.New(ctx, &core.StorageConfig{Type: "repository", Backends: []string{tt.backend}})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			store := coretest.SharedMemoryStorage("repository")
+
+			// The backups share their chunks
+			data := randomData(1, 3<<20)
+			upload(t, provider, data, 2)
+			upload(t, provider, data, 3)
+			if tt.fault != nil {
+				tt.fault(store)
+			}
+			objects := store.Len()
+			if err := provider.Delete(ctx, first); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected Delete() to fail with %q, got %v", tt.wantErr, err)
+			}
+
+			store.Reset()
+			if store.Len() != objects {
+				t.Errorf("expected nothing to be removed, %d of %d objects remain", store.Len(), objects)
+			}
+			if got := download(t, provider, second); !bytes.Equal(got, data) {
+				t.Error("remaining backup does not round-trip")
+			}
+		})
+	}
+}
+
+// hidingStorage is a memory storage whose listings leave out the first test
+// backup, as a backend returning part of its listing would
+type hidingStorage struct {
+	*coretest.MemoryStorage
+}
+
+func (s hidingStorage) List(ctx context.Context) ([]*core.BackupMetadata, error) {
+	backups, err := s.MemoryStorage.List(ctx)
+	var listed []*core.BackupMetadata
+	for _, backup := range backups {
+		if backup.ID != "orders_postgres_20260302-020000.dump" {
+			listed = append(listed, backup)
+		}
+	}
+	return listed, err
+}
+
+func init() {
+	core.RegisterStorage("hiding", func(ctx context.Context, config *core.StorageConfig) (core.StorageProvider, error) {
+		return hidingStorage{coretest.SharedMemoryStorage(config.Bucket)}, nil
+	})
+}
+
+func TestIndex(t *testing.T) {
+	provider, store := newRepository(t)
+	ctx := context.Background()
+
+	for day := 1; day <= 5; day++ {
+		upload(t, provider, randomData(int64(day), 2<<20), day)
+	}
+
+	// Uploads and deletes read the index rather than every snapshot
+	downloads := store.Calls(coretest.OpDownload)
+	upload(t, provider, randomData(6, 2<<20), 6)
+	if got := store.Calls(coretest.OpDownload) - downloads; got != 1 {
+		t.Errorf("expected Upload() to read the index only, got %d downloads", got)
+	}
+
+	downloads = store.Calls(coretest.OpDownload)
+	if err := provider.Delete(ctx, "orders_postgres_20260301-020000.dump"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got := store.Calls(coretest.OpDownload) - downloads; got != 2 {
+		t.Errorf("expected Delete() to read the snapshot and the index only, got %d downloads", got)
+	}
+
+	// A lost index is rebuilt from the snapshots
+	if err := store.Delete(ctx, "index/references.json"); err != nil {
+		t.Fatal(err)
+	}
+	shared := randomData(2, 2<<20)
+	upload(t, provider, shared, 7)
+	if err := provider.Delete(ctx, "orders_postgres_20260302-020000.dump"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got := download(t, provider, "orders_postgres_20260307-020000.dump"); !bytes.Equal(got, shared) {
+		t.Error("backup sharing the deleted backup's chunks does not round-trip")
+	}
+
+	for _, day := range []string{"03", "04", "05", "06", "07"} {
+		if err := provider.Delete(ctx, "orders_postgres_202603"+day+"-020000.dump"); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+	}
+	if store.Len() != 0 {
+		t.Errorf("expected an empty repository, %d objects remain", store.Len())
+	}
+}
+
+func TestUpload_FailureRemovesChunks(t *testing.T) {
+	provider, store := newRepository(t)
+
+	// The lock and the first chunk are stored, the second chunk fails
+	store.Queue(coretest.OpUpload, coretest.Fault{}, coretest.Fault{}, coretest.Fault{Err: errors.New("bucket unavailable")})
+
+	metadata := testMetadata(2)
+	err := provider.Upload(context.Background(), bytes.NewReader(randomData(1, 6<<20)), metadata)
+	if err == nil || !strings.Contains(err.Error(), "bucket unavailable") {
+		t.Fatalf("expected the storage failure, got %v", err)
+	}
+	if store.Len() != 0 {
+		t.Errorf("expected the stored chunks to be removed, %d objects remain", store.Len())
+	}
+}
+
+func TestUpload_FailureKeepsChunksWhileLocked(t *testing.T) {
+	provider, store := newRepository(t)
+
+	// Another process is uploading, and may reuse the chunks stored
+	store.Put("repository-lock-shared-0123456789abcdef.dump", nil, &core.BackupMetadata{Timestamp: time.Now()})
+	store.Queue(coretest.OpUpload, coretest.Fault{}, coretest.Fault{}, coretest.Fault{Err: errors.New("bucket unavailable")})
+
+	err := provider.Upload(context.Background(), bytes.NewReader(randomData(1, 6<<20)), testMetadata(2))
+	if err == nil || !strings.Contains(err.Error(), "bucket unavailable") {
+		t.Fatalf("expected the storage failure, got %v", err)
+	}
+	if store.Len() != 2 {
+		t.Errorf("expected the other lock and the stored chunk to remain, %d objects remain", store.Len())
+	}
+}
+
+func TestDelete_WhileUploading(t *testing.T) {
+	provider, store := newRepository(t)
+	ctx := context.Background()
+
+	data := randomData(1, 3<<20)
+	upload(t, provider, data, 2)
+
+	// A second upload of the same data reuses every chunk, and its snapshot
+	// is stored slowly: its lock is stored first, then the snapshot
+	uploads := store.Calls(coretest.OpUpload)
+	store.Queue(coretest.OpUpload, coretest.Fault{}, coretest.Fault{Delay: 500 * time.Millisecond})
+	done := make(chan error)
+	go func() {
+		done <- provider.Upload(ctx, bytes.NewReader(data), testMetadata(3))
+	}()
+	for store.Calls(coretest.OpUpload) < uploads+2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Deleting the first backup now would remove the chunks the second
+	// reuses before its snapshot refers to them
+	err := provider.Delete(ctx, "orders_postgres_20260302-020000.dump")
+	if err == nil || !strings.Contains(err.Error(), "repository is locked") {
+		t.Errorf("expected the delete to be refused, got %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	for _, id := range []string{"orders_postgres_20260302-020000.dump", "orders_postgres_20260303-020000.dump"} {
+		if got := download(t, provider, id); !bytes.Equal(got, data) {
+			t.Errorf("%s does not round-trip", id)
+		}
+	}
+
+	// Once the upload is done, the delete succeeds and keeps the shared chunks
+	if err := provider.Delete(ctx, "orders_postgres_20260302-020000.dump"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got := download(t, provider, "orders_postgres_20260303-020000.dump"); !bytes.Equal(got, data) {
+		t.Error("remaining backup does not round-trip")
+	}
+}
+
+func TestLocks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("stale locks are ignored", func(t *testing.T) {
+		provider, store := newRepository(t)
+		store.Put("repository-lock-exclusive-0123456789abcdef.dump", nil, &core.BackupMetadata{Timestamp: time.Now().Add(-time.Hour)})
+
+		data := randomData(1, 1<<20)
+		upload(t, provider, data, 2)
+		if err := provider.Delete(ctx, "orders_postgres_20260302-020000.dump"); err != nil {
+			t.Errorf("Delete() error = %v", err)
+		}
+	})
+
+	t.Run("uploads wait for deletes", func(t *testing.T) {
+		provider, store := newRepository(t)
+		store.Put("repository-lock-exclusive-0123456789abcdef.dump", nil, &core.BackupMetadata{Timestamp: time.Now()})
+
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		err := provider.Upload(ctx, bytes.NewReader(randomData(1, 1<<20)), testMetadata(2))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the upload to wait for the lock, got %v", err)
+		}
+		if store.Len() != 1 {
+			t.Errorf("expected only the other lock to remain, %d objects remain", store.Len())
+		}
+	})
+
+	t.Run("locks are not listed", func(t *testing.T) {
+		provider, store := newRepository(t)
+		store.Put("repository-lock-shared-0123456789abcdef.dump", nil, &core.BackupMetadata{Timestamp: time.Now()})
+
+		backups, err := provider.List(ctx)
+		if err != nil || len(backups) != 0 {
+			t.Errorf("expected no backups, got %v (%v)", backups, err)
+		}
+	})
+}
+
+func TestUpload_ReadError(t *testing.T) {
+	provider, store := newRepository(t)
+
+	reader := io.MultiReader(bytes.NewReader(randomData(1, 10<<20)), &failingReader{errors.New("database connection lost")})
+	err := provider.Upload(context.Background(), reader, testMetadata(2))
+	if err == nil || !strings.Contains(err.Error(), "database connection lost") {
+		t.Fatalf("expected the read error, got %v", err)
+	}
+	if store.Len() != 0 {
+		t.Errorf("expected nothing stored after a failed read, %d objects remain", store.Len())
+	}
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func TestDownload_CorruptChunk(t *testing.T) {
+	provider, store := newRepository(t)
+
+	upload(t, provider, randomData(1, 3<<20), 2)
+
+	// The snapshot is downloaded first, then its chunks
+	store.Queue(coretest.OpDownload, coretest.Fault{}, coretest.Fault{Corrupt: []int64{100}})
+
+	reader, err := provider.Download(context.Background(), "orders_postgres_20260302-020000.dump")
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	defer reader.Close()
+
+	if _, err := io.ReadAll(reader); err == nil || !strings.Contains(err.Error(), "is corrupt") {
+		t.Errorf("expected a corrupt chunk error, got %v", err)
+	}
+}
+
+func TestDownload_Missing(t *testing.T) {
+	provider, _ := newRepository(t)
+
+	_, err := provider.Download(context.Background(), "missing.dump")
+	if !errors.Is(err, core.ErrBackupNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestBackupsStoredInFull(t *testing.T) {
+	provider, store := newRepository(t)
+	ctx := context.Background()
+
+	// A backup written before the storage became a repository
+	store.Put("orders_postgres_20260101-020000.dump", []byte("full backup"), testMetadata(1))
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 || backups[0].Size != int64(len("full backup")) {
+		t.Errorf("expected the stored backup, got %+v", backups)
+	}
+
+	if got := download(t, provider, "orders_postgres_20260101-020000.dump"); string(got) != "full backup" {
+		t.Errorf("Download() = %q", got)
+	}
+
+	// New backups deduplicate alongside it
+	upload(t, provider, randomData(1, 1<<20), 2)
+
+	if err := provider.Delete(ctx, "orders_postgres_20260101-020000.dump"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok := store.Data("orders_postgres_20260101-020000.dump"); ok {
+		t.Error("expected the backup to be deleted")
+	}
+}
+
+func TestNestedKeys(t *testing.T) {
+	provider, store := newRepository(t)
+	ctx := context.Background()
+
+	// WAL segments and other nested keys are stored as they are
+	metadata := &core.BackupMetadata{Key: "wal/00000001/000000010000000000000001.gz", Timestamp: time.Now()}
+	if err := provider.Upload(ctx, strings.NewReader("segment"), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if data, ok := store.Data(metadata.Key); !ok || string(data) != "segment" {
+		t.Errorf("expected the segment stored in full, got %q", data)
+	}
+
+	if got := download(t, provider, metadata.Key); string(got) != "segment" {
+		t.Errorf("Download() = %q", got)
+	}
+	if err := provider.Delete(ctx, metadata.Key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if store.Len() != 0 {
+		t.Errorf("expected the segment to be deleted, %d objects remain", store.Len())
+	}
+}
+
+func TestBackupService_Repository(t *testing.T) {
+	provider, _ := newRepository(t)
+	ctx := context.Background()
+
+	db := coretest.NewFakeDatabase(randomData(1, 2<<20))
+	service := core.NewBackupService(db, provider)
+
+	if _, err := service.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	backups, err := service.List(ctx)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("List() = %v, %v", backups, err)
+	}
+	if err := service.Restore(ctx, backups[0].ID); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	restores := db.Restores()
+	if len(restores) != 1 || !bytes.Equal(restores[0].Data, db.Data) {
+		t.Error("expected the backup to be restored as it was taken")
+	}
+}
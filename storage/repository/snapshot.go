@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// snapshotHeader starts every snapshot, telling snapshots apart from
+// backups stored in full before the storage became a repository
+const snapshotHeader = "goarchive-snapshot 1\n"
+
+// errNotSnapshot is returned when reading a backup that is not a snapshot
+var errNotSnapshot = errors.New("not a repository snapshot")
+
+// snapshot lists the chunks of a backup in order
+type snapshot struct {
+	Size     int64      `json:"size"`
+	Checksum string     `json:"checksum"` // MD5 of the whole backup
+	Chunks   []chunkRef `json:"chunks"`
+}
+
+// chunkRef refers to a stored chunk
+type chunkRef struct {
+	Hash string `json:"hash"` // SHA-256, naming the chunk in the repository
+	Size int64  `json:"size"`
+}
+
+// encode returns the stored form of s
+func (s *snapshot) encode() ([]byte, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	return append([]byte(snapshotHeader), data...), nil
+}
+
+// decodeSnapshot reads a snapshot from r. It returns errNotSnapshot, and a
+// reader over the whole of r, when r holds a backup stored in full.
+func decodeSnapshot(r io.Reader) (*snapshot, io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(len(snapshotHeader))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	if !bytes.Equal(header, []byte(snapshotHeader)) {
+		return nil, buffered, errNotSnapshot
+	}
+
+	if _, err := buffered.Discard(len(snapshotHeader)); err != nil {
+		return nil, nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var s snapshot
+	if err := json.NewDecoder(buffered).Decode(&s); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	return &s, nil, nil
+}
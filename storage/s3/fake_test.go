@@ -0,0 +1,424 @@
+package s3_test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeObject is an object stored by fakeS3
+type fakeObject struct {
+	data     []byte
+	size     int64 // Reported size, when larger than the data held
+	metadata http.Header
+	tags     url.Values
+	class    string // Empty for STANDARD, as S3 reports it
+	restore  string // x-amz-restore header, empty before a retrieval
+	heads    int    // HEAD requests since the retrieval was requested
+}
+
+// fakeUpload is a multipart upload in progress
+type fakeUpload struct {
+	key    string
+	object *fakeObject
+	parts  map[int][]byte
+	size   int64 // Bytes copied from ranges of other objects
+}
+
+// fakeS3 serves the path-style S3 requests the provider makes, storing
+// objects in memory. Listings return pageSize keys per page, 1000 when
+// unset, as S3 does. Retrievals of archived objects complete after
+// restoreAfter HEAD requests, or never when it is negative.
+type fakeS3 struct {
+	mu           sync.Mutex
+	bucket       string
+	objects      map[string]*fakeObject
+	pageSize     int
+	restoreAfter int
+	restores     []string // Bodies of RestoreObject requests
+	uploads      map[string]*fakeUpload
+	completed    int      // Multipart uploads completed
+	copies       int      // CopyObject requests
+	copyRanges   []string // Source ranges of UploadPartCopy requests
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{bucket: bucket, objects: make(map[string]*fakeObject), uploads: make(map[string]*fakeUpload)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	query := r.URL.Query()
+	if key == "" && r.Method == http.MethodGet {
+		f.list(w, query)
+		return
+	}
+	if query.Has("tagging") {
+		f.tagging(w, r, key)
+		return
+	}
+	if query.Has("uploads") || query.Has("uploadId") {
+		f.multipart(w, r, key)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			f.copy(w, r, key, source)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = newFakeObject(r, data)
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodHead:
+		obj, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if obj.restore != "" {
+			obj.heads++
+			if f.restoreAfter >= 0 && obj.heads >= f.restoreAfter {
+				obj.restore = `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`
+			}
+		}
+		obj.writeHeaders(w)
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodGet:
+		obj, ok := f.objects[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if (obj.class == "GLACIER" || obj.class == "DEEP_ARCHIVE") && !strings.Contains(obj.restore, `"false"`) {
+			writeError(w, http.StatusForbidden, "InvalidObjectState")
+			return
+		}
+		obj.writeHeaders(w)
+		w.Write(obj.data)
+
+	case http.MethodPost:
+		obj, ok := f.objects[key]
+		if !ok || !r.URL.Query().Has("restore") {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if obj.restore != "" {
+			writeError(w, http.StatusConflict, "RestoreAlreadyInProgress")
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.restores = append(f.restores, string(body))
+		obj.restore = `ongoing-request="true"`
+		w.WriteHeader(http.StatusAccepted)
+
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// newFakeObject returns an object with the data, metadata, tags and storage
+// class of an upload request
+func newFakeObject(r *http.Request, data []byte) *fakeObject {
+	tags, _ := url.ParseQuery(r.Header.Get("X-Amz-Tagging"))
+	return &fakeObject{data: data, metadata: userMetadata(r.Header), tags: tags, class: r.Header.Get("X-Amz-Storage-Class")}
+}
+
+// tagging handles PutObjectTagging and GetObjectTagging
+func (f *fakeS3) tagging(w http.ResponseWriter, r *http.Request, key string) {
+	obj, ok := f.objects[key]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	type tag struct {
+		Key   string
+		Value string
+	}
+	type tagging struct {
+		XMLName xml.Name `xml:"Tagging"`
+		TagSet  []tag    `xml:"TagSet>Tag"`
+	}
+
+	if r.Method == http.MethodPut {
+		var body tagging
+		if err := xml.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		obj.tags = make(url.Values)
+		for _, t := range body.TagSet {
+			obj.tags.Set(t.Key, t.Value)
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var result tagging
+	for name := range obj.tags {
+		result.TagSet = append(result.TagSet, tag{Key: name, Value: obj.tags.Get(name)})
+	}
+	xml.NewEncoder(w).Encode(result)
+}
+
+// multipart handles CreateMultipartUpload, UploadPart,
+// CompleteMultipartUpload and AbortMultipartUpload
+func (f *fakeS3) multipart(w http.ResponseWriter, r *http.Request, key string) {
+	query := r.URL.Query()
+	if r.Method == http.MethodPost && query.Has("uploads") {
+		id := fmt.Sprintf("upload-%d", len(f.uploads)+f.completed+1)
+		f.uploads[id] = &fakeUpload{key: key, object: newFakeObject(r, nil), parts: make(map[int][]byte)}
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, f.bucket, key, id)
+		return
+	}
+
+	id := query.Get("uploadId")
+	upload, ok := f.uploads[id]
+	if !ok || upload.key != key {
+		writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			f.copyPart(w, r, upload, number, source)
+			return
+		}
+		upload.parts[number], _ = io.ReadAll(r.Body)
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, number))
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodPost:
+		numbers := make([]int, 0, len(upload.parts))
+		for number := range upload.parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		for _, number := range numbers {
+			upload.object.data = append(upload.object.data, upload.parts[number]...)
+		}
+		upload.object.size = upload.size
+		f.objects[key] = upload.object
+		delete(f.uploads, id)
+		f.completed++
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>"etag-%d"</ETag></CompleteMultipartUploadResult>`, f.bucket, key, len(numbers))
+
+	case http.MethodDelete:
+		delete(f.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// copyPart handles UploadPartCopy. Objects may report a size larger than
+// the data they hold, so ranges are clipped to the data.
+func (f *fakeS3) copyPart(w http.ResponseWriter, r *http.Request, upload *fakeUpload, number int, source string) {
+	obj, ok := f.source(source)
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	copyRange := r.Header.Get("X-Amz-Copy-Source-Range")
+	var first, last int64
+	if _, err := fmt.Sscanf(copyRange, "bytes=%d-%d", &first, &last); err != nil || first > last || last >= obj.length() {
+		writeError(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	f.copyRanges = append(f.copyRanges, copyRange)
+
+	data := obj.data[min(first, int64(len(obj.data))):min(last+1, int64(len(obj.data)))]
+	upload.parts[number] = append([]byte(nil), data...)
+	upload.size += last - first + 1
+
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><CopyPartResult><ETag>"part-%d"</ETag></CopyPartResult>`, number)
+}
+
+// copy handles CopyObject, which the provider only uses to change the
+// storage class of an object
+func (f *fakeS3) copy(w http.ResponseWriter, r *http.Request, key string, source string) {
+	obj, ok := f.source(source)
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	f.copies++
+
+	copied := *obj
+	copied.class = r.Header.Get("X-Amz-Storage-Class")
+	if copied.class == "STANDARD" {
+		copied.class = ""
+	}
+	copied.restore, copied.heads = "", 0
+	f.objects[key] = &copied
+
+	fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`)
+}
+
+// source returns the object named by an x-amz-copy-source header
+func (f *fakeS3) source(source string) (*fakeObject, bool) {
+	source, _ = url.PathUnescape(source)
+	obj, ok := f.objects[strings.TrimPrefix(strings.TrimPrefix(source, "/"), f.bucket+"/")]
+	return obj, ok
+}
+
+// list handles ListObjectsV2, grouping keys below the delimiter into
+// common prefixes
+func (f *fakeS3) list(w http.ResponseWriter, query url.Values) {
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+
+	// Entries are object keys, or common prefixes ending with the delimiter
+	seen := make(map[string]bool)
+	var entries []string
+	for key := range f.objects {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		entry := key
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			entry = prefix + rest[:i+len(delimiter)]
+		}
+		if !seen[entry] {
+			seen[entry] = true
+			entries = append(entries, entry)
+		}
+	}
+	sort.Strings(entries)
+
+	// Continuation tokens are the last entry of the previous page
+	if token := query.Get("continuation-token"); token != "" {
+		i := sort.SearchStrings(entries, token)
+		if i < len(entries) && entries[i] == token {
+			i++
+		}
+		entries = entries[i:]
+	}
+
+	pageSize := f.pageSize
+	if pageSize == 0 {
+		pageSize = 1000
+	}
+
+	type content struct {
+		Key          string
+		LastModified string
+		Size         int64
+		StorageClass string
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Name                  string
+		Prefix                string
+		KeyCount              int
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+		Contents              []content
+		CommonPrefixes        []commonPrefix
+	}{Name: f.bucket, Prefix: prefix}
+
+	if len(entries) > pageSize {
+		entries = entries[:pageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = entries[len(entries)-1]
+	}
+
+	for _, entry := range entries {
+		obj, ok := f.objects[entry]
+		if !ok {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: entry})
+			continue
+		}
+		class := obj.class
+		if class == "" {
+			class = "STANDARD"
+		}
+		result.Contents = append(result.Contents, content{
+			Key:          entry,
+			LastModified: time.Now().UTC().Format(time.RFC3339),
+			Size:         obj.length(),
+			StorageClass: class,
+		})
+	}
+	result.KeyCount = len(entries)
+
+	xml.NewEncoder(w).Encode(result)
+}
+
+// put stores an object directly, as if uploaded earlier
+func (f *fakeS3) put(key string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.objects[key] = &fakeObject{data: data, metadata: make(http.Header)}
+}
+
+func (o *fakeObject) writeHeaders(w http.ResponseWriter) {
+	for name, values := range o.metadata {
+		w.Header()[name] = values
+	}
+	if o.class != "" {
+		w.Header().Set("X-Amz-Storage-Class", o.class)
+	}
+	if o.restore != "" {
+		w.Header().Set("X-Amz-Restore", o.restore)
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(o.length()))
+}
+
+// length returns the size S3 reports for the object
+func (o *fakeObject) length() int64 {
+	return max(o.size, int64(len(o.data)))
+}
+
+// userMetadata returns the x-amz-meta- headers of a request
+func userMetadata(header http.Header) http.Header {
+	metadata := make(http.Header)
+	for name, values := range header {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") {
+			metadata[name] = values
+		}
+	}
+	return metadata
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
@@ -1,13 +1,16 @@
 package s3
 
 import (
-	"bytes"
 	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path"
+	"strings"
+	"sync"
 	"time"
 
 	"goarchive/core"
@@ -15,7 +18,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 // init registers the S3 provider with the global registry
@@ -25,17 +30,36 @@ func init() {
 	})
 }
 
-// Provider implements the StorageProvider interface for AWS S3
+const (
+	// partSize is the size of each part of a multipart upload. An upload
+	// has at most 10000 parts, so backups are limited to about 625 GiB.
+	partSize = 64 << 20
+
+	// partConcurrency is the number of parts uploaded at once, and so the
+	// number of parts held in memory
+	partConcurrency = 4
+
+	// lookupConcurrency is the number of objects whose metadata List reads
+	// at once
+	lookupConcurrency = 16
+)
+
+// Provider implements the StorageProvider and Tierer interfaces for AWS S3
 type Provider struct {
-	client *s3.Client
-	config *core.StorageConfig
+	client        *s3.Client
+	config        *core.StorageConfig
+	retrievalTier types.Tier
 }
 
 // New creates a new S3 provider
 func New(ctx context.Context, storageConfig *core.StorageConfig) (*Provider, error) {
+	retrievalTier, err := parseRetrievalTier(storageConfig.RetrievalTier)
+	if err != nil {
+		return nil, err
+	}
+
 	// Load AWS config
 	var cfg aws.Config
-	var err error
 
 	// Build config options
 	configOpts := []func(*config.LoadOptions) error{
@@ -71,87 +95,142 @@ func New(ctx context.Context, storageConfig *core.StorageConfig) (*Provider, err
 	}
 
 	return &Provider{
-		client: client,
-		config: storageConfig,
+		client:        client,
+		config:        storageConfig,
+		retrievalTier: retrievalTier,
 	}, nil
 }
 
-// Upload uploads the backup data to S3
+// Upload streams the backup data to S3 in a multipart upload, holding at
+// most partConcurrency parts in memory
 func (p *Provider) Upload(ctx context.Context, reader io.Reader, metadata *core.BackupMetadata) error {
-	// Read all data and calculate checksum
-	data, err := io.ReadAll(reader)
-	if err != nil {
-		return fmt.Errorf("failed to read backup data: %w", err)
-	}
-
-	// Calculate MD5 checksum
-	hash := md5.Sum(data)
-	checksum := hex.EncodeToString(hash[:])
-	metadata.Checksum = checksum
-	metadata.Size = int64(len(data))
-
-	// Create S3 key
 	key := p.getBackupKey(metadata)
 
-	// Upload to S3
-	_, err = p.client.PutObject(ctx, &s3.PutObjectInput{
+	// The uploader reads the body in order, so the hash sees the whole stream
+	hash := md5.New()
+	counter := &countingWriter{}
+	body := io.TeeReader(reader, io.MultiWriter(hash, counter))
+
+	uploader := manager.NewUploader(p.client, func(u *manager.Uploader) {
+		u.PartSize = partSize
+		u.Concurrency = partConcurrency
+	})
+	_, err := uploader.Upload(ctx, &s3.PutObjectInput{
 		Bucket:      aws.String(p.config.Bucket),
 		Key:         aws.String(key),
-		Body:        bytes.NewReader(data),
+		Body:        body,
 		ContentType: aws.String("application/octet-stream"),
-		Metadata: map[string]string{
-			"database-name": metadata.DatabaseName,
-			"database-type": metadata.DatabaseType,
-			"backup-id":     metadata.ID,
-			"timestamp":     metadata.Timestamp.Format(time.RFC3339),
-			"checksum":      checksum,
-		},
-		Tagging: aws.String("Type=DatabaseBackup&Source=" + metadata.DatabaseType),
+		Metadata:    objectMetadata(metadata),
+		Tagging:     aws.String("Type=DatabaseBackup&Source=" + metadata.DatabaseType),
 	})
-
 	if err != nil {
 		return fmt.Errorf("failed to upload to S3: %w", err)
 	}
 
-	return nil
-}
+	metadata.Checksum = hex.EncodeToString(hash.Sum(nil))
+	metadata.Size = counter.n
 
-// List lists available backups
-func (p *Provider) List(ctx context.Context) ([]*core.BackupMetadata, error) {
-	result, err := p.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+	// The checksum is only known once the data is stored, and unlike the
+	// object's metadata its tags can be changed in place
+	_, err = p.client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
 		Bucket: aws.String(p.config.Bucket),
-		Prefix: aws.String(p.config.Prefix),
+		Key:    aws.String(key),
+		Tagging: &types.Tagging{TagSet: []types.Tag{
+			{Key: aws.String("Type"), Value: aws.String("DatabaseBackup")},
+			{Key: aws.String("Source"), Value: aws.String(metadata.DatabaseType)},
+			{Key: aws.String(checksumTag), Value: aws.String(metadata.Checksum)},
+		}},
 	})
-
 	if err != nil {
-		return nil, fmt.Errorf("failed to list objects: %w", err)
+		fmt.Fprintf(os.Stderr, "Warning: failed to store the checksum of %s: %v\n", key, err)
 	}
 
+	return nil
+}
+
+// List lists available backups, reading every page of the listing
+func (p *Provider) List(ctx context.Context) ([]*core.BackupMetadata, error) {
+	// The delimiter leaves out objects stored under nested keys (e.g. WAL
+	// archives)
+	pages := s3.NewListObjectsV2Paginator(p.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(p.config.Bucket),
+		Prefix:    aws.String(p.listPrefix()),
+		Delimiter: aws.String("/"),
+	})
+
 	var backups []*core.BackupMetadata
-	for _, obj := range result.Contents {
-		// Parse timestamp from key
-		timestamp := *obj.LastModified
-
-		backup := &core.BackupMetadata{
-			ID:        path.Base(*obj.Key),
-			Timestamp: timestamp,
-			Size:      *obj.Size,
+	var keys []string
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		for _, obj := range page.Contents {
+			backups = append(backups, &core.BackupMetadata{
+				ID:        path.Base(*obj.Key),
+				Timestamp: *obj.LastModified,
+				Size:      *obj.Size,
+				Tier:      objectStorageClass(string(obj.StorageClass)),
+			})
+			keys = append(keys, *obj.Key)
 		}
-		backups = append(backups, backup)
 	}
 
+	p.describe(ctx, backups, keys)
 	return backups, nil
 }
 
-// Download downloads a backup from S3
+// describe fills in the metadata stored with each listed object, if it can
+// be read. The listing holds neither the user metadata nor the tags, so
+// they are read for lookupConcurrency objects at once.
+func (p *Provider) describe(ctx context.Context, backups []*core.BackupMetadata, keys []string) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, lookupConcurrency)
+	for i, backup := range backups {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			head, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{
+				Bucket: aws.String(p.config.Bucket),
+				Key:    aws.String(keys[i]),
+			})
+			if err == nil {
+				applyObjectMetadata(backup, head.Metadata)
+			}
+			if backup.Checksum == "" {
+				backup.Checksum = p.objectChecksum(ctx, keys[i])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Download downloads a backup from S3. Backups in an archive storage class
+// are retrieved first.
 func (p *Provider) Download(ctx context.Context, backupID string) (io.ReadCloser, error) {
 	key := path.Join(p.config.Prefix, backupID)
-
-	result, err := p.client.GetObject(ctx, &s3.GetObjectInput{
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(p.config.Bucket),
 		Key:    aws.String(key),
-	})
+	}
 
+	result, err := p.client.GetObject(ctx, input)
+	var archived *types.InvalidObjectState
+	if errors.As(err, &archived) {
+		if err := p.retrieve(ctx, backupID, key); err != nil {
+			return nil, err
+		}
+		result, err = p.client.GetObject(ctx, input)
+	}
+
+	var missing *types.NoSuchKey
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to download from S3: %w", err)
 	}
@@ -175,8 +254,21 @@ func (p *Provider) Delete(ctx context.Context, backupID string) error {
 	return nil
 }
 
+// listPrefix returns the prefix of the objects holding backups, which ends
+// with a slash unless backups are stored at the root of the bucket
+func (p *Provider) listPrefix() string {
+	prefix := strings.Trim(p.config.Prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
 // getBackupKey generates the S3 key for a backup
 func (p *Provider) getBackupKey(metadata *core.BackupMetadata) string {
+	if metadata.Key != "" {
+		return path.Join(p.config.Prefix, metadata.Key)
+	}
 	filename := fmt.Sprintf("%s_%s_%s.dump",
 		metadata.DatabaseName,
 		metadata.DatabaseType,
@@ -184,3 +276,74 @@ func (p *Provider) getBackupKey(metadata *core.BackupMetadata) string {
 	)
 	return path.Join(p.config.Prefix, filename)
 }
+
+// tagPrefix prefixes the user metadata keys holding backup tags
+const tagPrefix = "tag-"
+
+// checksumTag is the object tag holding the MD5 checksum of a backup
+const checksumTag = "Checksum"
+
+// objectChecksum returns the checksum tag of an object, or an empty string
+// when it cannot be read
+func (p *Provider) objectChecksum(ctx context.Context, key string) string {
+	tagging, err := p.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(p.config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return ""
+	}
+	for _, tag := range tagging.TagSet {
+		if aws.ToString(tag.Key) == checksumTag {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// objectMetadata returns the user metadata stored with a backup object
+func objectMetadata(metadata *core.BackupMetadata) map[string]string {
+	objectMeta := map[string]string{
+		"database-name": metadata.DatabaseName,
+		"database-type": metadata.DatabaseType,
+		"backup-id":     metadata.ID,
+		"timestamp":     metadata.Timestamp.Format(time.RFC3339),
+		"backup-mode":   metadata.BackupMode,
+	}
+	for key, value := range metadata.Tags {
+		// S3 returns user metadata keys in lower case
+		objectMeta[tagPrefix+strings.ToLower(key)] = value
+	}
+	return objectMeta
+}
+
+// applyObjectMetadata copies the user metadata stored by Upload into backup
+func applyObjectMetadata(backup *core.BackupMetadata, metadata map[string]string) {
+	backup.DatabaseName = metadata["database-name"]
+	backup.DatabaseType = metadata["database-type"]
+	backup.Checksum = metadata["checksum"] // Backups uploaded in one request
+	backup.BackupMode = metadata["backup-mode"]
+
+	for key, value := range metadata {
+		if tag, ok := strings.CutPrefix(key, tagPrefix); ok {
+			if backup.Tags == nil {
+				backup.Tags = make(map[string]string)
+			}
+			backup.Tags[tag] = value
+		}
+	}
+
+	if t, err := time.Parse(time.RFC3339, metadata["timestamp"]); err == nil {
+		backup.Timestamp = t
+	}
+}
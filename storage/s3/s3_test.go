@@ -3,8 +3,16 @@ package s3_test
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
 	"os"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"goarchive/core"
@@ -72,6 +80,172 @@ func TestNew(t *testing.T) {
 			t.Error("expected non-nil provider")
 		}
 	})
+
+	t.Run("with invalid retrieval tier", func(t *testing.T) {
+		ctx := context.Background()
+		config := &core.StorageConfig{
+			Type:          "s3",
+			Bucket:        "test-bucket",
+			Region:        "us-west-2",
+			RetrievalTier: "Fastest",
+		}
+
+		if _, err := s3.New(ctx, config); err == nil {
+			t.Error("expected an error for an invalid retrieval tier")
+		}
+	})
+}
+
+func TestProvider_Upload(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		size      int
+		multipart int
+	}{
+		{name: "single request", size: 1024, multipart: 0},
+		{name: "multipart", size: 64<<20 + 1<<20, multipart: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, fake, _ := newFakeS3Provider(t, nil)
+			data := make([]byte, tt.size)
+			rand.New(rand.NewSource(1)).Read(data)
+
+			// Hide the reader's other methods, as a database dump stream would
+			metadata := &core.BackupMetadata{DatabaseName: "big", DatabaseType: "postgres", Timestamp: time.Now()}
+			if err := provider.Upload(ctx, struct{ io.Reader }{bytes.NewReader(data)}, metadata); err != nil {
+				t.Fatalf("Upload() error = %v", err)
+			}
+
+			sum := md5.Sum(data)
+			checksum := hex.EncodeToString(sum[:])
+			if metadata.Checksum != checksum || metadata.Size != int64(tt.size) {
+				t.Errorf("expected checksum %s and size %d, got %s and %d", checksum, tt.size, metadata.Checksum, metadata.Size)
+			}
+			if fake.completed != tt.multipart {
+				t.Errorf("expected %d multipart uploads, got %d", tt.multipart, fake.completed)
+			}
+
+			id := fmt.Sprintf("big_postgres_%s.dump", metadata.Timestamp.Format("20060102-150405"))
+			reader, err := provider.Download(ctx, id)
+			if err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			downloaded, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil || !bytes.Equal(downloaded, data) {
+				t.Errorf("downloaded %d bytes differing from the %d uploaded (%v)", len(downloaded), len(data), err)
+			}
+
+			backups, err := provider.List(ctx)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			for _, backup := range backups {
+				if backup.ID == id && backup.Checksum != checksum {
+					t.Errorf("expected the listed checksum %s, got %q", checksum, backup.Checksum)
+				}
+			}
+		})
+	}
+
+	t.Run("read failure", func(t *testing.T) {
+		provider, fake, _ := newFakeS3Provider(t, nil)
+		reader := io.MultiReader(bytes.NewReader(make([]byte, 65<<20)), iotest.ErrReader(errors.New("dump failed")))
+
+		err := provider.Upload(ctx, reader, &core.BackupMetadata{DatabaseName: "big", DatabaseType: "postgres", Timestamp: time.Now()})
+		if err == nil || !strings.Contains(err.Error(), "dump failed") {
+			t.Errorf("expected the read failure, got %v", err)
+		}
+		if len(fake.uploads) != 0 || len(fake.objects) != 1 {
+			t.Errorf("expected the upload to be aborted, got %d uploads and %d objects", len(fake.uploads), len(fake.objects))
+		}
+	})
+}
+
+func TestProvider_DownloadMissing(t *testing.T) {
+	provider, _, _ := newFakeS3Provider(t, nil)
+
+	if _, err := provider.Download(context.Background(), "missing.dump"); !errors.Is(err, core.ErrBackupNotFound) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestProvider_List(t *testing.T) {
+	for _, prefix := range []string{"test-backups/", "test-backups"} {
+		t.Run(prefix, func(t *testing.T) {
+			provider, fake, backupID := newFakeS3Provider(t, func(config *core.StorageConfig) {
+				config.Prefix = prefix
+			})
+
+			// Listings take several pages, and nested keys outnumber backups
+			fake.pageSize = 2
+			for i := range 4 {
+				fake.put(fmt.Sprintf("test-backups/backup-%d.dump", i), []byte("data"))
+			}
+			for i := range 5 {
+				fake.put(fmt.Sprintf("test-backups/chunks/%02d/chunk", i), []byte("chunk"))
+			}
+			fake.put("other/backup.dump", []byte("data"))
+
+			backups, err := provider.List(context.Background())
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+
+			ids := make(map[string]bool)
+			for _, backup := range backups {
+				ids[backup.ID] = true
+			}
+			if len(backups) != 5 || !ids[backupID] || !ids["backup-3.dump"] {
+				t.Errorf("expected the 5 backups, got %v", ids)
+			}
+		})
+	}
+}
+
+func TestProvider_ListMetadata(t *testing.T) {
+	ctx := context.Background()
+	provider, _, _ := newFakeS3Provider(t, nil)
+
+	// More backups than are looked up at once
+	checksums := make(map[string]string)
+	for i := range 40 {
+		data := []byte(fmt.Sprintf("backup %d", i))
+		metadata := &core.BackupMetadata{
+			DatabaseName: fmt.Sprintf("db%d", i),
+			DatabaseType: "postgres",
+			Timestamp:    time.Date(2024, 3, 1, 0, 0, i, 0, time.UTC),
+			Tags:         map[string]string{"index": fmt.Sprint(i)},
+		}
+		if err := provider.Upload(ctx, bytes.NewReader(data), metadata); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+		sum := md5.Sum(data)
+		checksums[metadata.DatabaseName] = hex.EncodeToString(sum[:])
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 41 {
+		t.Fatalf("expected 41 backups, got %d", len(backups))
+	}
+	for _, backup := range backups {
+		if backup.DatabaseName == "testdb" {
+			continue
+		}
+		if backup.Checksum == "" || backup.Checksum != checksums[backup.DatabaseName] {
+			t.Errorf("%s: expected checksum %q, got %q", backup.ID, checksums[backup.DatabaseName], backup.Checksum)
+		}
+		if want := strings.TrimPrefix(backup.DatabaseName, "db"); backup.Tags["index"] != want {
+			t.Errorf("%s: expected tag index=%s, got %v", backup.ID, want, backup.Tags)
+		}
+	}
 }
 
 func TestProvider_AutoRegistration(t *testing.T) {
@@ -163,6 +337,7 @@ func TestIntegration_S3(t *testing.T) {
 			ID:           "integration-test-backup",
 			DatabaseName: "testdb",
 			DatabaseType: "postgres",
+			BackupMode:   "physical",
 			Timestamp:    time.Now(),
 		}
 
@@ -196,6 +371,13 @@ func TestIntegration_S3(t *testing.T) {
 		if backups == nil {
 			t.Error("Expected non-nil backups slice")
 		}
+
+		// Metadata stored on upload should be read back
+		for _, backup := range backups {
+			if backup.DatabaseName == "testdb" && backup.BackupMode != "physical" {
+				t.Errorf("Expected BackupMode 'physical', got '%s'", backup.BackupMode)
+			}
+		}
 	})
 
 	t.Run("Download", func(t *testing.T) {
@@ -222,6 +404,34 @@ func TestIntegration_S3(t *testing.T) {
 		}
 	})
 
+	t.Run("TierAndRetrieve", func(t *testing.T) {
+		backups, err := provider.List(ctx)
+		if err != nil || len(backups) == 0 {
+			t.Skip("No backups to move")
+			return
+		}
+
+		backupID := backups[0].ID
+		if err := provider.Tier(ctx, backupID, "GLACIER"); err != nil {
+			t.Fatalf("Tier() error = %v", err)
+		}
+
+		// Downloads request the retrieval and wait for it
+		waitConfig := getTestS3Config()
+		waitConfig.RetrievalTier = "Expedited"
+		waitConfig.RetrievalWait = time.Minute
+		waiting, err := s3.New(ctx, waitConfig)
+		if err != nil {
+			t.Fatalf("Failed to create S3 provider: %v", err)
+		}
+
+		reader, err := waiting.Download(ctx, backupID)
+		if err != nil {
+			t.Fatalf("Download() error = %v", err)
+		}
+		reader.Close()
+	})
+
 	t.Run("Delete", func(t *testing.T) {
 		// Try to delete a backup if any exist
 		backups, err := provider.List(ctx)
@@ -0,0 +1,312 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"goarchive/core"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// maxCopySize is the size of the largest object S3 copies in a single
+	// request
+	maxCopySize = 5 << 30
+
+	// copyPartSize is the size of each part of a multipart copy. A copy has
+	// at most 10000 parts, enough for objects of up to about 9.8 TiB.
+	copyPartSize = 1 << 30
+)
+
+// Tier moves a backup to an S3 storage class, such as GLACIER or
+// DEEP_ARCHIVE, by copying the object onto itself. Backups in an archive
+// class must be retrieved before they can move to another class.
+func (p *Provider) Tier(ctx context.Context, backupID string, tier string) error {
+	class, err := parseStorageClass(tier)
+	if err != nil {
+		return err
+	}
+
+	key := path.Join(p.config.Prefix, backupID)
+	head, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(p.config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+		}
+		return fmt.Errorf("failed to read backup from S3: %w", err)
+	}
+
+	current := objectStorageClass(string(head.StorageClass))
+	if current == string(class) {
+		return nil
+	}
+	if isArchiveClass(current) && !retrieved(head.Restore) {
+		return fmt.Errorf("backup %s is in the %s storage class; retrieve it before moving it to %s", backupID, current, class)
+	}
+
+	if aws.ToInt64(head.ContentLength) > maxCopySize {
+		err = p.copyMultipart(ctx, key, head, class)
+	} else {
+		_, err = p.client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:            aws.String(p.config.Bucket),
+			Key:               aws.String(key),
+			CopySource:        aws.String(copySource(p.config.Bucket, key)),
+			StorageClass:      class,
+			MetadataDirective: types.MetadataDirectiveCopy,
+			TaggingDirective:  types.TaggingDirectiveCopy,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to change storage class: %w", err)
+	}
+
+	return nil
+}
+
+// copyMultipart copies an object too large for CopyObject onto itself in
+// parts, in a new storage class. The metadata and tags that CopyObject
+// would keep are set on the new object.
+func (p *Provider) copyMultipart(ctx context.Context, key string, head *s3.HeadObjectOutput, class types.StorageClass) error {
+	tagging, err := p.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(p.config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read object tags: %w", err)
+	}
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:       aws.String(p.config.Bucket),
+		Key:          aws.String(key),
+		StorageClass: class,
+		ContentType:  head.ContentType,
+		Metadata:     head.Metadata,
+	}
+	if len(tagging.TagSet) > 0 {
+		tags := make(url.Values)
+		for _, tag := range tagging.TagSet {
+			tags.Set(aws.ToString(tag.Key), aws.ToString(tag.Value))
+		}
+		input.Tagging = aws.String(tags.Encode())
+	}
+
+	upload, err := p.client.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	parts, err := p.copyParts(ctx, key, upload.UploadId, head)
+	if err == nil {
+		_, err = p.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(p.config.Bucket),
+			Key:             aws.String(key),
+			UploadId:        upload.UploadId,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+		})
+	}
+	if err != nil {
+		// Don't leave the parts copied so far stored, and billed
+		p.client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(p.config.Bucket),
+			Key:      aws.String(key),
+			UploadId: upload.UploadId,
+		})
+		return err
+	}
+
+	return nil
+}
+
+// copyParts copies the object described by head into the parts of a
+// multipart upload, partConcurrency parts at a time. The copies fail if the
+// object changes while they run.
+func (p *Provider) copyParts(ctx context.Context, key string, uploadID *string, head *s3.HeadObjectOutput) ([]types.CompletedPart, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	size := aws.ToInt64(head.ContentLength)
+	parts := make([]types.CompletedPart, (size+copyPartSize-1)/copyPartSize)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var copyErr error
+	slots := make(chan struct{}, partConcurrency)
+	for i := range parts {
+		if ctx.Err() != nil {
+			break
+		}
+		number := int32(i + 1)
+		first := int64(i) * copyPartSize
+		last := min(first+copyPartSize, size) - 1
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result, err := p.client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+				Bucket:            aws.String(p.config.Bucket),
+				Key:               aws.String(key),
+				UploadId:          uploadID,
+				PartNumber:        aws.Int32(number),
+				CopySource:        aws.String(copySource(p.config.Bucket, key)),
+				CopySourceRange:   aws.String(fmt.Sprintf("bytes=%d-%d", first, last)),
+				CopySourceIfMatch: head.ETag,
+			})
+			if err != nil {
+				// Report the first failure, not the cancellations it causes
+				mu.Lock()
+				if copyErr == nil {
+					copyErr = fmt.Errorf("failed to copy part %d: %w", number, err)
+					cancel()
+				}
+				mu.Unlock()
+				return
+			}
+			parts[i] = types.CompletedPart{ETag: result.CopyPartResult.ETag, PartNumber: aws.Int32(number)}
+		}()
+	}
+	wg.Wait()
+
+	if copyErr == nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return parts, copyErr
+}
+
+// retrieve handles a download of a backup in an archive storage class. It
+// requests the backup's retrieval unless one is already under way, then
+// waits up to StorageConfig.RetrievalWait for the retrieval to complete.
+func (p *Provider) retrieve(ctx context.Context, backupID string, key string) error {
+	head, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(p.config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read backup from S3: %w", err)
+	}
+	class := objectStorageClass(string(head.StorageClass))
+
+	if head.Restore == nil {
+		request := &types.RestoreRequest{
+			GlacierJobParameters: &types.GlacierJobParameters{Tier: p.retrievalTier},
+		}
+		// Retrieved copies of objects in archive classes expire; those in
+		// the archive tiers of Intelligent-Tiering move back instead
+		if isArchiveClass(class) {
+			request.Days = aws.Int32(1)
+		}
+
+		_, err := p.client.RestoreObject(ctx, &s3.RestoreObjectInput{
+			Bucket:         aws.String(p.config.Bucket),
+			Key:            aws.String(key),
+			RestoreRequest: request,
+		})
+		var apiErr smithy.APIError
+		if err != nil && !(errors.As(err, &apiErr) && apiErr.ErrorCode() == "RestoreAlreadyInProgress") {
+			return fmt.Errorf("failed to request retrieval of backup %s: %w", backupID, err)
+		}
+	}
+
+	wait := p.config.RetrievalWait
+	if wait == 0 {
+		return fmt.Errorf("backup %s is in the %s storage class; its retrieval has been requested, retry the download once it completes or set a retrieval wait", backupID, class)
+	}
+
+	deadline := time.Now().Add(wait)
+	for !retrieved(head.Restore) {
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("backup %s was not retrieved from the %s storage class within %s; retry the download later", backupID, class, wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval(wait)):
+		}
+
+		head, err = p.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(p.config.Bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to check retrieval of backup %s: %w", backupID, err)
+		}
+	}
+
+	return nil
+}
+
+// pollInterval returns how often to check on a retrieval waited on for wait
+func pollInterval(wait time.Duration) time.Duration {
+	return min(max(wait/10, 10*time.Millisecond), time.Minute)
+}
+
+// retrieved reports whether the x-amz-restore header of an object shows a
+// completed retrieval
+func retrieved(restore *string) bool {
+	return restore != nil && strings.Contains(*restore, `ongoing-request="false"`)
+}
+
+// isArchiveClass reports whether objects in a storage class must be
+// retrieved before they can be read
+func isArchiveClass(class string) bool {
+	return class == string(types.StorageClassGlacier) || class == string(types.StorageClassDeepArchive)
+}
+
+// objectStorageClass returns the storage class S3 reports for an object,
+// which is empty for STANDARD
+func objectStorageClass(class string) string {
+	if class == "" {
+		return string(types.StorageClassStandard)
+	}
+	return class
+}
+
+// parseStorageClass returns the S3 storage class with the given name,
+// ignoring case
+func parseStorageClass(name string) (types.StorageClass, error) {
+	for _, class := range types.StorageClassStandard.Values() {
+		if strings.EqualFold(name, string(class)) {
+			return class, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported S3 storage class: %s", name)
+}
+
+// parseRetrievalTier returns the Glacier retrieval tier with the given name,
+// ignoring case. An empty name selects Standard.
+func parseRetrievalTier(name string) (types.Tier, error) {
+	if name == "" {
+		return types.TierStandard, nil
+	}
+	for _, tier := range []types.Tier{types.TierExpedited, types.TierStandard, types.TierBulk} {
+		if strings.EqualFold(name, string(tier)) {
+			return tier, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported S3 retrieval tier: %s (use Expedited, Standard or Bulk)", name)
+}
+
+// copySource returns the URL-encoded source of a copy of key in bucket
+func copySource(bucket string, key string) string {
+	segments := strings.Split(bucket+"/"+key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
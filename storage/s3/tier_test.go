@@ -0,0 +1,223 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"goarchive/core"
+	"goarchive/storage/s3"
+)
+
+// newFakeS3Provider returns a provider using a fake S3 server, with a
+// backup already uploaded
+func newFakeS3Provider(t *testing.T, configure func(*core.StorageConfig)) (*s3.Provider, *fakeS3, string) {
+	fake, server := newFakeS3(t, "test-backup-bucket")
+
+	config := &core.StorageConfig{
+		Type:      "s3",
+		Bucket:    "test-backup-bucket",
+		Region:    "us-east-1",
+		Endpoint:  server.URL,
+		AccessKey: "test",
+		SecretKey: "test",
+		Prefix:    "test-backups/",
+	}
+	if configure != nil {
+		configure(config)
+	}
+
+	ctx := context.Background()
+	provider, err := s3.New(ctx, config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	metadata := &core.BackupMetadata{
+		DatabaseName: "testdb",
+		DatabaseType: "postgres",
+		Timestamp:    time.Date(2024, 2, 15, 12, 0, 0, 0, time.UTC),
+	}
+	if err := provider.Upload(ctx, bytes.NewReader([]byte("archived backup data")), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	return provider, fake, "testdb_postgres_20240215-120000.dump"
+}
+
+func TestProvider_Tier(t *testing.T) {
+	ctx := context.Background()
+	provider, _, backupID := newFakeS3Provider(t, nil)
+
+	listed := func() *core.BackupMetadata {
+		backups, err := provider.List(ctx)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if len(backups) != 1 {
+			t.Fatalf("expected 1 backup, got %d", len(backups))
+		}
+		return backups[0]
+	}
+
+	if tier := listed().Tier; tier != "STANDARD" {
+		t.Errorf("expected tier STANDARD, got %q", tier)
+	}
+
+	if err := provider.Tier(ctx, backupID, "glacier_ir"); err != nil {
+		t.Fatalf("Tier() error = %v", err)
+	}
+	backup := listed()
+	if backup.Tier != "GLACIER_IR" || backup.DatabaseName != "testdb" {
+		t.Errorf("expected a GLACIER_IR backup with its metadata, got %+v", backup)
+	}
+
+	// Moving to the current class does nothing
+	if err := provider.Tier(ctx, backupID, "GLACIER_IR"); err != nil {
+		t.Errorf("Tier() error = %v", err)
+	}
+
+	if err := provider.Tier(ctx, backupID, "FROZEN"); err == nil || !strings.Contains(err.Error(), "unsupported S3 storage class") {
+		t.Errorf("expected an unsupported class error, got %v", err)
+	}
+	if err := provider.Tier(ctx, "missing.dump", "GLACIER"); !errors.Is(err, core.ErrBackupNotFound) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	if err := provider.Tier(ctx, backupID, "DEEP_ARCHIVE"); err != nil {
+		t.Fatalf("Tier() error = %v", err)
+	}
+	if err := provider.Tier(ctx, backupID, "STANDARD"); err == nil || !strings.Contains(err.Error(), "retrieve it") {
+		t.Errorf("expected archived backups to need retrieval before moving, got %v", err)
+	}
+}
+
+func TestProvider_TierLargeBackup(t *testing.T) {
+	ctx := context.Background()
+	provider, fake, backupID := newFakeS3Provider(t, nil)
+
+	// Objects over 5 GiB are too large for CopyObject
+	key := "test-backups/" + backupID
+	fake.mu.Lock()
+	fake.objects[key].size = 6<<30 + 1
+	fake.mu.Unlock()
+
+	if err := provider.Tier(ctx, backupID, "GLACIER"); err != nil {
+		t.Fatalf("Tier() error = %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if fake.copies != 0 {
+		t.Errorf("expected no CopyObject requests, got %d", fake.copies)
+	}
+	// Parts are copied concurrently, in any order
+	if len(fake.copyRanges) != 7 || !slices.Contains(fake.copyRanges, "bytes=0-1073741823") || !slices.Contains(fake.copyRanges, "bytes=6442450944-6442450944") {
+		t.Errorf("expected 6 parts of 1 GiB and a last part of 1 byte, got %v", fake.copyRanges)
+	}
+	if len(fake.uploads) != 0 {
+		t.Errorf("expected no multipart uploads left open, got %d", len(fake.uploads))
+	}
+
+	obj := fake.objects[key]
+	if obj.class != "GLACIER" || obj.length() != 6<<30+1 || string(obj.data) != "archived backup data" {
+		t.Errorf("expected a GLACIER copy of the whole backup, got class %q, size %d, data %q", obj.class, obj.length(), obj.data)
+	}
+	if obj.metadata.Get("X-Amz-Meta-Database-Name") != "testdb" {
+		t.Errorf("expected the metadata to be kept, got %v", obj.metadata)
+	}
+	if obj.tags.Get("Type") != "DatabaseBackup" || obj.tags.Get("Checksum") == "" {
+		t.Errorf("expected the tags to be kept, got %v", obj.tags)
+	}
+}
+
+func TestProvider_DownloadArchived(t *testing.T) {
+	ctx := context.Background()
+
+	download := func(provider *s3.Provider, backupID string) ([]byte, error) {
+		reader, err := provider.Download(ctx, backupID)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	}
+
+	t.Run("requests retrieval without waiting", func(t *testing.T) {
+		provider, fake, backupID := newFakeS3Provider(t, nil)
+		fake.restoreAfter = -1
+		if err := provider.Tier(ctx, backupID, "GLACIER"); err != nil {
+			t.Fatalf("Tier() error = %v", err)
+		}
+
+		for range 2 {
+			if _, err := download(provider, backupID); err == nil || !strings.Contains(err.Error(), "retrieval has been requested") {
+				t.Errorf("expected a retrieval error, got %v", err)
+			}
+		}
+		if len(fake.restores) != 1 {
+			t.Fatalf("expected 1 retrieval request, got %d", len(fake.restores))
+		}
+		if !strings.Contains(fake.restores[0], "<Days>1</Days>") || !strings.Contains(fake.restores[0], "<Tier>Standard</Tier>") {
+			t.Errorf("unexpected retrieval request: %s", fake.restores[0])
+		}
+	})
+
+	t.Run("waits for retrieval", func(t *testing.T) {
+		provider, fake, backupID := newFakeS3Provider(t, func(config *core.StorageConfig) {
+			config.RetrievalTier = "bulk"
+			config.RetrievalWait = time.Second
+		})
+		fake.restoreAfter = 3
+		if err := provider.Tier(ctx, backupID, "DEEP_ARCHIVE"); err != nil {
+			t.Fatalf("Tier() error = %v", err)
+		}
+
+		data, err := download(provider, backupID)
+		if err != nil {
+			t.Fatalf("Download() error = %v", err)
+		}
+		if string(data) != "archived backup data" {
+			t.Errorf("downloaded data doesn't match: %q", data)
+		}
+		if len(fake.restores) != 1 || !strings.Contains(fake.restores[0], "<Tier>Bulk</Tier>") {
+			t.Errorf("expected 1 bulk retrieval request, got %v", fake.restores)
+		}
+
+		// A retrieved backup can move back to an online class
+		if err := provider.Tier(ctx, backupID, "STANDARD"); err != nil {
+			t.Errorf("Tier() error = %v", err)
+		}
+	})
+
+	t.Run("gives up after the wait", func(t *testing.T) {
+		provider, fake, backupID := newFakeS3Provider(t, func(config *core.StorageConfig) {
+			config.RetrievalWait = 50 * time.Millisecond
+		})
+		fake.restoreAfter = -1
+		if err := provider.Tier(ctx, backupID, "GLACIER"); err != nil {
+			t.Fatalf("Tier() error = %v", err)
+		}
+
+		if _, err := download(provider, backupID); err == nil || !strings.Contains(err.Error(), "was not retrieved") {
+			t.Errorf("expected a timeout error, got %v", err)
+		}
+	})
+
+	t.Run("online backups download directly", func(t *testing.T) {
+		provider, fake, backupID := newFakeS3Provider(t, nil)
+
+		if _, err := download(provider, backupID); err != nil {
+			t.Errorf("Download() error = %v", err)
+		}
+		if len(fake.restores) != 0 {
+			t.Errorf("expected no retrieval request, got %d", len(fake.restores))
+		}
+	})
+}
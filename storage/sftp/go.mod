@@ -0,0 +1,16 @@
+module goarchive/storage/sftp
+
+go 1.24.0
+
+require (
+	github.com/pkg/sftp v1.13.10
+	goarchive v0.0.0
+	golang.org/x/crypto v0.47.0
+)
+
+require (
+	github.com/kr/fs v0.1.0 // indirect
+	golang.org/x/sys v0.40.0 // indirect
+)
+
+replace goarchive => ../../
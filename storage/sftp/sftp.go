@@ -0,0 +1,413 @@
+package sftp
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"goarchive/core"
+)
+
+const (
+	defaultPort = "22"
+
+	// dialTimeout bounds connecting to the server and the SSH handshake
+	dialTimeout = 30 * time.Second
+
+	// maxManifestSize bounds the manifests read when listing backups
+	maxManifestSize = 1 << 20
+)
+
+// init registers the SFTP provider with the global registry
+func init() {
+	core.RegisterStorage("sftp", func(ctx context.Context, config *core.StorageConfig) (core.StorageProvider, error) {
+		return New(ctx, config)
+	})
+}
+
+// Provider implements the StorageProvider interface for SFTP servers
+type Provider struct {
+	config *core.StorageConfig
+	conn   *ssh.Client
+	client *sftp.Client
+	path   string
+}
+
+// New connects to the SFTP server at config.Endpoint ("host" or
+// "host:port") and creates the backup directory config.Path. The server's
+// host key must be listed in config.KnownHostsFile, or in
+// ~/.ssh/known_hosts by default.
+func New(ctx context.Context, config *core.StorageConfig) (*Provider, error) {
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("storage endpoint is required for SFTP storage")
+	}
+	if config.Username == "" {
+		return nil, fmt.Errorf("storage username is required for SFTP storage")
+	}
+
+	addr := address(config.Endpoint)
+
+	auth, err := authMethods(config)
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback, err := hostKeyCallback(config.KnownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dial(ctx, addr, &ssh.ClientConfig{
+		User:            config.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sftp.NewClient(conn, sftp.UseConcurrentWrites(true))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start SFTP session: %w", err)
+	}
+
+	dir := config.Path
+	if dir == "" {
+		dir = "backups"
+	}
+	if err := client.MkdirAll(dir); err != nil {
+		client.Close()
+		conn.Close()
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	return &Provider{
+		config: config,
+		conn:   conn,
+		client: client,
+		path:   dir,
+	}, nil
+}
+
+// address returns the host and port to connect to, adding the default port
+func address(endpoint string) string {
+	endpoint = strings.TrimPrefix(endpoint, "sftp://")
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if _, _, err := net.SplitHostPort(endpoint); err == nil {
+		return endpoint
+	}
+	return net.JoinHostPort(strings.Trim(endpoint, "[]"), defaultPort)
+}
+
+// authMethods returns the private key or password authentication for the
+// server. With a private key, the password is its passphrase.
+func authMethods(config *core.StorageConfig) ([]ssh.AuthMethod, error) {
+	if config.PrivateKeyFile != "" {
+		key, err := os.ReadFile(config.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+
+		signer, err := ssh.ParsePrivateKey(key)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			if config.Password == "" {
+				return nil, fmt.Errorf("private key is encrypted; set its passphrase as the storage password")
+			}
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(config.Password))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+
+	if config.Password != "" {
+		// Servers may ask for the password through keyboard-interactive
+		// authentication instead
+		answer := func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i := range answers {
+				answers[i] = config.Password
+			}
+			return answers, nil
+		}
+		return []ssh.AuthMethod{ssh.Password(config.Password), ssh.KeyboardInteractive(answer)}, nil
+	}
+
+	return nil, fmt.Errorf("storage password or private key is required for SFTP storage")
+}
+
+// hostKeyCallback verifies the server's host key against a known_hosts file
+func hostKeyCallback(file string) (ssh.HostKeyCallback, error) {
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find known_hosts file: %w", err)
+		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	callback, err := knownhosts.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known_hosts file: %w", err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) == 0 {
+				return fmt.Errorf("host key for %s is not in %s; add it with ssh-keyscan", hostname, file)
+			}
+			return fmt.Errorf("host key for %s does not match %s", hostname, file)
+		}
+		return err
+	}, nil
+}
+
+// dial connects to the server and completes the SSH handshake
+func dial(ctx context.Context, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SFTP server: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to SFTP server: %w", err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// Upload writes the backup to a temporary file next to its final name and
+// renames it into place once complete, with its manifest alongside
+func (p *Provider) Upload(ctx context.Context, reader io.Reader, metadata *core.BackupMetadata) error {
+	fullPath, err := p.resolve(p.getBackupFilename(metadata))
+	if err != nil {
+		return err
+	}
+
+	// Keys may place backups in subdirectories
+	if err := p.client.MkdirAll(path.Dir(fullPath)); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	hash := md5.New()
+	temp, size, err := p.writeTemp(fullPath, io.TeeReader(&contextReader{ctx: ctx, r: reader}, hash))
+	if err != nil {
+		return err
+	}
+
+	metadata.Checksum = hex.EncodeToString(hash.Sum(nil))
+	metadata.Size = size
+
+	// The manifest goes first, so a listed backup always has one
+	manifestPath := fullPath + ".meta"
+	if err := p.writeFile(manifestPath, []byte(core.FormatManifest(metadata))); err != nil {
+		p.client.Remove(temp)
+		return err
+	}
+	if err := p.rename(temp, fullPath); err != nil {
+		p.client.Remove(temp)
+		p.client.Remove(manifestPath)
+		return fmt.Errorf("failed to rename backup file: %w", err)
+	}
+
+	return nil
+}
+
+// writeTemp writes data to a new temporary file next to name, returning
+// its path and size. The file is removed if writing fails.
+func (p *Provider) writeTemp(name string, data io.Reader) (string, int64, error) {
+	temp := path.Join(path.Dir(name), fmt.Sprintf(".%s.tmp-%d", path.Base(name), time.Now().UnixNano()))
+
+	file, err := p.client.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create backup file: %w", err)
+	}
+
+	size, err := file.ReadFrom(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		p.client.Remove(temp)
+		return "", 0, fmt.Errorf("failed to write backup file: %w", err)
+	}
+
+	return temp, size, nil
+}
+
+// writeFile replaces the file at name with data
+func (p *Provider) writeFile(name string, data []byte) error {
+	temp, _, err := p.writeTemp(name, strings.NewReader(string(data)))
+	if err != nil {
+		return err
+	}
+	if err := p.rename(temp, name); err != nil {
+		p.client.Remove(temp)
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	return nil
+}
+
+// rename moves oldname to newname, replacing newname. Without the OpenSSH
+// posix-rename extension, SFTP renames fail when newname exists, so it is
+// removed first.
+func (p *Provider) rename(oldname, newname string) error {
+	if _, ok := p.client.HasExtension("posix-rename@openssh.com"); ok {
+		return p.client.PosixRename(oldname, newname)
+	}
+	if err := p.client.Remove(newname); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return p.client.Rename(oldname, newname)
+}
+
+// List lists available backups in the remote directory
+func (p *Provider) List(ctx context.Context) ([]*core.BackupMetadata, error) {
+	entries, err := p.client.ReadDirContext(ctx, p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var backups []*core.BackupMetadata
+	for _, entry := range entries {
+		// Skip directories, manifests and uploads in progress
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".dump") {
+			continue
+		}
+
+		backup := &core.BackupMetadata{
+			ID:        entry.Name(),
+			Timestamp: entry.ModTime(),
+			Size:      entry.Size(),
+		}
+		if manifest, err := p.readManifest(path.Join(p.path, entry.Name()+".meta")); err == nil {
+			core.ParseManifest(backup, manifest)
+		}
+
+		backups = append(backups, backup)
+	}
+
+	// Sort by timestamp descending (newest first)
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Timestamp.After(backups[j].Timestamp)
+	})
+
+	return backups, nil
+}
+
+// readManifest reads the manifest at name
+func (p *Provider) readManifest(name string) (string, error) {
+	file, err := p.client.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxManifestSize))
+	return string(data), err
+}
+
+// Download reads a backup from the SFTP server
+func (p *Provider) Download(ctx context.Context, backupID string) (io.ReadCloser, error) {
+	fullPath, err := p.resolve(backupID)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := p.client.Open(fullPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+		}
+		return nil, fmt.Errorf("failed to open backup file: %w", err)
+	}
+
+	return file, nil
+}
+
+// Delete removes a backup and its manifest from the SFTP server
+func (p *Provider) Delete(ctx context.Context, backupID string) error {
+	fullPath, err := p.resolve(backupID)
+	if err != nil {
+		return err
+	}
+
+	if err := p.client.Remove(fullPath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+		}
+		return fmt.Errorf("failed to delete backup file: %w", err)
+	}
+
+	// Try to delete the manifest too (non-fatal if it doesn't exist)
+	p.client.Remove(fullPath + ".meta")
+
+	return nil
+}
+
+// Close ends the SFTP session and closes the connection
+func (p *Provider) Close() error {
+	p.client.Close()
+	return p.conn.Close()
+}
+
+// resolve returns the remote path of a backup, rejecting names that leave
+// the backup directory
+func (p *Provider) resolve(name string) (string, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid backup key: %s", name)
+	}
+	return path.Join(p.path, clean), nil
+}
+
+// getBackupFilename generates the filename for a backup
+func (p *Provider) getBackupFilename(metadata *core.BackupMetadata) string {
+	if metadata.Key != "" {
+		return metadata.Key
+	}
+	return fmt.Sprintf("%s_%s_%s.dump",
+		metadata.DatabaseName,
+		metadata.DatabaseType,
+		metadata.Timestamp.Format("20060102-150405"),
+	)
+}
+
+// contextReader stops reading once its context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read reads from the underlying reader unless the context is done
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,446 @@
+package sftp_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"goarchive/core"
+	sftpstorage "goarchive/storage/sftp"
+)
+
+const (
+	testUser     = "backup"
+	testPassword = "s3cret"
+)
+
+// testServer is an in-process SSH server offering the SFTP subsystem on the
+// local filesystem
+type testServer struct {
+	addr       string
+	knownHosts string // known_hosts file listing the server's host key
+	keyFile    string // Private key accepted for testUser
+	dir        string // Directory backups are stored in
+}
+
+func newSigner(t *testing.T) (ssh.Signer, ed25519.PrivateKey) {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	return signer, key
+}
+
+func startServer(t *testing.T) *testServer {
+	t.Helper()
+
+	dir := t.TempDir()
+	hostKey, _ := newSigner(t)
+	clientKey, clientPrivate := newSigner(t)
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == testUser && string(password) == testPassword {
+				return nil, nil
+			}
+			return nil, errors.New("access denied")
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == testUser && bytes.Equal(key.Marshal(), clientKey.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("access denied")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn, config)
+		}
+	}()
+
+	addr := listener.Addr().String()
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey.PublicKey()) + "\n"
+	if err := os.WriteFile(knownHosts, []byte(line), 0600); err != nil {
+		t.Fatalf("failed to write known_hosts: %v", err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(clientPrivate, "")
+	if err != nil {
+		t.Fatalf("failed to marshal private key: %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("failed to write private key: %v", err)
+	}
+
+	return &testServer{addr: addr, knownHosts: knownHosts, keyFile: keyFile, dir: dir}
+}
+
+func serveConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				// The payload is the length-prefixed subsystem name
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					server, err := sftp.NewServer(channel)
+					if err == nil {
+						server.Serve()
+					}
+					channel.Close()
+				}
+			}
+		}()
+	}
+}
+
+// config returns a configuration for the server with password
+// authentication
+func (s *testServer) config() *core.StorageConfig {
+	return &core.StorageConfig{
+		Type:           "sftp",
+		Endpoint:       s.addr,
+		Username:       testUser,
+		Password:       testPassword,
+		KnownHostsFile: s.knownHosts,
+		Path:           s.dir,
+	}
+}
+
+func newProvider(t *testing.T, config *core.StorageConfig) *sftpstorage.Provider {
+	t.Helper()
+
+	provider, err := sftpstorage.New(context.Background(), config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { provider.Close() })
+	return provider
+}
+
+func TestNew(t *testing.T) {
+	server := startServer(t)
+
+	otherHostKey, _ := newSigner(t)
+	wrongKnownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(server.addr)}, otherHostKey.PublicKey()) + "\n"
+	if err := os.WriteFile(wrongKnownHosts, []byte(line), 0600); err != nil {
+		t.Fatalf("failed to write known_hosts: %v", err)
+	}
+	emptyKnownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(emptyKnownHosts, nil, 0600); err != nil {
+		t.Fatalf("failed to write known_hosts: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *core.StorageConfig)
+		wantErr string
+	}{
+		{name: "missing endpoint", modify: func(c *core.StorageConfig) { c.Endpoint = "" }, wantErr: "endpoint is required"},
+		{name: "missing username", modify: func(c *core.StorageConfig) { c.Username = "" }, wantErr: "username is required"},
+		{name: "missing credentials", modify: func(c *core.StorageConfig) { c.Password = "" }, wantErr: "password or private key is required"},
+		{name: "missing private key", modify: func(c *core.StorageConfig) { c.PrivateKeyFile = filepath.Join(t.TempDir(), "missing") }, wantErr: "failed to read private key"},
+		{name: "missing known_hosts", modify: func(c *core.StorageConfig) { c.KnownHostsFile = filepath.Join(t.TempDir(), "missing") }, wantErr: "known_hosts"},
+		{name: "unknown host key", modify: func(c *core.StorageConfig) { c.KnownHostsFile = emptyKnownHosts }, wantErr: "is not in"},
+		{name: "changed host key", modify: func(c *core.StorageConfig) { c.KnownHostsFile = wrongKnownHosts }, wantErr: "does not match"},
+		{name: "wrong password", modify: func(c *core.StorageConfig) { c.Password = "wrong" }, wantErr: "unable to authenticate"},
+		{name: "password", modify: func(c *core.StorageConfig) {}},
+		{name: "private key", modify: func(c *core.StorageConfig) { c.Password, c.PrivateKeyFile = "", server.keyFile }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := server.config()
+			tt.modify(config)
+
+			provider, err := sftpstorage.New(context.Background(), config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+				provider.Close()
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNew_EncryptedPrivateKey(t *testing.T) {
+	server := startServer(t)
+
+	key, err := os.ReadFile(server.keyFile)
+	if err != nil {
+		t.Fatalf("failed to read private key: %v", err)
+	}
+	parsed, err := ssh.ParseRawPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to parse private key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(parsed, "", []byte("passphrase"))
+	if err != nil {
+		t.Fatalf("failed to encrypt private key: %v", err)
+	}
+	encrypted := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(encrypted, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("failed to write private key: %v", err)
+	}
+
+	config := server.config()
+	config.PrivateKeyFile = encrypted
+	config.Password = ""
+	if _, err := sftpstorage.New(context.Background(), config); err == nil || !strings.Contains(err.Error(), "encrypted") {
+		t.Errorf("expected error about the passphrase, got %v", err)
+	}
+
+	config.Password = "passphrase"
+	newProvider(t, config)
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	server := startServer(t)
+
+	provider, err := core.GetStorage(context.Background(), "sftp", server.config())
+	if err != nil {
+		t.Fatalf("GetStorage() error = %v", err)
+	}
+	provider.(*sftpstorage.Provider).Close()
+}
+
+func TestUploadListDownloadDelete(t *testing.T) {
+	server := startServer(t)
+	provider := newProvider(t, server.config())
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("backup data "), 100000)
+	metadata := &core.BackupMetadata{
+		ID:           "20260215-103020",
+		DatabaseName: "orders",
+		DatabaseType: "postgres",
+		BackupMode:   "logical",
+		Timestamp:    time.Date(2026, 2, 15, 10, 30, 20, 0, time.UTC),
+		Tags:         map[string]string{"server-version": "16.2"},
+	}
+	if err := provider.Upload(ctx, bytes.NewReader(data), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	sum := md5.Sum(data)
+	if metadata.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("Checksum = %s, want %s", metadata.Checksum, hex.EncodeToString(sum[:]))
+	}
+	if metadata.Size != int64(len(data)) {
+		t.Errorf("Size = %d, want %d", metadata.Size, len(data))
+	}
+
+	// The backup and its manifest are in place, with no temporary files
+	entries, err := os.ReadDir(server.dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	want := []string{"orders_postgres_20260215-103020.dump", "orders_postgres_20260215-103020.dump.meta"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+
+	// Files below the backups, such as WAL archives, are not listed
+	wal := &core.BackupMetadata{Key: "wal/00000001/000000010000000000000001.gz"}
+	if err := provider.Upload(ctx, strings.NewReader("wal"), wal); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %d", len(backups))
+	}
+
+	backup := backups[0]
+	if backup.ID != "orders_postgres_20260215-103020.dump" {
+		t.Errorf("ID = %s", backup.ID)
+	}
+	if backup.DatabaseName != "orders" || backup.DatabaseType != "postgres" || backup.BackupMode != "logical" {
+		t.Errorf("unexpected metadata: %+v", backup)
+	}
+	if !backup.Timestamp.Equal(metadata.Timestamp) {
+		t.Errorf("Timestamp = %v, want %v", backup.Timestamp, metadata.Timestamp)
+	}
+	if backup.Checksum != metadata.Checksum || backup.Size != metadata.Size {
+		t.Errorf("listed checksum %s and size %d, want %s and %d", backup.Checksum, backup.Size, metadata.Checksum, metadata.Size)
+	}
+	if backup.Tags["server-version"] != "16.2" {
+		t.Errorf("expected tags to round-trip, got %v", backup.Tags)
+	}
+
+	reader, err := provider.Download(ctx, backup.ID)
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	downloaded, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatalf("failed to read download: %v", err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Error("downloaded data differs from the upload")
+	}
+
+	if err := provider.Delete(ctx, backup.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(server.dir, backup.ID+".meta")); !os.IsNotExist(err) {
+		t.Errorf("expected the manifest to be deleted, got %v", err)
+	}
+	if err := provider.Delete(ctx, backup.ID); err == nil {
+		t.Error("expected error deleting a missing backup, got nil")
+	}
+}
+
+func TestUpload_ReplacesExistingBackup(t *testing.T) {
+	server := startServer(t)
+	provider := newProvider(t, server.config())
+	ctx := context.Background()
+
+	for _, content := range []string{"first", "second"} {
+		metadata := &core.BackupMetadata{Key: "latest.dump", BackupMode: content}
+		if err := provider.Upload(ctx, strings.NewReader(content), metadata); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(server.dir, "latest.dump"))
+	if err != nil {
+		t.Fatalf("failed to read backup: %v", err)
+	}
+	if string(data) != "second" {
+		t.Errorf("backup = %q, want %q", data, "second")
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 || backups[0].BackupMode != "second" {
+		t.Errorf("expected the manifest of the second upload, got %+v", backups)
+	}
+}
+
+// failingReader returns some data and then an error
+type failingReader struct {
+	sent bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if !r.sent {
+		r.sent = true
+		return copy(p, "partial backup"), nil
+	}
+	return 0, errors.New("database connection lost")
+}
+
+func TestUpload_FailedBackupLeavesNoFile(t *testing.T) {
+	server := startServer(t)
+	provider := newProvider(t, server.config())
+
+	metadata := &core.BackupMetadata{DatabaseName: "orders", DatabaseType: "postgres", Timestamp: time.Now()}
+	if err := provider.Upload(context.Background(), &failingReader{}, metadata); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	entries, err := os.ReadDir(server.dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no files after a failed upload, got %d", len(entries))
+	}
+}
+
+func TestInvalidBackupID(t *testing.T) {
+	server := startServer(t)
+	provider := newProvider(t, server.config())
+	ctx := context.Background()
+
+	for _, id := range []string{"../outside.dump", "/etc/passwd", ".."} {
+		if _, err := provider.Download(ctx, id); err == nil {
+			t.Errorf("Download(%q) expected error, got nil", id)
+		}
+		if err := provider.Delete(ctx, id); err == nil {
+			t.Errorf("Delete(%q) expected error, got nil", id)
+		}
+	}
+
+	metadata := &core.BackupMetadata{Key: "../outside.dump"}
+	if err := provider.Upload(ctx, strings.NewReader("data"), metadata); err == nil {
+		t.Error("expected error uploading outside the backup directory, got nil")
+	}
+}
+
+func TestDownload_Missing(t *testing.T) {
+	server := startServer(t)
+	provider := newProvider(t, server.config())
+
+	_, err := provider.Download(context.Background(), "missing.dump")
+	if err == nil || !strings.Contains(err.Error(), "backup not found") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
@@ -0,0 +1,9 @@
+module goarchive/storage/webdav
+
+go 1.24.0
+
+require goarchive v0.0.0
+
+require golang.org/x/net v0.50.0
+
+replace goarchive => ../../
@@ -0,0 +1,441 @@
+package webdav
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"goarchive/core"
+)
+
+// maxManifestSize bounds the manifests and PROPFIND responses read when
+// listing backups
+const maxManifestSize = 1 << 20
+
+// propfindBody requests the properties List needs
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+// init registers the WebDAV provider with the global registry
+func init() {
+	core.RegisterStorage("webdav", func(ctx context.Context, config *core.StorageConfig) (core.StorageProvider, error) {
+		return New(config)
+	})
+}
+
+// Provider implements the StorageProvider interface for WebDAV servers such
+// as Nextcloud and ownCloud
+type Provider struct {
+	config *core.StorageConfig
+	client *http.Client
+	base   *url.URL // Collection backups are stored under, ending with a slash
+
+	mu          sync.Mutex
+	collections map[string]bool // Collections known to exist
+}
+
+// New creates a new WebDAV provider storing backups under config.Prefix at
+// the URL in config.Endpoint. Requests use config.Token as a bearer token,
+// or config.Username and config.Password for basic authentication.
+func New(config *core.StorageConfig) (*Provider, error) {
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("storage endpoint is required for WebDAV storage")
+	}
+
+	base, err := url.Parse(config.Endpoint)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid storage endpoint: %s", config.Endpoint)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base = base.JoinPath("/")
+	}
+
+	return &Provider{
+		config:      config,
+		client:      &http.Client{},
+		base:        base,
+		collections: make(map[string]bool),
+	}, nil
+}
+
+// Upload streams the backup to a temporary file next to its final name and
+// moves it into place once complete, with its manifest alongside. Missing
+// collections are created first.
+func (p *Provider) Upload(ctx context.Context, reader io.Reader, metadata *core.BackupMetadata) error {
+	key, err := p.resolve(p.getBackupFilename(metadata))
+	if err != nil {
+		return err
+	}
+
+	if err := p.mkcolAll(ctx, path.Dir(key)); err != nil {
+		return err
+	}
+
+	temp := path.Join(path.Dir(key), fmt.Sprintf(".%s.tmp-%d", path.Base(key), time.Now().UnixNano()))
+	body := &hashingReader{r: reader, hash: md5.New()}
+	if err := p.put(ctx, temp, body, -1); err != nil {
+		p.remove(context.WithoutCancel(ctx), temp)
+		if body.err != nil {
+			return fmt.Errorf("failed to read backup data: %w", body.err)
+		}
+		return err
+	}
+
+	metadata.Checksum = hex.EncodeToString(body.hash.Sum(nil))
+	metadata.Size = body.n
+
+	// The manifest goes first, so a listed backup always has one
+	manifest := core.FormatManifest(metadata)
+	if err := p.put(ctx, key+".meta", strings.NewReader(manifest), int64(len(manifest))); err != nil {
+		p.remove(context.WithoutCancel(ctx), temp)
+		return err
+	}
+	if err := p.move(ctx, temp, key); err != nil {
+		p.remove(context.WithoutCancel(ctx), temp)
+		p.remove(context.WithoutCancel(ctx), key+".meta")
+		return err
+	}
+
+	return nil
+}
+
+// put uploads body to name. A size of -1 streams a body of unknown length.
+func (p *Provider) put(ctx context.Context, name string, body io.Reader, size int64) error {
+	req, err := p.newRequest(ctx, http.MethodPut, p.url(name), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	defer drain(resp)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to upload %s: %s", name, resp.Status)
+	}
+	return nil
+}
+
+// move renames oldname to newname, replacing newname
+func (p *Provider) move(ctx context.Context, oldname, newname string) error {
+	req, err := p.newRequest(ctx, "MOVE", p.url(oldname), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Destination", p.url(newname))
+	req.Header.Set("Overwrite", "T")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to move backup into place: %w", err)
+	}
+	defer drain(resp)
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("failed to move backup into place: %s", resp.Status)
+	}
+	return nil
+}
+
+// mkcolAll creates the collection dir and any missing parents, like
+// os.MkdirAll
+func (p *Provider) mkcolAll(ctx context.Context, dir string) error {
+	var current string
+	for _, segment := range strings.Split(dir, "/") {
+		if segment == "" || segment == "." {
+			continue
+		}
+		current = path.Join(current, segment)
+
+		p.mu.Lock()
+		exists := p.collections[current]
+		p.mu.Unlock()
+		if exists {
+			continue
+		}
+
+		req, err := p.newRequest(ctx, "MKCOL", p.url(current)+"/", nil)
+		if err != nil {
+			return err
+		}
+		resp, err := p.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to create collection %s: %w", current, err)
+		}
+		drain(resp)
+
+		// Servers answer 405 Method Not Allowed for existing collections
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("failed to create collection %s: %s", current, resp.Status)
+		}
+
+		p.mu.Lock()
+		p.collections[current] = true
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+// multistatus is the PROPFIND response
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+				ContentLength string `xml:"DAV: getcontentlength"`
+				LastModified  string `xml:"DAV: getlastmodified"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// List lists available backups in the collection
+func (p *Provider) List(ctx context.Context) ([]*core.BackupMetadata, error) {
+	dir := p.prefix()
+
+	req, err := p.newRequest(ctx, "PROPFIND", p.url(dir)+"/", strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	defer drain(resp)
+
+	if resp.StatusCode == http.StatusNotFound {
+		// Nothing has been uploaded yet
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("failed to list backups: %s", resp.Status)
+	}
+
+	var result multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse backup listing: %w", err)
+	}
+
+	var backups []*core.BackupMetadata
+	for _, response := range result.Responses {
+		href, err := url.Parse(response.Href)
+		if err != nil {
+			continue
+		}
+		name := path.Base(href.Path)
+
+		for _, propstat := range response.Propstat {
+			prop := propstat.Prop
+			// Skip collections, manifests and uploads in progress
+			if !strings.Contains(propstat.Status, " 200 ") || prop.ResourceType.Collection != nil || !strings.HasSuffix(name, ".dump") {
+				continue
+			}
+
+			backup := &core.BackupMetadata{ID: name}
+			backup.Size, _ = strconv.ParseInt(prop.ContentLength, 10, 64)
+			backup.Timestamp, _ = http.ParseTime(prop.LastModified)
+			if manifest, err := p.readManifest(ctx, path.Join(dir, name+".meta")); err == nil {
+				core.ParseManifest(backup, manifest)
+			}
+
+			backups = append(backups, backup)
+		}
+	}
+
+	// Sort by timestamp descending (newest first)
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Timestamp.After(backups[j].Timestamp)
+	})
+
+	return backups, nil
+}
+
+// readManifest reads the manifest at name
+func (p *Provider) readManifest(ctx context.Context, name string) (string, error) {
+	body, err := p.get(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, maxManifestSize))
+	return string(data), err
+}
+
+// Download reads a backup from the WebDAV server
+func (p *Provider) Download(ctx context.Context, backupID string) (io.ReadCloser, error) {
+	key, err := p.resolve(backupID)
+	if err != nil {
+		return nil, err
+	}
+	return p.get(ctx, key)
+}
+
+// get downloads the file at name
+func (p *Provider) get(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := p.newRequest(ctx, http.MethodGet, p.url(name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download backup: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		drain(resp)
+		return nil, fmt.Errorf("%w: %s", core.ErrBackupNotFound, path.Base(name))
+	case resp.StatusCode != http.StatusOK:
+		drain(resp)
+		return nil, fmt.Errorf("failed to download backup: %s", resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// Delete removes a backup and its manifest from the WebDAV server
+func (p *Provider) Delete(ctx context.Context, backupID string) error {
+	key, err := p.resolve(backupID)
+	if err != nil {
+		return err
+	}
+
+	status, err := p.remove(ctx, key)
+	if err != nil {
+		return err
+	}
+	switch {
+	case status == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", core.ErrBackupNotFound, backupID)
+	case status < 200 || status > 299:
+		return fmt.Errorf("failed to delete backup: %s", http.StatusText(status))
+	}
+
+	// Try to delete the manifest too (non-fatal if it doesn't exist)
+	p.remove(ctx, key+".meta")
+
+	return nil
+}
+
+// remove deletes the file at name, returning the response status
+func (p *Provider) remove(ctx context.Context, name string) (int, error) {
+	req, err := p.newRequest(ctx, http.MethodDelete, p.url(name), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete backup: %w", err)
+	}
+	drain(resp)
+
+	return resp.StatusCode, nil
+}
+
+// newRequest creates an authenticated request
+func (p *Provider) newRequest(ctx context.Context, method, target string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	switch {
+	case p.config.Token != "":
+		req.Header.Set("Authorization", "Bearer "+p.config.Token)
+	case p.config.Username != "":
+		req.SetBasicAuth(p.config.Username, p.config.Password)
+	}
+
+	return req, nil
+}
+
+// url returns the URL of name, a slash-separated path below the endpoint
+func (p *Provider) url(name string) string {
+	var segments []string
+	for _, segment := range strings.Split(name, "/") {
+		if segment != "" && segment != "." {
+			segments = append(segments, url.PathEscape(segment))
+		}
+	}
+	return p.base.JoinPath(segments...).String()
+}
+
+// prefix returns the collection holding backups, relative to the endpoint
+func (p *Provider) prefix() string {
+	return strings.Trim(path.Clean("/"+p.config.Prefix), "/")
+}
+
+// resolve returns the path of a backup below the endpoint, rejecting names
+// that leave the backup collection
+func (p *Provider) resolve(name string) (string, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid backup key: %s", name)
+	}
+	return path.Join(p.prefix(), clean), nil
+}
+
+// getBackupFilename generates the filename for a backup
+func (p *Provider) getBackupFilename(metadata *core.BackupMetadata) string {
+	if metadata.Key != "" {
+		return metadata.Key
+	}
+	return fmt.Sprintf("%s_%s_%s.dump",
+		metadata.DatabaseName,
+		metadata.DatabaseType,
+		metadata.Timestamp.Format("20060102-150405"),
+	)
+}
+
+// drain reads the rest of a response body and closes it, so the connection
+// can be reused
+func drain(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxManifestSize))
+	resp.Body.Close()
+}
+
+// hashingReader hashes and counts the data read through it, and records
+// the reader's error, which the HTTP client does not return as is
+type hashingReader struct {
+	r    io.Reader
+	hash hash.Hash
+	n    int64
+	err  error
+}
+
+// Read reads from the underlying reader
+func (r *hashingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.hash.Write(p[:n])
+	r.n += int64(n)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
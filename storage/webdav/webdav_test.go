@@ -0,0 +1,367 @@
+package webdav_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	xwebdav "golang.org/x/net/webdav"
+
+	"goarchive/core"
+	"goarchive/storage/webdav"
+)
+
+const (
+	testUser     = "alice"
+	testPassword = "s3cret"
+	testToken    = "app-token"
+
+	// davPath is where the server mounts the user's files, as Nextcloud does
+	davPath = "/remote.php/dav/files/alice"
+)
+
+// startServer runs an in-process WebDAV server on a temporary directory,
+// accepting basic authentication for testUser and bearer testToken
+func startServer(t *testing.T) (endpoint, dir string) {
+	t.Helper()
+
+	dir = t.TempDir()
+	handler := &xwebdav.Handler{
+		Prefix:     davPath,
+		FileSystem: xwebdav.Dir(dir),
+		LockSystem: xwebdav.NewMemLS(),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if r.Header.Get("Authorization") != "Bearer "+testToken && (!ok || user != testUser || password != testPassword) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL + davPath + "/", dir
+}
+
+func newProvider(t *testing.T, config *core.StorageConfig) *webdav.Provider {
+	t.Helper()
+
+	provider, err := webdav.New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return provider
+}
+
+// listFiles returns the files below dir, relative to it
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to list files: %v", err)
+	}
+	return files
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		wantErr  bool
+	}{
+		{name: "missing endpoint", endpoint: "", wantErr: true},
+		{name: "missing scheme", endpoint: "cloud.example.com/remote.php/dav/files/alice", wantErr: true},
+		{name: "unsupported scheme", endpoint: "ftp://cloud.example.com/", wantErr: true},
+		{name: "https", endpoint: "https://cloud.example.com/remote.php/dav/files/alice"},
+		{name: "http", endpoint: "http://localhost:8080/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := webdav.New(&core.StorageConfig{Type: "webdav", Endpoint: tt.endpoint})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_AutoRegistration(t *testing.T) {
+	provider, err := core.GetStorage(context.Background(), "webdav", &core.StorageConfig{
+		Endpoint: "https://cloud.example.com/remote.php/dav/files/alice/",
+	})
+	if err != nil {
+		t.Fatalf("GetStorage() error = %v", err)
+	}
+	if provider == nil {
+		t.Error("expected non-nil provider")
+	}
+}
+
+func TestUploadListDownloadDelete(t *testing.T) {
+	endpoint, dir := startServer(t)
+	provider := newProvider(t, &core.StorageConfig{
+		Endpoint: endpoint,
+		Username: testUser,
+		Password: testPassword,
+		Prefix:   "team/databases/backups/",
+	})
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("backup data "), 100000)
+	metadata := &core.BackupMetadata{
+		ID:           "20260215-103020",
+		DatabaseName: "orders",
+		DatabaseType: "postgres",
+		BackupMode:   "logical",
+		Timestamp:    time.Date(2026, 2, 15, 10, 30, 20, 0, time.UTC),
+		Tags:         map[string]string{"server-version": "16.2"},
+	}
+	if err := provider.Upload(ctx, bytes.NewReader(data), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	sum := md5.Sum(data)
+	if metadata.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("Checksum = %s, want %s", metadata.Checksum, hex.EncodeToString(sum[:]))
+	}
+	if metadata.Size != int64(len(data)) {
+		t.Errorf("Size = %d, want %d", metadata.Size, len(data))
+	}
+
+	// The collections were created, with no temporary files left
+	files := listFiles(t, dir)
+	want := []string{
+		"team/databases/backups/orders_postgres_20260215-103020.dump",
+		"team/databases/backups/orders_postgres_20260215-103020.dump.meta",
+	}
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+
+	// Files below the backups, such as WAL archives, are not listed
+	wal := &core.BackupMetadata{Key: "wal/00000001/000000010000000000000001.gz"}
+	if err := provider.Upload(ctx, strings.NewReader("wal"), wal); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %d", len(backups))
+	}
+
+	backup := backups[0]
+	if backup.ID != "orders_postgres_20260215-103020.dump" {
+		t.Errorf("ID = %s", backup.ID)
+	}
+	if backup.DatabaseName != "orders" || backup.DatabaseType != "postgres" || backup.BackupMode != "logical" {
+		t.Errorf("unexpected metadata: %+v", backup)
+	}
+	if !backup.Timestamp.Equal(metadata.Timestamp) {
+		t.Errorf("Timestamp = %v, want %v", backup.Timestamp, metadata.Timestamp)
+	}
+	if backup.Checksum != metadata.Checksum || backup.Size != metadata.Size {
+		t.Errorf("listed checksum %s and size %d, want %s and %d", backup.Checksum, backup.Size, metadata.Checksum, metadata.Size)
+	}
+	if backup.Tags["server-version"] != "16.2" {
+		t.Errorf("expected tags to round-trip, got %v", backup.Tags)
+	}
+
+	reader, err := provider.Download(ctx, backup.ID)
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	downloaded, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatalf("failed to read download: %v", err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Error("downloaded data differs from the upload")
+	}
+
+	if err := provider.Delete(ctx, backup.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	files = listFiles(t, dir)
+	if len(files) != 2 || !strings.Contains(files[0], "/wal/") || !strings.Contains(files[1], "/wal/") {
+		t.Errorf("expected the backup and its manifest to be deleted, got %v", files)
+	}
+	if err := provider.Delete(ctx, backup.ID); err == nil {
+		t.Error("expected error deleting a missing backup, got nil")
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	endpoint, _ := startServer(t)
+
+	tests := []struct {
+		name    string
+		config  *core.StorageConfig
+		wantErr bool
+	}{
+		{name: "basic", config: &core.StorageConfig{Endpoint: endpoint, Username: testUser, Password: testPassword}},
+		{name: "bearer", config: &core.StorageConfig{Endpoint: endpoint, Token: testToken}},
+		{name: "wrong password", config: &core.StorageConfig{Endpoint: endpoint, Username: testUser, Password: "wrong"}, wantErr: true},
+		{name: "wrong token", config: &core.StorageConfig{Endpoint: endpoint, Token: "wrong"}, wantErr: true},
+		{name: "anonymous", config: &core.StorageConfig{Endpoint: endpoint}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newProvider(t, tt.config)
+			metadata := &core.BackupMetadata{DatabaseName: "orders", DatabaseType: "postgres", Timestamp: time.Now()}
+			err := provider.Upload(context.Background(), strings.NewReader("data"), metadata)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Upload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpload_SpecialCharacters(t *testing.T) {
+	endpoint, dir := startServer(t)
+	provider := newProvider(t, &core.StorageConfig{Endpoint: endpoint, Token: testToken, Prefix: "backups & more/"})
+	ctx := context.Background()
+
+	metadata := &core.BackupMetadata{DatabaseName: "sales #1 100%", DatabaseType: "mysql", Timestamp: time.Now()}
+	if err := provider.Upload(ctx, strings.NewReader("data"), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 || !strings.HasPrefix(backups[0].ID, "sales #1 100%_mysql_") {
+		t.Fatalf("unexpected backups: %+v", backups)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "backups & more", backups[0].ID)); err != nil {
+		t.Errorf("expected the backup on disk: %v", err)
+	}
+
+	reader, err := provider.Download(ctx, backups[0].ID)
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	reader.Close()
+}
+
+func TestUpload_ReplacesExistingBackup(t *testing.T) {
+	endpoint, dir := startServer(t)
+	provider := newProvider(t, &core.StorageConfig{Endpoint: endpoint, Token: testToken})
+	ctx := context.Background()
+
+	for _, content := range []string{"first", "second"} {
+		metadata := &core.BackupMetadata{Key: "latest.dump", BackupMode: content}
+		if err := provider.Upload(ctx, strings.NewReader(content), metadata); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "latest.dump"))
+	if err != nil {
+		t.Fatalf("failed to read backup: %v", err)
+	}
+	if string(data) != "second" {
+		t.Errorf("backup = %q, want %q", data, "second")
+	}
+
+	backups, err := provider.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 1 || backups[0].BackupMode != "second" {
+		t.Errorf("expected the manifest of the second upload, got %+v", backups)
+	}
+}
+
+// failingReader returns some data and then an error
+type failingReader struct {
+	sent bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if !r.sent {
+		r.sent = true
+		return copy(p, "partial backup"), nil
+	}
+	return 0, errors.New("database connection lost")
+}
+
+func TestUpload_FailedBackupLeavesNoFile(t *testing.T) {
+	endpoint, dir := startServer(t)
+	provider := newProvider(t, &core.StorageConfig{Endpoint: endpoint, Token: testToken, Prefix: "backups/"})
+
+	metadata := &core.BackupMetadata{DatabaseName: "orders", DatabaseType: "postgres", Timestamp: time.Now()}
+	err := provider.Upload(context.Background(), &failingReader{}, metadata)
+	if err == nil || !strings.Contains(err.Error(), "database connection lost") {
+		t.Fatalf("expected the read error, got %v", err)
+	}
+
+	if files := listFiles(t, dir); len(files) != 0 {
+		t.Errorf("expected no files after a failed upload, got %v", files)
+	}
+}
+
+func TestList_MissingCollection(t *testing.T) {
+	endpoint, _ := startServer(t)
+	provider := newProvider(t, &core.StorageConfig{Endpoint: endpoint, Token: testToken, Prefix: "not-yet-created/"})
+
+	backups, err := provider.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("expected no backups, got %d", len(backups))
+	}
+}
+
+func TestInvalidBackupID(t *testing.T) {
+	endpoint, _ := startServer(t)
+	provider := newProvider(t, &core.StorageConfig{Endpoint: endpoint, Token: testToken, Prefix: "backups/"})
+	ctx := context.Background()
+
+	for _, id := range []string{"../outside.dump", "/etc/passwd", ".."} {
+		if _, err := provider.Download(ctx, id); err == nil {
+			t.Errorf("Download(%q) expected error, got nil", id)
+		}
+		if err := provider.Delete(ctx, id); err == nil {
+			t.Errorf("Delete(%q) expected error, got nil", id)
+		}
+	}
+}
+
+func TestDownload_Missing(t *testing.T) {
+	endpoint, _ := startServer(t)
+	provider := newProvider(t, &core.StorageConfig{Endpoint: endpoint, Token: testToken})
+
+	_, err := provider.Download(context.Background(), "missing.dump")
+	if err == nil || !strings.Contains(err.Error(), "backup not found") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}